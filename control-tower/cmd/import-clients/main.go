@@ -0,0 +1,64 @@
+// import-clients creates the clients of a CSV or Booksy export for a creator.
+//
+//	go run ./control-tower/cmd/import-clients --creator owner@test.com --path ./customers.json
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/japb1998/control-tower/internal/service"
+	"github.com/japb1998/control-tower/internal/storage"
+	"github.com/japb1998/control-tower/pkg/awssess"
+)
+
+func main() {
+	creator := flag.String("creator", "", "email of the clients owner")
+	path := flag.String("path", "", "path of the export file")
+	format := flag.String("format", "", "csv or booksy, detected from the file extension when not provided")
+	flag.Parse()
+
+	if *creator == "" || *path == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *format == "" {
+		*format = service.CSVFormat
+		if strings.EqualFold(filepath.Ext(*path), ".json") {
+			*format = service.BooksyFormat
+		}
+	}
+
+	f, err := os.Open(*path)
+
+	if err != nil {
+		log.Fatalf("unable to open file='%s' error: %s", *path, err)
+	}
+	defer f.Close()
+
+	rows, err := service.ParseImport(*format, f)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	repos, err := storage.FromEnv(awssess.MustGetSession())
+
+	if err != nil {
+		log.Fatalf("error initializing storage error: %s", err)
+	}
+	report, err := service.NewClientSvc(repos.Clients).ImportClients(context.Background(), *creator, rows)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+}
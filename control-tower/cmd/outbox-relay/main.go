@@ -0,0 +1,74 @@
+// outbox-relay applies the schedule operations that notification writes left in the outbox.
+// it runs as a scheduled lambda or once from the command line.
+//
+//	go run ./control-tower/cmd/outbox-relay
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/japb1998/control-tower/internal/scheduler"
+	"github.com/japb1998/control-tower/internal/service"
+	"github.com/japb1998/control-tower/internal/storage"
+	"github.com/japb1998/control-tower/pkg/awssess"
+)
+
+var logHandler = slog.NewTextHandler(os.Stdout, nil)
+var relayLogger = slog.New(logHandler).With(slog.String("name", "outbox-relay"))
+
+func main() {
+	notificationSvc, err := newNotificationService()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if os.Getenv("AWS_LAMBDA_RUNTIME_API") != "" {
+		lambda.Start(func(ctx context.Context) (*service.RelayReport, error) {
+			report, err := notificationSvc.RelayOutbox(ctx)
+
+			if err != nil {
+				relayLogger.Error("error relaying outbox", slog.String("error", err.Error()))
+				return nil, err
+			}
+			return report, nil
+		})
+		return
+	}
+
+	report, err := notificationSvc.RelayOutbox(context.Background())
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+}
+
+// newNotificationService uses the local scheduler when SCHEDULER is local, like the api.
+func newNotificationService() (*service.NotificationService, error) {
+	sess := awssess.MustGetSession()
+	repos, err := storage.FromEnv(sess)
+
+	if err != nil {
+		return nil, err
+	}
+	var sch scheduler.Scheduler = scheduler.NewScheduler(sess)
+
+	if os.Getenv("SCHEDULER") == "local" {
+		local, err := scheduler.NewLocalScheduler(os.Getenv("LOCAL_SCHEDULER_FILE"))
+
+		if err != nil {
+			return nil, err
+		}
+		sch = local
+	}
+	return service.NewNotificationService(repos.Notifications, sch, repos.Settings).WithClients(repos.Clients), nil
+}
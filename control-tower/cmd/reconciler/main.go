@@ -0,0 +1,84 @@
+// reconciler compares the eventbridge schedules with the pending notifications of every creator and reports the differences.
+// it runs as a scheduled lambda or from the command line, differences are only repaired with apply.
+//
+//	go run ./control-tower/cmd/reconciler --apply
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/japb1998/control-tower/internal/scheduler"
+	"github.com/japb1998/control-tower/internal/service"
+	"github.com/japb1998/control-tower/internal/storage"
+	"github.com/japb1998/control-tower/pkg/awssess"
+)
+
+var logHandler = slog.NewTextHandler(os.Stdout, nil)
+var reconcilerLogger = slog.New(logHandler).With(slog.String("name", "reconciler"))
+
+func main() {
+	apply := flag.Bool("apply", false, "repair the differences, they are only reported when not set")
+	flag.Parse()
+
+	notificationSvc, err := newNotificationService()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// the lambda reads the mode from RECONCILE_APPLY since scheduled events have no input.
+	if os.Getenv("AWS_LAMBDA_RUNTIME_API") != "" {
+		applyEnv, _ := strconv.ParseBool(os.Getenv("RECONCILE_APPLY"))
+		lambda.Start(func(ctx context.Context) (*service.ReconcileReport, error) {
+			report, err := notificationSvc.Reconcile(ctx, applyEnv)
+
+			if err != nil {
+				reconcilerLogger.Error("error reconciling schedules", slog.String("error", err.Error()))
+				return nil, err
+			}
+			for _, issue := range report.Issues {
+				reconcilerLogger.Info("schedule issue", slog.String("kind", issue.Kind), slog.String("createdBy", issue.CreatedBy), slog.String("notificationId", issue.NotificationId), slog.String("detail", issue.Detail), slog.String("repair", issue.Repair), slog.Bool("repaired", issue.Repaired), slog.String("error", issue.Error))
+			}
+			return report, nil
+		})
+		return
+	}
+
+	report, err := notificationSvc.Reconcile(context.Background(), *apply)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+}
+
+// newNotificationService uses the local scheduler when SCHEDULER is local, like the api.
+func newNotificationService() (*service.NotificationService, error) {
+	sess := awssess.MustGetSession()
+	repos, err := storage.FromEnv(sess)
+
+	if err != nil {
+		return nil, err
+	}
+	var sch scheduler.Scheduler = scheduler.NewScheduler(sess)
+
+	if os.Getenv("SCHEDULER") == "local" {
+		local, err := scheduler.NewLocalScheduler(os.Getenv("LOCAL_SCHEDULER_FILE"))
+
+		if err != nil {
+			return nil, err
+		}
+		sch = local
+	}
+	return service.NewNotificationService(repos.Notifications, sch, repos.Settings).WithClients(repos.Clients), nil
+}
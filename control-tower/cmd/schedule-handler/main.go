@@ -7,7 +7,6 @@ import (
 	"os"
 
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/japb1998/control-tower/pkg/sms"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-lambda-go/otellambda"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-lambda-go/otellambda/xrayconfig"
 	"go.opentelemetry.io/contrib/propagators/aws/xray"
@@ -19,7 +18,6 @@ import (
 var logHandler = slog.NewTextHandler(os.Stdout, nil)
 var handlerLogger = slog.New(logHandler).With(slog.String("name", "scheduler-handler"))
 var tracer trace.Tracer
-var msgSvc *sms.MsgSvc
 var apiUrl = os.Getenv("API_URL")
 
 func main() {
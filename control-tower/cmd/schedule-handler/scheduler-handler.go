@@ -1,15 +1,14 @@
 package main
 
 import (
-	"encoding/json"
 	"log"
 	"log/slog"
 	"os"
 
 	"github.com/japb1998/control-tower/internal/apigateway"
-	"github.com/japb1998/control-tower/internal/database"
 	"github.com/japb1998/control-tower/internal/scheduler"
 	"github.com/japb1998/control-tower/internal/service"
+	"github.com/japb1998/control-tower/internal/storage"
 	"github.com/japb1998/control-tower/pkg/awssess"
 	"github.com/japb1998/control-tower/pkg/credentials"
 	"github.com/japb1998/control-tower/pkg/email"
@@ -17,6 +16,8 @@ import (
 	"github.com/joho/godotenv"
 )
 
+var deliverySvc *service.DeliveryService
+
 func init() {
 	// if local load from .env file
 	switch os.Getenv("STAGE") {
@@ -29,40 +30,57 @@ func init() {
 
 	sess := awssess.MustGetSession()
 
-	var ops email.EmailSvcOpts
-	secretArn := os.Getenv("MAIL_GUN_SECRET_ID")
-	cm := credentials.NewCredentialsManager(sess)
-
-	b, err := cm.GetSecret(secretArn)
+	repos, err := storage.FromEnv(sess)
 
 	if err != nil {
-		handlerLogger.Error("error getting secret", slog.String("arn", secretArn), slog.String("error", err.Error()))
+		handlerLogger.Error("error initializing storage", slog.String("error", err.Error()))
 		panic("error initializing handler")
 	}
 
-	err = json.Unmarshal([]byte(*b), &ops)
+	secretArn := os.Getenv("MAIL_GUN_SECRET_ID")
+	cm := credentials.NewCredentialsManager(sess)
+
+	ops, err := email.LoadOpts(cm, secretArn)
 
 	if err != nil {
-		handlerLogger.Error("error unmarshalling secret", slog.String("error", err.Error()))
+		handlerLogger.Error("error getting mailgun options", slog.String("arn", secretArn), slog.String("error", err.Error()))
 		panic("error initializing handler")
 	}
 	// email
-	emailSvc = email.NewEmailService(&ops)
+	emailSvc := email.NewEmailService(ops)
 	scheduler := scheduler.NewScheduler(sess)
 
 	// message service
-	msgSvc = sms.MusInitMsgSvc(os.Getenv("TWILIO_SERVICE_ID"))
+	msgSvc := sms.MusInitMsgSvc(os.Getenv("TWILIO_SERVICE_ID")).WithStatusCallback(apiUrl + "/webhooks/twilio/status")
 
 	// notification
-	notificationStore := database.NewNotificationRepository(sess)
-	notificationSvc = service.NewNotificationService(notificationStore, scheduler)
+	notificationSvc := service.NewNotificationService(repos.Notifications, scheduler, repos.Settings).WithClients(repos.Clients)
 
 	// client
-	clientStore := database.NewClientRepo(sess)
-	clientSvc = service.NewClientSvc(clientStore)
+	clientSvc := service.NewClientSvc(repos.Clients)
 
 	// connection
-	connRepo := database.NewConnectionRepo(sess)
 	apigw := apigateway.NewApiGatewayClient(sess, os.Getenv("WS_HTTPS_URL"))
-	connectionSvc = service.NewConnectionSvc(connRepo, apigw)
+	connectionSvc := service.NewConnectionSvc(repos.Connections, apigw)
+
+	// channels
+	notifiers := service.NewNotifierRegistry(
+		service.NewEmailNotifier(emailSvc, apiUrl).WithReplyTo(os.Getenv("MAILGUN_REPLY_TO")),
+		service.NewWhatsappNotifier(msgSvc, os.Getenv("TWILIO_TEMPLATE_ID")).WithLocalizedTemplate(service.Spanish, os.Getenv("TWILIO_TEMPLATE_ID_ES")),
+		service.NewSMSNotifier(msgSvc),
+	).
+		WithRetryPolicy(service.Email, service.NewRetryPolicy(email.IsRetryable)).
+		WithRetryPolicy(service.Phone, service.NewRetryPolicy(sms.IsRetryable)).
+		WithRetryPolicy(service.SMS, service.NewRetryPolicy(sms.IsRetryable))
+
+	// messages
+	messageSvc := service.NewMessageService(repos.Messages, clientSvc, notifiers, connectionSvc)
+
+	// templates
+	templateSvc := service.NewTemplateService(repos.Templates, clientSvc, repos.Settings, apiUrl)
+
+	// delivery
+	deliverySvc = service.NewDeliveryService(notificationSvc, clientSvc, connectionSvc, repos.Settings, notifiers).
+		WithMessages(messageSvc).
+		WithTemplates(templateSvc)
 }
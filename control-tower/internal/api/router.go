@@ -82,6 +82,14 @@ func InitRoutes() *gin.Engine {
 	{
 		unsubscribe.GET("/:creator/:userID", controller.OptOut)
 	}
+	// provider webhooks, they are verified with the provider signature.
+	webhooks := r.Group("/webhooks")
+	{
+		webhooks.POST("/mailgun", controller.MailgunWebhook)
+		webhooks.POST("/mailgun/inbound", controller.MailgunInboundWebhook)
+		webhooks.POST("/twilio/status", controller.TwilioStatusWebhook)
+		webhooks.POST("/twilio/inbound", controller.TwilioInboundWebhook)
+	}
 	r.Use(currentUserMiddleWare())
 
 	// NOTIFICATIONS ROUTER
@@ -89,19 +97,51 @@ func InitRoutes() *gin.Engine {
 	{
 		schedule.GET("", controller.GetSchedules)
 		schedule.POST("", controller.PostSchedule)
+		schedule.POST("/bulk", controller.PostBulkSchedule)
 		schedule.GET("/:id", controller.GetSchedule)
 		schedule.PATCH("/:id", controller.UpdateSchedule)
 		schedule.DELETE("/:id", controller.DeleteSchedule)
+		schedule.POST("/:id/retry", controller.RetrySchedule)
+		schedule.POST("/:id/send", controller.SendSchedule)
+		schedule.POST("/:id/resend", controller.ResendSchedule)
 	}
 
 	//CLIENT ROUTER
 	clients := r.Group("/clients")
 	{
 		clients.GET("", controller.ClientsWithFilters)
+		clients.GET("/export", controller.ExportClients)
+		clients.GET("/duplicates", controller.ClientDuplicates)
+		clients.GET("/archived", controller.ArchivedClients)
 		clients.POST("", controller.CreateClient)
+		clients.POST("/import", controller.ImportClients)
 		clients.PATCH("/:id", controller.UpdateClient)
 		clients.GET("/:id", controller.GetClientByID)
 		clients.DELETE("/:id", controller.DeleteClient)
+		clients.POST("/:id/merge", controller.MergeClients)
+		clients.POST("/:id/restore", controller.RestoreClient)
+		clients.GET("/:id/messages", controller.GetClientMessages)
+		clients.POST("/:id/messages", controller.ReplyToClient)
+	}
+
+	// SETTINGS ROUTER
+	settings := r.Group("/settings")
+	{
+		settings.GET("", controller.GetSettings)
+		settings.PUT("", controller.PutSettings)
+	}
+
+	// TEMPLATES ROUTER
+	templates := r.Group("/templates")
+	{
+		templates.GET("", controller.GetTemplates)
+		templates.POST("", controller.CreateTemplate)
+		templates.GET("/variables", controller.GetTemplateVariables)
+		templates.POST("/preview", controller.PreviewDraftTemplate)
+		templates.GET("/:id", controller.GetTemplate)
+		templates.PUT("/:id", controller.UpdateTemplate)
+		templates.DELETE("/:id", controller.DeleteTemplate)
+		templates.POST("/:id/preview", controller.PreviewTemplate)
 	}
 
 	return r
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
@@ -80,6 +81,10 @@ func CreateClient(c *gin.Context) {
 
 	if client, err := clientService.CreateClient(c.Request.Context(), userEmail, clientDto); err != nil {
 		clientLogger.Error("Error creating user", slog.String("error", err.Error()))
+		if errors.Is(err, service.ErrInvalidTimeZone) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error creating user"})
 		return
 	} else {
@@ -129,6 +134,14 @@ func UpdateClient(c *gin.Context) {
 	if client, err := clientService.UpdateUser(c.Request.Context(), userEmail, clientId, clientDto); err != nil {
 
 		clientLogger.Error("Error updating user", slog.String("error", err.Error()))
+		if errors.Is(err, service.ErrClientNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, service.ErrInvalidTimeZone) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 	} else {
 		c.JSON(http.StatusOK, client)
@@ -152,12 +165,13 @@ func GetClientByID(c *gin.Context) {
 	client, err := clientService.GetClientById(c.Request.Context(), userEmail, clientId)
 
 	if err != nil {
+		if errors.Is(err, service.ErrClientNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
 		clientLogger.Error("Error Getting client by ID", slog.String("error", err.Error()))
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error getting user"})
 		return
-	} else if client == nil {
-		c.AbortWithStatus(http.StatusNotFound)
-		return
 	}
 
 	c.JSON(http.StatusOK, client)
@@ -167,17 +181,32 @@ func GetClientByID(c *gin.Context) {
 // @Tags CLIENT
 // @Summary delete client by ID.
 // @Schemes
-// @Description delete client by ID.
+// @Description delete client by ID and every notification of the client. with archive=true the client is hidden and its pending notifications are cancelled until it is restored.
 // @Param Authorization header string true "Bearer token"
 // @Param id path string true "Client ID"
+// @Param archive query bool false "archive the client instead of deleting it"
 // @Produce json
-// @Success 200 {object} service.ClientDto
+// @Success 204
 // @Router /clients/{id} [delete]
 func DeleteClient(c *gin.Context) {
 	userEmail := c.MustGet("email").(string)
 	clientId, _ := c.Params.Get("id")
 
-	err := clientService.DeleteClient(userEmail, clientId)
+	if c.Query("archive") == "true" {
+		if err := clientService.ArchiveClient(c.Request.Context(), userEmail, clientId); err != nil {
+			clientLogger.Error("Error archiving client", slog.String("error", err.Error()))
+			if errors.Is(err, service.ErrClientNotFound) {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error archiving user"})
+			return
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+		return
+	}
+
+	err := clientService.DeleteClient(c.Request.Context(), userEmail, clientId)
 
 	if err != nil {
 		clientLogger.Error("Error deleting client", slog.String("error", err.Error()))
@@ -188,6 +217,59 @@ func DeleteClient(c *gin.Context) {
 	c.AbortWithStatus(http.StatusNoContent)
 }
 
+// ArchivedClients get archived clients.
+// @Tags CLIENT
+// @Summary get archived clients.
+// @Schemes
+// @Description get the archived clients of the creator.
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {array} service.ClientDto
+// @Router /clients/archived [get]
+func ArchivedClients(c *gin.Context) {
+	userEmail := c.MustGet("email").(string)
+
+	clients, err := clientService.GetArchivedClients(c.Request.Context(), userEmail)
+
+	if err != nil {
+		clientLogger.Error("Error getting archived clients", slog.String("error", err.Error()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error getting archived clients"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"records": clients,
+		"count":   len(clients),
+	})
+}
+
+// RestoreClient restore archived client.
+// @Tags CLIENT
+// @Summary restore archived client.
+// @Schemes
+// @Description restore an archived client. cancelled notifications are not rescheduled, the next maintenance reminder is.
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Client ID"
+// @Produce json
+// @Success 200 {object} service.ClientDto
+// @Router /clients/{id}/restore [post]
+func RestoreClient(c *gin.Context) {
+	userEmail := c.MustGet("email").(string)
+	clientId := c.Param("id")
+
+	client, err := clientService.RestoreClient(c.Request.Context(), userEmail, clientId)
+
+	if err != nil {
+		clientLogger.Error("Error restoring client", slog.String("error", err.Error()))
+		if errors.Is(err, service.ErrClientNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error restoring user"})
+		return
+	}
+	c.JSON(http.StatusOK, client)
+}
+
 // DeleteClient mark client as not available for notifications.
 // @Tags CLIENT
 // @Summary mark client as not available for notifications.
@@ -283,3 +365,235 @@ func ClientsWithFilters(c *gin.Context) {
 		return
 	}
 }
+
+// ClientDuplicates get duplicated clients.
+// @Tags CLIENT
+// @Summary get groups of clients that are likely the same person.
+// @Schemes
+// @Description group clients sharing a phone, an email or a similar name.
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {array} service.DuplicateGroup
+// @Router /clients/duplicates [get]
+func ClientDuplicates(c *gin.Context) {
+	userEmail := c.MustGet("email").(string)
+
+	groups, err := clientService.FindDuplicates(c.Request.Context(), userEmail)
+
+	if err != nil {
+		clientLogger.Error("Error finding duplicates", slog.String("error", err.Error()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error finding duplicates"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"records": groups,
+		"count":   len(groups),
+	})
+}
+
+// MergeClients merge clients.
+// @Tags CLIENT
+// @Summary merge duplicated clients into the client.
+// @Schemes
+// @Description fill the client empty fields from the duplicates, move their notifications to the client and delete them.
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Client ID"
+// @Param request body service.MergeClients true "merge clients dto"
+// @Accept json
+// @Produce json
+// @Success 200 {object} service.ClientDto
+// @Failure 500 {object} service.MergeError "the merge stopped while deleting the duplicates"
+// @Router /clients/{id}/merge [post]
+func MergeClients(c *gin.Context) {
+	userEmail := c.MustGet("email").(string)
+	clientId := c.Param("id")
+	var mergeDto service.MergeClients
+
+	if err := c.ShouldBindJSON(&mergeDto); err != nil {
+		clientLogger.Error("MergeClients validation error", slog.String("error", err.Error()))
+		var ve validator.ValidationErrors
+
+		if errors.As(err, &ve) {
+			output := make([]ErrMsg, len(ve))
+			for i, fe := range ve {
+				output[i] = ErrMsg{
+					Message: getErrorMsg(fe),
+					Field:   fe.Field(),
+				}
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"errors": output,
+			})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	client, err := clientService.MergeClients(c.Request.Context(), userEmail, clientId, mergeDto)
+
+	if err != nil {
+		clientLogger.Error("Error merging clients", slog.String("error", err.Error()))
+		var mergeErr *service.MergeError
+		switch {
+		case errors.As(err, &mergeErr):
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "merge stopped part way, retry it with the remaining duplicates", "merged": mergeErr.Merged, "remaining": mergeErr.Remaining})
+		case errors.Is(err, service.ErrClientNotFound):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrInvalidMerge):
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error merging clients"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, client)
+}
+
+// ExportClients export clients.
+// @Tags CLIENT
+// @Summary export the clients matching the filters.
+// @Schemes
+// @Description stream every client matching the filters as CSV or NDJSON.
+// @Param Authorization header string true "Bearer token"
+// @Param format query string false "csv or ndjson" default(csv)
+// @Param phone query string false "Phone number to filter by"
+// @Param email query string false "email to filter by"
+// @Param firstName query string false "First Name to filter by"
+// @Param lastName query string false "Last Name to filter by"
+// @Param lastSeen query string false "last seen date to filter by"
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Success 200
+// @Router /clients/export [get]
+func ExportClients(c *gin.Context) {
+	requestorEmail := c.MustGet("email").(string)
+	var filters service.PatchClient
+
+	if err := c.ShouldBindWith(&filters, binding.Query); err != nil {
+		clientLogger.Error("Error on filters validation", slog.String("error", err.Error()))
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			out := make([]ErrMsg, len(ve))
+
+			for i, fe := range ve {
+				out[i] = ErrMsg{
+					Message: getErrorMsg(fe),
+					Field:   fe.Field(),
+				}
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"errors": out,
+			})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to validate query parameters",
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", service.CSVFormat)
+	var contentType string
+
+	switch format {
+	case service.CSVFormat:
+		contentType = "text/csv"
+	case service.NDJSONFormat:
+		contentType = "application/x-ndjson"
+	default:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("format='%s' is not supported", format),
+		})
+		return
+	}
+	w := &exportWriter{c: c, contentType: contentType, filename: "clients." + format}
+
+	if err := clientService.ExportClients(c.Request.Context(), requestorEmail, filters, format, w); err != nil {
+		clientLogger.Error("Error exporting clients", slog.String("error", err.Error()))
+
+		// once the export started the status can't be changed, the client gets a truncated file.
+		if !w.started {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "error exporting clients",
+			})
+		}
+		return
+	}
+}
+
+// exportWriter sets the download headers with the first write, errors before the export starts are sent as json.
+type exportWriter struct {
+	c           *gin.Context
+	contentType string
+	filename    string
+	started     bool
+}
+
+func (w *exportWriter) Write(p []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		w.c.Header("Content-Type", w.contentType)
+		w.c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", w.filename))
+	}
+	return w.c.Writer.Write(p)
+}
+
+// ImportClients import clients.
+// @Tags CLIENT
+// @Summary import clients from a CSV or Booksy export.
+// @Schemes
+// @Description import clients from a CSV or Booksy customers.json export. rows matching an existing client phone or email are skipped.
+// @Param Authorization header string true "Bearer token"
+// @Param format query string false "csv or booksy, detected from the file when not provided"
+// @Param file formData file false "export file, the request body is used when not provided"
+// @Accept mpfd
+// @Produce json
+// @Success 200 {object} service.ImportReport
+// @Router /clients/import [post]
+func ImportClients(c *gin.Context) {
+	userEmail := c.MustGet("email").(string)
+	format := c.Query("format")
+	body := c.Request.Body
+	name := ""
+
+	if file, header, err := c.Request.FormFile("file"); err == nil {
+		defer file.Close()
+		body = file
+		name = header.Filename
+	}
+	if format == "" {
+		format = importFormat(name, c.ContentType())
+	}
+
+	rows, err := service.ParseImport(format, body)
+
+	if err != nil {
+		clientLogger.Error("ImportClients parse error", slog.String("error", err.Error()))
+		if errors.Is(err, service.ErrInvalidImport) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error importing clients"})
+		return
+	}
+
+	report, err := clientService.ImportClients(c.Request.Context(), userEmail, rows)
+
+	if err != nil {
+		clientLogger.Error("Error importing clients", slog.String("error", err.Error()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error importing clients"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// importFormat detects the format of the import from the file name or the content type. csv is the default.
+func importFormat(filename, contentType string) string {
+	if strings.HasSuffix(strings.ToLower(filename), ".json") || contentType == binding.MIMEJSON {
+		return service.BooksyFormat
+	}
+	return service.CSVFormat
+}
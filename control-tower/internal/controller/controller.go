@@ -1,15 +1,21 @@
 package controller
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/japb1998/control-tower/internal/apigateway"
-	"github.com/japb1998/control-tower/internal/database"
 	"github.com/japb1998/control-tower/internal/scheduler"
 	"github.com/japb1998/control-tower/internal/service"
+	"github.com/japb1998/control-tower/internal/storage"
 	"github.com/japb1998/control-tower/pkg/awssess"
+	"github.com/japb1998/control-tower/pkg/credentials"
+	"github.com/japb1998/control-tower/pkg/email"
+	"github.com/japb1998/control-tower/pkg/sms"
 	"github.com/joho/godotenv"
 	"go.opentelemetry.io/otel"
 )
@@ -30,19 +36,82 @@ func init() {
 	// aws session
 	sess := awssess.MustGetSession()
 
+	// repositories
+	repos, err := storage.FromEnv(sess)
+	if err != nil {
+		log.Fatalf("Error initializing storage: %s", err)
+	}
+
+	// settings service
+	settingsStore := repos.Settings
+	settingsService = service.NewSettingsService(settingsStore)
+
 	// scheduler service
-	scheduler := scheduler.NewScheduler(sess)
-	notificationStore := database.NewNotificationRepository(sess)
-	notificationService = service.NewNotificationService(notificationStore, scheduler)
-	//client service
-	clientStore := database.NewClientRepo(sess)
-	clientService = service.NewClientSvc(clientStore)
-	notificationLogger.Info("Controllers Initialized")
+	var sch scheduler.Scheduler = scheduler.NewScheduler(sess)
+	var startLocalScheduler func(ctx context.Context, invoke scheduler.Invoker)
+	if os.Getenv("SCHEDULER") == "local" {
+		local, err := scheduler.NewLocalScheduler(os.Getenv("LOCAL_SCHEDULER_FILE"))
+		if err != nil {
+			log.Fatalf("Error loading local scheduler: %s", err)
+		}
+		startLocalScheduler = local.Start
+		sch = local
+	}
+	notificationStore := repos.Notifications
+	notificationService = service.NewNotificationService(notificationStore, sch, settingsStore).WithTemplates(repos.Templates).WithClients(repos.Clients)
 
 	// ws service
 	apigw := apigateway.NewApiGatewayClient(sess, os.Getenv("WS_HTTPS_URL"))
-	connStore := database.NewConnectionRepo(sess)
+	connStore := repos.Connections
 	connectionSvc = service.NewConnectionSvc(connStore, apigw)
+
+	//client service
+	clientStore := repos.Clients
+	clientService = service.NewClientSvc(clientStore).WithNotifications(notificationService).WithBroadcaster(connectionSvc)
+	notificationLogger.Info("Controllers Initialized")
+
+	// delivery service, used by the send now actions.
+	apiUrl = os.Getenv("API_URL")
+	twilioAuthToken = os.Getenv("TWILIO_AUTH_TOKEN")
+	notifiers := newNotifiers(sess)
+	templateService = service.NewTemplateService(repos.Templates, clientService, settingsStore, apiUrl)
+	messageService = service.NewMessageService(repos.Messages, clientService, notifiers, connectionSvc)
+	deliveryService = service.NewDeliveryService(notificationService, clientService, connectionSvc, settingsStore, notifiers).
+		WithMessages(messageService).
+		WithTemplates(templateService)
+
+	// the local scheduler delivers the notifications in process instead of invoking the schedule handler lambda.
+	if startLocalScheduler != nil {
+		startLocalScheduler(context.Background(), deliveryService.Invoke)
+		notificationLogger.Info("Local scheduler started")
+	}
 	// initialize tracer
 	tracer = otel.Tracer("github.com/japb1998/control-tower/internal/controller")
 }
+
+// newNotifiers registers the channels that are configured. the api starts without mailgun or twilio,
+// sends through a channel that is not registered fail and its webhooks are rejected.
+func newNotifiers(sess *session.Session) *service.NotifierRegistry {
+	notifiers := service.NewNotifierRegistry()
+
+	ops, err := email.LoadOpts(credentials.NewCredentialsManager(sess), os.Getenv("MAIL_GUN_SECRET_ID"))
+	if err != nil {
+		notificationLogger.Error("email channel disabled, unable to load email options", slog.String("error", err.Error()))
+	} else {
+		mailgunSigningKey = ops.WebhookSigningKey
+		notifiers.Register(service.NewEmailNotifier(email.NewEmailService(ops), apiUrl).WithReplyTo(os.Getenv("MAILGUN_REPLY_TO")))
+		notifiers.WithRetryPolicy(service.Email, service.NewRetryPolicy(email.IsRetryable))
+	}
+
+	msgSvc, err := sms.NewMsgSvc(os.Getenv("TWILIO_SERVICE_ID"))
+	if err != nil {
+		notificationLogger.Error("whatsapp and sms channels disabled, unable to setup twilio", slog.String("error", err.Error()))
+		return notifiers
+	}
+	msgSvc = msgSvc.WithStatusCallback(apiUrl + "/webhooks/twilio/status")
+	notifiers.Register(service.NewWhatsappNotifier(msgSvc, os.Getenv("TWILIO_TEMPLATE_ID")).WithLocalizedTemplate(service.Spanish, os.Getenv("TWILIO_TEMPLATE_ID_ES")))
+	notifiers.Register(service.NewSMSNotifier(msgSvc))
+	notifiers.WithRetryPolicy(service.Phone, service.NewRetryPolicy(sms.IsRetryable)).
+		WithRetryPolicy(service.SMS, service.NewRetryPolicy(sms.IsRetryable))
+	return notifiers
+}
@@ -24,6 +24,8 @@ func getErrorMsg(fe validator.FieldError) string {
 		return "should meet e164 format"
 	case "rfc3339":
 		return "field should be date" + fe.Param()
+	case "timezone":
+		return "should be an IANA time zone like America/New_York"
 	}
 
 	return "Unknown error"
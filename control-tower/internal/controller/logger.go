@@ -9,10 +9,16 @@ import (
 var (
 	clientHandler       = slog.NewTextHandler(os.Stdout, nil).WithAttrs([]slog.Attr{slog.String("name", "clientController")})
 	notificationHandler = slog.NewTextHandler(os.Stdout, nil).WithAttrs([]slog.Attr{slog.String("name", "notificationController")})
+	settingsHandler     = slog.NewTextHandler(os.Stdout, nil).WithAttrs([]slog.Attr{slog.String("name", "settingsController")})
+	messageHandler      = slog.NewTextHandler(os.Stdout, nil).WithAttrs([]slog.Attr{slog.String("name", "messageController")})
+	templateHandler     = slog.NewTextHandler(os.Stdout, nil).WithAttrs([]slog.Attr{slog.String("name", "templateController")})
 )
 
 // loggers
 var (
 	clientLogger       = slog.New(clientHandler)
 	notificationLogger = slog.New(notificationHandler)
+	settingsLogger     = slog.New(settingsHandler)
+	messageLogger      = slog.New(messageHandler)
+	templateLogger     = slog.New(templateHandler)
 )
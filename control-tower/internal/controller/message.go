@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/japb1998/control-tower/internal/service"
+)
+
+var messageService *service.MessageService
+
+// GetClientMessages get the conversation with the client.
+// @Tags CLIENT
+// @Summary get the conversation with the client.
+// @Schemes
+// @Description reminders, replies and client answers, oldest message first.
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Client ID"
+// @Produce json
+// @Success 200 {array} service.Message
+// @Router /clients/{id}/messages [get]
+func GetClientMessages(c *gin.Context) {
+	userEmail := c.MustGet("email").(string)
+	clientId, _ := c.Params.Get("id")
+
+	messages, err := messageService.GetMessages(c.Request.Context(), userEmail, clientId)
+
+	if err != nil {
+		if errors.Is(err, service.ErrClientNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		messageLogger.Error("error getting messages", slog.String("error", err.Error()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error getting messages"})
+		return
+	}
+	c.JSON(http.StatusOK, messages)
+}
+
+// ReplyToClient send a message to the client.
+// @Tags CLIENT
+// @Summary send a message to the client.
+// @Schemes
+// @Description sends the message through the channel and adds it to the conversation. the subject is only used by emails.
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Client ID"
+// @Param request body service.Reply true "reply"
+// @Produce json
+// @Success 201 {object} service.Message
+// @Router /clients/{id}/messages [post]
+func ReplyToClient(c *gin.Context) {
+	userEmail := c.MustGet("email").(string)
+	clientId, _ := c.Params.Get("id")
+	var reply service.Reply
+
+	if err := c.ShouldBindJSON(&reply); err != nil {
+		messageLogger.Error("error validating reply", slog.String("error", err.Error()))
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			out := make([]ErrMsg, len(ve))
+
+			for i, fe := range ve {
+				out[i] = ErrMsg{
+					Message: getErrorMsg(fe),
+					Field:   fe.Field(),
+				}
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"errors": out,
+			})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to validate input.",
+		})
+		return
+	}
+
+	message, err := messageService.Reply(c.Request.Context(), userEmail, clientId, reply)
+
+	switch {
+	case errors.Is(err, service.ErrClientNotFound):
+		c.AbortWithStatus(http.StatusNotFound)
+	case errors.Is(err, service.ErrClientOptedOut), errors.Is(err, service.ErrChannelDisabled):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrNoContact), errors.Is(err, service.ErrUnsupportedChannel):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		messageLogger.Error("error sending reply", slog.String("error", err.Error()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusCreated, message)
+	}
+}
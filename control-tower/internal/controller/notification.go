@@ -24,6 +24,7 @@ var (
 	TableName           = os.Getenv("EMAIL_TABLE")
 	ClientTable         = os.Getenv("CLIENT_TABLE")
 	notificationService *service.NotificationService
+	deliveryService     *service.DeliveryService
 )
 
 type PaginatedNotifications struct {
@@ -38,13 +39,15 @@ type PaginationOps struct {
 	Limit *int `form:"limit" json:"limit" binding:"omitempty,min=1"`
 }
 type Notification struct {
-	ID              string            `json:"id,omitempty"`
-	Status          string            `json:"status"`
-	Date            string            `json:"date"`
-	CreatedBy       string            `json:"createdBy"`
-	ClientToken     string            `json:"-"`
-	DeliveryMethods []int8            `json:"deliveryMethods"`
-	Client          service.ClientDto `json:"client"`
+	ID              string               `json:"id,omitempty"`
+	Status          string               `json:"status"`
+	Date            string               `json:"date"`
+	CreatedBy       string               `json:"createdBy"`
+	ClientToken     string               `json:"-"`
+	DeliveryMethods []int8               `json:"deliveryMethods"`
+	Recurrence      *service.Recurrence  `json:"recurrence,omitempty"`
+	Occurrences     []service.Occurrence `json:"occurrences,omitempty"`
+	Client          service.ClientDto    `json:"client"`
 }
 
 // @BasePath /
@@ -187,7 +190,8 @@ func GetSchedule(c *gin.Context) {
 // @Param Authorization header string true "Bearer token"
 // @Param request body service.NotificationInput true "body"
 // @Accept json
-// @Success 204
+// @Produce json
+// @Success 201 {object} service.Notification "requestedDate is the date that was asked for when the send window moved the notification"
 // @Router /schedule [post]
 func PostSchedule(c *gin.Context) {
 	ctx, startSpan := tracer.Start(c.Request.Context(), "post-schedule")
@@ -223,6 +227,13 @@ func PostSchedule(c *gin.Context) {
 	user, err := clientService.GetClientById(clientCtx, userEmail, schedule.ClientId)
 
 	if err != nil {
+		if errors.Is(err, service.ErrClientNotFound) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Client ID='%s' not found.", schedule.ClientId),
+			})
+			clientSpan.End()
+			return
+		}
 		notificationLogger.Error("Error getting client", slog.String("error", err.Error()))
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
 			"error": "failed to create schedule",
@@ -240,10 +251,10 @@ func PostSchedule(c *gin.Context) {
 
 	_, scheduleSpan := tracer.Start(ctx, "create-schedule")
 
-	id, err := notificationService.ScheduleNotification(userEmail, &schedule)
+	n, err := notificationService.CreateNotification(userEmail, &schedule)
 
 	if err != nil {
-		if errors.Is(err, service.ErrInvalidDate) {
+		if errors.Is(err, service.ErrInvalidDate) || errors.Is(err, service.ErrInvalidRecurrence) || errors.Is(err, service.ErrTemplateNotFound) || errors.Is(err, service.ErrTemplateChannel) || errors.Is(err, service.ErrInvalidMessage) || errors.Is(err, service.ErrOutsideSendWindow) {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 				"error": err.Error(),
 			})
@@ -261,17 +272,79 @@ func PostSchedule(c *gin.Context) {
 	/*
 		TODO: move ws notification to eventBridge event handler.
 	*/
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
-		if err := sendUserNotification(c.Request.Context(), user.Email, id, service.NotificationCreatedAction); err != nil {
+		defer wg.Done()
+		if err := sendUserNotification(c.Request.Context(), userEmail, n.ID, service.NotificationCreatedAction); err != nil {
 			notificationLogger.Error("error sending ws message", slog.String("error", err.Error()))
 		}
 
 	}()
-	c.Writer.WriteHeader(204)
+	c.JSON(http.StatusCreated, n)
 	wg.Wait()
 }
 
+// PostBulkSchedule schedules the same notification for many clients.
+// @Summary schedule a notification for a segment of clients.
+// @Schemes
+// @Description schedule a notification for the clients in clientIds or the ones matching filter, on date or offsetDays after their lastSeen.
+// @Description clients with a notification pending on the same day are skipped.
+// @Tags SCHEDULES
+// @Param Authorization header string true "Bearer token"
+// @Param Idempotency-Key header string false "retrying with the same key returns the notifications of the first request"
+// @Param request body service.BulkScheduleInput true "body"
+// @Accept json
+// @Produce json
+// @Success 200 {object} service.BulkScheduleJob
+// @Router /schedule/bulk [post]
+func PostBulkSchedule(c *gin.Context) {
+	ctx, span := tracer.Start(c.Request.Context(), "post-bulk-schedule")
+	defer span.End()
+	defer c.Request.Body.Close()
+	userEmail := c.MustGet("email").(string)
+	var input service.BulkScheduleInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			out := make([]ErrMsg, len(ve))
+
+			for i, fe := range ve {
+				out[i] = ErrMsg{
+					Message: getErrorMsg(fe),
+					Field:   fe.Field(),
+				}
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"errors": out,
+			})
+			return
+		}
+		notificationLogger.Error("error on validation", slog.String("error", err.Error()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to create schedules",
+		})
+		return
+	}
+
+	input.IdempotencyKey = c.GetHeader("Idempotency-Key")
+	job, err := notificationService.BulkSchedule(ctx, userEmail, &input)
+
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidBulkSchedule) || errors.Is(err, service.ErrBulkTooLarge) || errors.Is(err, service.ErrTemplateNotFound) || errors.Is(err, service.ErrTemplateChannel) || errors.Is(err, service.ErrInvalidMessage) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to create schedules",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
 // UpdateSchedule updates schedule.
 // @Summary patch existing schedule by id.
 // @Schemes
@@ -283,6 +356,7 @@ func PostSchedule(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Success 200 {object} service.Notification
+// @Success 202 {object} service.Notification "the notification is updated, its schedule is updated later"
 // @Router /schedule/{id} [patch]
 func UpdateSchedule(c *gin.Context) {
 
@@ -306,7 +380,7 @@ func UpdateSchedule(c *gin.Context) {
 			})
 			return
 		}
-		notificationLogger.Error("error on validation", slog.String("error", err.Error()), "")
+		notificationLogger.Error("error on validation", slog.String("error", err.Error()))
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
 			"error": "failed to update schedule",
 		})
@@ -315,12 +389,21 @@ func UpdateSchedule(c *gin.Context) {
 	n, err := notificationService.UpdateNotification(userEmail, id, notification)
 
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidRecurrence) || errors.Is(err, service.ErrTemplateNotFound) || errors.Is(err, service.ErrTemplateChannel) || errors.Is(err, service.ErrInvalidMessage) || errors.Is(err, service.ErrOutsideSendWindow) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
-
+	if n.SchedulePending {
+		c.JSON(http.StatusAccepted, n)
+		return
+	}
 	c.JSON(http.StatusOK, n)
 }
 
@@ -358,6 +441,119 @@ func DeleteSchedule(c *gin.Context) {
 	c.AbortWithStatus(http.StatusNoContent)
 }
 
+// RetrySchedule schedules a new send of the channels that failed, channels that were sent are not sent again.
+// @Summary retry the failed channels of a schedule.
+// @Schemes
+// @Description retry the failed channels of a schedule.
+// @Tags SCHEDULES
+// @Param Authorization header string true "Bearer token"
+// @Param id path string false "Schedule ID"
+// @Produce json
+// @Success 202 {object} service.Notification
+// @Router /schedule/{id}/retry [post]
+func RetrySchedule(c *gin.Context) {
+	userEmail := c.MustGet("email").(string)
+	id := c.Params.ByName("id")
+
+	n, err := notificationService.RetryFailedChannels(userEmail, id)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNotificationNotFound):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": fmt.Sprintf("notification ID='%s' not found.", id),
+			})
+		case errors.Is(err, service.ErrNothingToRetry), errors.Is(err, service.ErrRetryPending):
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": err.Error(),
+			})
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+		}
+		return
+	}
+	// the retry is scheduled even if the ws message fails.
+	if err := sendUserNotification(c.Request.Context(), userEmail, id, service.NotificationUpdatedAction); err != nil {
+		notificationLogger.Error("error sending ws message", slog.String("error", err.Error()))
+	}
+
+	c.JSON(http.StatusAccepted, n)
+}
+
+// SendSchedule sends a pending schedule now instead of waiting for its date.
+// @Summary send a schedule now.
+// @Schemes
+// @Description sends a pending one time schedule now, its scheduled send is cancelled. schedules that were already sent are returned as they are.
+// @Tags SCHEDULES
+// @Param Authorization header string true "Bearer token"
+// @Param id path string false "Schedule ID"
+// @Produce json
+// @Success 200 {object} service.Notification
+// @Router /schedule/{id}/send [post]
+func SendSchedule(c *gin.Context) {
+	ctx, span := tracer.Start(c.Request.Context(), "send-schedule")
+	defer span.End()
+	userEmail := c.MustGet("email").(string)
+	id := c.Params.ByName("id")
+
+	n, err := deliveryService.SendNow(ctx, userEmail, id)
+
+	if err != nil {
+		abortDeliveryError(c, id, err)
+		return
+	}
+	c.JSON(http.StatusOK, n)
+}
+
+// ResendSchedule sends the failed channels of a schedule again.
+// @Summary resend the failed channels of a schedule.
+// @Schemes
+// @Description sends the channels that failed on the last send now, a pending retry is cancelled. channels that were sent are not sent again.
+// @Tags SCHEDULES
+// @Param Authorization header string true "Bearer token"
+// @Param id path string false "Schedule ID"
+// @Produce json
+// @Success 200 {object} service.Notification
+// @Router /schedule/{id}/resend [post]
+func ResendSchedule(c *gin.Context) {
+	ctx, span := tracer.Start(c.Request.Context(), "resend-schedule")
+	defer span.End()
+	userEmail := c.MustGet("email").(string)
+	id := c.Params.ByName("id")
+
+	n, err := deliveryService.Resend(ctx, userEmail, id)
+
+	if err != nil {
+		abortDeliveryError(c, id, err)
+		return
+	}
+	c.JSON(http.StatusOK, n)
+}
+
+func abortDeliveryError(c *gin.Context, id string, err error) {
+	switch {
+	case errors.Is(err, service.ErrNotificationNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("notification ID='%s' not found.", id),
+		})
+	case errors.Is(err, service.ErrClientNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, service.ErrRecurringSend), errors.Is(err, service.ErrClientOptedOut):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		notificationLogger.Error("error delivering notification", slog.String("id", id), slog.String("error", err.Error()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("unable to deliver notification ID='%s'", id),
+		})
+	}
+}
+
 // aggregateNotifications receives a service.Notification List and retrieves a Notification with the full client struct.
 func aggregateNotifications(ctx context.Context, nl []service.Notification) ([]Notification, error) {
 
@@ -384,6 +580,8 @@ func aggregateNotifications(ctx context.Context, nl []service.Notification) ([]N
 				CreatedBy:       n.CreatedBy,
 				ClientToken:     n.ClientToken,
 				DeliveryMethods: n.DeliveryMethods,
+				Recurrence:      n.Recurrence,
+				Occurrences:     n.Occurrences,
 				Client:          *c,
 			}
 
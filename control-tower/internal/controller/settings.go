@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/japb1998/control-tower/internal/service"
+)
+
+var settingsService *service.SettingsService
+
+// GetSettings get the settings of the current user.
+// @Tags SETTINGS
+// @Summary get the settings of the current user.
+// @Schemes
+// @Description get the settings of the current user.
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {object} service.Settings
+// @Router /settings [get]
+func GetSettings(c *gin.Context) {
+	userEmail := c.MustGet("email").(string)
+
+	settings, err := settingsService.GetSettings(c.Request.Context(), userEmail)
+
+	if err != nil {
+		settingsLogger.Error("error getting settings", slog.String("error", err.Error()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get settings",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// PutSettings replace the settings of the current user.
+// @Tags SETTINGS
+// @Summary replace the settings of the current user.
+// @Schemes
+// @Description replace the settings of the current user.
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.PutSettings true "settings"
+// @Accept json
+// @Produce json
+// @Success 200 {object} service.Settings
+// @Router /settings [put]
+func PutSettings(c *gin.Context) {
+	userEmail := c.MustGet("email").(string)
+	var input service.PutSettings
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		settingsLogger.Error("error validating settings", slog.String("error", err.Error()))
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			out := make([]ErrMsg, len(ve))
+
+			for i, fe := range ve {
+				out[i] = ErrMsg{
+					Message: getErrorMsg(fe),
+					Field:   fe.Field(),
+				}
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"errors": out,
+			})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to validate input.",
+		})
+		return
+	}
+
+	settings, err := settingsService.SaveSettings(c.Request.Context(), userEmail, input)
+
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTimeZone) || errors.Is(err, service.ErrInvalidSendWindow) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to save settings",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
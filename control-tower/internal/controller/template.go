@@ -0,0 +1,303 @@
+package controller
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/japb1998/control-tower/internal/service"
+)
+
+var templateService *service.TemplateService
+
+// GetTemplates get the templates of the current user.
+// @Tags TEMPLATES
+// @Summary get the templates of the current user.
+// @Schemes
+// @Description get the reminder templates of the current user.
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {array} service.Template
+// @Router /templates [get]
+func GetTemplates(c *gin.Context) {
+	userEmail := c.MustGet("email").(string)
+
+	templates, err := templateService.GetTemplates(c.Request.Context(), userEmail)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get templates",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+// GetTemplateVariables get the variables the templates can use.
+// @Tags TEMPLATES
+// @Summary get the variables the templates can use.
+// @Schemes
+// @Description variables are written as {{name}} in the subject and body of the templates.
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200 {array} service.TemplateVariable
+// @Router /templates/variables [get]
+func GetTemplateVariables(c *gin.Context) {
+	c.JSON(http.StatusOK, service.TemplateVariables)
+}
+
+// GetTemplate get a template of the current user.
+// @Tags TEMPLATES
+// @Summary get a template of the current user.
+// @Schemes
+// @Description get a template of the current user.
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Template ID"
+// @Produce json
+// @Success 200 {object} service.Template
+// @Router /templates/{id} [get]
+func GetTemplate(c *gin.Context) {
+	userEmail := c.MustGet("email").(string)
+	id, _ := c.Params.Get("id")
+
+	t, err := templateService.GetTemplate(c.Request.Context(), userEmail, id)
+
+	if err != nil {
+		abortTemplateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// CreateTemplate create a template.
+// @Tags TEMPLATES
+// @Summary create a template.
+// @Schemes
+// @Description create a reminder template for a channel. unknown variables are rejected.
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.TemplateInput true "template"
+// @Accept json
+// @Produce json
+// @Success 201 {object} service.Template
+// @Router /templates [post]
+func CreateTemplate(c *gin.Context) {
+	userEmail := c.MustGet("email").(string)
+	var input service.TemplateInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		templateLogger.Error("error validating template", slog.String("error", err.Error()))
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			out := make([]ErrMsg, len(ve))
+
+			for i, fe := range ve {
+				out[i] = ErrMsg{
+					Message: getErrorMsg(fe),
+					Field:   fe.Field(),
+				}
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"errors": out,
+			})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to validate input.",
+		})
+		return
+	}
+
+	t, err := templateService.CreateTemplate(c.Request.Context(), userEmail, input)
+
+	if err != nil {
+		abortTemplateError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, t)
+}
+
+// UpdateTemplate replace a template.
+// @Tags TEMPLATES
+// @Summary replace a template.
+// @Schemes
+// @Description replace a template, the notifications that reference it send the new version.
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Template ID"
+// @Param request body service.TemplateInput true "template"
+// @Accept json
+// @Produce json
+// @Success 200 {object} service.Template
+// @Router /templates/{id} [put]
+func UpdateTemplate(c *gin.Context) {
+	userEmail := c.MustGet("email").(string)
+	id, _ := c.Params.Get("id")
+	var input service.TemplateInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		templateLogger.Error("error validating template", slog.String("error", err.Error()))
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			out := make([]ErrMsg, len(ve))
+
+			for i, fe := range ve {
+				out[i] = ErrMsg{
+					Message: getErrorMsg(fe),
+					Field:   fe.Field(),
+				}
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"errors": out,
+			})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to validate input.",
+		})
+		return
+	}
+
+	t, err := templateService.UpdateTemplate(c.Request.Context(), userEmail, id, input)
+
+	if err != nil {
+		abortTemplateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// DeleteTemplate delete a template.
+// @Tags TEMPLATES
+// @Summary delete a template.
+// @Schemes
+// @Description delete a template, the notifications that reference it send the channel defaults.
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Template ID"
+// @Success 204
+// @Router /templates/{id} [delete]
+func DeleteTemplate(c *gin.Context) {
+	userEmail := c.MustGet("email").(string)
+	id, _ := c.Params.Get("id")
+
+	if err := templateService.DeleteTemplate(c.Request.Context(), userEmail, id); err != nil {
+		abortTemplateError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// PreviewTemplate render a template.
+// @Tags TEMPLATES
+// @Summary render a template.
+// @Schemes
+// @Description render the template with the values of the client, sample values are used without a client.
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Template ID"
+// @Param request body service.PreviewInput true "preview"
+// @Accept json
+// @Produce json
+// @Success 200 {object} service.RenderedTemplate
+// @Router /templates/{id}/preview [post]
+func PreviewTemplate(c *gin.Context) {
+	userEmail := c.MustGet("email").(string)
+	id, _ := c.Params.Get("id")
+	var input service.PreviewInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		templateLogger.Error("error validating preview", slog.String("error", err.Error()))
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			out := make([]ErrMsg, len(ve))
+
+			for i, fe := range ve {
+				out[i] = ErrMsg{
+					Message: getErrorMsg(fe),
+					Field:   fe.Field(),
+				}
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"errors": out,
+			})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to validate input.",
+		})
+		return
+	}
+
+	rendered, err := templateService.PreviewTemplate(c.Request.Context(), userEmail, id, input)
+
+	if err != nil {
+		abortTemplateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rendered)
+}
+
+// PreviewDraftTemplate render a template before it is saved.
+// @Tags TEMPLATES
+// @Summary render a template before it is saved.
+// @Schemes
+// @Description render the template with the values of the client, sample values are used without a client.
+// @Param Authorization header string true "Bearer token"
+// @Param request body service.DraftPreview true "preview"
+// @Accept json
+// @Produce json
+// @Success 200 {object} service.RenderedTemplate
+// @Router /templates/preview [post]
+func PreviewDraftTemplate(c *gin.Context) {
+	userEmail := c.MustGet("email").(string)
+	var input service.DraftPreview
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		templateLogger.Error("error validating preview", slog.String("error", err.Error()))
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			out := make([]ErrMsg, len(ve))
+
+			for i, fe := range ve {
+				out[i] = ErrMsg{
+					Message: getErrorMsg(fe),
+					Field:   fe.Field(),
+				}
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"errors": out,
+			})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to validate input.",
+		})
+		return
+	}
+
+	rendered, err := templateService.PreviewDraft(c.Request.Context(), userEmail, input)
+
+	if err != nil {
+		abortTemplateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rendered)
+}
+
+// abortTemplateError responds with the status of the template service error.
+func abortTemplateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrTemplateNotFound), errors.Is(err, service.ErrClientNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, service.ErrInvalidTemplate):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		templateLogger.Error("template error", slog.String("error", err.Error()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+	}
+}
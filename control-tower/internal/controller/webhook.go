@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/japb1998/control-tower/internal/service"
+	"github.com/japb1998/control-tower/pkg/email"
+	"github.com/japb1998/control-tower/pkg/sms"
+)
+
+var (
+	mailgunSigningKey string // verifies the mailgun webhooks.
+	twilioAuthToken   string // verifies the twilio webhooks.
+	apiUrl            string // public url of the api, twilio signs the url it calls.
+)
+
+// MailgunWebhook receives the mailgun delivery events.
+// @Tags WEBHOOKS
+// @Summary receives the mailgun delivery events.
+// @Schemes
+// @Description records delivered, opened and failed events on the notification deliveries. clients that hard bounce or complain get email disabled.
+// @Accept json
+// @Success 200
+// @Failure 401
+// @Failure 406
+// @Failure 503
+// @Router /webhooks/mailgun [post]
+func MailgunWebhook(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "unable to read body",
+		})
+		return
+	}
+	event, err := email.ParseWebhook(body, mailgunSigningKey, time.Now())
+
+	switch {
+	case errors.Is(err, email.ErrInvalidSignature):
+		notificationLogger.Warn("invalid mailgun webhook signature", slog.String("error", err.Error()))
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+		return
+	// mailgun does not retry webhooks rejected with 406.
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	err = deliveryService.HandleEmailEvent(c.Request.Context(), event)
+
+	// mailgun retries the events rejected with other errors.
+	if errors.Is(err, service.ErrDeliveryPending) {
+		notificationLogger.Info("mailgun event before its delivery was recorded", slog.String("event", event.Event), slog.String("messageId", event.MessageId()))
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		notificationLogger.Error("error handling mailgun event", slog.String("event", event.Event), slog.String("error", err.Error()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "unable to handle event",
+		})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// MailgunInboundWebhook receives the emails the clients send, it is the target of the mailgun route of the reply address.
+// @Tags WEBHOOKS
+// @Summary receives the emails the clients send.
+// @Schemes
+// @Description adds the email to the conversation of the clients with the sender address.
+// @Accept mpfd
+// @Success 200
+// @Failure 401
+// @Failure 406
+// @Router /webhooks/mailgun/inbound [post]
+func MailgunInboundWebhook(c *gin.Context) {
+	if err := c.Request.ParseMultipartForm(32 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{
+			"error": "invalid form",
+		})
+		return
+	}
+	inbound, err := email.ParseInboundEmail(c.Request.PostForm, mailgunSigningKey, time.Now())
+
+	switch {
+	case errors.Is(err, email.ErrInvalidSignature):
+		notificationLogger.Warn("invalid mailgun inbound signature", slog.String("error", err.Error()))
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+		return
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := deliveryService.HandleInboundEmail(c.Request.Context(), inbound); err != nil {
+		messageLogger.Error("error handling inbound email", slog.String("error", err.Error()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "unable to handle email",
+		})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// TwilioStatusWebhook receives the status changes of the twilio messages.
+// @Tags WEBHOOKS
+// @Summary receives the status changes of the twilio messages.
+// @Schemes
+// @Description records delivered, read and failed statuses on the notification deliveries.
+// @Accept x-www-form-urlencoded
+// @Success 200
+// @Failure 401
+// @Router /webhooks/twilio/status [post]
+func TwilioStatusWebhook(c *gin.Context) {
+	if !validTwilioRequest(c) {
+		return
+	}
+	status := sms.ParseStatusCallback(c.Request.URL.Query(), c.Request.PostForm)
+
+	if err := deliveryService.HandleMessageStatus(c.Request.Context(), status); err != nil {
+		notificationLogger.Error("error handling twilio status", slog.String("status", status.MessageStatus), slog.String("error", err.Error()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "unable to handle status",
+		})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// TwilioInboundWebhook receives the messages the clients send to our number.
+// @Tags WEBHOOKS
+// @Summary receives the messages the clients send to our number.
+// @Schemes
+// @Description STOP or BAJA opts the client out, CONFIRM or SI confirms the last reminder sent to the client.
+// @Accept x-www-form-urlencoded
+// @Produce xml
+// @Success 200
+// @Failure 401
+// @Router /webhooks/twilio/inbound [post]
+func TwilioInboundWebhook(c *gin.Context) {
+	if !validTwilioRequest(c) {
+		return
+	}
+	msg := sms.ParseInboundMessage(c.Request.PostForm)
+
+	if err := deliveryService.HandleInboundMessage(c.Request.Context(), msg); err != nil {
+		notificationLogger.Error("error handling inbound message", slog.String("messageId", msg.MessageSid), slog.String("error", err.Error()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "unable to handle message",
+		})
+		return
+	}
+	// an empty response, the reply is not answered.
+	c.Data(http.StatusOK, "text/xml", []byte("<Response></Response>"))
+}
+
+// validTwilioRequest parses the form and checks the twilio signature, invalid requests are aborted.
+func validTwilioRequest(c *gin.Context) bool {
+	if err := c.Request.ParseForm(); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "invalid form",
+		})
+		return false
+	}
+	err := sms.ValidateRequest(twilioAuthToken, apiUrl+c.Request.URL.RequestURI(), c.Request.PostForm, c.GetHeader("X-Twilio-Signature"))
+
+	if err != nil {
+		notificationLogger.Warn("invalid twilio webhook signature", slog.String("path", c.Request.URL.Path))
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+		return false
+	}
+	return true
+}
@@ -21,19 +21,32 @@ var (
 	clientLogger     = log.New(os.Stdout, "[Client Repository] ", log.Default().Flags())
 )
 
+var (
+	ErrClientNotFound = errors.New("Client not found")
+)
+
+const (
+	batchWriteLimit    = 25 // max items dynamo accepts in a single batch write.
+	batchWriteAttempts = 5
+)
+
 type ClientRepository struct {
 	Client    *DynamoClient
 	tableName string
 }
 
 type PatchClientItem struct {
-	Phone       string     `json:"phone"`
-	Email       string     `json:"email"`
-	FirstName   string     `json:"firstName"`
-	LastName    string     `json:"lastName"`
-	LastSeen    *time.Time `json:"lastSeen"`
-	Description string     `json:"description"`
-	OptIn       *bool      `json:"optIn"`
+	Phone            string     `json:"phone"`
+	Email            string     `json:"email"`
+	FirstName        string     `json:"firstName"`
+	LastName         string     `json:"lastName"`
+	LastSeen         *time.Time `json:"lastSeen"`
+	Description      string     `json:"description"`
+	OptIn            *bool      `json:"optIn"`
+	DeliveryMethods  []int8     `json:"deliveryMethods"`
+	DisabledChannels *[]int8    `json:"disabledChannels"` // nil keeps the current channels, an empty list enables every channel.
+	Language         string     `json:"language"`
+	TimeZone         string     `json:"timeZone"`
 }
 
 func NewClientRepo(sess *session.Session) *ClientRepository {
@@ -49,6 +62,7 @@ func NewClientRepo(sess *session.Session) *ClientRepository {
 	return clientRepository
 }
 
+// GetClientsByCreator returns every client of the creator that is not archived.
 func (c *ClientRepository) GetClientsByCreator(createdBy string) ([]model.ClientItem, error) {
 
 	queryValue, err := dynamodbattribute.MarshalMap(map[string]any{
@@ -67,9 +81,11 @@ func (c *ClientRepository) GetClientsByCreator(createdBy string) ([]model.Client
 	queryInput := &dynamodb.QueryInput{
 		TableName:                 &c.tableName,
 		KeyConditionExpression:    aws.String("#primaryKey = :primaryKey"),
+		FilterExpression:          aws.String("attribute_not_exists(#deletedAt)"),
 		ExpressionAttributeValues: queryValue,
 		ExpressionAttributeNames: map[string]*string{
 			"#primaryKey": aws.String("primaryKey"),
+			"#deletedAt":  aws.String("deletedAt"),
 		},
 		ExclusiveStartKey: lastEvaluatedKey,
 	}
@@ -105,9 +121,66 @@ func (c *ClientRepository) GetClientsByCreator(createdBy string) ([]model.Client
 
 }
 
-func (c *ClientRepository) ClientCountWithFilters(createdBy string, clientPatch PatchClientItem) (int64, error) {
-	var queryInput = &dynamodb.QueryInput{
-		TableName: &c.tableName,
+// GetClientsByPhone returns the clients of every creator with the phone that are not archived, sorted by creator.
+func (c *ClientRepository) GetClientsByPhone(phone string) ([]model.ClientItem, error) {
+	return c.queryByIndex("PHONE", "phone", phone)
+}
+
+// GetClientsByEmail returns the clients of every creator with the email that are not archived, sorted by creator.
+func (c *ClientRepository) GetClientsByEmail(email string) ([]model.ClientItem, error) {
+	return c.queryByIndex("EMAIL", "email", email)
+}
+
+// queryByIndex returns the clients of every creator with the attribute value that are not archived, the attribute is the key of the index.
+func (c *ClientRepository) queryByIndex(index, name, value string) ([]model.ClientItem, error) {
+	values, err := dynamodbattribute.MarshalMap(map[string]any{
+		":value": value,
+	})
+
+	if err != nil {
+		clientLogger.Println("error marshalling value error: ", err)
+		return nil, fmt.Errorf("invalid %s: %s", name, value)
+	}
+	input := &dynamodb.QueryInput{
+		TableName:                 &c.tableName,
+		IndexName:                 aws.String(index),
+		KeyConditionExpression:    aws.String("#attr = :value"),
+		FilterExpression:          aws.String("attribute_not_exists(#deletedAt)"),
+		ExpressionAttributeValues: values,
+		ExpressionAttributeNames: map[string]*string{
+			"#attr":      aws.String(name),
+			"#deletedAt": aws.String("deletedAt"),
+		},
+	}
+	clients := make([]model.ClientItem, 0)
+
+	for {
+		output, err := c.Client.Query(input)
+
+		if err != nil {
+			return nil, fmt.Errorf("error querying client items by %s error: %s", name, err)
+		}
+		var items []model.ClientItem
+
+		if err := dynamodbattribute.UnmarshalListOfMaps(output.Items, &items); err != nil {
+			clientLogger.Println("error unmarshalling clients error:", err)
+			return nil, fmt.Errorf("error unmarshalling clients")
+		}
+		clients = append(clients, items...)
+
+		if output.LastEvaluatedKey == nil {
+			break
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+	return clients, nil
+}
+
+// filterQuery returns the query of the creator clients matching any of the provided filters. archived clients are excluded.
+func (c *ClientRepository) filterQuery(createdBy string, clientPatch PatchClientItem) (*dynamodb.QueryInput, error) {
+	queryInput := &dynamodb.QueryInput{
+		TableName:        &c.tableName,
+		ScanIndexForward: aws.Bool(true),
 	}
 	primaryKeyExpressionList := []string{"#primaryKey = :primaryKey"}
 
@@ -117,6 +190,7 @@ func (c *ClientRepository) ClientCountWithFilters(createdBy string, clientPatch
 	filterExpressionList := make([]string, 0)
 	expressionAttributeNames := map[string]*string{
 		"#primaryKey": aws.String("primaryKey"),
+		"#deletedAt":  aws.String("deletedAt"),
 	}
 
 	if clientPatch.Phone != "" {
@@ -147,86 +221,66 @@ func (c *ClientRepository) ClientCountWithFilters(createdBy string, clientPatch
 		expressionAttributeNames["#lastSeen"] = aws.String("lastSeen")
 		filterExpressionList = append(filterExpressionList, "#lastSeen = :lastSeen")
 	}
-
+	// values
 	marshaledValues, err := dynamodbattribute.MarshalMap(attributeValues)
 
 	if err != nil {
+		return nil, err
+	}
+	queryInput.ExpressionAttributeValues = marshaledValues
 
-		clientLogger.Println(err)
+	// names
+	queryInput.ExpressionAttributeNames = expressionAttributeNames
 
-		return 0, errors.New("error while retreiving clients")
-	}
+	filterExpression := "attribute_not_exists(#deletedAt)"
 	if len(filterExpressionList) > 0 {
-		queryInput.FilterExpression = aws.String(strings.Join(filterExpressionList, " OR "))
+		filterExpression += fmt.Sprintf(" AND (%s)", strings.Join(filterExpressionList, " OR "))
 	}
+	queryInput.FilterExpression = &filterExpression
+
 	queryInput.KeyConditionExpression = aws.String(strings.Join(primaryKeyExpressionList, " and "))
-	queryInput.ExpressionAttributeNames = expressionAttributeNames
-	queryInput.ExpressionAttributeValues = marshaledValues
-	queryInput.Select = aws.String("COUNT")
-	output, err := c.Client.Query(queryInput)
+	return queryInput, nil
+}
+
+func (c *ClientRepository) ClientCountWithFilters(createdBy string, clientPatch PatchClientItem) (int64, error) {
+	queryInput, err := c.filterQuery(createdBy, clientPatch)
 
 	if err != nil {
 
-		log.Println(err.Error())
+		clientLogger.Println(err)
 
 		return 0, errors.New("error while retreiving clients")
 	}
+	queryInput.Select = aws.String("COUNT")
 
-	return *output.Count, nil
+	var count int64
+	for {
+		output, err := c.Client.Query(queryInput)
 
-}
+		if err != nil {
 
-func (c *ClientRepository) GetClientWithFilters(createdBy string, clientPatch PatchClientItem, p *PaginationOps) ([]model.ClientItem, error) {
-	var clientEntityList []model.ClientItem
+			log.Println(err.Error())
 
-	var lastEvaluatedKey map[string]*dynamodb.AttributeValue
-	var queryInput = &dynamodb.QueryInput{
-		TableName:         &c.tableName,
-		ExclusiveStartKey: lastEvaluatedKey,
-		Limit:             aws.Int64(int64(p.Skip + p.Limit)),
-		ScanIndexForward:  aws.Bool(true),
-	}
-	primaryKeyExpressionList := []string{"#primaryKey = :primaryKey"}
+			return 0, errors.New("error while retreiving clients")
+		}
+		count += *output.Count
 
-	attributeValues := map[string]any{
-		":primaryKey": createdBy,
-	}
-	filterExpressionList := make([]string, 0)
-	expressionAttributeNames := map[string]*string{
-		"#primaryKey": aws.String("primaryKey"),
+		if output.LastEvaluatedKey == nil {
+			break
+		}
+		queryInput.ExclusiveStartKey = output.LastEvaluatedKey
 	}
 
-	if clientPatch.Phone != "" {
-		attributeValues[":phone"] = clientPatch.Phone
-		expressionAttributeNames["#phone"] = aws.String("phone")
-		filterExpressionList = append(filterExpressionList, "#phone = :phone")
-	}
-	if clientPatch.Email != "" {
-		attributeValues[":email"] = clientPatch.Email
-		expressionAttributeNames["#email"] = aws.String("email")
-		filterExpressionList = append(filterExpressionList, "contains(#email, :email)")
-	}
+	return count, nil
 
-	if clientPatch.FirstName != "" {
-		attributeValues[":firstName"] = clientPatch.FirstName
-		expressionAttributeNames["#firstName"] = aws.String("firstName")
-		filterExpressionList = append(filterExpressionList, "contains(#firstName,:firstName)")
-	}
+}
 
-	if clientPatch.LastName != "" {
-		attributeValues[":lastName"] = clientPatch.LastName
-		expressionAttributeNames["#lastName"] = aws.String("lastName")
-		filterExpressionList = append(filterExpressionList, "contains(#lastName, :lastName)")
-	}
+// GetClientWithFilters get clients with filters. Paginated, Zero indexed
+func (c *ClientRepository) GetClientWithFilters(createdBy string, clientPatch PatchClientItem, p *PaginationOps) ([]model.ClientItem, error) {
+	var clientEntityList []model.ClientItem
 
-	if clientPatch.LastSeen != nil {
-		clientLogger.Println("Last Seen='%w'", clientPatch.LastSeen)
-		attributeValues[":lastSeen"] = clientPatch.LastSeen
-		expressionAttributeNames["#lastSeen"] = aws.String("lastSeen")
-		filterExpressionList = append(filterExpressionList, "#lastSeen = :lastSeen")
-	}
-	// values
-	marshaledValues, err := dynamodbattribute.MarshalMap(attributeValues)
+	var lastEvaluatedKey map[string]*dynamodb.AttributeValue
+	queryInput, err := c.filterQuery(createdBy, clientPatch)
 
 	if err != nil {
 
@@ -234,16 +288,7 @@ func (c *ClientRepository) GetClientWithFilters(createdBy string, clientPatch Pa
 
 		return nil, errors.New("error while retrieving clients")
 	}
-	queryInput.ExpressionAttributeValues = marshaledValues
-
-	// names
-	queryInput.ExpressionAttributeNames = expressionAttributeNames
-
-	if len(filterExpressionList) > 0 {
-		queryInput.FilterExpression = aws.String(strings.Join(filterExpressionList, " OR "))
-	}
-
-	queryInput.KeyConditionExpression = aws.String(strings.Join(primaryKeyExpressionList, " and "))
+	queryInput.Limit = aws.Int64(int64(p.Skip + p.Limit))
 
 	// loop until lastEvaluated key is nil or we reach our limit setup by the pagination.
 	for {
@@ -294,6 +339,42 @@ func (c *ClientRepository) GetClientWithFilters(createdBy string, clientPatch Pa
 
 }
 
+// WalkClientsWithFilters calls fn with every client matching the filters one page at a time, it stops at the first error.
+func (c *ClientRepository) WalkClientsWithFilters(createdBy string, clientPatch PatchClientItem, fn func(model.ClientItem) error) error {
+	queryInput, err := c.filterQuery(createdBy, clientPatch)
+
+	if err != nil {
+		clientLogger.Println(err)
+		return errors.New("error while retrieving clients")
+	}
+
+	for {
+		output, err := c.Client.Query(queryInput)
+
+		if err != nil {
+			clientLogger.Println(err)
+			return errors.New("error while retrieving clients")
+		}
+		var clients []model.ClientItem
+
+		if err := dynamodbattribute.UnmarshalListOfMaps(output.Items, &clients); err != nil {
+			clientLogger.Println(err)
+			return fmt.Errorf("error while retrieving clients error: %w", err)
+		}
+
+		for _, client := range clients {
+			if err := fn(client); err != nil {
+				return err
+			}
+		}
+
+		if output.LastEvaluatedKey == nil {
+			return nil
+		}
+		queryInput.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
 func (c *ClientRepository) CreateClient(client model.ClientItem) (model.ClientItem, error) {
 	clientLogger.Printf("Creating user: %v", client)
 	item, err := dynamodbattribute.MarshalMap(client)
@@ -314,7 +395,70 @@ func (c *ClientRepository) CreateClient(client model.ClientItem) (model.ClientIt
 		return model.ClientItem{}, fmt.Errorf("error while creating client")
 	}
 
-	return model.ClientItem{}, nil
+	return client, nil
+}
+
+// CreateClients stores the clients with batch writes. it returns the clients that could not be written.
+func (c *ClientRepository) CreateClients(clients []model.ClientItem) ([]model.ClientItem, error) {
+	var failed []model.ClientItem
+	var errs []error
+
+	for start := 0; start < len(clients); start += batchWriteLimit {
+		end := min(start+batchWriteLimit, len(clients))
+		chunk := clients[start:end]
+		requests := make([]*dynamodb.WriteRequest, 0, len(chunk))
+		byId := make(map[string]model.ClientItem, len(chunk))
+
+		for _, client := range chunk {
+			item, err := dynamodbattribute.MarshalMap(client)
+
+			if err != nil {
+				failed = append(failed, client)
+				errs = append(errs, fmt.Errorf("error marshalling client id='%s' error: %w", client.SortKey, err))
+				continue
+			}
+			byId[client.SortKey] = client
+			requests = append(requests, &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: item}})
+		}
+
+		unprocessed, err := c.batchWrite(requests)
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, r := range unprocessed {
+			var client model.ClientItem
+
+			if err := dynamodbattribute.UnmarshalMap(r.PutRequest.Item, &client); err == nil {
+				failed = append(failed, byId[client.SortKey])
+			}
+		}
+	}
+	clientLogger.Printf("created %d clients, %d failed\n", len(clients)-len(failed), len(failed))
+	return failed, errors.Join(errs...)
+}
+
+// batchWrite writes the requests retrying the unprocessed ones. it returns the requests that were not written.
+func (c *ClientRepository) batchWrite(requests []*dynamodb.WriteRequest) ([]*dynamodb.WriteRequest, error) {
+	backoff := time.Millisecond * 100
+
+	for attempt := 0; len(requests) > 0 && attempt < batchWriteAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		output, err := c.Client.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]*dynamodb.WriteRequest{
+				c.tableName: requests,
+			},
+		})
+
+		if err != nil {
+			return requests, fmt.Errorf("error writing clients error: %w", err)
+		}
+		requests = output.UnprocessedItems[c.tableName]
+	}
+	return requests, nil
 }
 
 func (c *ClientRepository) UpdateUser(createdBy string, clientId string, client PatchClientItem) (model.ClientItem, error) {
@@ -361,8 +505,32 @@ func (c *ClientRepository) UpdateUser(createdBy string, clientId string, client
 		expressionList = append(expressionList, "#lastSeen = :lastSeen")
 	}
 
+	if len(client.DeliveryMethods) > 0 {
+		updateExpressionValues[":deliveryMethods"] = client.DeliveryMethods
+		updateExpressionNames["#deliveryMethods"] = aws.String("deliveryMethods")
+		expressionList = append(expressionList, "#deliveryMethods = :deliveryMethods")
+	}
+
+	if client.DisabledChannels != nil {
+		updateExpressionValues[":disabledChannels"] = *client.DisabledChannels
+		updateExpressionNames["#disabledChannels"] = aws.String("disabledChannels")
+		expressionList = append(expressionList, "#disabledChannels = :disabledChannels")
+	}
+
+	if client.Language != "" {
+		updateExpressionValues[":language"] = client.Language
+		updateExpressionNames["#language"] = aws.String("language")
+		expressionList = append(expressionList, "#language = :language")
+	}
+
+	if client.TimeZone != "" {
+		updateExpressionValues[":timeZone"] = client.TimeZone
+		updateExpressionNames["#timeZone"] = aws.String("timeZone")
+		expressionList = append(expressionList, "#timeZone = :timeZone")
+	}
+
 	if len(expressionList) == 0 {
-		return model.ClientItem{}, fmt.Errorf("empty update not allowed")
+		return model.ClientItem{}, ErrEmptyUpdate
 	}
 	expressionList = append(expressionList, fmt.Sprint("lastUpdateAt = :lastUpdateAt"))
 	updateExpressionValues[":lastUpdateAt"] = time.Now().Format(time.RFC3339)
@@ -396,11 +564,16 @@ func (c *ClientRepository) UpdateUser(createdBy string, clientId string, client
 		ExpressionAttributeNames:  updateExpressionNames,
 		ExpressionAttributeValues: marshalledExpressionValues,
 		ReturnValues:              aws.String("ALL_NEW"),
+		ConditionExpression:       aws.String("attribute_exists(sortKey) AND attribute_not_exists(deletedAt)"),
 	}
 
 	output, err := c.Client.UpdateItem(updateInput)
 
 	if err != nil {
+		var conditionErr *dynamodb.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return model.ClientItem{}, ErrClientNotFound
+		}
 		log.Println(output)
 		return model.ClientItem{}, fmt.Errorf("error updating item error: %w", err)
 	}
@@ -437,6 +610,7 @@ func (c *ClientRepository) DeleteClient(createdBy, id string) error {
 	return nil
 }
 
+// GetClientById returns nil when the client does not exist or is archived.
 func (c *ClientRepository) GetClientById(createdBy, id string) (*model.ClientItem, error) {
 
 	key, err := dynamodbattribute.MarshalMap(map[string]string{
@@ -467,8 +641,135 @@ func (c *ClientRepository) GetClientById(createdBy, id string) (*model.ClientIte
 		if err != nil {
 			return nil, errors.New("error While Getting Client")
 		}
+		// archived clients are only reachable through GetArchivedClients and RestoreClient.
+		if clientEntity.DeletedAt != nil {
+			return nil, nil
+		}
 
 		return &clientEntity, nil
 	}
 
 }
+
+// GetArchivedClients returns the archived clients of the creator.
+func (c *ClientRepository) GetArchivedClients(createdBy string) ([]model.ClientItem, error) {
+	queryValue, err := dynamodbattribute.MarshalMap(map[string]any{
+		":primaryKey": createdBy,
+	})
+
+	if err != nil {
+		clientLogger.Println("error marshalling query key error: ", err)
+		return nil, fmt.Errorf("invalid creator: %s", createdBy)
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:                 &c.tableName,
+		KeyConditionExpression:    aws.String("#primaryKey = :primaryKey"),
+		FilterExpression:          aws.String("attribute_exists(#deletedAt)"),
+		ExpressionAttributeValues: queryValue,
+		ExpressionAttributeNames: map[string]*string{
+			"#primaryKey": aws.String("primaryKey"),
+			"#deletedAt":  aws.String("deletedAt"),
+		},
+	}
+	clients := make([]model.ClientItem, 0)
+
+	for {
+		output, err := c.Client.Query(queryInput)
+
+		if err != nil {
+			clientLogger.Println(err)
+			return nil, fmt.Errorf("error querying client items error: %s", err)
+		}
+		var items []model.ClientItem
+
+		if err := dynamodbattribute.UnmarshalListOfMaps(output.Items, &items); err != nil {
+			clientLogger.Println("error unmarshalling clients error:", err)
+			return nil, fmt.Errorf("error unmarshalling clients")
+		}
+		clients = append(clients, items...)
+
+		if output.LastEvaluatedKey == nil {
+			return clients, nil
+		}
+		queryInput.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// ArchiveClient hides the client until it is restored. it fails with ErrClientNotFound when the client does not exist or is already archived.
+func (c *ClientRepository) ArchiveClient(createdBy, id string, at time.Time) error {
+	_, err := c.setDeletedAt(createdBy, id, &at)
+	return err
+}
+
+// RestoreClient makes an archived client visible again. it fails with ErrClientNotFound when the client is not archived.
+func (c *ClientRepository) RestoreClient(createdBy, id string) (model.ClientItem, error) {
+	return c.setDeletedAt(createdBy, id, nil)
+}
+
+// deletedAtInput returns the update that archives the client at the date, a nil date restores it.
+func (c *ClientRepository) deletedAtInput(createdBy, id string, at *time.Time, now time.Time) (*dynamodb.UpdateItemInput, error) {
+	key, err := dynamodbattribute.MarshalMap(map[string]string{
+		"primaryKey": createdBy,
+		"sortKey":    id,
+	})
+
+	if err != nil {
+		clientLogger.Println(err)
+		return nil, fmt.Errorf("error Marshalling update values")
+	}
+	values := map[string]any{
+		":lastUpdateAt": now.UTC().Format(time.RFC3339),
+	}
+	// an update expression can only have one SET clause.
+	update := "REMOVE #deletedAt SET #lastUpdateAt = :lastUpdateAt"
+	condition := "attribute_exists(#deletedAt)"
+
+	if at != nil {
+		values[":deletedAt"] = at.UTC().Format(time.RFC3339)
+		update = "SET #deletedAt = :deletedAt, #lastUpdateAt = :lastUpdateAt"
+		condition = "attribute_exists(sortKey) AND attribute_not_exists(#deletedAt)"
+	}
+	marshalledValues, err := dynamodbattribute.MarshalMap(values)
+
+	if err != nil {
+		clientLogger.Println(err)
+		return nil, fmt.Errorf("error Marshalling update values")
+	}
+	return &dynamodb.UpdateItemInput{
+		TableName:        &c.tableName,
+		Key:              key,
+		UpdateExpression: aws.String(update),
+		ExpressionAttributeNames: map[string]*string{
+			"#deletedAt":    aws.String("deletedAt"),
+			"#lastUpdateAt": aws.String("lastUpdateAt"),
+		},
+		ExpressionAttributeValues: marshalledValues,
+		ConditionExpression:       aws.String(condition),
+		ReturnValues:              aws.String("ALL_NEW"),
+	}, nil
+}
+
+func (c *ClientRepository) setDeletedAt(createdBy, id string, at *time.Time) (model.ClientItem, error) {
+	input, err := c.deletedAtInput(createdBy, id, at, time.Now())
+
+	if err != nil {
+		return model.ClientItem{}, err
+	}
+	output, err := c.Client.UpdateItem(input)
+
+	if err != nil {
+		var conditionErr *dynamodb.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return model.ClientItem{}, ErrClientNotFound
+		}
+		clientLogger.Println(err)
+		return model.ClientItem{}, fmt.Errorf("error updating item error: %w", err)
+	}
+	var item model.ClientItem
+
+	if err := dynamodbattribute.UnmarshalMap(output.Attributes, &item); err != nil {
+		return model.ClientItem{}, fmt.Errorf("error while marshalling updated value, value was possibly updated error: %w", err)
+	}
+	return item, nil
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestDeletedAtInput(t *testing.T) {
+	repo := &ClientRepository{tableName: "clients"}
+	at := time.Date(2024, time.March, 4, 15, 0, 0, 0, time.UTC)
+	placeholder := regexp.MustCompile(`[:#]\w+`)
+
+	tests := []struct {
+		name       string
+		at         *time.Time
+		expression string
+		condition  string
+	}{
+		{"archive", &at, "SET #deletedAt = :deletedAt, #lastUpdateAt = :lastUpdateAt", "attribute_exists(sortKey) AND attribute_not_exists(#deletedAt)"},
+		{"restore", nil, "REMOVE #deletedAt SET #lastUpdateAt = :lastUpdateAt", "attribute_exists(#deletedAt)"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			input, err := repo.deletedAtInput("creator@test.com", "id", tc.at, at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expression := aws.StringValue(input.UpdateExpression)
+
+			if expression != tc.expression || aws.StringValue(input.ConditionExpression) != tc.condition {
+				t.Fatalf("unexpected expressions: '%s', '%s'", expression, aws.StringValue(input.ConditionExpression))
+			}
+			if strings.Count(expression, "SET") != 1 {
+				t.Fatalf("expected a single SET clause, got: '%s'", expression)
+			}
+			// dynamo rejects names and values that are missing or not used by the expressions.
+			used := make(map[string]bool)
+			for _, p := range placeholder.FindAllString(expression+" "+tc.condition, -1) {
+				used[p] = true
+			}
+			for name := range input.ExpressionAttributeNames {
+				if !used[name] {
+					t.Errorf("name '%s' is not used", name)
+				}
+				delete(used, name)
+			}
+			for value := range input.ExpressionAttributeValues {
+				if !used[value] {
+					t.Errorf("value '%s' is not used", value)
+				}
+				delete(used, value)
+			}
+			if len(used) != 0 {
+				t.Errorf("placeholders without a name or value: %v", used)
+			}
+		})
+	}
+}
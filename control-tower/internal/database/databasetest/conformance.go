@@ -0,0 +1,888 @@
+// databasetest package has the conformance suites every repository implementation must pass.
+// each suite works on a random creator so it can run against shared tables.
+package databasetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/japb1998/control-tower/internal/database"
+	"github.com/japb1998/control-tower/internal/model"
+	"github.com/japb1998/control-tower/internal/service"
+)
+
+func newCreator() string {
+	return "conformance-" + uuid.New().String()
+}
+
+// ClientRepository checks the filter, pagination, not found and empty update semantics of a client repository.
+func ClientRepository(t *testing.T, repo service.ClientRepository) {
+	creator := newCreator()
+	lastSeen := time.Now().UTC().Truncate(time.Second)
+
+	clients := []*model.ClientItem{
+		model.NewClientItem(creator, "+17860000001", "ana@test.com", "Ana", "Perez", "", &lastSeen, nil),
+		model.NewClientItem(creator, "+17860000002", "bea@test.com", "Bea", "Lopez", "", nil, []int8{0}),
+		model.NewClientItem(creator, "+17860000003", "carla@test.com", "Carla", "Lopez", "", nil, nil),
+	}
+	for _, c := range clients {
+		if _, err := repo.CreateClient(*c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		for _, c := range clients {
+			repo.DeleteClient(creator, c.SortKey)
+		}
+	})
+	ids := make([]string, 0, len(clients))
+	for _, c := range clients {
+		ids = append(ids, c.SortKey)
+	}
+	sort.Strings(ids)
+
+	t.Run("get by id", func(t *testing.T) {
+		c, err := repo.GetClientById(creator, clients[0].SortKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c == nil || c.FirstName != "Ana" || c.Phone != "+17860000001" || !c.OptIn {
+			t.Fatalf("unexpected client: %+v", c)
+		}
+		if c.LastSeen == nil || !c.LastSeen.Equal(lastSeen) {
+			t.Fatalf("expected lastSeen='%s', got: %v", lastSeen, c.LastSeen)
+		}
+
+		missing, err := repo.GetClientById(creator, uuid.New().String())
+		if err != nil || missing != nil {
+			t.Fatalf("expected nil client without error, got: %v, %v", missing, err)
+		}
+	})
+
+	t.Run("get by creator", func(t *testing.T) {
+		list, err := repo.GetClientsByCreator(creator)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := clientIds(list); !equal(got, ids) {
+			t.Fatalf("expected clients %v, got: %v", ids, got)
+		}
+
+		other, err := repo.GetClientsByCreator(newCreator())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(other) != 0 {
+			t.Fatalf("expected no clients for another creator, got: %d", len(other))
+		}
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			filter database.PatchClientItem
+			want   []string
+		}{
+			{"no filters", database.PatchClientItem{}, ids},
+			{"phone", database.PatchClientItem{Phone: "+17860000002"}, []string{clients[1].SortKey}},
+			{"contains", database.PatchClientItem{LastName: "Lop"}, []string{clients[1].SortKey, clients[2].SortKey}},
+			{"any filter", database.PatchClientItem{FirstName: "Ana", Email: "carla"}, []string{clients[0].SortKey, clients[2].SortKey}},
+			{"lastSeen", database.PatchClientItem{LastSeen: &lastSeen}, []string{clients[0].SortKey}},
+			{"no match", database.PatchClientItem{FirstName: "Zoe"}, nil},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				want := append([]string(nil), tc.want...)
+				sort.Strings(want)
+
+				list, err := repo.GetClientWithFilters(creator, tc.filter, &database.PaginationOps{Limit: 10})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := clientIds(list); !equal(got, want) {
+					t.Fatalf("expected clients %v, got: %v", want, got)
+				}
+
+				count, err := repo.ClientCountWithFilters(creator, tc.filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if count != int64(len(want)) {
+					t.Fatalf("expected count %d, got: %d", len(want), count)
+				}
+			})
+		}
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		pages := []struct {
+			ops  database.PaginationOps
+			want []string
+		}{
+			{database.PaginationOps{Limit: 2, Skip: 0}, ids[:2]},
+			{database.PaginationOps{Limit: 2, Skip: 2}, ids[2:]},
+			{database.PaginationOps{Limit: 2, Skip: 4}, nil},
+		}
+		for _, p := range pages {
+			list, err := repo.GetClientWithFilters(creator, database.PatchClientItem{}, &p.ops)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := clientIds(list); !equal(got, p.want) {
+				t.Fatalf("page %+v expected %v, got: %v", p.ops, p.want, got)
+			}
+		}
+	})
+
+	t.Run("walk", func(t *testing.T) {
+		walked := make([]model.ClientItem, 0)
+		err := repo.WalkClientsWithFilters(creator, database.PatchClientItem{LastName: "Lop"}, func(c model.ClientItem) error {
+			walked = append(walked, c)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{clients[1].SortKey, clients[2].SortKey}
+		sort.Strings(want)
+
+		if got := clientIds(walked); !equal(got, want) {
+			t.Fatalf("expected to walk the Lopez clients, got: %v", got)
+		}
+
+		stop := errors.New("stop")
+		calls := 0
+		err = repo.WalkClientsWithFilters(creator, database.PatchClientItem{}, func(model.ClientItem) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) || calls != 1 {
+			t.Fatalf("expected the walk to stop at the first error, got: %d calls, %v", calls, err)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		if _, err := repo.UpdateUser(creator, clients[1].SortKey, database.PatchClientItem{}); !errors.Is(err, database.ErrEmptyUpdate) {
+			t.Fatalf("expected ErrEmptyUpdate, got: %v", err)
+		}
+		if _, err := repo.UpdateUser(creator, uuid.New().String(), database.PatchClientItem{FirstName: "Nobody"}); !errors.Is(err, database.ErrClientNotFound) {
+			t.Fatalf("expected ErrClientNotFound, got: %v", err)
+		}
+
+		optIn := false
+		item, err := repo.UpdateUser(creator, clients[1].SortKey, database.PatchClientItem{FirstName: "Beatriz", OptIn: &optIn, DeliveryMethods: []int8{0, 1}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.FirstName != "Beatriz" || item.LastName != "Lopez" || item.OptIn || len(item.DeliveryMethods) != 2 || item.LastUpdateAt.IsZero() {
+			t.Fatalf("unexpected updated client: %+v", item)
+		}
+
+		stored, err := repo.GetClientById(creator, clients[1].SortKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.FirstName != "Beatriz" || stored.OptIn {
+			t.Fatalf("update was not stored: %+v", stored)
+		}
+
+		disabled := []int8{1}
+		item, err = repo.UpdateUser(creator, clients[1].SortKey, database.PatchClientItem{DisabledChannels: &disabled})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(item.DisabledChannels) != 1 || item.DisabledChannels[0] != 1 || item.FirstName != "Beatriz" {
+			t.Fatalf("unexpected disabled channels: %+v", item)
+		}
+		enabled := []int8{}
+		if _, err := repo.UpdateUser(creator, clients[1].SortKey, database.PatchClientItem{DisabledChannels: &enabled}); err != nil {
+			t.Fatal(err)
+		}
+		stored, err = repo.GetClientById(creator, clients[1].SortKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stored.DisabledChannels) != 0 {
+			t.Fatalf("expected every channel to be enabled: %+v", stored)
+		}
+
+		item, err = repo.UpdateUser(creator, clients[1].SortKey, database.PatchClientItem{Language: "es", TimeZone: "Europe/Madrid"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.Language != "es" || item.TimeZone != "Europe/Madrid" || item.FirstName != "Beatriz" {
+			t.Fatalf("unexpected language: %+v", item)
+		}
+	})
+
+	t.Run("batch create", func(t *testing.T) {
+		batch := make([]model.ClientItem, 0, 30)
+		for i := 0; i < cap(batch); i++ {
+			batch = append(batch, *model.NewClientItem(creator, "", "", "Batch", "Client", "", &lastSeen, nil))
+		}
+		t.Cleanup(func() {
+			for _, c := range batch {
+				repo.DeleteClient(creator, c.SortKey)
+			}
+		})
+
+		failed, err := repo.CreateClients(batch)
+		if err != nil || len(failed) != 0 {
+			t.Fatalf("expected every client to be created, got: %d failed, %v", len(failed), err)
+		}
+		count, err := repo.ClientCountWithFilters(creator, database.PatchClientItem{FirstName: "Batch"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != int64(len(batch)) {
+			t.Fatalf("expected %d batch clients, got: %d", len(batch), count)
+		}
+	})
+
+	t.Run("archive", func(t *testing.T) {
+		archived := model.NewClientItem(creator, "+17860000009", "", "Archived", "Client", "", &lastSeen, nil)
+		if _, err := repo.CreateClient(*archived); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { repo.DeleteClient(creator, archived.SortKey) })
+
+		if _, err := repo.RestoreClient(creator, archived.SortKey); !errors.Is(err, database.ErrClientNotFound) {
+			t.Fatalf("expected restoring an active client to fail with ErrClientNotFound, got: %v", err)
+		}
+		if err := repo.ArchiveClient(creator, archived.SortKey, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.ArchiveClient(creator, archived.SortKey, time.Now()); !errors.Is(err, database.ErrClientNotFound) {
+			t.Fatalf("expected archiving twice to fail with ErrClientNotFound, got: %v", err)
+		}
+		if err := repo.ArchiveClient(creator, uuid.New().String(), time.Now()); !errors.Is(err, database.ErrClientNotFound) {
+			t.Fatalf("expected archiving a missing client to fail with ErrClientNotFound, got: %v", err)
+		}
+
+		// archived clients are hidden from every read and can't be updated.
+		if c, err := repo.GetClientById(creator, archived.SortKey); err != nil || c != nil {
+			t.Fatalf("expected archived client to be hidden, got: %v, %v", c, err)
+		}
+		list, err := repo.GetClientsByCreator(creator)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := clientIds(list); !equal(got, ids) {
+			t.Fatalf("expected clients %v, got: %v", ids, got)
+		}
+		count, err := repo.ClientCountWithFilters(creator, database.PatchClientItem{Phone: archived.Phone})
+		if err != nil || count != 0 {
+			t.Fatalf("expected archived client to be filtered out, got: %d, %v", count, err)
+		}
+		if _, err := repo.UpdateUser(creator, archived.SortKey, database.PatchClientItem{FirstName: "Nobody"}); !errors.Is(err, database.ErrClientNotFound) {
+			t.Fatalf("expected ErrClientNotFound updating an archived client, got: %v", err)
+		}
+		archivedList, err := repo.GetArchivedClients(creator)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(archivedList) != 1 || archivedList[0].SortKey != archived.SortKey || archivedList[0].DeletedAt == nil {
+			t.Fatalf("expected the archived client, got: %+v", archivedList)
+		}
+
+		restored, err := repo.RestoreClient(creator, archived.SortKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if restored.DeletedAt != nil || restored.FirstName != "Archived" {
+			t.Fatalf("unexpected restored client: %+v", restored)
+		}
+		if c, err := repo.GetClientById(creator, archived.SortKey); err != nil || c == nil {
+			t.Fatalf("expected restored client to be visible, got: %v, %v", c, err)
+		}
+	})
+
+	t.Run("by contact", func(t *testing.T) {
+		// contacts are unique per run, the dynamo table is shared between runs.
+		phone := fmt.Sprintf("+1305%07d", uuid.New().ID()%10000000)
+		email := uuid.New().String() + "@test.com"
+		other := newCreator()
+		sameCreator := model.NewClientItem(creator, phone, email, "Phone", "One", "", nil, nil)
+		otherCreator := model.NewClientItem(other, phone, email, "Phone", "Two", "", nil, nil)
+		archived := model.NewClientItem(creator, phone, email, "Phone", "Archived", "", nil, nil)
+
+		for _, c := range []*model.ClientItem{sameCreator, otherCreator, archived} {
+			if _, err := repo.CreateClient(*c); err != nil {
+				t.Fatal(err)
+			}
+			c := c
+			t.Cleanup(func() { repo.DeleteClient(c.PrimaryKey, c.SortKey) })
+		}
+		if err := repo.ArchiveClient(creator, archived.SortKey, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+
+		for name, get := range map[string]func() ([]model.ClientItem, error){
+			"phone": func() ([]model.ClientItem, error) { return repo.GetClientsByPhone(phone) },
+			"email": func() ([]model.ClientItem, error) { return repo.GetClientsByEmail(email) },
+		} {
+			list, err := get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			found := make(map[string]string, len(list))
+			for _, c := range list {
+				found[c.SortKey] = c.PrimaryKey
+			}
+			if len(list) != 2 || found[sameCreator.SortKey] != creator || found[otherCreator.SortKey] != other {
+				t.Fatalf("expected the clients of both creators by %s, got: %+v", name, list)
+			}
+		}
+		if list, err := repo.GetClientsByPhone("+13050000000"); err != nil || len(list) != 0 {
+			t.Fatalf("expected no clients, got: %v, %v", list, err)
+		}
+		if list, err := repo.GetClientsByEmail("nobody@test.com"); err != nil || len(list) != 0 {
+			t.Fatalf("expected no clients, got: %v, %v", list, err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := repo.DeleteClient(creator, clients[2].SortKey); err != nil {
+			t.Fatal(err)
+		}
+		if c, err := repo.GetClientById(creator, clients[2].SortKey); err != nil || c != nil {
+			t.Fatalf("expected deleted client to be gone, got: %v, %v", c, err)
+		}
+		if err := repo.DeleteClient(creator, uuid.New().String()); err != nil {
+			t.Fatalf("expected deleting a missing client to succeed, got: %s", err)
+		}
+	})
+}
+
+// NotificationRepository checks the ordering, pagination, not found and empty update semantics of a notification repository.
+func NotificationRepository(t *testing.T, repo service.NotificationRepository) {
+	creator := newCreator()
+	now := time.Now().UTC().Truncate(time.Second)
+	clientId := uuid.New().String()
+
+	notifications := []*model.NotificationItem{
+		model.NewNotificationItem(creator, "NOT_SENT", clientId, now.Add(time.Hour*48), []int8{0}),
+		model.NewNotificationItem(creator, "NOT_SENT", uuid.New().String(), now.Add(time.Hour*24), []int8{1}),
+		model.NewNotificationItem(creator, "NOT_SENT", clientId, now.Add(time.Hour*72), []int8{0, 1}),
+	}
+	for _, n := range notifications {
+		if err := repo.Create(*n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		for _, n := range notifications {
+			repo.Delete(creator, n.SortKey)
+		}
+	})
+	// ordered by date
+	byDate := []string{notifications[1].SortKey, notifications[0].SortKey, notifications[2].SortKey}
+
+	t.Run("get", func(t *testing.T) {
+		n, err := repo.GetNotification(creator, notifications[0].SortKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n.ClientId != clientId || n.Status != "NOT_SENT" || n.ClientToken != notifications[0].ClientToken {
+			t.Fatalf("unexpected notification: %+v", n)
+		}
+
+		if _, err := repo.GetNotification(creator, uuid.New().String()); !errors.Is(err, database.ErrNotificationNotFound) {
+			t.Fatalf("expected ErrNotificationNotFound, got: %v", err)
+		}
+	})
+
+	t.Run("by creator", func(t *testing.T) {
+		if _, err := repo.GetNotificationsByCreator(creator, nil); err == nil {
+			t.Fatal("expected an error without pagination")
+		}
+		pages := []struct {
+			ops  database.PaginationOps
+			want []string
+		}{
+			{database.PaginationOps{Limit: 10}, byDate},
+			{database.PaginationOps{Limit: 2, Skip: 0}, byDate[:2]},
+			{database.PaginationOps{Limit: 2, Skip: 2}, byDate[2:]},
+			{database.PaginationOps{Limit: 2, Skip: 4}, nil},
+		}
+		for _, p := range pages {
+			res, err := repo.GetNotificationsByCreator(creator, &p.ops)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Total != int64(len(notifications)) {
+				t.Fatalf("expected total %d, got: %d", len(notifications), res.Total)
+			}
+			if got := notificationIds(res.Data); !equal(got, p.want) {
+				t.Fatalf("page %+v expected %v, got: %v", p.ops, p.want, got)
+			}
+		}
+	})
+
+	t.Run("by client", func(t *testing.T) {
+		list, err := repo.GetNotificationsByClient(creator, clientId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := notificationIds(list)
+		sort.Strings(got)
+		want := []string{notifications[0].SortKey, notifications[2].SortKey}
+		sort.Strings(want)
+
+		if !equal(got, want) {
+			t.Fatalf("expected %v, got: %v", want, got)
+		}
+	})
+
+	t.Run("set status", func(t *testing.T) {
+		if err := repo.SetStatus(creator, notifications[1].SortKey, "SENT"); err != nil {
+			t.Fatal(err)
+		}
+		n, err := repo.GetNotification(creator, notifications[1].SortKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n.Status != "SENT" {
+			t.Fatalf("expected status SENT, got: %s", n.Status)
+		}
+
+		if err := repo.SetStatus(creator, uuid.New().String(), "SENT"); !errors.Is(err, database.ErrNotificationNotFound) {
+			t.Fatalf("expected ErrNotificationNotFound, got: %v", err)
+		}
+	})
+
+	t.Run("change status", func(t *testing.T) {
+		id := notifications[1].SortKey
+		if err := repo.ChangeStatus(creator, id, "NOT_SENT", "SENDING"); !errors.Is(err, database.ErrStatusChanged) {
+			t.Fatalf("expected ErrStatusChanged, got: %v", err)
+		}
+		if err := repo.ChangeStatus(creator, id, "SENT", "FAILED"); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := repo.GetNotification(creator, id); err != nil || n.Status != "FAILED" {
+			t.Fatalf("expected status FAILED, got: %+v, %v", n, err)
+		}
+		if err := repo.ChangeStatus(creator, id, "FAILED", "SENT"); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.ChangeStatus(creator, uuid.New().String(), "NOT_SENT", "SENDING"); !errors.Is(err, database.ErrNotificationNotFound) {
+			t.Fatalf("expected ErrNotificationNotFound, got: %v", err)
+		}
+	})
+
+	t.Run("by status", func(t *testing.T) {
+		list, err := repo.GetNotificationsByStatus("NOT_SENT")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// other creators can share the table.
+		got := make([]string, 0)
+		for _, n := range list {
+			if n.PartitionKey == creator {
+				got = append(got, n.SortKey)
+			}
+		}
+		sort.Strings(got)
+		want := []string{notifications[0].SortKey, notifications[2].SortKey}
+		sort.Strings(want)
+
+		if !equal(got, want) {
+			t.Fatalf("expected %v, got: %v", want, got)
+		}
+	})
+
+	t.Run("update notification", func(t *testing.T) {
+		if _, err := repo.UpdateNotification(creator, notifications[0].SortKey, database.PatchNotificationItem{}); !errors.Is(err, database.ErrEmptyUpdate) {
+			t.Fatalf("expected ErrEmptyUpdate, got: %v", err)
+		}
+		if _, err := repo.UpdateNotification(creator, uuid.New().String(), database.PatchNotificationItem{Status: "SENT"}); !errors.Is(err, database.ErrNotificationNotFound) {
+			t.Fatalf("expected ErrNotificationNotFound, got: %v", err)
+		}
+
+		date := now.Add(time.Hour * 96)
+		item, err := repo.UpdateNotification(creator, notifications[0].SortKey, database.PatchNotificationItem{Date: date, DeliveryMethods: []int8{1}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored, err := time.Parse(time.RFC3339, item.Date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !stored.Equal(date) || item.TTL != date.Add(time.Hour*24).Unix() || len(item.DeliveryMethods) != 1 || item.DeliveryMethods[0] != 1 {
+			t.Fatalf("unexpected updated notification: %+v", item)
+		}
+		if item.ClientId != clientId || item.ClientToken != notifications[0].ClientToken {
+			t.Fatalf("update changed fields that were not in the patch: %+v", item)
+		}
+
+		// recurring notifications without an end do not expire.
+		item, err = repo.UpdateNotification(creator, notifications[0].SortKey, database.PatchNotificationItem{Recurrence: &model.Recurrence{Frequency: "WEEKLY", Interval: 2}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.Recurrence == nil || item.Recurrence.Interval != 2 || item.TTL != 0 {
+			t.Fatalf("unexpected recurring notification: %+v", item)
+		}
+
+		item, err = repo.UpdateNotification(creator, notifications[0].SortKey, database.PatchNotificationItem{TemplateId: "template-1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.TemplateId != "template-1" || item.Recurrence == nil {
+			t.Fatalf("unexpected notification template: %+v", item)
+		}
+
+		item, err = repo.UpdateNotification(creator, notifications[0].SortKey, database.PatchNotificationItem{Subject: "See you soon", Message: "Hi {{firstName}}", TimeZone: "America/Chicago"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.Subject != "See you soon" || item.Message != "Hi {{firstName}}" || item.TimeZone != "America/Chicago" || item.TemplateId != "template-1" {
+			t.Fatalf("unexpected notification message: %+v", item)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		missing := *model.NewNotificationItem(creator, "NOT_SENT", clientId, now, nil)
+		if _, err := repo.Update(missing); !errors.Is(err, database.ErrNotificationNotFound) {
+			t.Fatalf("expected ErrNotificationNotFound, got: %v", err)
+		}
+
+		item := *notifications[2]
+		item.Occurrences = []model.OccurrenceItem{{Number: 1, Date: item.Date, SentAt: now.Format(time.RFC3339), Status: "SENT"}}
+		item.Deliveries = []model.DeliveryItem{{Channel: 1, Status: "sent", MessageId: "msg-1", Attempt: 1, StartedAt: now.Format(time.RFC3339), FinishedAt: now.Format(time.RFC3339)}}
+		if _, err := repo.Update(item); err != nil {
+			t.Fatal(err)
+		}
+		stored, err := repo.GetNotification(creator, item.SortKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stored.Occurrences) != 1 || stored.Occurrences[0].Status != "SENT" || len(stored.Deliveries) != 1 || stored.Deliveries[0].MessageId != "msg-1" {
+			t.Fatalf("update was not stored: %+v", stored)
+		}
+
+		// an item read before the last write is not stored.
+		if _, err := repo.Update(item); !errors.Is(err, database.ErrVersionConflict) {
+			t.Fatalf("expected ErrVersionConflict, got: %v", err)
+		}
+		if err := repo.SetStatus(creator, item.SortKey, "FAILED"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.Update(*stored); !errors.Is(err, database.ErrVersionConflict) {
+			t.Fatalf("expected ErrVersionConflict after a status change, got: %v", err)
+		}
+	})
+
+	t.Run("outbox", func(t *testing.T) {
+		item := model.NewNotificationItem(creator, "NOT_SENT", clientId, now.Add(time.Hour*24), []int8{2})
+		created := model.NewOutboxItem("create", creator, item.SortKey, item.ClientToken)
+		created.CreatedAt = now.Format(time.RFC3339Nano)
+
+		if err := repo.CreateWithOutbox(*item, *created); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			repo.Delete(creator, item.SortKey)
+		})
+		if _, err := repo.GetNotification(creator, item.SortKey); err != nil {
+			t.Fatalf("expected the notification to be stored, got: %v", err)
+		}
+
+		// entries of failed updates are not stored.
+		missing := model.NewOutboxItem("update", creator, uuid.New().String(), "")
+		if _, err := repo.UpdateNotificationWithOutbox(creator, missing.NotificationId, database.PatchNotificationItem{Subject: "hi"}, *missing); !errors.Is(err, database.ErrNotificationNotFound) {
+			t.Fatalf("expected ErrNotificationNotFound, got: %v", err)
+		}
+		updated := model.NewOutboxItem("update", creator, item.SortKey, item.ClientToken)
+		updated.CreatedAt = now.Add(time.Second).Format(time.RFC3339Nano)
+
+		n, err := repo.UpdateNotificationWithOutbox(creator, item.SortKey, database.PatchNotificationItem{Subject: "hi"}, *updated)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n.Subject != "hi" {
+			t.Fatalf("update was not stored: %+v", n)
+		}
+
+		if err := repo.RecordOutboxAttempt(created.Id, "throttled"); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := repo.GetOutbox(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// other tests can share the outbox.
+		mine := make([]model.OutboxItem, 0)
+		for _, e := range entries {
+			if e.CreatedBy == creator {
+				mine = append(mine, e)
+			}
+		}
+		if len(mine) != 2 || mine[0].Id != created.Id || mine[1].Id != updated.Id {
+			t.Fatalf("expected the entries in write order, got: %+v", mine)
+		}
+		if mine[0].Attempts != 1 || mine[0].LastError != "throttled" || mine[1].Payload != updated.Payload {
+			t.Fatalf("unexpected entries: %+v", mine)
+		}
+
+		for _, id := range []string{created.Id, updated.Id, uuid.New().String()} {
+			if err := repo.DeleteOutbox(id); err != nil {
+				t.Fatal(err)
+			}
+		}
+		entries, err = repo.GetOutbox(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if e.CreatedBy == creator {
+				t.Fatalf("expected the entries to be removed, got: %+v", e)
+			}
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := repo.Delete(creator, notifications[1].SortKey); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.GetNotification(creator, notifications[1].SortKey); !errors.Is(err, database.ErrNotificationNotFound) {
+			t.Fatalf("expected ErrNotificationNotFound, got: %v", err)
+		}
+		if err := repo.Delete(creator, uuid.New().String()); err != nil {
+			t.Fatalf("expected deleting a missing notification to succeed, got: %s", err)
+		}
+	})
+}
+
+// ConnectionRepository checks a connection repository keeps every connection of a user.
+func ConnectionRepository(t *testing.T, repo service.ConnectionRepo) {
+	ctx := context.Background()
+	email := newCreator()
+	conns := []model.Connection{
+		{ConnectionId: uuid.New().String(), Email: email},
+		{ConnectionId: uuid.New().String(), Email: email},
+	}
+	for _, c := range conns {
+		if err := repo.SaveConnection(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		for _, c := range conns {
+			repo.DeleteConnection(ctx, c)
+		}
+	})
+
+	list, err := repo.GetConnectionIds(ctx, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != len(conns) {
+		t.Fatalf("expected %d connections, got: %d", len(conns), len(list))
+	}
+
+	if err := repo.DeleteConnection(ctx, conns[0]); err != nil {
+		t.Fatal(err)
+	}
+	list, err = repo.GetConnectionIds(ctx, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ConnectionId != conns[1].ConnectionId {
+		t.Fatalf("expected only connection '%s', got: %v", conns[1].ConnectionId, list)
+	}
+
+	other, err := repo.GetConnectionIds(ctx, newCreator())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(other) != 0 {
+		t.Fatalf("expected no connections for another user, got: %d", len(other))
+	}
+}
+
+// SettingsRepository checks a settings repository returns nil for creators without settings.
+func SettingsRepository(t *testing.T, repo service.SettingsRepository) {
+	creator := newCreator()
+
+	s, err := repo.GetSettings(creator)
+	if err != nil || s != nil {
+		t.Fatalf("expected nil settings without error, got: %v, %v", s, err)
+	}
+
+	item := model.NewSettingsItem(creator, 3)
+	item.DefaultLanguage = "es"
+	item.TimeZone = "America/Chicago"
+	if err := repo.SaveSettings(*item); err != nil {
+		t.Fatal(err)
+	}
+	s, err = repo.GetSettings(creator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s == nil || s.MaintenanceIntervalWeeks != 3 || s.DefaultLanguage != "es" || s.TimeZone != "America/Chicago" {
+		t.Fatalf("unexpected settings: %+v", s)
+	}
+}
+
+func clientIds(items []model.ClientItem) []string {
+	ids := make([]string, 0, len(items))
+	for _, c := range items {
+		ids = append(ids, c.SortKey)
+	}
+	return ids
+}
+
+func notificationIds(items []model.NotificationItem) []string {
+	ids := make([]string, 0, len(items))
+	for _, n := range items {
+		ids = append(ids, n.SortKey)
+	}
+	return ids
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// MessageRepository checks the messages of a thread are returned in send order, that threads don't mix and that a sort key is only stored once.
+func MessageRepository(t *testing.T, repo service.MessageRepository) {
+	creator := newCreator()
+	clientId, otherId := uuid.New().String(), uuid.New().String()
+	now := time.Now()
+
+	messages := []*model.MessageItem{
+		model.NewMessageItem(creator, clientId, model.MessageOutbound, 0, "", "reminder", now.Add(-time.Hour)),
+		model.NewMessageItem(creator, clientId, model.MessageInbound, 0, "", "SI", now.Add(-time.Minute)),
+		model.NewMessageItem(creator, clientId, model.MessageOutbound, 1, "Re: reminder", "see you", now),
+		model.NewMessageItem(creator, otherId, model.MessageInbound, 0, "", "hello", now),
+	}
+	messages[0].NotificationId = uuid.New().String()
+	messages[0].MessageId = "SM1"
+
+	// saved out of order, threads are sorted by date.
+	for _, i := range []int{2, 0, 3, 1} {
+		if err := repo.SaveMessage(*messages[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	thread, err := repo.GetMessages(creator, clientId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thread) != 3 {
+		t.Fatalf("expected 3 messages, got: %+v", thread)
+	}
+	for i, m := range thread {
+		if m.Id != messages[i].Id {
+			t.Fatalf("expected message %d to be '%s', got: '%s'", i, messages[i].Body, m.Body)
+		}
+	}
+	if first := thread[0]; first.NotificationId != messages[0].NotificationId || first.MessageId != "SM1" || first.Direction != model.MessageOutbound || !first.CreatedAt.Equal(messages[0].CreatedAt) {
+		t.Fatalf("unexpected message: %+v", first)
+	}
+	if thread[2].Subject != "Re: reminder" || thread[2].Channel != 1 {
+		t.Fatalf("unexpected message: %+v", thread[2])
+	}
+
+	// messages are only stored once.
+	duplicate := *messages[1]
+	duplicate.Body = "SI SI"
+	if err := repo.SaveMessage(duplicate); !errors.Is(err, database.ErrMessageExists) {
+		t.Fatalf("expected ErrMessageExists, got: %v", err)
+	}
+	if thread, err := repo.GetMessages(creator, clientId); err != nil || len(thread) != 3 || thread[1].Body != "SI" {
+		t.Fatalf("expected the first message to be kept, got: %+v, %v", thread, err)
+	}
+
+	empty, err := repo.GetMessages(creator, uuid.New().String())
+	if err != nil || len(empty) != 0 {
+		t.Fatalf("expected an empty thread, got: %v, %v", empty, err)
+	}
+}
+
+// TemplateRepository checks the save, replace, list and delete semantics of a template repository.
+func TemplateRepository(t *testing.T, repo service.TemplateRepository) {
+	creator := newCreator()
+
+	templates := []*model.TemplateItem{
+		model.NewTemplateItem(creator, "maintenance", 1, "{{weeks}} weeks", "Hi {{firstName}}", "lashroom"),
+		model.NewTemplateItem(creator, "text", 2, "", "Hi {{firstName}}, reply STOP to opt out", ""),
+	}
+	templates[0].Language = "en"
+	templates[0].Variants = map[string]model.TemplateVariantItem{
+		"es": {Subject: "{{weeks}} semanas", Body: "Hola {{firstName}}", ProviderTemplateId: "lashroom-es"},
+	}
+	for _, tmpl := range templates {
+		if err := repo.SaveTemplate(*tmpl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		for _, tmpl := range templates {
+			repo.DeleteTemplate(creator, tmpl.SortKey)
+		}
+	})
+
+	item, err := repo.GetTemplate(creator, templates[0].SortKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item == nil || item.Name != "maintenance" || item.Channel != 1 || item.Subject != "{{weeks}} weeks" || item.ProviderTemplateId != "lashroom" || !item.CreatedAt.Equal(templates[0].CreatedAt) {
+		t.Fatalf("unexpected template: %+v", item)
+	}
+	if item.Language != "en" || item.Variants["es"] != templates[0].Variants["es"] {
+		t.Fatalf("unexpected template variants: %+v", item)
+	}
+
+	if missing, err := repo.GetTemplate(creator, uuid.New().String()); err != nil || missing != nil {
+		t.Fatalf("expected no template, got: %v, %v", missing, err)
+	}
+
+	// saving the same id replaces the template.
+	updated := *templates[1]
+	updated.Body = "Hi {{clientName}}"
+	if err := repo.SaveTemplate(updated); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := repo.GetTemplates(creator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 templates, got: %+v", items)
+	}
+	if !sort.SliceIsSorted(items, func(i, j int) bool { return items[i].SortKey < items[j].SortKey }) {
+		t.Fatalf("expected templates sorted by id, got: %+v", items)
+	}
+	for _, i := range items {
+		if i.SortKey == updated.SortKey && i.Body != updated.Body {
+			t.Fatalf("expected the template to be replaced, got: %+v", i)
+		}
+	}
+
+	if err := repo.DeleteTemplate(creator, templates[0].SortKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteTemplate(creator, templates[0].SortKey); err != nil {
+		t.Fatalf("deleting a missing template should not fail, got: %v", err)
+	}
+	if items, err := repo.GetTemplates(creator); err != nil || len(items) != 1 {
+		t.Fatalf("expected 1 template, got: %v, %v", items, err)
+	}
+}
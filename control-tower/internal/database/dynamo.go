@@ -85,3 +85,23 @@ func (dynamodb *DynamoClient) DeleteItem(input *dynamodb.DeleteItemInput) (*dyna
 		return output, nil
 	}
 }
+
+func (dynamodb *DynamoClient) BatchWriteItem(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+
+	if output, err := dynamodb.Client.BatchWriteItem(input); err != nil {
+		log.Println(err.Error())
+		return nil, err
+	} else {
+		return output, nil
+	}
+}
+
+func (dynamodb *DynamoClient) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+
+	if output, err := dynamodb.Client.TransactWriteItems(input); err != nil {
+		log.Println(err.Error())
+		return nil, err
+	} else {
+		return output, nil
+	}
+}
@@ -0,0 +1,51 @@
+package database_test
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/japb1998/control-tower/internal/database"
+	"github.com/japb1998/control-tower/internal/database/databasetest"
+	"github.com/japb1998/control-tower/pkg/awssess"
+	"github.com/joho/godotenv"
+)
+
+// dynamoSession returns a session for the tables in test.env. the suite is skipped unless DYNAMO_CONFORMANCE is set because it needs real tables.
+func dynamoSession(t *testing.T) *session.Session {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	godotenv.Load(path.Join(cwd, "../../test.env"))
+
+	if os.Getenv("DYNAMO_CONFORMANCE") == "" {
+		t.Skip("DYNAMO_CONFORMANCE is not set")
+	}
+	return awssess.MustGetSession()
+}
+
+func TestClientRepository(t *testing.T) {
+	databasetest.ClientRepository(t, database.NewClientRepo(dynamoSession(t)))
+}
+
+func TestNotificationRepository(t *testing.T) {
+	databasetest.NotificationRepository(t, database.NewNotificationRepository(dynamoSession(t)))
+}
+
+func TestConnectionRepository(t *testing.T) {
+	databasetest.ConnectionRepository(t, database.NewConnectionRepo(dynamoSession(t)))
+}
+
+func TestSettingsRepository(t *testing.T) {
+	databasetest.SettingsRepository(t, database.NewSettingsRepo(dynamoSession(t)))
+}
+
+func TestMessageRepository(t *testing.T) {
+	databasetest.MessageRepository(t, database.NewMessageRepo(dynamoSession(t)))
+}
+
+func TestTemplateRepository(t *testing.T) {
+	databasetest.TemplateRepository(t, database.NewTemplateRepo(dynamoSession(t)))
+}
@@ -0,0 +1,328 @@
+// memory package keeps the repositories in process. it follows the same semantics as the dynamo repositories and is used for tests and local runs.
+package memory
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/database"
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+type ClientRepository struct {
+	mu      sync.RWMutex
+	clients map[string]map[string]model.ClientItem // creator -> id -> client
+}
+
+func NewClientRepo() *ClientRepository {
+	return &ClientRepository{
+		clients: make(map[string]map[string]model.ClientItem),
+	}
+}
+
+// GetClientsByCreator returns every client of the creator that is not archived sorted by id.
+func (r *ClientRepository) GetClientsByCreator(createdBy string) ([]model.ClientItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.list(createdBy, func(model.ClientItem) bool { return true }), nil
+}
+
+// GetArchivedClients returns the archived clients of the creator sorted by id.
+func (r *ClientRepository) GetArchivedClients(createdBy string) ([]model.ClientItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	items := make([]model.ClientItem, 0)
+	for _, c := range r.clients[createdBy] {
+		if c.DeletedAt != nil {
+			items = append(items, copyClient(c))
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].SortKey < items[j].SortKey
+	})
+	return items, nil
+}
+
+// ArchiveClient hides the client. it fails with database.ErrClientNotFound when the client does not exist or is already archived.
+func (r *ClientRepository) ArchiveClient(createdBy, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.clients[createdBy][id]
+
+	if !ok || item.DeletedAt != nil {
+		return database.ErrClientNotFound
+	}
+	at = at.UTC().Truncate(time.Second)
+	item.DeletedAt = &at
+	item.LastUpdateAt = time.Now().UTC().Truncate(time.Second)
+	r.clients[createdBy][id] = item
+	return nil
+}
+
+// RestoreClient makes an archived client visible again. it fails with database.ErrClientNotFound when the client is not archived.
+func (r *ClientRepository) RestoreClient(createdBy, id string) (model.ClientItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.clients[createdBy][id]
+
+	if !ok || item.DeletedAt == nil {
+		return model.ClientItem{}, database.ErrClientNotFound
+	}
+	item.DeletedAt = nil
+	item.LastUpdateAt = time.Now().UTC().Truncate(time.Second)
+	r.clients[createdBy][id] = item
+	return copyClient(item), nil
+}
+
+func (r *ClientRepository) ClientCountWithFilters(createdBy string, clientPatch database.PatchClientItem) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return int64(len(r.list(createdBy, clientFilter(clientPatch)))), nil
+}
+
+// GetClientWithFilters returns the clients matching any of the filters. pagination is zero based.
+func (r *ClientRepository) GetClientWithFilters(createdBy string, clientPatch database.PatchClientItem, p *database.PaginationOps) ([]model.ClientItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return paginate(r.list(createdBy, clientFilter(clientPatch)), p), nil
+}
+
+// WalkClientsWithFilters calls fn with every client matching the filters sorted by id.
+func (r *ClientRepository) WalkClientsWithFilters(createdBy string, clientPatch database.PatchClientItem, fn func(model.ClientItem) error) error {
+	r.mu.RLock()
+	clients := r.list(createdBy, clientFilter(clientPatch))
+	r.mu.RUnlock()
+
+	for _, c := range clients {
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ClientRepository) CreateClient(client model.ClientItem) (model.ClientItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.clients[client.PrimaryKey] == nil {
+		r.clients[client.PrimaryKey] = make(map[string]model.ClientItem)
+	}
+	r.clients[client.PrimaryKey][client.SortKey] = copyClient(client)
+
+	return client, nil
+}
+
+// CreateClients stores every client, none of them can fail.
+func (r *ClientRepository) CreateClients(clients []model.ClientItem) ([]model.ClientItem, error) {
+	for _, c := range clients {
+		r.CreateClient(c)
+	}
+	return nil, nil
+}
+
+// UpdateUser applies the non empty fields of the patch. it fails with database.ErrClientNotFound when the client does not exist.
+func (r *ClientRepository) UpdateUser(createdBy string, clientId string, client database.PatchClientItem) (model.ClientItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	empty := client.FirstName == "" && client.LastName == "" && client.Description == "" && client.Phone == "" &&
+		client.Email == "" && client.OptIn == nil && client.LastSeen == nil && len(client.DeliveryMethods) == 0 && client.DisabledChannels == nil && client.Language == "" && client.TimeZone == ""
+
+	if empty {
+		return model.ClientItem{}, database.ErrEmptyUpdate
+	}
+	item, ok := r.clients[createdBy][clientId]
+
+	if !ok || item.DeletedAt != nil {
+		return model.ClientItem{}, database.ErrClientNotFound
+	}
+
+	if client.FirstName != "" {
+		item.FirstName = client.FirstName
+	}
+	if client.LastName != "" {
+		item.LastName = client.LastName
+	}
+	if client.Description != "" {
+		item.Description = client.Description
+	}
+	if client.Phone != "" {
+		item.Phone = client.Phone
+	}
+	if client.Email != "" {
+		item.Email = client.Email
+	}
+	if client.OptIn != nil {
+		item.OptIn = *client.OptIn
+	}
+	if client.LastSeen != nil {
+		lastSeen := *client.LastSeen
+		item.LastSeen = &lastSeen
+	}
+	if len(client.DeliveryMethods) > 0 {
+		item.DeliveryMethods = append([]int8(nil), client.DeliveryMethods...)
+	}
+	if client.DisabledChannels != nil {
+		item.DisabledChannels = append([]int8(nil), *client.DisabledChannels...)
+	}
+	if client.Language != "" {
+		item.Language = client.Language
+	}
+	if client.TimeZone != "" {
+		item.TimeZone = client.TimeZone
+	}
+	item.LastUpdateAt = time.Now().UTC().Truncate(time.Second)
+	r.clients[createdBy][clientId] = item
+
+	return copyClient(item), nil
+}
+
+// DeleteClient removes the client. deleting a client that does not exist is not an error.
+func (r *ClientRepository) DeleteClient(createdBy, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.clients[createdBy], id)
+	return nil
+}
+
+// GetClientById returns nil when the client does not exist or is archived.
+func (r *ClientRepository) GetClientById(createdBy, id string) (*model.ClientItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	item, ok := r.clients[createdBy][id]
+
+	if !ok || item.DeletedAt != nil {
+		return nil, nil
+	}
+	c := copyClient(item)
+	return &c, nil
+}
+
+// GetClientsByPhone returns the clients of every creator with the phone that are not archived, sorted by creator and id.
+func (r *ClientRepository) GetClientsByPhone(phone string) ([]model.ClientItem, error) {
+	return r.listAll(func(c model.ClientItem) bool { return c.Phone == phone }), nil
+}
+
+// GetClientsByEmail returns the clients of every creator with the email that are not archived, sorted by creator and id.
+func (r *ClientRepository) GetClientsByEmail(email string) ([]model.ClientItem, error) {
+	return r.listAll(func(c model.ClientItem) bool { return c.Email == email }), nil
+}
+
+// listAll returns the clients of every creator that are not archived and match, sorted by creator and id.
+func (r *ClientRepository) listAll(match func(model.ClientItem) bool) []model.ClientItem {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	items := make([]model.ClientItem, 0)
+	for _, clients := range r.clients {
+		for _, c := range clients {
+			if c.DeletedAt == nil && match(c) {
+				items = append(items, copyClient(c))
+			}
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].PrimaryKey != items[j].PrimaryKey {
+			return items[i].PrimaryKey < items[j].PrimaryKey
+		}
+		return items[i].SortKey < items[j].SortKey
+	})
+	return items
+}
+
+// list returns the creator clients that are not archived and match the filter sorted by id, the same order as the table sort key.
+func (r *ClientRepository) list(createdBy string, match func(model.ClientItem) bool) []model.ClientItem {
+	items := make([]model.ClientItem, 0, len(r.clients[createdBy]))
+
+	for _, c := range r.clients[createdBy] {
+		if c.DeletedAt == nil && match(c) {
+			items = append(items, copyClient(c))
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].SortKey < items[j].SortKey
+	})
+	return items
+}
+
+// clientFilter matches clients that satisfy at least one of the provided filters. no filters matches every client.
+func clientFilter(f database.PatchClientItem) func(model.ClientItem) bool {
+	return func(c model.ClientItem) bool {
+		filtered := false
+
+		if f.Phone != "" {
+			filtered = true
+			if c.Phone == f.Phone {
+				return true
+			}
+		}
+		if f.Email != "" {
+			filtered = true
+			if strings.Contains(c.Email, f.Email) {
+				return true
+			}
+		}
+		if f.FirstName != "" {
+			filtered = true
+			if strings.Contains(c.FirstName, f.FirstName) {
+				return true
+			}
+		}
+		if f.LastName != "" {
+			filtered = true
+			if strings.Contains(c.LastName, f.LastName) {
+				return true
+			}
+		}
+		if f.LastSeen != nil {
+			filtered = true
+			if c.LastSeen != nil && c.LastSeen.Equal(*f.LastSeen) {
+				return true
+			}
+		}
+		return !filtered
+	}
+}
+
+// paginate returns the page of items described by p. pagination is zero based.
+func paginate[T any](items []T, p *database.PaginationOps) []T {
+	if p == nil {
+		return items
+	}
+	if len(items) <= p.Skip {
+		return make([]T, 0)
+	}
+	if len(items) > p.Skip+p.Limit {
+		return items[p.Skip : p.Skip+p.Limit]
+	}
+	return items[p.Skip:]
+}
+
+func copyClient(c model.ClientItem) model.ClientItem {
+	if c.LastSeen != nil {
+		lastSeen := *c.LastSeen
+		c.LastSeen = &lastSeen
+	}
+	if c.DeliveryMethods != nil {
+		c.DeliveryMethods = append([]int8(nil), c.DeliveryMethods...)
+	}
+	if c.DisabledChannels != nil {
+		c.DisabledChannels = append([]int8(nil), c.DisabledChannels...)
+	}
+	if c.DeletedAt != nil {
+		deletedAt := *c.DeletedAt
+		c.DeletedAt = &deletedAt
+	}
+	return c
+}
@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+type ConnectionRepository struct {
+	mu          sync.RWMutex
+	connections map[string]map[string]model.Connection // email -> connection id -> connection
+}
+
+func NewConnectionRepo() *ConnectionRepository {
+	return &ConnectionRepository{
+		connections: make(map[string]map[string]model.Connection),
+	}
+}
+
+// SaveConnection stores the connection id and the user ID it belongs to.
+func (cr *ConnectionRepository) SaveConnection(ctx context.Context, conn model.Connection) error {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if cr.connections[conn.Email] == nil {
+		cr.connections[conn.Email] = make(map[string]model.Connection)
+	}
+	cr.connections[conn.Email][conn.ConnectionId] = conn
+	return nil
+}
+
+// DeleteConnection - deletes connection. deleting a connection that does not exist is not an error.
+func (cr *ConnectionRepository) DeleteConnection(ctx context.Context, conn model.Connection) error {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	delete(cr.connections[conn.Email], conn.ConnectionId)
+	return nil
+}
+
+// GetConnectionIds search for all connection Ids related to the specified userId.
+func (cr *ConnectionRepository) GetConnectionIds(ctx context.Context, email string) ([]model.Connection, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+
+	var conns []model.Connection
+
+	for _, c := range cr.connections[email] {
+		conns = append(conns, c)
+	}
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].ConnectionId < conns[j].ConnectionId
+	})
+	return conns, nil
+}
@@ -0,0 +1,32 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/japb1998/control-tower/internal/database/databasetest"
+	"github.com/japb1998/control-tower/internal/database/memory"
+)
+
+func TestClientRepository(t *testing.T) {
+	databasetest.ClientRepository(t, memory.NewClientRepo())
+}
+
+func TestNotificationRepository(t *testing.T) {
+	databasetest.NotificationRepository(t, memory.NewNotificationRepo())
+}
+
+func TestConnectionRepository(t *testing.T) {
+	databasetest.ConnectionRepository(t, memory.NewConnectionRepo())
+}
+
+func TestSettingsRepository(t *testing.T) {
+	databasetest.SettingsRepository(t, memory.NewSettingsRepo())
+}
+
+func TestMessageRepository(t *testing.T) {
+	databasetest.MessageRepository(t, memory.NewMessageRepo())
+}
+
+func TestTemplateRepository(t *testing.T) {
+	databasetest.TemplateRepository(t, memory.NewTemplateRepo())
+}
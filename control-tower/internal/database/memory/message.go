@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/japb1998/control-tower/internal/database"
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+type MessageRepository struct {
+	mu       sync.RWMutex
+	messages map[string][]model.MessageItem // thread key -> messages
+}
+
+func NewMessageRepo() *MessageRepository {
+	return &MessageRepository{
+		messages: make(map[string][]model.MessageItem),
+	}
+}
+
+// SaveMessage stores the message in the thread of its client, it fails with database.ErrMessageExists when the thread has a message with the sort key.
+func (r *MessageRepository) SaveMessage(message model.MessageItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	thread := r.messages[message.PrimaryKey]
+	i := sort.Search(len(thread), func(i int) bool {
+		return thread[i].SortKey >= message.SortKey
+	})
+
+	if i < len(thread) && thread[i].SortKey == message.SortKey {
+		return database.ErrMessageExists
+	}
+	thread = append(thread, model.MessageItem{})
+	copy(thread[i+1:], thread[i:])
+	thread[i] = message
+	r.messages[message.PrimaryKey] = thread
+	return nil
+}
+
+// GetMessages returns the thread of the client sorted by sort key.
+func (r *MessageRepository) GetMessages(createdBy, clientId string) ([]model.MessageItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append(make([]model.MessageItem, 0), r.messages[model.MessageThreadKey(createdBy, clientId)]...), nil
+}
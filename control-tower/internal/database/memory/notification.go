@@ -0,0 +1,268 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/database"
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+type NotificationRepository struct {
+	mu            sync.RWMutex
+	notifications map[string]map[string]model.NotificationItem // creator -> id -> notification
+	outbox        map[string]model.OutboxItem                  // id -> entry
+}
+
+func NewNotificationRepo() *NotificationRepository {
+	return &NotificationRepository{
+		notifications: make(map[string]map[string]model.NotificationItem),
+		outbox:        make(map[string]model.OutboxItem),
+	}
+}
+
+func (r *NotificationRepository) Create(notification model.NotificationItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.notifications[notification.PartitionKey] == nil {
+		r.notifications[notification.PartitionKey] = make(map[string]model.NotificationItem)
+	}
+	r.notifications[notification.PartitionKey][notification.SortKey] = copyNotification(notification)
+	return nil
+}
+
+// Update replaces an existing notification item when it still has the version of the item, the returned item has the new version.
+func (r *NotificationRepository) Update(notification model.NotificationItem) (model.NotificationItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.notifications[notification.PartitionKey][notification.SortKey]
+
+	if !ok {
+		return model.NotificationItem{}, database.ErrNotificationNotFound
+	}
+	if current.Version != notification.Version {
+		return model.NotificationItem{}, database.ErrVersionConflict
+	}
+	notification.Version++
+	r.notifications[notification.PartitionKey][notification.SortKey] = copyNotification(notification)
+	return notification, nil
+}
+
+// Delete removes the notification. deleting a notification that does not exist is not an error.
+func (r *NotificationRepository) Delete(createdBy, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.notifications[createdBy], name)
+	return nil
+}
+
+func (r *NotificationRepository) GetNotification(createdBy, name string) (*model.NotificationItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	item, ok := r.notifications[createdBy][name]
+
+	if !ok {
+		return nil, database.ErrNotificationNotFound
+	}
+	n := copyNotification(item)
+	return &n, nil
+}
+
+func (r *NotificationRepository) GetNotificationCountByCreator(createdBy string) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return int64(len(r.notifications[createdBy])), nil
+}
+
+// GetNotificationsByCreator gets notification by its creator sorted by date. pagination is Zero based.
+func (r *NotificationRepository) GetNotificationsByCreator(createdBy string, ops *database.PaginationOps) (*database.PaginatedNotifications, error) {
+	if ops == nil {
+		return nil, fmt.Errorf("Pagination ops is not optional")
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	items := r.list(createdBy, func(model.NotificationItem) bool { return true })
+	// same order as the DATE index.
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Date < items[j].Date
+	})
+
+	return &database.PaginatedNotifications{
+		Total: int64(len(items)),
+		Data:  paginate(items, ops),
+	}, nil
+}
+
+// GetNotificationsByClient returns every notification of a creator that belongs to the client.
+func (r *NotificationRepository) GetNotificationsByClient(createdBy, clientId string) ([]model.NotificationItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.list(createdBy, func(n model.NotificationItem) bool { return n.ClientId == clientId }), nil
+}
+
+// GetNotificationsByStatus returns the notifications of every creator with the status sorted by creator and id.
+func (r *NotificationRepository) GetNotificationsByStatus(status string) ([]model.NotificationItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	creators := make([]string, 0, len(r.notifications))
+
+	for createdBy := range r.notifications {
+		creators = append(creators, createdBy)
+	}
+	sort.Strings(creators)
+	items := make([]model.NotificationItem, 0)
+
+	for _, createdBy := range creators {
+		items = append(items, r.list(createdBy, func(n model.NotificationItem) bool { return n.Status == status })...)
+	}
+	return items, nil
+}
+
+func (r *NotificationRepository) SetStatus(partitionKey string, sortKey string, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.notifications[partitionKey][sortKey]
+
+	if !ok {
+		return database.ErrNotificationNotFound
+	}
+	item.Status = status
+	item.Version++
+	r.notifications[partitionKey][sortKey] = item
+	return nil
+}
+
+// ChangeStatus sets the status only when the notification has the from status, it fails with database.ErrStatusChanged otherwise.
+func (r *NotificationRepository) ChangeStatus(partitionKey, sortKey, from, to string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.notifications[partitionKey][sortKey]
+
+	if !ok {
+		return database.ErrNotificationNotFound
+	}
+	if item.Status != from {
+		return database.ErrStatusChanged
+	}
+	item.Status = to
+	item.Version++
+	r.notifications[partitionKey][sortKey] = item
+	return nil
+}
+
+// UpdateNotification applies the non empty fields of the patch, the same way the dynamo repository does.
+func (r *NotificationRepository) UpdateNotification(createdBy, name string, notification database.PatchNotificationItem) (*model.NotificationItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.update(createdBy, name, notification)
+}
+
+// update applies the patch, the caller holds the lock.
+func (r *NotificationRepository) update(createdBy, name string, notification database.PatchNotificationItem) (*model.NotificationItem, error) {
+	empty := notification.ClientId == "" && notification.Status == "" && notification.Date.IsZero() &&
+		notification.TTL == 0 && notification.Recurrence == nil && notification.DeliveryMethods == nil && notification.TemplateId == "" &&
+		notification.Subject == "" && notification.Message == "" && notification.TimeZone == "" && notification.RequestedDate == nil
+
+	if empty {
+		return &model.NotificationItem{}, database.ErrEmptyUpdate
+	}
+	item, ok := r.notifications[createdBy][name]
+
+	if !ok {
+		return nil, database.ErrNotificationNotFound
+	}
+
+	if notification.ClientId != "" {
+		item.ClientId = notification.ClientId
+	}
+	if notification.Status != "" {
+		item.Status = notification.Status
+	}
+	if !notification.Date.IsZero() {
+		item.Date = notification.Date.Format(time.RFC3339Nano)
+
+		if notification.TTL == 0 && notification.Recurrence == nil {
+			notification.TTL = notification.Date.Add(time.Hour * 24).Unix()
+		}
+	}
+	if notification.TTL != 0 {
+		item.TTL = notification.TTL
+	}
+	if notification.Recurrence != nil {
+		recurrence := *notification.Recurrence
+		item.Recurrence = &recurrence
+
+		if notification.TTL == 0 {
+			// recurring notifications without an end do not expire.
+			item.TTL = 0
+		}
+	}
+	if notification.DeliveryMethods != nil {
+		item.DeliveryMethods = append([]int8(nil), notification.DeliveryMethods...)
+	}
+	if notification.TemplateId != "" {
+		item.TemplateId = notification.TemplateId
+	}
+	if notification.Subject != "" {
+		item.Subject = notification.Subject
+	}
+	if notification.Message != "" {
+		item.Message = notification.Message
+	}
+	if notification.TimeZone != "" {
+		item.TimeZone = notification.TimeZone
+	}
+	if notification.RequestedDate != nil {
+		item.RequestedDate = *notification.RequestedDate
+	}
+	item.Version++
+	r.notifications[createdBy][name] = item
+
+	n := copyNotification(item)
+	return &n, nil
+}
+
+// list returns the creator notifications that match the filter sorted by id, the same order as the table sort key.
+func (r *NotificationRepository) list(createdBy string, match func(model.NotificationItem) bool) []model.NotificationItem {
+	items := make([]model.NotificationItem, 0, len(r.notifications[createdBy]))
+
+	for _, n := range r.notifications[createdBy] {
+		if match(n) {
+			items = append(items, copyNotification(n))
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].SortKey < items[j].SortKey
+	})
+	return items
+}
+
+func copyNotification(n model.NotificationItem) model.NotificationItem {
+	if n.DeliveryMethods != nil {
+		n.DeliveryMethods = append([]int8(nil), n.DeliveryMethods...)
+	}
+	if n.Recurrence != nil {
+		recurrence := *n.Recurrence
+		n.Recurrence = &recurrence
+	}
+	if n.Occurrences != nil {
+		n.Occurrences = append([]model.OccurrenceItem(nil), n.Occurrences...)
+	}
+	if n.Deliveries != nil {
+		n.Deliveries = append([]model.DeliveryItem(nil), n.Deliveries...)
+	}
+	return n
+}
@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/japb1998/control-tower/internal/database"
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+// CreateWithOutbox stores the notification and its outbox entry together.
+func (r *NotificationRepository) CreateWithOutbox(notification model.NotificationItem, entry model.OutboxItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.notifications[notification.PartitionKey] == nil {
+		r.notifications[notification.PartitionKey] = make(map[string]model.NotificationItem)
+	}
+	r.notifications[notification.PartitionKey][notification.SortKey] = copyNotification(notification)
+	r.outbox[entry.Id] = entry
+	return nil
+}
+
+// UpdateNotificationWithOutbox applies the patch and stores the outbox entry together, the entry is not stored when the update fails.
+func (r *NotificationRepository) UpdateNotificationWithOutbox(createdBy, name string, notification database.PatchNotificationItem, entry model.OutboxItem) (*model.NotificationItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, err := r.update(createdBy, name, notification)
+
+	if err != nil {
+		return item, err
+	}
+	r.outbox[entry.Id] = entry
+	return item, nil
+}
+
+// GetOutbox returns up to limit pending outbox entries, the oldest first.
+func (r *NotificationRepository) GetOutbox(limit int) ([]model.OutboxItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := make([]model.OutboxItem, 0, len(r.outbox))
+
+	for _, entry := range r.outbox {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].CreatedAt != entries[j].CreatedAt {
+			return entries[i].CreatedAt < entries[j].CreatedAt
+		}
+		return entries[i].Id < entries[j].Id
+	})
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// DeleteOutbox removes the entry, deleting an entry that does not exist is not an error.
+func (r *NotificationRepository) DeleteOutbox(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.outbox, id)
+	return nil
+}
+
+// RecordOutboxAttempt counts a failed attempt to apply the entry.
+func (r *NotificationRepository) RecordOutboxAttempt(id, lastError string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.outbox[id]
+
+	if !ok {
+		return nil
+	}
+	entry.Attempts++
+	entry.LastError = lastError
+	r.outbox[id] = entry
+	return nil
+}
@@ -0,0 +1,39 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+type SettingsRepository struct {
+	mu       sync.RWMutex
+	settings map[string]model.SettingsItem
+}
+
+func NewSettingsRepo() *SettingsRepository {
+	return &SettingsRepository{
+		settings: make(map[string]model.SettingsItem),
+	}
+}
+
+// GetSettings returns the settings of a creator or nil when the creator has not saved any.
+func (r *SettingsRepository) GetSettings(createdBy string) (*model.SettingsItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	item, ok := r.settings[createdBy]
+
+	if !ok {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+func (r *SettingsRepository) SaveSettings(settings model.SettingsItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.settings[settings.PrimaryKey] = settings
+	return nil
+}
@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+type TemplateRepository struct {
+	mu        sync.RWMutex
+	templates map[string]map[string]model.TemplateItem // creator -> id -> template
+}
+
+func NewTemplateRepo() *TemplateRepository {
+	return &TemplateRepository{
+		templates: make(map[string]map[string]model.TemplateItem),
+	}
+}
+
+func (r *TemplateRepository) SaveTemplate(template model.TemplateItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.templates[template.PrimaryKey] == nil {
+		r.templates[template.PrimaryKey] = make(map[string]model.TemplateItem)
+	}
+	r.templates[template.PrimaryKey][template.SortKey] = template
+	return nil
+}
+
+// GetTemplate returns the template or nil when the creator has no template with the id.
+func (r *TemplateRepository) GetTemplate(createdBy, id string) (*model.TemplateItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	item, ok := r.templates[createdBy][id]
+
+	if !ok {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+// GetTemplates returns all the templates of the creator sorted by id, the same order as the table sort key.
+func (r *TemplateRepository) GetTemplates(createdBy string) ([]model.TemplateItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	items := make([]model.TemplateItem, 0, len(r.templates[createdBy]))
+
+	for _, t := range r.templates[createdBy] {
+		items = append(items, t)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].SortKey < items[j].SortKey })
+	return items, nil
+}
+
+func (r *TemplateRepository) DeleteTemplate(createdBy, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.templates[createdBy], id)
+	return nil
+}
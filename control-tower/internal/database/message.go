@@ -0,0 +1,105 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+var ErrMessageExists = errors.New("Message already exists")
+
+var (
+	messageRepository *MessageRepository
+	messageLogger     = log.New(os.Stdout, "[Message Repository] ", log.Default().Flags())
+)
+
+type MessageRepository struct {
+	Client    *DynamoClient
+	tableName string
+}
+
+func NewMessageRepo(sess *session.Session) *MessageRepository {
+	messageLogger.Println("message Table ", os.Getenv("MESSAGE_TABLE"))
+	if messageRepository == nil {
+		client := newDynamoClient(sess)
+		messageRepository = &MessageRepository{
+			Client:    client,
+			tableName: os.Getenv("MESSAGE_TABLE"),
+		}
+	}
+
+	return messageRepository
+}
+
+// SaveMessage stores the message in the thread of its client, it fails with ErrMessageExists when the thread has a message with the sort key.
+func (r *MessageRepository) SaveMessage(message model.MessageItem) error {
+	item, err := dynamodbattribute.MarshalMap(message)
+
+	if err != nil {
+		return fmt.Errorf("unable to marshal message error: %w", err)
+	}
+
+	_, err = r.Client.PutItem(&dynamodb.PutItemInput{
+		TableName:           &r.tableName,
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(sortKey)"),
+	})
+
+	if err != nil {
+		var conditionErr *dynamodb.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return ErrMessageExists
+		}
+		return fmt.Errorf("error while saving message: %w", err)
+	}
+	return nil
+}
+
+// GetMessages returns the thread of the client sorted by sort key.
+func (r *MessageRepository) GetMessages(createdBy, clientId string) ([]model.MessageItem, error) {
+	key, err := dynamodbattribute.Marshal(model.MessageThreadKey(createdBy, clientId))
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid thread creator='%s' client='%s' error: %w", createdBy, clientId, err)
+	}
+	input := &dynamodb.QueryInput{
+		TableName:              &r.tableName,
+		KeyConditionExpression: aws.String("#primaryKey = :primaryKey"),
+		ExpressionAttributeNames: map[string]*string{
+			"#primaryKey": aws.String("primaryKey"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":primaryKey": key,
+		},
+		ScanIndexForward: aws.Bool(true),
+	}
+	messages := make([]model.MessageItem, 0)
+
+	for {
+		output, err := r.Client.Query(input)
+
+		if err != nil {
+			return nil, fmt.Errorf("error querying messages error: %w", err)
+		}
+		var items []model.MessageItem
+
+		if err := dynamodbattribute.UnmarshalListOfMaps(output.Items, &items); err != nil {
+			messageLogger.Println("error unmarshalling messages error:", err)
+			return nil, fmt.Errorf("error unmarshalling messages")
+		}
+		messages = append(messages, items...)
+
+		if output.LastEvaluatedKey == nil {
+			break
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+	return messages, nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,6 +21,8 @@ var notificationLogger = log.New(os.Stdout, "[Notification Repository] ", log.De
 var (
 	ErrEmptyUpdate          = errors.New("Update cannot have empty paremeters")
 	ErrNotificationNotFound = errors.New("Notification not found")
+	ErrVersionConflict      = errors.New("Notification was changed since it was read")
+	ErrStatusChanged        = errors.New("Notification status is not the expected one")
 )
 
 type PaginationOps struct {
@@ -36,27 +39,52 @@ func NewNotificationRepository(sess *session.Session) *notificationRepository {
 	client := newDynamoClient(sess)
 	if notificationRepo == nil {
 		notificationRepo = &notificationRepository{
-			client:    client,
-			tableName: os.Getenv("EMAIL_TABLE"),
+			client:      client,
+			tableName:   os.Getenv("EMAIL_TABLE"),
+			outboxTable: os.Getenv("OUTBOX_TABLE"),
 		}
 	}
 	return notificationRepo
 }
 
 type PatchNotificationItem struct {
-	Date            time.Time `json:"date"`
-	Status          string    `json:"status"`
-	ClientId        string    `json:"clientId"`
-	DeliveryMethods []int8    `json:"deliveryMethods"`
-	TTL             int64     `json:"TTL"`
+	Date            time.Time         `json:"date"`
+	Status          string            `json:"status"`
+	ClientId        string            `json:"clientId"`
+	DeliveryMethods []int8            `json:"deliveryMethods"`
+	Recurrence      *model.Recurrence `json:"recurrence"`
+	TTL             int64             `json:"TTL"` // when empty and date is updated TTL is set to one day after the date.
+	TemplateId      string            `json:"templateId"`
+	Subject         string            `json:"subject"`
+	Message         string            `json:"message"`
+	TimeZone        string            `json:"timeZone"`
+	RequestedDate   *string           `json:"requestedDate"` // nil keeps the requested date, empty removes it.
 }
 
 type notificationRepository struct {
-	client    *DynamoClient
-	tableName string
+	client      *DynamoClient
+	tableName   string
+	outboxTable string
 }
 
 func (r *notificationRepository) SetStatus(partitionKey string, sortKey string, status string) error {
+	return r.setStatus(partitionKey, sortKey, "", status)
+}
+
+// ChangeStatus sets the status only when the notification has the from status, it fails with ErrStatusChanged otherwise.
+func (r *notificationRepository) ChangeStatus(partitionKey, sortKey, from, to string) error {
+	err := r.setStatus(partitionKey, sortKey, from, to)
+
+	if errors.Is(err, ErrNotificationNotFound) {
+		if _, getErr := r.GetNotification(partitionKey, sortKey); getErr == nil {
+			return ErrStatusChanged
+		}
+	}
+	return err
+}
+
+// setStatus sets the status, an empty from sets it whatever the current status is.
+func (r *notificationRepository) setStatus(partitionKey, sortKey, from, status string) error {
 	key, err := dynamodbattribute.MarshalMap(map[string]string{
 		"primaryKey": partitionKey,
 		"sortKey":    sortKey,
@@ -73,25 +101,75 @@ func (r *notificationRepository) SetStatus(partitionKey string, sortKey string,
 	input := &dynamodb.UpdateItemInput{
 		TableName:        &r.tableName,
 		Key:              key,
-		UpdateExpression: aws.String("SET #status = :status"),
+		UpdateExpression: aws.String("SET #status = :status, " + versionIncrement),
 		ExpressionAttributeNames: map[string]*string{
-			"#status": aws.String("status"),
+			"#status":  aws.String("status"),
+			"#version": aws.String("version"),
 		},
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":status": statusAttr,
+			":zero":   {N: aws.String("0")},
+			":one":    {N: aws.String("1")},
 		},
+		ConditionExpression: aws.String("attribute_exists(sortKey)"),
+	}
+	if from != "" {
+		input.ConditionExpression = aws.String("attribute_exists(sortKey) AND #status = :from")
+		input.ExpressionAttributeValues[":from"] = &dynamodb.AttributeValue{S: aws.String(from)}
 	}
 	_, err = r.client.UpdateItem(input)
 
 	if err != nil {
+		var conditionErr *dynamodb.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return ErrNotificationNotFound
+		}
 		return err
 	}
 
 	return nil
 }
 
+// versionIncrement is the update expression clause every partial write uses to move the notification version forward.
+const versionIncrement = "#version = if_not_exists(#version, :zero) + :one"
+
+// Update replaces an existing notification item. it fails with ErrVersionConflict when the stored version is not the one of the item,
+// the returned item has the new version.
 func (r *notificationRepository) Update(notification model.NotificationItem) (model.NotificationItem, error) {
-	return model.NotificationItem{}, nil
+	current := notification.Version
+	notification.Version++
+	marshalledItem, err := dynamodbattribute.MarshalMap(notification)
+
+	if err != nil {
+		return model.NotificationItem{}, fmt.Errorf("unable to marshal notification error: %w", err)
+	}
+	input := &dynamodb.PutItemInput{
+		TableName:                &r.tableName,
+		Item:                     marshalledItem,
+		ExpressionAttributeNames: map[string]*string{"#version": aws.String("version")},
+	}
+	// version 0 is not stored.
+	if current == 0 {
+		input.ConditionExpression = aws.String("attribute_exists(sortKey) AND attribute_not_exists(#version)")
+	} else {
+		input.ConditionExpression = aws.String("attribute_exists(sortKey) AND #version = :version")
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":version": {N: aws.String(strconv.Itoa(current))},
+		}
+	}
+	_, err = r.client.PutItem(input)
+
+	if err != nil {
+		var conditionErr *dynamodb.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			if _, err := r.GetNotification(notification.PartitionKey, notification.SortKey); err != nil {
+				return model.NotificationItem{}, err
+			}
+			return model.NotificationItem{}, ErrVersionConflict
+		}
+		return model.NotificationItem{}, fmt.Errorf("error when updating notification item error: %w", err)
+	}
+	return notification, nil
 }
 
 // Create
@@ -191,7 +269,6 @@ func (r *notificationRepository) GetNotificationCountByCreator(createdBy string)
 	}
 
 	var count int64
-	var LastEvaluatedKey map[string]*dynamodb.AttributeValue
 	input := &dynamodb.QueryInput{
 		TableName:              &r.tableName,
 		KeyConditionExpression: aws.String("#createdBy = :createdBy"),
@@ -201,8 +278,7 @@ func (r *notificationRepository) GetNotificationCountByCreator(createdBy string)
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":createdBy": creatorAttr,
 		},
-		ExclusiveStartKey: LastEvaluatedKey,
-		Select:            aws.String("COUNT"),
+		Select: aws.String("COUNT"),
 	}
 	for {
 		output, err := r.client.Query(input)
@@ -214,7 +290,7 @@ func (r *notificationRepository) GetNotificationCountByCreator(createdBy string)
 		if output.LastEvaluatedKey == nil {
 			break
 		}
-		LastEvaluatedKey = output.LastEvaluatedKey
+		input.ExclusiveStartKey = output.LastEvaluatedKey
 	}
 	return count, nil
 }
@@ -232,7 +308,6 @@ func (r *notificationRepository) GetNotificationsByCreator(createdBy string, ops
 	}
 
 	var items = make([]model.NotificationItem, 0)
-	var LastEvaluatedKey map[string]*dynamodb.AttributeValue
 	input := &dynamodb.QueryInput{
 		TableName:              &r.tableName,
 		KeyConditionExpression: aws.String("#createdBy = :createdBy"),
@@ -242,10 +317,9 @@ func (r *notificationRepository) GetNotificationsByCreator(createdBy string, ops
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":createdBy": creatorAttr,
 		},
-		ExclusiveStartKey: LastEvaluatedKey,
-		ScanIndexForward:  aws.Bool(true),
-		IndexName:         aws.String("DATE"),
-		Limit:             aws.Int64(int64(ops.Limit + ops.Skip)),
+		ScanIndexForward: aws.Bool(true),
+		IndexName:        aws.String("DATE"),
+		Limit:            aws.Int64(int64(ops.Limit + ops.Skip)),
 	}
 	for {
 		if len(items) != 0 {
@@ -268,7 +342,7 @@ func (r *notificationRepository) GetNotificationsByCreator(createdBy string, ops
 		if output.LastEvaluatedKey == nil || len(items) >= (ops.Skip+ops.Limit) {
 			break
 		}
-		LastEvaluatedKey = output.LastEvaluatedKey
+		input.ExclusiveStartKey = output.LastEvaluatedKey
 	}
 	// manual pagination.
 	if len(items) > ops.Skip+ops.Limit {
@@ -293,7 +367,117 @@ func (r *notificationRepository) GetNotificationsByCreator(createdBy string, ops
 
 }
 
+// GetNotificationsByClient returns every notification of a creator that belongs to the client.
+func (r *notificationRepository) GetNotificationsByClient(createdBy, clientId string) ([]model.NotificationItem, error) {
+	attrValues, err := dynamodbattribute.MarshalMap(map[string]string{
+		":createdBy": createdBy,
+		":clientId":  clientId,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling query values: %w", err)
+	}
+
+	var items = make([]model.NotificationItem, 0)
+	input := &dynamodb.QueryInput{
+		TableName:              &r.tableName,
+		KeyConditionExpression: aws.String("#createdBy = :createdBy"),
+		FilterExpression:       aws.String("#clientId = :clientId"),
+		ExpressionAttributeNames: map[string]*string{
+			"#createdBy": aws.String("primaryKey"),
+			"#clientId":  aws.String("clientId"),
+		},
+		ExpressionAttributeValues: attrValues,
+	}
+	for {
+		output, err := r.client.Query(input)
+		if err != nil {
+			return nil, fmt.Errorf("error querying notification by client: %w", err)
+		}
+		var list []model.NotificationItem
+		err = dynamodbattribute.UnmarshalListOfMaps(output.Items, &list)
+
+		if err != nil {
+			return nil, fmt.Errorf("error querying notification by client: %w", err)
+		}
+		items = append(items, list...)
+
+		if output.LastEvaluatedKey == nil {
+			break
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+	return items, nil
+}
+
+// GetNotificationsByStatus returns the notifications of every creator with the status. the table is scanned since status is not part of any index.
+func (r *notificationRepository) GetNotificationsByStatus(status string) ([]model.NotificationItem, error) {
+	attrValues, err := dynamodbattribute.MarshalMap(map[string]string{
+		":status": status,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling scan values: %w", err)
+	}
+
+	var items = make([]model.NotificationItem, 0)
+	input := &dynamodb.ScanInput{
+		TableName:        &r.tableName,
+		FilterExpression: aws.String("#status = :status"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
+		ExpressionAttributeValues: attrValues,
+	}
+	for {
+		output, err := r.client.Scan(input)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning notifications by status: %w", err)
+		}
+		var list []model.NotificationItem
+		err = dynamodbattribute.UnmarshalListOfMaps(output.Items, &list)
+
+		if err != nil {
+			return nil, fmt.Errorf("error scanning notifications by status: %w", err)
+		}
+		items = append(items, list...)
+
+		if output.LastEvaluatedKey == nil {
+			break
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+	return items, nil
+}
+
 func (r *notificationRepository) UpdateNotification(createdBy, name string, notification PatchNotificationItem) (*model.NotificationItem, error) {
+	input, err := r.updateInput(createdBy, name, notification)
+
+	if err != nil {
+		return &model.NotificationItem{}, err
+	}
+	output, err := r.client.UpdateItem(input)
+
+	if err != nil {
+		var conditionErr *dynamodb.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return nil, ErrNotificationNotFound
+		}
+		notificationLogger.Printf("Error when updating item error: %s", err)
+
+		return nil, fmt.Errorf("error when updating notification item error: %w", err)
+	}
+	var item model.NotificationItem
+	err = dynamodbattribute.UnmarshalMap(output.Attributes, &item)
+
+	if err != nil {
+		return nil, fmt.Errorf("error when updating notification item error: %w", err)
+	}
+	return &item, nil
+}
+
+// updateInput returns the update of the non empty fields of the patch.
+func (r *notificationRepository) updateInput(createdBy, name string, notification PatchNotificationItem) (*dynamodb.UpdateItemInput, error) {
 	attrNames := map[string]*string{}
 	attrValues := map[string]*dynamodb.AttributeValue{}
 	updateExpSlc := make([]string, 0)
@@ -332,18 +516,102 @@ func (r *notificationRepository) UpdateNotification(createdBy, name string, noti
 
 		attrValues[":date"] = val
 
+		if notification.TTL == 0 && notification.Recurrence == nil {
+			notification.TTL = notification.Date.Add(time.Hour * 24).Unix()
+		}
+	}
+
+	if notification.TTL != 0 {
 		// we reset the ttl
-		updateExpSlc = append(updateExpSlc, fmt.Sprintf("#ttl = :ttl"))
+		updateExpSlc = append(updateExpSlc, "#ttl = :ttl")
 		attrNames["#ttl"] = aws.String("TTL")
 
-		val, err = dynamodbattribute.Marshal(notification.Date.Add(time.Hour * 24))
+		val, err := dynamodbattribute.Marshal(notification.TTL)
 		if err != nil {
-			return nil, fmt.Errorf("unable to update notification date error: %w", err)
+			return nil, fmt.Errorf("unable to update notification ttl error: %w", err)
 		}
 
 		attrValues[":ttl"] = val
 	}
 
+	if notification.Recurrence != nil {
+		updateExpSlc = append(updateExpSlc, "#recurrence = :recurrence")
+		attrNames["#recurrence"] = aws.String("recurrence")
+
+		val, err := dynamodbattribute.Marshal(notification.Recurrence)
+		if err != nil {
+			return nil, fmt.Errorf("unable to update notification recurrence error: %w", err)
+		}
+
+		attrValues[":recurrence"] = val
+	}
+	var removeExp string
+	if notification.Recurrence != nil && notification.TTL == 0 {
+		// recurring notifications without an end do not expire.
+		removeExp = " REMOVE #ttl"
+		attrNames["#ttl"] = aws.String("TTL")
+	}
+
+	if notification.TemplateId != "" {
+		updateExpSlc = append(updateExpSlc, "#templateId = :templateId")
+		attrNames["#templateId"] = aws.String("templateId")
+
+		val, err := dynamodbattribute.Marshal(notification.TemplateId)
+		if err != nil {
+			return nil, fmt.Errorf("unable to update notification template error: %w", err)
+		}
+
+		attrValues[":templateId"] = val
+	}
+
+	if notification.Subject != "" {
+		updateExpSlc = append(updateExpSlc, "#subject = :subject")
+		attrNames["#subject"] = aws.String("subject")
+
+		val, err := dynamodbattribute.Marshal(notification.Subject)
+		if err != nil {
+			return nil, fmt.Errorf("unable to update notification subject error: %w", err)
+		}
+
+		attrValues[":subject"] = val
+	}
+
+	if notification.Message != "" {
+		updateExpSlc = append(updateExpSlc, "#message = :message")
+		attrNames["#message"] = aws.String("message")
+
+		val, err := dynamodbattribute.Marshal(notification.Message)
+		if err != nil {
+			return nil, fmt.Errorf("unable to update notification message error: %w", err)
+		}
+
+		attrValues[":message"] = val
+	}
+
+	if notification.TimeZone != "" {
+		updateExpSlc = append(updateExpSlc, "#timeZone = :timeZone")
+		attrNames["#timeZone"] = aws.String("timeZone")
+
+		val, err := dynamodbattribute.Marshal(notification.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("unable to update notification time zone error: %w", err)
+		}
+
+		attrValues[":timeZone"] = val
+	}
+
+	if notification.RequestedDate != nil {
+		updateExpSlc = append(updateExpSlc, "#requestedDate = :requestedDate")
+		attrNames["#requestedDate"] = aws.String("requestedDate")
+
+		val, err := dynamodbattribute.Marshal(*notification.RequestedDate)
+		if err != nil {
+			return nil, fmt.Errorf("unable to update notification requested date error: %w", err)
+		}
+
+		attrValues[":requestedDate"] = val
+	}
+
 	if notification.DeliveryMethods != nil || len(notification.DeliveryMethods) > 0 {
 		updateExpSlc = append(updateExpSlc, fmt.Sprintf("#deliveryMethods = :deliveryMethods"))
 		attrNames["#deliveryMethods"] = aws.String("deliveryMethods")
@@ -361,33 +629,23 @@ func (r *notificationRepository) UpdateNotification(createdBy, name string, noti
 		"sortKey":    name,
 	})
 	if err != nil {
-		return &model.NotificationItem{}, fmt.Errorf("Error unmarshalling Key error: %w", err)
+		return nil, fmt.Errorf("Error unmarshalling Key error: %w", err)
 	}
-	exp := fmt.Sprintf("SET %s", strings.Join(updateExpSlc, ", "))
-
 	if len(attrNames) == 0 || len(attrValues) == 0 {
-		return &model.NotificationItem{}, ErrEmptyUpdate
-	}
-	input := &dynamodb.UpdateItemInput{
+		return nil, ErrEmptyUpdate
+	}
+	updateExpSlc = append(updateExpSlc, versionIncrement)
+	attrNames["#version"] = aws.String("version")
+	attrValues[":zero"] = &dynamodb.AttributeValue{N: aws.String("0")}
+	attrValues[":one"] = &dynamodb.AttributeValue{N: aws.String("1")}
+	exp := fmt.Sprintf("SET %s%s", strings.Join(updateExpSlc, ", "), removeExp)
+	return &dynamodb.UpdateItemInput{
 		TableName:                 &r.tableName,
 		UpdateExpression:          aws.String(exp),
 		ExpressionAttributeNames:  attrNames,
 		ExpressionAttributeValues: attrValues,
 		Key:                       key,
-	}
-
-	output, err := r.client.UpdateItem(input)
-
-	if err != nil {
-		notificationLogger.Printf("Error when updating item error: %s", err)
-
-		return nil, fmt.Errorf("error when updating notification item error: %w", err)
-	}
-	var item model.NotificationItem
-	err = dynamodbattribute.UnmarshalMap(output.Attributes, &item)
-
-	if err != nil {
-		return nil, fmt.Errorf("error when updating notification item error: %w", err)
-	}
-	return &item, nil
+		ConditionExpression:       aws.String("attribute_exists(sortKey)"),
+		ReturnValues:              aws.String("ALL_NEW"),
+	}, nil
 }
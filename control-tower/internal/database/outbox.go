@@ -0,0 +1,182 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+// CreateWithOutbox stores the notification and its outbox entry in a single transaction.
+func (r *notificationRepository) CreateWithOutbox(notification model.NotificationItem, entry model.OutboxItem) error {
+	item, err := dynamodbattribute.MarshalMap(notification)
+
+	if err != nil {
+		return err
+	}
+	put, err := r.outboxPut(entry)
+
+	if err != nil {
+		return err
+	}
+	_, err = r.client.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Put: &dynamodb.Put{TableName: &r.tableName, Item: item}},
+			put,
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("error when storing notification error: %w", err)
+	}
+	return nil
+}
+
+// UpdateNotificationWithOutbox applies the patch and stores the outbox entry in a single transaction.
+func (r *notificationRepository) UpdateNotificationWithOutbox(createdBy, name string, notification PatchNotificationItem, entry model.OutboxItem) (*model.NotificationItem, error) {
+	input, err := r.updateInput(createdBy, name, notification)
+
+	if err != nil {
+		return &model.NotificationItem{}, err
+	}
+	put, err := r.outboxPut(entry)
+
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.client.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Update: &dynamodb.Update{
+				TableName:                 input.TableName,
+				Key:                       input.Key,
+				UpdateExpression:          input.UpdateExpression,
+				ExpressionAttributeNames:  input.ExpressionAttributeNames,
+				ExpressionAttributeValues: input.ExpressionAttributeValues,
+				ConditionExpression:       input.ConditionExpression,
+			}},
+			put,
+		},
+	})
+
+	if err != nil {
+		var canceled *dynamodb.TransactionCanceledException
+		// reasons are in the order of the transaction items, the first one is the notification.
+		if errors.As(err, &canceled) && len(canceled.CancellationReasons) > 0 && aws.StringValue(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			return nil, ErrNotificationNotFound
+		}
+		notificationLogger.Printf("Error when updating item error: %s", err)
+
+		return nil, fmt.Errorf("error when updating notification item error: %w", err)
+	}
+	return r.GetNotification(createdBy, name)
+}
+
+func (r *notificationRepository) outboxPut(entry model.OutboxItem) (*dynamodb.TransactWriteItem, error) {
+	item, err := dynamodbattribute.MarshalMap(entry)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal outbox entry error: %w", err)
+	}
+	return &dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{TableName: &r.outboxTable, Item: item},
+	}, nil
+}
+
+// GetOutbox returns up to limit pending outbox entries, the oldest first. the outbox is expected to be small so it is scanned.
+func (r *notificationRepository) GetOutbox(limit int) ([]model.OutboxItem, error) {
+	input := &dynamodb.ScanInput{
+		TableName: &r.outboxTable,
+	}
+	entries := make([]model.OutboxItem, 0)
+
+	for {
+		output, err := r.client.Scan(input)
+
+		if err != nil {
+			return nil, fmt.Errorf("error scanning outbox error: %w", err)
+		}
+		var list []model.OutboxItem
+
+		if err := dynamodbattribute.UnmarshalListOfMaps(output.Items, &list); err != nil {
+			return nil, fmt.Errorf("error unmarshalling outbox error: %w", err)
+		}
+		entries = append(entries, list...)
+
+		if output.LastEvaluatedKey == nil {
+			break
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedAt < entries[j].CreatedAt
+	})
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// DeleteOutbox removes the entry, deleting an entry that does not exist is not an error.
+func (r *notificationRepository) DeleteOutbox(id string) error {
+	key, err := dynamodbattribute.MarshalMap(map[string]string{
+		"id": id,
+	})
+
+	if err != nil {
+		return err
+	}
+	_, err = r.client.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: &r.outboxTable,
+		Key:       key,
+	})
+
+	if err != nil {
+		return fmt.Errorf("error deleting outbox entry='%s' error: %w", id, err)
+	}
+	return nil
+}
+
+// RecordOutboxAttempt counts a failed attempt to apply the entry.
+func (r *notificationRepository) RecordOutboxAttempt(id, lastError string) error {
+	key, err := dynamodbattribute.MarshalMap(map[string]string{
+		"id": id,
+	})
+
+	if err != nil {
+		return err
+	}
+	values, err := dynamodbattribute.MarshalMap(map[string]any{
+		":one":       1,
+		":zero":      0,
+		":lastError": lastError,
+	})
+
+	if err != nil {
+		return err
+	}
+	_, err = r.client.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:        &r.outboxTable,
+		Key:              key,
+		UpdateExpression: aws.String("SET #attempts = if_not_exists(#attempts, :zero) + :one, #lastError = :lastError"),
+		ExpressionAttributeNames: map[string]*string{
+			"#attempts":  aws.String("attempts"),
+			"#lastError": aws.String("lastError"),
+		},
+		ExpressionAttributeValues: values,
+		ConditionExpression:       aws.String("attribute_exists(id)"),
+	})
+
+	if err != nil {
+		var conditionErr *dynamodb.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return nil
+		}
+		return fmt.Errorf("error updating outbox entry='%s' error: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+var (
+	settingsRepository *SettingsRepository
+	settingsLogger     = log.New(os.Stdout, "[Settings Repository] ", log.Default().Flags())
+)
+
+type SettingsRepository struct {
+	Client    *DynamoClient
+	tableName string
+}
+
+func NewSettingsRepo(sess *session.Session) *SettingsRepository {
+	settingsLogger.Println("settings Table ", os.Getenv("SETTINGS_TABLE"))
+	if settingsRepository == nil {
+		client := newDynamoClient(sess)
+		settingsRepository = &SettingsRepository{
+			Client:    client,
+			tableName: os.Getenv("SETTINGS_TABLE"),
+		}
+	}
+
+	return settingsRepository
+}
+
+// GetSettings returns the settings of a creator or nil when the creator has not saved any.
+func (r *SettingsRepository) GetSettings(createdBy string) (*model.SettingsItem, error) {
+	key, err := dynamodbattribute.MarshalMap(map[string]string{
+		"primaryKey": createdBy,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid creator provided creator: '%s', error: %w", createdBy, err)
+	}
+
+	output, err := r.Client.GetOne(&dynamodb.GetItemInput{
+		TableName: &r.tableName,
+		Key:       key,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("error while getting settings: %w", err)
+	}
+
+	if len(output.Item) == 0 {
+		return nil, nil
+	}
+	var item model.SettingsItem
+
+	if err := dynamodbattribute.UnmarshalMap(output.Item, &item); err != nil {
+		return nil, fmt.Errorf("error while unmarshalling settings: %w", err)
+	}
+	return &item, nil
+}
+
+// SaveSettings creates or replaces the settings of a creator.
+func (r *SettingsRepository) SaveSettings(settings model.SettingsItem) error {
+	item, err := dynamodbattribute.MarshalMap(settings)
+
+	if err != nil {
+		return fmt.Errorf("unable to marshal settings error: %w", err)
+	}
+
+	_, err = r.Client.PutItem(&dynamodb.PutItemInput{
+		TableName: &r.tableName,
+		Item:      item,
+	})
+
+	if err != nil {
+		return fmt.Errorf("error while saving settings: %w", err)
+	}
+	return nil
+}
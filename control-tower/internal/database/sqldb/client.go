@@ -0,0 +1,364 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/database"
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+const (
+	insertClient = `INSERT INTO clients (created_by, id, phone, email, first_name, last_name, last_seen, deleted_at, item)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (created_by, id) DO UPDATE SET phone = excluded.phone, email = excluded.email, first_name = excluded.first_name,
+	last_name = excluded.last_name, last_seen = excluded.last_seen, deleted_at = excluded.deleted_at, item = excluded.item`
+	updateClient = `UPDATE clients SET phone = ?, email = ?, first_name = ?, last_name = ?, last_seen = ?, deleted_at = ?, item = ?
+	WHERE created_by = ? AND id = ?`
+)
+
+type ClientRepository struct {
+	db *DB
+}
+
+func NewClientRepo(db *DB) *ClientRepository {
+	return &ClientRepository{
+		db: db,
+	}
+}
+
+func decodeClient(data []byte, c *model.ClientItem) error {
+	return json.Unmarshal(data, c)
+}
+
+// clientArgs returns the column values of the client in the insert order.
+func clientArgs(c model.ClientItem) ([]any, error) {
+	item, err := json.Marshal(c)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal client error: %w", err)
+	}
+	var lastSeen, deletedAt *string
+	if c.LastSeen != nil {
+		ls := formatTime(*c.LastSeen)
+		lastSeen = &ls
+	}
+	if c.DeletedAt != nil {
+		da := formatTime(*c.DeletedAt)
+		deletedAt = &da
+	}
+	return []any{c.PrimaryKey, c.SortKey, c.Phone, c.Email, c.FirstName, c.LastName, lastSeen, deletedAt, string(item)}, nil
+}
+
+// GetClientsByCreator returns every client of the creator that is not archived.
+func (r *ClientRepository) GetClientsByCreator(createdBy string) ([]model.ClientItem, error) {
+	rows, err := r.db.query(context.Background(), "SELECT item FROM clients WHERE created_by = ? AND deleted_at IS NULL ORDER BY id", createdBy)
+
+	if err != nil {
+		sqlLogger.Println("error querying clients error:", err)
+		return nil, fmt.Errorf("error querying client items error: %s", err)
+	}
+	return scanItems(rows, decodeClient)
+}
+
+// GetClientsByPhone returns the clients of every creator with the phone that are not archived.
+func (r *ClientRepository) GetClientsByPhone(phone string) ([]model.ClientItem, error) {
+	rows, err := r.db.query(context.Background(), "SELECT item FROM clients WHERE phone = ? AND deleted_at IS NULL ORDER BY created_by, id", phone)
+
+	if err != nil {
+		sqlLogger.Println("error querying clients error:", err)
+		return nil, fmt.Errorf("error querying client items error: %s", err)
+	}
+	return scanItems(rows, decodeClient)
+}
+
+// GetClientsByEmail returns the clients of every creator with the email that are not archived.
+func (r *ClientRepository) GetClientsByEmail(email string) ([]model.ClientItem, error) {
+	rows, err := r.db.query(context.Background(), "SELECT item FROM clients WHERE email = ? AND deleted_at IS NULL ORDER BY created_by, id", email)
+
+	if err != nil {
+		sqlLogger.Println("error querying clients error:", err)
+		return nil, fmt.Errorf("error querying client items error: %s", err)
+	}
+	return scanItems(rows, decodeClient)
+}
+
+// clientFilter returns the where clause for the filters. like dynamo any of the provided filters must match, archived clients never do.
+func (r *ClientRepository) clientFilter(createdBy string, f database.PatchClientItem) (string, []any) {
+	conditions := make([]string, 0)
+	args := []any{createdBy}
+
+	if f.Phone != "" {
+		conditions = append(conditions, "phone = ?")
+		args = append(args, f.Phone)
+	}
+	if f.Email != "" {
+		conditions = append(conditions, r.db.contains("email"))
+		args = append(args, f.Email)
+	}
+	if f.FirstName != "" {
+		conditions = append(conditions, r.db.contains("first_name"))
+		args = append(args, f.FirstName)
+	}
+	if f.LastName != "" {
+		conditions = append(conditions, r.db.contains("last_name"))
+		args = append(args, f.LastName)
+	}
+	if f.LastSeen != nil {
+		conditions = append(conditions, "last_seen = ?")
+		args = append(args, formatTime(*f.LastSeen))
+	}
+
+	where := "created_by = ? AND deleted_at IS NULL"
+	if len(conditions) > 0 {
+		where += fmt.Sprintf(" AND (%s)", strings.Join(conditions, " OR "))
+	}
+	return where, args
+}
+
+func (r *ClientRepository) ClientCountWithFilters(createdBy string, clientPatch database.PatchClientItem) (int64, error) {
+	where, args := r.clientFilter(createdBy, clientPatch)
+	var count int64
+
+	if err := r.db.queryRow(context.Background(), "SELECT COUNT(*) FROM clients WHERE "+where, args...).Scan(&count); err != nil {
+		sqlLogger.Println(err)
+		return 0, errors.New("error while retreiving clients")
+	}
+	return count, nil
+}
+
+// GetClientWithFilters get clients with filters. Paginated, Zero indexed
+func (r *ClientRepository) GetClientWithFilters(createdBy string, clientPatch database.PatchClientItem, p *database.PaginationOps) ([]model.ClientItem, error) {
+	where, args := r.clientFilter(createdBy, clientPatch)
+	query := "SELECT item FROM clients WHERE " + where + " ORDER BY id"
+
+	if p != nil {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, p.Limit, p.Skip)
+	}
+	rows, err := r.db.query(context.Background(), query, args...)
+
+	if err != nil {
+		sqlLogger.Println(err)
+		return nil, errors.New("error while retrieving clients")
+	}
+	return scanItems(rows, decodeClient)
+}
+
+// WalkClientsWithFilters calls fn with every client matching the filters. clients are read in pages so the connection is not held while fn runs.
+func (r *ClientRepository) WalkClientsWithFilters(createdBy string, clientPatch database.PatchClientItem, fn func(model.ClientItem) error) error {
+	where, args := r.clientFilter(createdBy, clientPatch)
+	lastId := ""
+
+	for {
+		rows, err := r.db.query(context.Background(), "SELECT item FROM clients WHERE "+where+" AND id > ? ORDER BY id LIMIT ?", append(args, lastId, walkPageSize)...)
+
+		if err != nil {
+			sqlLogger.Println(err)
+			return errors.New("error while retrieving clients")
+		}
+		clients, err := scanItems(rows, decodeClient)
+
+		if err != nil {
+			sqlLogger.Println(err)
+			return errors.New("error while retrieving clients")
+		}
+
+		for _, c := range clients {
+			if err := fn(c); err != nil {
+				return err
+			}
+		}
+
+		if len(clients) < walkPageSize {
+			return nil
+		}
+		lastId = clients[len(clients)-1].SortKey
+	}
+}
+
+// CreateClient stores the client, an existing client with the same id is replaced.
+func (r *ClientRepository) CreateClient(client model.ClientItem) (model.ClientItem, error) {
+	args, err := clientArgs(client)
+
+	if err != nil {
+		return model.ClientItem{}, err
+	}
+	_, err = r.db.exec(context.Background(), insertClient, args...)
+
+	if err != nil {
+		sqlLogger.Println(err)
+		return model.ClientItem{}, fmt.Errorf("error while creating client")
+	}
+	return client, nil
+}
+
+// CreateClients stores the clients in a single transaction, when it fails none of them is stored.
+func (r *ClientRepository) CreateClients(clients []model.ClientItem) ([]model.ClientItem, error) {
+	ctx := context.Background()
+
+	err := r.db.inTx(ctx, func(tx *sql.Tx) error {
+		for _, c := range clients {
+			args, err := clientArgs(c)
+
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, r.db.rebind(insertClient), args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		sqlLogger.Println(err)
+		return clients, fmt.Errorf("error while creating clients error: %w", err)
+	}
+	return nil, nil
+}
+
+func (r *ClientRepository) UpdateUser(createdBy string, clientId string, client database.PatchClientItem) (model.ClientItem, error) {
+	empty := client.FirstName == "" && client.LastName == "" && client.Description == "" && client.Phone == "" &&
+		client.Email == "" && client.OptIn == nil && client.LastSeen == nil && len(client.DeliveryMethods) == 0 && client.DisabledChannels == nil && client.Language == "" && client.TimeZone == ""
+
+	if empty {
+		return model.ClientItem{}, database.ErrEmptyUpdate
+	}
+
+	return r.modify(createdBy, clientId, "deleted_at IS NULL", func(item *model.ClientItem) {
+		if client.FirstName != "" {
+			item.FirstName = client.FirstName
+		}
+		if client.LastName != "" {
+			item.LastName = client.LastName
+		}
+		if client.Description != "" {
+			item.Description = client.Description
+		}
+		if client.Phone != "" {
+			item.Phone = client.Phone
+		}
+		if client.Email != "" {
+			item.Email = client.Email
+		}
+		if client.OptIn != nil {
+			item.OptIn = *client.OptIn
+		}
+		if client.LastSeen != nil {
+			item.LastSeen = client.LastSeen
+		}
+		if len(client.DeliveryMethods) > 0 {
+			item.DeliveryMethods = client.DeliveryMethods
+		}
+		if client.DisabledChannels != nil {
+			item.DisabledChannels = *client.DisabledChannels
+		}
+		if client.Language != "" {
+			item.Language = client.Language
+		}
+		if client.TimeZone != "" {
+			item.TimeZone = client.TimeZone
+		}
+	})
+}
+
+// GetArchivedClients returns the archived clients of the creator.
+func (r *ClientRepository) GetArchivedClients(createdBy string) ([]model.ClientItem, error) {
+	rows, err := r.db.query(context.Background(), "SELECT item FROM clients WHERE created_by = ? AND deleted_at IS NOT NULL ORDER BY id", createdBy)
+
+	if err != nil {
+		sqlLogger.Println("error querying clients error:", err)
+		return nil, fmt.Errorf("error querying client items error: %s", err)
+	}
+	return scanItems(rows, decodeClient)
+}
+
+// ArchiveClient hides the client. it fails with database.ErrClientNotFound when the client does not exist or is already archived.
+func (r *ClientRepository) ArchiveClient(createdBy, id string, at time.Time) error {
+	at = at.UTC().Truncate(time.Second)
+	_, err := r.modify(createdBy, id, "deleted_at IS NULL", func(item *model.ClientItem) {
+		item.DeletedAt = &at
+	})
+	return err
+}
+
+// RestoreClient makes an archived client visible again. it fails with database.ErrClientNotFound when the client is not archived.
+func (r *ClientRepository) RestoreClient(createdBy, id string) (model.ClientItem, error) {
+	return r.modify(createdBy, id, "deleted_at IS NOT NULL", func(item *model.ClientItem) {
+		item.DeletedAt = nil
+	})
+}
+
+// modify applies fn to the client matching the condition in a transaction. it fails with database.ErrClientNotFound when no client matches.
+func (r *ClientRepository) modify(createdBy, clientId, condition string, fn func(*model.ClientItem)) (model.ClientItem, error) {
+	ctx := context.Background()
+	var item model.ClientItem
+
+	err := r.db.inTx(ctx, func(tx *sql.Tx) error {
+		var data []byte
+		err := tx.QueryRowContext(ctx, r.db.rebind("SELECT item FROM clients WHERE created_by = ? AND id = ? AND "+condition+r.db.forUpdate()), createdBy, clientId).Scan(&data)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.ErrClientNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := decodeClient(data, &item); err != nil {
+			return err
+		}
+		fn(&item)
+		item.LastUpdateAt = time.Now().UTC().Truncate(time.Second)
+
+		args, err := clientArgs(item)
+
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, r.db.rebind(updateClient), append(args[2:], createdBy, clientId)...)
+		return err
+	})
+
+	if err != nil {
+		if errors.Is(err, database.ErrClientNotFound) {
+			return model.ClientItem{}, err
+		}
+		return model.ClientItem{}, fmt.Errorf("error updating item error: %w", err)
+	}
+	return item, nil
+}
+
+func (r *ClientRepository) DeleteClient(createdBy, id string) error {
+	if _, err := r.db.exec(context.Background(), "DELETE FROM clients WHERE created_by = ? AND id = ?", createdBy, id); err != nil {
+		sqlLogger.Println(err)
+		return errors.New("error While Deleting Client")
+	}
+	return nil
+}
+
+// GetClientById returns nil when the client does not exist or is archived.
+func (r *ClientRepository) GetClientById(createdBy, id string) (*model.ClientItem, error) {
+	var data []byte
+	err := r.db.queryRow(context.Background(), "SELECT item FROM clients WHERE created_by = ? AND id = ? AND deleted_at IS NULL", createdBy, id).Scan(&data)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		sqlLogger.Println(err)
+		return nil, errors.New("error While Getting Client")
+	}
+	var item model.ClientItem
+
+	if err := decodeClient(data, &item); err != nil {
+		return nil, errors.New("error While Getting Client")
+	}
+	return &item, nil
+}
@@ -0,0 +1,59 @@
+package sqldb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+type ConnectionRepository struct {
+	db *DB
+}
+
+func NewConnectionRepo(db *DB) *ConnectionRepository {
+	return &ConnectionRepository{
+		db: db,
+	}
+}
+
+// SaveConnection stores the connection id and the user ID it belongs to.
+func (cr *ConnectionRepository) SaveConnection(ctx context.Context, conn model.Connection) error {
+	_, err := cr.db.exec(ctx, "INSERT INTO connections (email, connection_id) VALUES (?, ?) ON CONFLICT (email, connection_id) DO NOTHING", conn.Email, conn.ConnectionId)
+
+	if err != nil {
+		return fmt.Errorf("unable to save connection error='%w'", err)
+	}
+	return nil
+}
+
+// DeleteConnection - deletes connection from database
+func (cr *ConnectionRepository) DeleteConnection(ctx context.Context, conn model.Connection) error {
+	_, err := cr.db.exec(ctx, "DELETE FROM connections WHERE email = ? AND connection_id = ?", conn.Email, conn.ConnectionId)
+
+	if err != nil {
+		return fmt.Errorf("unable to delete connection error='%w'", err)
+	}
+	return nil
+}
+
+// GetConnectionIds search for all connection Ids related to the specified userId.
+func (cr *ConnectionRepository) GetConnectionIds(ctx context.Context, email string) ([]model.Connection, error) {
+	rows, err := cr.db.query(ctx, "SELECT connection_id FROM connections WHERE email = ? ORDER BY connection_id", email)
+
+	if err != nil {
+		return nil, fmt.Errorf("error while retrieving connection IDs error='%w'", err)
+	}
+	defer rows.Close()
+
+	var conns []model.Connection
+	for rows.Next() {
+		conn := model.Connection{Email: email}
+
+		if err := rows.Scan(&conn.ConnectionId); err != nil {
+			return nil, fmt.Errorf("unable to read connection, error:'%w'", err)
+		}
+		conns = append(conns, conn)
+	}
+	return conns, rows.Err()
+}
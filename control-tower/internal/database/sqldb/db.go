@@ -0,0 +1,163 @@
+// sqldb package stores the repositories in a SQL database, postgres and sqlite are supported.
+// every row keeps the full item as json next to the columns used for lookups, filters and ordering.
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// walkPageSize is the number of rows read at a time when walking a table.
+const walkPageSize = 500
+
+var (
+	ErrUnsupportedDriver = errors.New("unsupported sql driver")
+)
+
+var sqlLogger = log.New(os.Stdout, "[SQL Repository] ", log.Default().Flags())
+
+type DB struct {
+	db     *sql.DB
+	driver string
+}
+
+// Open connects to the database and applies the pending migrations.
+func Open(driver, dsn string) (*DB, error) {
+	if driver != DriverPostgres && driver != DriverSQLite {
+		return nil, fmt.Errorf("driver='%s': %w", driver, ErrUnsupportedDriver)
+	}
+	db, err := sql.Open(driver, dsn)
+
+	if err != nil {
+		return nil, fmt.Errorf("error opening database error: %w", err)
+	}
+
+	if driver == DriverSQLite {
+		// sqlite allows a single writer, a single connection also keeps in memory databases alive.
+		db.SetMaxOpenConns(1)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error connecting to database error: %w", err)
+	}
+	d := &DB{
+		db:     db,
+		driver: driver,
+	}
+
+	if err := d.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// rebind replaces the ? placeholders with the numbered ones postgres expects.
+func (d *DB) rebind(query string) string {
+	if d.driver != DriverPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// contains returns a case sensitive substring condition on col, the same as the dynamo contains function.
+func (d *DB) contains(col string) string {
+	if d.driver == DriverPostgres {
+		return fmt.Sprintf("strpos(%s, ?) > 0", col)
+	}
+	return fmt.Sprintf("instr(%s, ?) > 0", col)
+}
+
+// forUpdate locks the selected rows until the transaction ends. sqlite has a single writer so it does not need it.
+func (d *DB) forUpdate() string {
+	if d.driver == DriverPostgres {
+		return " FOR UPDATE"
+	}
+	return ""
+}
+
+func (d *DB) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return d.db.ExecContext(ctx, d.rebind(query), args...)
+}
+
+func (d *DB) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return d.db.QueryContext(ctx, d.rebind(query), args...)
+}
+
+func (d *DB) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	return d.db.QueryRowContext(ctx, d.rebind(query), args...)
+}
+
+// inTx runs fn in a transaction, it is rolled back when fn fails.
+func (d *DB) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// formatTime is the format used for the time columns. fixed width UTC keeps the text order equal to the time order.
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000Z07:00")
+}
+
+// scanItems decodes the json item column of every row.
+func scanItems[T any](rows *sql.Rows, decode func([]byte, *T) error) ([]T, error) {
+	defer rows.Close()
+	items := make([]T, 0)
+
+	for rows.Next() {
+		var data []byte
+
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var item T
+
+		if err := decode(data, &item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,55 @@
+package sqldb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/japb1998/control-tower/internal/database"
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+type MessageRepository struct {
+	db *DB
+}
+
+func NewMessageRepo(db *DB) *MessageRepository {
+	return &MessageRepository{
+		db: db,
+	}
+}
+
+func decodeMessage(data []byte, m *model.MessageItem) error {
+	return json.Unmarshal(data, m)
+}
+
+// SaveMessage stores the message in the thread of its client, it fails with database.ErrMessageExists when the thread has a message with the sort key.
+func (r *MessageRepository) SaveMessage(message model.MessageItem) error {
+	item, err := json.Marshal(message)
+
+	if err != nil {
+		return fmt.Errorf("unable to marshal message error: %w", err)
+	}
+	res, err := r.db.exec(context.Background(), `INSERT INTO messages (thread, sort_key, item) VALUES (?, ?, ?)
+	ON CONFLICT (thread, sort_key) DO NOTHING`, message.PrimaryKey, message.SortKey, string(item))
+
+	if err != nil {
+		sqlLogger.Println(err)
+		return fmt.Errorf("error while saving message: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return database.ErrMessageExists
+	}
+	return nil
+}
+
+// GetMessages returns the thread of the client sorted by sort key.
+func (r *MessageRepository) GetMessages(createdBy, clientId string) ([]model.MessageItem, error) {
+	rows, err := r.db.query(context.Background(), "SELECT item FROM messages WHERE thread = ? ORDER BY sort_key", model.MessageThreadKey(createdBy, clientId))
+
+	if err != nil {
+		sqlLogger.Println("error querying messages error:", err)
+		return nil, fmt.Errorf("error querying messages error: %s", err)
+	}
+	return scanItems(rows, decodeMessage)
+}
@@ -0,0 +1,103 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migrations are applied in order and only once. never edit an applied migration, add a new one instead.
+var migrations = []string{
+	`CREATE TABLE clients (
+		created_by TEXT NOT NULL,
+		id TEXT NOT NULL,
+		phone TEXT NOT NULL DEFAULT '',
+		email TEXT NOT NULL DEFAULT '',
+		first_name TEXT NOT NULL DEFAULT '',
+		last_name TEXT NOT NULL DEFAULT '',
+		last_seen TEXT,
+		item TEXT NOT NULL,
+		PRIMARY KEY (created_by, id)
+	)`,
+	`CREATE TABLE notifications (
+		created_by TEXT NOT NULL,
+		id TEXT NOT NULL,
+		client_id TEXT NOT NULL,
+		date TEXT NOT NULL,
+		item TEXT NOT NULL,
+		PRIMARY KEY (created_by, id)
+	)`,
+	// same lookup as the dynamo DATE index.
+	`CREATE INDEX notifications_date ON notifications (created_by, date, id)`,
+	`CREATE INDEX notifications_client ON notifications (created_by, client_id)`,
+	`CREATE TABLE connections (
+		email TEXT NOT NULL,
+		connection_id TEXT NOT NULL,
+		PRIMARY KEY (email, connection_id)
+	)`,
+	`CREATE TABLE settings (
+		created_by TEXT NOT NULL PRIMARY KEY,
+		item TEXT NOT NULL
+	)`,
+	// archived clients keep their row until they are restored or deleted.
+	`ALTER TABLE clients ADD COLUMN deleted_at TEXT`,
+	// inbound messages find the clients of every creator by phone.
+	`CREATE INDEX clients_phone ON clients (phone)`,
+	// inbound emails find the clients of every creator by email.
+	`CREATE INDEX clients_email ON clients (email)`,
+	// conversation with the clients, same keys as the dynamo messages table.
+	`CREATE TABLE messages (
+		thread TEXT NOT NULL,
+		sort_key TEXT NOT NULL,
+		item TEXT NOT NULL,
+		PRIMARY KEY (thread, sort_key)
+	)`,
+	// reminder templates of the creators.
+	`CREATE TABLE templates (
+		created_by TEXT NOT NULL,
+		id TEXT NOT NULL,
+		item TEXT NOT NULL,
+		PRIMARY KEY (created_by, id)
+	)`,
+	// scheduler operations written with the notifications, removed once they are applied.
+	`CREATE TABLE outbox (
+		id TEXT NOT NULL PRIMARY KEY,
+		created_at TEXT NOT NULL,
+		item TEXT NOT NULL
+	)`,
+}
+
+// migrate applies the migrations that were not applied yet. the applied versions are kept in schema_migrations.
+func (d *DB) migrate(ctx context.Context) error {
+	_, err := d.exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`)
+
+	if err != nil {
+		return fmt.Errorf("error creating migrations table error: %w", err)
+	}
+	var current int
+
+	if err := d.queryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return fmt.Errorf("error getting schema version error: %w", err)
+	}
+
+	for i := current; i < len(migrations); i++ {
+		version := i + 1
+		err := d.inTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, d.rebind("INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)"), version, formatTime(time.Now()))
+			return err
+		})
+
+		if err != nil {
+			return fmt.Errorf("error applying migration version=%d error: %w", version, err)
+		}
+		sqlLogger.Printf("applied migration version=%d\n", version)
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/database"
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+type NotificationRepository struct {
+	db *DB
+}
+
+func NewNotificationRepo(db *DB) *NotificationRepository {
+	return &NotificationRepository{
+		db: db,
+	}
+}
+
+func decodeNotification(data []byte, n *model.NotificationItem) error {
+	return json.Unmarshal(data, n)
+}
+
+// notificationArgs returns the column values of the notification in the insert order.
+func notificationArgs(n model.NotificationItem) ([]any, error) {
+	item, err := json.Marshal(n)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal notification error: %w", err)
+	}
+	return []any{n.PartitionKey, n.SortKey, n.ClientId, n.Date, string(item)}, nil
+}
+
+// Create stores the notification, an existing notification with the same id is replaced.
+func (r *NotificationRepository) Create(notification model.NotificationItem) error {
+	args, err := notificationArgs(notification)
+
+	if err != nil {
+		return err
+	}
+	_, err = r.db.exec(context.Background(), `INSERT INTO notifications (created_by, id, client_id, date, item) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (created_by, id) DO UPDATE SET client_id = excluded.client_id, date = excluded.date, item = excluded.item`, args...)
+	return err
+}
+
+// Update replaces an existing notification item when it still has the version of the item, the returned item has the new version.
+func (r *NotificationRepository) Update(notification model.NotificationItem) (model.NotificationItem, error) {
+	item, err := r.modify(notification.PartitionKey, notification.SortKey, func(item *model.NotificationItem) error {
+		if item.Version != notification.Version {
+			return database.ErrVersionConflict
+		}
+		*item = notification
+		return nil
+	})
+
+	if err != nil {
+		return model.NotificationItem{}, err
+	}
+	return *item, nil
+}
+
+func (r *NotificationRepository) Delete(createdBy, name string) error {
+	if _, err := r.db.exec(context.Background(), "DELETE FROM notifications WHERE created_by = ? AND id = ?", createdBy, name); err != nil {
+		sqlLogger.Println(err)
+		return fmt.Errorf("error while deleting notification: %s", name)
+	}
+	return nil
+}
+
+func (r *NotificationRepository) GetNotification(createdBy, name string) (*model.NotificationItem, error) {
+	var data []byte
+	err := r.db.queryRow(context.Background(), "SELECT item FROM notifications WHERE created_by = ? AND id = ?", createdBy, name).Scan(&data)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, database.ErrNotificationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error while getting notification: %w", err)
+	}
+	var item model.NotificationItem
+
+	if err := decodeNotification(data, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *NotificationRepository) GetNotificationCountByCreator(createdBy string) (int64, error) {
+	var count int64
+
+	if err := r.db.queryRow(context.Background(), "SELECT COUNT(*) FROM notifications WHERE created_by = ?", createdBy).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error querying notification by creator: %w", err)
+	}
+	return count, nil
+}
+
+// GetNotificationsByCreator gets notification by its creator sorted by date. pagination is Zero based.
+func (r *NotificationRepository) GetNotificationsByCreator(createdBy string, ops *database.PaginationOps) (*database.PaginatedNotifications, error) {
+	if ops == nil {
+		return nil, fmt.Errorf("Pagination ops is not optional")
+	}
+	rows, err := r.db.query(context.Background(), "SELECT item FROM notifications WHERE created_by = ? ORDER BY date, id LIMIT ? OFFSET ?", createdBy, ops.Limit, ops.Skip)
+
+	if err != nil {
+		return nil, fmt.Errorf("error querying notification by creator: %w", err)
+	}
+	items, err := scanItems(rows, decodeNotification)
+
+	if err != nil {
+		return nil, fmt.Errorf("error querying notification by creator: %w", err)
+	}
+	count, err := r.GetNotificationCountByCreator(createdBy)
+
+	if err != nil {
+		return nil, err
+	}
+	return &database.PaginatedNotifications{
+		Total: count,
+		Data:  items,
+	}, nil
+}
+
+// GetNotificationsByClient returns every notification of a creator that belongs to the client.
+func (r *NotificationRepository) GetNotificationsByClient(createdBy, clientId string) ([]model.NotificationItem, error) {
+	rows, err := r.db.query(context.Background(), "SELECT item FROM notifications WHERE created_by = ? AND client_id = ? ORDER BY id", createdBy, clientId)
+
+	if err != nil {
+		return nil, fmt.Errorf("error querying notification by client: %w", err)
+	}
+	return scanItems(rows, decodeNotification)
+}
+
+// GetNotificationsByStatus returns the notifications of every creator with the status sorted by creator and id.
+// status only lives in the item column so the rows are filtered after decoding them.
+func (r *NotificationRepository) GetNotificationsByStatus(status string) ([]model.NotificationItem, error) {
+	rows, err := r.db.query(context.Background(), "SELECT item FROM notifications ORDER BY created_by, id")
+
+	if err != nil {
+		return nil, fmt.Errorf("error querying notifications by status: %w", err)
+	}
+	items, err := scanItems(rows, decodeNotification)
+
+	if err != nil {
+		return nil, fmt.Errorf("error querying notifications by status: %w", err)
+	}
+	matching := make([]model.NotificationItem, 0, len(items))
+
+	for _, item := range items {
+		if item.Status == status {
+			matching = append(matching, item)
+		}
+	}
+	return matching, nil
+}
+
+func (r *NotificationRepository) SetStatus(partitionKey string, sortKey string, status string) error {
+	_, err := r.modify(partitionKey, sortKey, func(item *model.NotificationItem) error {
+		item.Status = status
+		return nil
+	})
+	return err
+}
+
+// ChangeStatus sets the status only when the notification has the from status, it fails with database.ErrStatusChanged otherwise.
+func (r *NotificationRepository) ChangeStatus(partitionKey, sortKey, from, to string) error {
+	_, err := r.modify(partitionKey, sortKey, func(item *model.NotificationItem) error {
+		if item.Status != from {
+			return database.ErrStatusChanged
+		}
+		item.Status = to
+		return nil
+	})
+	return err
+}
+
+// UpdateNotification applies the non empty fields of the patch, the same way the dynamo repository does.
+func (r *NotificationRepository) UpdateNotification(createdBy, name string, notification database.PatchNotificationItem) (*model.NotificationItem, error) {
+	return r.updateNotification(createdBy, name, notification, nil)
+}
+
+// updateNotification applies the patch, with runs in the same transaction when it is not nil.
+func (r *NotificationRepository) updateNotification(createdBy, name string, notification database.PatchNotificationItem, with func(ctx context.Context, tx *sql.Tx) error) (*model.NotificationItem, error) {
+	empty := notification.ClientId == "" && notification.Status == "" && notification.Date.IsZero() &&
+		notification.TTL == 0 && notification.Recurrence == nil && notification.DeliveryMethods == nil && notification.TemplateId == "" &&
+		notification.Subject == "" && notification.Message == "" && notification.TimeZone == "" && notification.RequestedDate == nil
+
+	if empty {
+		return &model.NotificationItem{}, database.ErrEmptyUpdate
+	}
+
+	return r.modifyWith(createdBy, name, func(item *model.NotificationItem) error {
+		if notification.ClientId != "" {
+			item.ClientId = notification.ClientId
+		}
+		if notification.Status != "" {
+			item.Status = notification.Status
+		}
+		ttl := notification.TTL
+		if !notification.Date.IsZero() {
+			item.Date = notification.Date.Format(time.RFC3339Nano)
+
+			if ttl == 0 && notification.Recurrence == nil {
+				ttl = notification.Date.Add(time.Hour * 24).Unix()
+			}
+		}
+		if ttl != 0 {
+			item.TTL = ttl
+		}
+		if notification.Recurrence != nil {
+			item.Recurrence = notification.Recurrence
+
+			if ttl == 0 {
+				// recurring notifications without an end do not expire.
+				item.TTL = 0
+			}
+		}
+		if notification.DeliveryMethods != nil {
+			item.DeliveryMethods = notification.DeliveryMethods
+		}
+		if notification.TemplateId != "" {
+			item.TemplateId = notification.TemplateId
+		}
+		if notification.Subject != "" {
+			item.Subject = notification.Subject
+		}
+		if notification.Message != "" {
+			item.Message = notification.Message
+		}
+		if notification.TimeZone != "" {
+			item.TimeZone = notification.TimeZone
+		}
+		if notification.RequestedDate != nil {
+			item.RequestedDate = *notification.RequestedDate
+		}
+		return nil
+	}, with)
+}
+
+// modify applies fn to the stored notification in a transaction and moves its version forward, an error from fn cancels the write.
+func (r *NotificationRepository) modify(createdBy, name string, fn func(item *model.NotificationItem) error) (*model.NotificationItem, error) {
+	return r.modifyWith(createdBy, name, fn, nil)
+}
+
+// modifyWith applies fn to the stored notification, with runs in the same transaction when it is not nil.
+func (r *NotificationRepository) modifyWith(createdBy, name string, fn func(item *model.NotificationItem) error, with func(ctx context.Context, tx *sql.Tx) error) (*model.NotificationItem, error) {
+	ctx := context.Background()
+	var item model.NotificationItem
+
+	err := r.db.inTx(ctx, func(tx *sql.Tx) error {
+		var data []byte
+		err := tx.QueryRowContext(ctx, r.db.rebind("SELECT item FROM notifications WHERE created_by = ? AND id = ?"+r.db.forUpdate()), createdBy, name).Scan(&data)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.ErrNotificationNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := decodeNotification(data, &item); err != nil {
+			return err
+		}
+		if err := fn(&item); err != nil {
+			return err
+		}
+		item.Version++
+		args, err := notificationArgs(item)
+
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, r.db.rebind("UPDATE notifications SET client_id = ?, date = ?, item = ? WHERE created_by = ? AND id = ?"), append(args[2:], createdBy, name)...)
+
+		if err != nil || with == nil {
+			return err
+		}
+		return with(ctx, tx)
+	})
+
+	if err != nil {
+		if errors.Is(err, database.ErrNotificationNotFound) || errors.Is(err, database.ErrVersionConflict) || errors.Is(err, database.ErrStatusChanged) {
+			return nil, err
+		}
+		sqlLogger.Printf("Error when updating item error: %s\n", err)
+		return nil, fmt.Errorf("error when updating notification item error: %w", err)
+	}
+	return &item, nil
+}
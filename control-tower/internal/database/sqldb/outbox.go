@@ -0,0 +1,110 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/japb1998/control-tower/internal/database"
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+func decodeOutbox(data []byte, entry *model.OutboxItem) error {
+	return json.Unmarshal(data, entry)
+}
+
+// insertOutbox stores the entry in the transaction of the notification write.
+func (r *NotificationRepository) insertOutbox(ctx context.Context, tx *sql.Tx, entry model.OutboxItem) error {
+	item, err := json.Marshal(entry)
+
+	if err != nil {
+		return fmt.Errorf("unable to marshal outbox entry error: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, r.db.rebind("INSERT INTO outbox (id, created_at, item) VALUES (?, ?, ?)"), entry.Id, entry.CreatedAt, string(item))
+	return err
+}
+
+// CreateWithOutbox stores the notification and its outbox entry in a single transaction.
+func (r *NotificationRepository) CreateWithOutbox(notification model.NotificationItem, entry model.OutboxItem) error {
+	args, err := notificationArgs(notification)
+
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	return r.db.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, r.db.rebind(`INSERT INTO notifications (created_by, id, client_id, date, item) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (created_by, id) DO UPDATE SET client_id = excluded.client_id, date = excluded.date, item = excluded.item`), args...)
+
+		if err != nil {
+			return err
+		}
+		return r.insertOutbox(ctx, tx, entry)
+	})
+}
+
+// UpdateNotificationWithOutbox applies the patch and stores the outbox entry in a single transaction.
+func (r *NotificationRepository) UpdateNotificationWithOutbox(createdBy, name string, notification database.PatchNotificationItem, entry model.OutboxItem) (*model.NotificationItem, error) {
+	return r.updateNotification(createdBy, name, notification, func(ctx context.Context, tx *sql.Tx) error {
+		return r.insertOutbox(ctx, tx, entry)
+	})
+}
+
+// GetOutbox returns up to limit pending outbox entries, the oldest first.
+func (r *NotificationRepository) GetOutbox(limit int) ([]model.OutboxItem, error) {
+	query := "SELECT item FROM outbox ORDER BY created_at, id"
+	args := []any{}
+
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := r.db.query(context.Background(), query, args...)
+
+	if err != nil {
+		return nil, fmt.Errorf("error querying outbox: %w", err)
+	}
+	return scanItems(rows, decodeOutbox)
+}
+
+// DeleteOutbox removes the entry, deleting an entry that does not exist is not an error.
+func (r *NotificationRepository) DeleteOutbox(id string) error {
+	if _, err := r.db.exec(context.Background(), "DELETE FROM outbox WHERE id = ?", id); err != nil {
+		return fmt.Errorf("error deleting outbox entry='%s' error: %w", id, err)
+	}
+	return nil
+}
+
+// RecordOutboxAttempt counts a failed attempt to apply the entry.
+func (r *NotificationRepository) RecordOutboxAttempt(id, lastError string) error {
+	ctx := context.Background()
+
+	return r.db.inTx(ctx, func(tx *sql.Tx) error {
+		var data []byte
+		err := tx.QueryRowContext(ctx, r.db.rebind("SELECT item FROM outbox WHERE id = ?"+r.db.forUpdate()), id).Scan(&data)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var entry model.OutboxItem
+
+		if err := decodeOutbox(data, &entry); err != nil {
+			return err
+		}
+		entry.Attempts++
+		entry.LastError = lastError
+		item, err := json.Marshal(entry)
+
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, r.db.rebind("UPDATE outbox SET item = ? WHERE id = ?"), string(item), id)
+		return err
+	})
+}
@@ -0,0 +1,54 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+type SettingsRepository struct {
+	db *DB
+}
+
+func NewSettingsRepo(db *DB) *SettingsRepository {
+	return &SettingsRepository{
+		db: db,
+	}
+}
+
+// GetSettings returns the settings of a creator or nil when the creator has not saved any.
+func (r *SettingsRepository) GetSettings(createdBy string) (*model.SettingsItem, error) {
+	var data []byte
+	err := r.db.queryRow(context.Background(), "SELECT item FROM settings WHERE created_by = ?", createdBy).Scan(&data)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting settings error: %w", err)
+	}
+	var item model.SettingsItem
+
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, fmt.Errorf("error unmarshalling settings error: %w", err)
+	}
+	return &item, nil
+}
+
+func (r *SettingsRepository) SaveSettings(settings model.SettingsItem) error {
+	item, err := json.Marshal(settings)
+
+	if err != nil {
+		return fmt.Errorf("error marshalling settings error: %w", err)
+	}
+	_, err = r.db.exec(context.Background(), "INSERT INTO settings (created_by, item) VALUES (?, ?) ON CONFLICT (created_by) DO UPDATE SET item = excluded.item", settings.PrimaryKey, string(item))
+
+	if err != nil {
+		return fmt.Errorf("error saving settings error: %w", err)
+	}
+	return nil
+}
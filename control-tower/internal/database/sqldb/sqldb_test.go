@@ -0,0 +1,96 @@
+package sqldb_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/japb1998/control-tower/internal/database/databasetest"
+	"github.com/japb1998/control-tower/internal/database/sqldb"
+)
+
+// openDBs returns a sqlite database and, when POSTGRES_URL is set, a postgres one.
+func openDBs(t *testing.T) map[string]*sqldb.DB {
+	dbs := make(map[string]*sqldb.DB)
+
+	lite, err := sqldb.Open(sqldb.DriverSQLite, filepath.Join(t.TempDir(), "control-tower.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbs[sqldb.DriverSQLite] = lite
+
+	if url := os.Getenv("POSTGRES_URL"); url != "" {
+		pg, err := sqldb.Open(sqldb.DriverPostgres, url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dbs[sqldb.DriverPostgres] = pg
+	}
+	t.Cleanup(func() {
+		for _, db := range dbs {
+			db.Close()
+		}
+	})
+	return dbs
+}
+
+func TestClientRepository(t *testing.T) {
+	for driver, db := range openDBs(t) {
+		t.Run(driver, func(t *testing.T) {
+			databasetest.ClientRepository(t, sqldb.NewClientRepo(db))
+		})
+	}
+}
+
+func TestNotificationRepository(t *testing.T) {
+	for driver, db := range openDBs(t) {
+		t.Run(driver, func(t *testing.T) {
+			databasetest.NotificationRepository(t, sqldb.NewNotificationRepo(db))
+		})
+	}
+}
+
+func TestConnectionRepository(t *testing.T) {
+	for driver, db := range openDBs(t) {
+		t.Run(driver, func(t *testing.T) {
+			databasetest.ConnectionRepository(t, sqldb.NewConnectionRepo(db))
+		})
+	}
+}
+
+func TestSettingsRepository(t *testing.T) {
+	for driver, db := range openDBs(t) {
+		t.Run(driver, func(t *testing.T) {
+			databasetest.SettingsRepository(t, sqldb.NewSettingsRepo(db))
+		})
+	}
+}
+
+func TestMessageRepository(t *testing.T) {
+	for driver, db := range openDBs(t) {
+		t.Run(driver, func(t *testing.T) {
+			databasetest.MessageRepository(t, sqldb.NewMessageRepo(db))
+		})
+	}
+}
+
+func TestTemplateRepository(t *testing.T) {
+	for driver, db := range openDBs(t) {
+		t.Run(driver, func(t *testing.T) {
+			databasetest.TemplateRepository(t, sqldb.NewTemplateRepo(db))
+		})
+	}
+}
+
+func TestMigrationsAreApplied(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control-tower.db")
+
+	// opening twice must not apply the migrations again.
+	for i := 0; i < 2; i++ {
+		db, err := sqldb.Open(sqldb.DriverSQLite, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+	}
+}
@@ -0,0 +1,77 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+type TemplateRepository struct {
+	db *DB
+}
+
+func NewTemplateRepo(db *DB) *TemplateRepository {
+	return &TemplateRepository{
+		db: db,
+	}
+}
+
+func decodeTemplate(data []byte, t *model.TemplateItem) error {
+	return json.Unmarshal(data, t)
+}
+
+func (r *TemplateRepository) SaveTemplate(template model.TemplateItem) error {
+	item, err := json.Marshal(template)
+
+	if err != nil {
+		return fmt.Errorf("error marshalling template error: %w", err)
+	}
+	_, err = r.db.exec(context.Background(), `INSERT INTO templates (created_by, id, item) VALUES (?, ?, ?)
+	ON CONFLICT (created_by, id) DO UPDATE SET item = excluded.item`, template.PrimaryKey, template.SortKey, string(item))
+
+	if err != nil {
+		return fmt.Errorf("error saving template error: %w", err)
+	}
+	return nil
+}
+
+// GetTemplate returns the template or nil when the creator has no template with the id.
+func (r *TemplateRepository) GetTemplate(createdBy, id string) (*model.TemplateItem, error) {
+	var data []byte
+	err := r.db.queryRow(context.Background(), "SELECT item FROM templates WHERE created_by = ? AND id = ?", createdBy, id).Scan(&data)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting template error: %w", err)
+	}
+	var item model.TemplateItem
+
+	if err := decodeTemplate(data, &item); err != nil {
+		return nil, fmt.Errorf("error unmarshalling template error: %w", err)
+	}
+	return &item, nil
+}
+
+// GetTemplates returns all the templates of the creator sorted by id.
+func (r *TemplateRepository) GetTemplates(createdBy string) ([]model.TemplateItem, error) {
+	rows, err := r.db.query(context.Background(), "SELECT item FROM templates WHERE created_by = ? ORDER BY id", createdBy)
+
+	if err != nil {
+		sqlLogger.Println("error querying templates error:", err)
+		return nil, fmt.Errorf("error querying templates error: %s", err)
+	}
+	return scanItems(rows, decodeTemplate)
+}
+
+func (r *TemplateRepository) DeleteTemplate(createdBy, id string) error {
+	if _, err := r.db.exec(context.Background(), "DELETE FROM templates WHERE created_by = ? AND id = ?", createdBy, id); err != nil {
+		return fmt.Errorf("error deleting template error: %w", err)
+	}
+	return nil
+}
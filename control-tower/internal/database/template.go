@@ -0,0 +1,149 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+var (
+	templateRepository *TemplateRepository
+	templateLogger     = log.New(os.Stdout, "[Template Repository] ", log.Default().Flags())
+)
+
+type TemplateRepository struct {
+	Client    *DynamoClient
+	tableName string
+}
+
+func NewTemplateRepo(sess *session.Session) *TemplateRepository {
+	templateLogger.Println("template Table ", os.Getenv("TEMPLATE_TABLE"))
+	if templateRepository == nil {
+		client := newDynamoClient(sess)
+		templateRepository = &TemplateRepository{
+			Client:    client,
+			tableName: os.Getenv("TEMPLATE_TABLE"),
+		}
+	}
+
+	return templateRepository
+}
+
+// SaveTemplate creates or replaces the template.
+func (r *TemplateRepository) SaveTemplate(template model.TemplateItem) error {
+	item, err := dynamodbattribute.MarshalMap(template)
+
+	if err != nil {
+		return fmt.Errorf("unable to marshal template error: %w", err)
+	}
+
+	_, err = r.Client.PutItem(&dynamodb.PutItemInput{
+		TableName: &r.tableName,
+		Item:      item,
+	})
+
+	if err != nil {
+		return fmt.Errorf("error while saving template: %w", err)
+	}
+	return nil
+}
+
+// GetTemplate returns the template or nil when the creator has no template with the id.
+func (r *TemplateRepository) GetTemplate(createdBy, id string) (*model.TemplateItem, error) {
+	key, err := dynamodbattribute.MarshalMap(map[string]string{
+		"primaryKey": createdBy,
+		"sortKey":    id,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid template creator: '%s' id: '%s', error: %w", createdBy, id, err)
+	}
+
+	output, err := r.Client.GetOne(&dynamodb.GetItemInput{
+		TableName: &r.tableName,
+		Key:       key,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("error while getting template: %w", err)
+	}
+
+	if len(output.Item) == 0 {
+		return nil, nil
+	}
+	var item model.TemplateItem
+
+	if err := dynamodbattribute.UnmarshalMap(output.Item, &item); err != nil {
+		return nil, fmt.Errorf("error while unmarshalling template: %w", err)
+	}
+	return &item, nil
+}
+
+// GetTemplates returns all the templates of the creator sorted by id.
+func (r *TemplateRepository) GetTemplates(createdBy string) ([]model.TemplateItem, error) {
+	key, err := dynamodbattribute.Marshal(createdBy)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid template creator: '%s', error: %w", createdBy, err)
+	}
+	input := &dynamodb.QueryInput{
+		TableName:              &r.tableName,
+		KeyConditionExpression: aws.String("#primaryKey = :primaryKey"),
+		ExpressionAttributeNames: map[string]*string{
+			"#primaryKey": aws.String("primaryKey"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":primaryKey": key,
+		},
+	}
+	templates := make([]model.TemplateItem, 0)
+
+	for {
+		output, err := r.Client.Query(input)
+
+		if err != nil {
+			return nil, fmt.Errorf("error querying templates error: %w", err)
+		}
+		var items []model.TemplateItem
+
+		if err := dynamodbattribute.UnmarshalListOfMaps(output.Items, &items); err != nil {
+			templateLogger.Println("error unmarshalling templates error:", err)
+			return nil, fmt.Errorf("error unmarshalling templates")
+		}
+		templates = append(templates, items...)
+
+		if output.LastEvaluatedKey == nil {
+			break
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+	return templates, nil
+}
+
+// DeleteTemplate removes the template, deleting a missing template is not an error.
+func (r *TemplateRepository) DeleteTemplate(createdBy, id string) error {
+	key, err := dynamodbattribute.MarshalMap(map[string]string{
+		"primaryKey": createdBy,
+		"sortKey":    id,
+	})
+
+	if err != nil {
+		return fmt.Errorf("invalid template creator: '%s' id: '%s', error: %w", createdBy, id, err)
+	}
+
+	_, err = r.Client.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: &r.tableName,
+		Key:       key,
+	})
+
+	if err != nil {
+		return fmt.Errorf("error while deleting template: %w", err)
+	}
+	return nil
+}
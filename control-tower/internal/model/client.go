@@ -7,30 +7,36 @@ import (
 )
 
 type ClientItem struct {
-	PrimaryKey   string     `json:"primaryKey"`
-	SortKey      string     `json:"sortKey"`
-	Phone        string     `json:"phone"`
-	Email        string     `json:"email"`
-	FirstName    string     `json:"firstName"`
-	LastName     string     `json:"lastName"`
-	Description  string     `json:"description"`
-	OptIn        bool       `json:"optIn"`
-	LastSeen     *time.Time `json:"lastSeen"`
-	CreatedAt    time.Time  `json:"createdAt"`
-	LastUpdateAt time.Time  `json:"lastUpdateAt"`
+	PrimaryKey       string     `json:"primaryKey"`
+	SortKey          string     `json:"sortKey"`
+	Phone            string     `json:"phone,omitempty"` // key of the PHONE index, clients without a phone are left out of it.
+	Email            string     `json:"email,omitempty"` // key of the EMAIL index, clients without an email are left out of it.
+	FirstName        string     `json:"firstName"`
+	LastName         string     `json:"lastName"`
+	Description      string     `json:"description"`
+	OptIn            bool       `json:"optIn"`
+	DeliveryMethods  []int8     `json:"deliveryMethods"`            // preferred delivery methods
+	DisabledChannels []int8     `json:"disabledChannels,omitempty"` // channels that are never used for the client, like emails that bounced.
+	Language         string     `json:"language,omitempty"`         // language of the reminders, the creator default when empty.
+	TimeZone         string     `json:"timeZone,omitempty"`         // IANA time zone of the client, the creator one when empty.
+	LastSeen         *time.Time `json:"lastSeen"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	LastUpdateAt     time.Time  `json:"lastUpdateAt"`
+	DeletedAt        *time.Time `json:"deletedAt,omitempty"` // archived clients are hidden until they are restored.
 }
 
-func NewClientItem(creator, phone, email, firstName, lastName, description string, lastSeen *time.Time) *ClientItem {
+func NewClientItem(creator, phone, email, firstName, lastName, description string, lastSeen *time.Time, deliveryMethods []int8) *ClientItem {
 	return &ClientItem{
-		PrimaryKey:  creator,
-		SortKey:     uuid.New().String(),
-		Phone:       phone,
-		Email:       email,
-		FirstName:   firstName,
-		LastName:    lastName,
-		Description: description,
-		CreatedAt:   time.Now().UTC(),
-		OptIn:       true,
-		LastSeen:    lastSeen,
+		PrimaryKey:      creator,
+		SortKey:         uuid.New().String(),
+		Phone:           phone,
+		Email:           email,
+		FirstName:       firstName,
+		LastName:        lastName,
+		Description:     description,
+		CreatedAt:       time.Now().UTC(),
+		OptIn:           true,
+		LastSeen:        lastSeen,
+		DeliveryMethods: deliveryMethods,
 	}
 }
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MessageInbound  = "inbound"  // sent by the client.
+	MessageOutbound = "outbound" // reminders and replies sent to the client.
+)
+
+// messageTimeLayout keeps the nanoseconds so the sort keys of a thread are in send order.
+const messageTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// MessageItem is a message of the conversation with a client.
+type MessageItem struct {
+	PrimaryKey     string    `json:"primaryKey"` // createdBy#clientId
+	SortKey        string    `json:"sortKey"`    // createdAt#id, inbound#messageId for the client messages with a provider id
+	Id             string    `json:"id"`
+	CreatedBy      string    `json:"createdBy"`
+	ClientId       string    `json:"clientId"`
+	Direction      string    `json:"direction"`
+	Channel        int8      `json:"channel"`
+	Subject        string    `json:"subject,omitempty"` // emails only
+	Body           string    `json:"body"`
+	MessageId      string    `json:"messageId,omitempty"`      // provider message id
+	NotificationId string    `json:"notificationId,omitempty"` // reminder the message was sent for
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+func NewMessageItem(createdBy, clientId, direction string, channel int8, subject, body string, at time.Time) *MessageItem {
+	id := uuid.New().String()
+	at = at.UTC()
+
+	return &MessageItem{
+		PrimaryKey: MessageThreadKey(createdBy, clientId),
+		SortKey:    at.Format(messageTimeLayout) + "#" + id,
+		Id:         id,
+		CreatedBy:  createdBy,
+		ClientId:   clientId,
+		Direction:  direction,
+		Channel:    channel,
+		Subject:    subject,
+		Body:       body,
+		CreatedAt:  at,
+	}
+}
+
+// InboundMessageKey is the sort key of a message sent by the client, the provider message id keeps retried webhooks from storing it twice.
+func InboundMessageKey(messageId string) string {
+	return MessageInbound + "#" + messageId
+}
+
+// MessageThreadKey is the key of the messages of a client.
+func MessageThreadKey(createdBy, clientId string) string {
+	return createdBy + "#" + clientId
+}
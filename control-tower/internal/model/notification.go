@@ -8,14 +8,66 @@ import (
 
 // NotificationItem is the entity that will be stored in dynamo
 type NotificationItem struct {
-	PartitionKey    string `json:"primaryKey"` //createdBy
-	SortKey         string `json:"sortKey"`    // ID this will be the schedule name
-	Status          string `json:"status"`
-	ClientId        string `json:"clientId"`
-	Date            string `json:"date"`
-	DeliveryMethods []int8 `json:"deliveryMethods"`
-	ClientToken     string `json:"clientToken"`
-	TTL             int64  `json:"TTL"` //  time to live
+	PartitionKey    string             `json:"primaryKey"` //createdBy
+	SortKey         string             `json:"sortKey"`    // ID this will be the schedule name
+	Status          string             `json:"status"`
+	ClientId        string             `json:"clientId"`
+	Date            string             `json:"date"`
+	DeliveryMethods []int8             `json:"deliveryMethods"`
+	ClientToken     string             `json:"clientToken"`
+	Recurrence      *Recurrence        `json:"recurrence,omitempty"`
+	Occurrences     []OccurrenceItem   `json:"occurrences,omitempty"`   // one entry per send of a recurring notification
+	Deliveries      []DeliveryItem     `json:"deliveries,omitempty"`    // one entry per channel attempt
+	PendingEvents   []ChannelEventItem `json:"pendingEvents,omitempty"` // provider events that arrived before the delivery of their message was recorded
+	RetrySchedule   string             `json:"retrySchedule,omitempty"` // pending schedule that retries the failed channels
+	ConfirmedAt     string             `json:"confirmedAt,omitempty"`   // when the client confirmed the reminder
+	TemplateId      string             `json:"templateId,omitempty"`    // template of the reminder, the channel defaults are used when empty
+	Subject         string             `json:"subject,omitempty"`       // replaces the subject of the email reminder
+	Message         string             `json:"message,omitempty"`       // replaces the body of the reminder in every channel
+	TimeZone        string             `json:"timeZone,omitempty"`      // IANA time zone of the schedule, from the client or the creator
+	RequestedDate   string             `json:"requestedDate,omitempty"` // date asked for when the send window moved the notification
+	Kind            string             `json:"kind,omitempty"`          // what scheduled the notification, empty when the creator did
+	Version         int                `json:"version,omitempty"`       // incremented by every write, full item updates only apply to the version they read
+	TTL             int64              `json:"TTL,omitempty"`           //  time to live
+}
+
+// Recurrence describes how a notification repeats. fields follow the RRULE ones (FREQ, INTERVAL, UNTIL, COUNT).
+type Recurrence struct {
+	Frequency string `json:"frequency"`
+	Interval  int    `json:"interval"`
+	Until     string `json:"until,omitempty"`
+	Count     int    `json:"count,omitempty"`
+}
+
+// OccurrenceItem is the result of a single send of a recurring notification.
+type OccurrenceItem struct {
+	Number      int    `json:"number"`
+	Date        string `json:"date"` // date the occurrence was scheduled for
+	SentAt      string `json:"sentAt"`
+	Status      string `json:"status"`
+	ConfirmedAt string `json:"confirmedAt,omitempty"` // when the client confirmed the occurrence
+}
+
+// ChannelEventItem is a provider event kept until the delivery of its message is recorded.
+type ChannelEventItem struct {
+	Channel   int8   `json:"channel"`
+	MessageId string `json:"messageId"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	At        string `json:"at"`
+}
+
+// DeliveryItem is the result of one attempt to send the notification through a channel.
+type DeliveryItem struct {
+	Channel    int8   `json:"channel"`
+	Status     string `json:"status"`
+	MessageId  string `json:"messageId,omitempty"` // provider message id
+	Error      string `json:"error,omitempty"`
+	Attempt    int    `json:"attempt"`              // 1 based, counted per channel and occurrence
+	Occurrence int    `json:"occurrence,omitempty"` // occurrence number of recurring notifications
+	StartedAt  string `json:"startedAt"`
+	FinishedAt string `json:"finishedAt"`
+	UpdatedAt  string `json:"updatedAt,omitempty"` // last provider event, like delivered or opened.
 }
 
 func NewNotificationItem(pk, status, clientId string, date time.Time, deliveryMethods []int8) *NotificationItem {
@@ -31,3 +83,15 @@ func NewNotificationItem(pk, status, clientId string, date time.Time, deliveryMe
 
 	}
 }
+
+// WithRecurrence makes the item recurring. last is the date of the last occurrence, nil when the recurrence has no end.
+func (n *NotificationItem) WithRecurrence(r *Recurrence, last *time.Time) *NotificationItem {
+	n.Recurrence = r
+	if last == nil {
+		// items without an end are kept until they are deleted.
+		n.TTL = 0
+	} else {
+		n.TTL = last.Add(time.Hour * 24).Unix()
+	}
+	return n
+}
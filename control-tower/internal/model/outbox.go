@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxItem is a scheduler operation stored in the same transaction as the notification write that needs it.
+// it is removed once the schedule is created or updated.
+type OutboxItem struct {
+	Id             string `json:"id"`
+	Operation      string `json:"operation"` // create or update
+	CreatedBy      string `json:"createdBy"`
+	NotificationId string `json:"notificationId"`
+	ClientToken    string `json:"clientToken"` // token of the notification, creates the schedule only once
+	Schedule       string `json:"schedule"`    // schedule name
+	Target         string `json:"target"`
+	Role           string `json:"role"`
+	TimeZone       string `json:"timeZone"`
+	Payload        string `json:"payload"`
+	Date           string `json:"date"`
+	EveryDays      int    `json:"everyDays,omitempty"` // recurring schedules only
+	End            string `json:"end,omitempty"`       // end of recurring schedules
+	Attempts       int    `json:"attempts,omitempty"`
+	LastError      string `json:"lastError,omitempty"`
+	CreatedAt      string `json:"createdAt"`
+}
+
+func NewOutboxItem(operation, createdBy, notificationId, clientToken string) *OutboxItem {
+	return &OutboxItem{
+		Id:             uuid.New().String(),
+		Operation:      operation,
+		CreatedBy:      createdBy,
+		NotificationId: notificationId,
+		ClientToken:    clientToken,
+		Schedule:       notificationId,
+		CreatedAt:      time.Now().UTC().Format(time.RFC3339Nano),
+	}
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// SettingsItem holds the configuration of a creator. there is one item per creator.
+type SettingsItem struct {
+	PrimaryKey               string `json:"primaryKey"` // createdBy
+	MaintenanceIntervalWeeks int    `json:"maintenanceIntervalWeeks"`
+	DisabledChannels         []int8 `json:"disabledChannels"`          // delivery methods that are never used for the creator clients.
+	DefaultLanguage          string `json:"defaultLanguage,omitempty"` // language of the clients without one.
+	TimeZone                 string `json:"timeZone,omitempty"`        // IANA time zone of the creator and their clients without one.
+	// SendWindow limits the hours reminders are sent at, reminders are sent at any time without it.
+	SendWindow   *SendWindowItem `json:"sendWindow,omitempty"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	LastUpdateAt time.Time       `json:"lastUpdateAt"`
+}
+
+// SendWindowItem are the hours and week days reminders can be sent at, in the notification time zone.
+type SendWindowItem struct {
+	Start       string `json:"start"`          // 15:04 layout
+	End         string `json:"end"`            // 15:04 layout, inclusive
+	Days        []int8 `json:"days,omitempty"` // allowed week days, 0 is sunday. every day when empty.
+	OutOfWindow string `json:"outOfWindow"`    // reject or shift the notifications outside of the window.
+}
+
+func NewSettingsItem(creator string, maintenanceIntervalWeeks int) *SettingsItem {
+	now := time.Now().UTC()
+	return &SettingsItem{
+		PrimaryKey:               creator,
+		MaintenanceIntervalWeeks: maintenanceIntervalWeeks,
+		CreatedAt:                now,
+		LastUpdateAt:             now,
+	}
+}
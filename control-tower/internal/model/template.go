@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TemplateItem is a reminder template of a creator for one channel.
+type TemplateItem struct {
+	PrimaryKey         string `json:"primaryKey"` // createdBy
+	SortKey            string `json:"sortKey"`    // id
+	Name               string `json:"name"`
+	Channel            int8   `json:"channel"`
+	Subject            string `json:"subject,omitempty"` // emails only
+	Body               string `json:"body"`
+	ProviderTemplateId string `json:"providerTemplateId,omitempty"` // mailgun template or twilio content template
+	Language           string `json:"language,omitempty"`           // language of the subject and body
+	// Variants are the translations of the template by language.
+	Variants  map[string]TemplateVariantItem `json:"variants,omitempty"`
+	CreatedAt time.Time                      `json:"createdAt"`
+	UpdatedAt time.Time                      `json:"updatedAt"`
+}
+
+// TemplateVariantItem is a template in another language.
+type TemplateVariantItem struct {
+	Subject            string `json:"subject,omitempty"`
+	Body               string `json:"body"`
+	ProviderTemplateId string `json:"providerTemplateId,omitempty"`
+}
+
+func NewTemplateItem(createdBy, name string, channel int8, subject, body, providerTemplateId string) *TemplateItem {
+	now := time.Now().UTC()
+
+	return &TemplateItem{
+		PrimaryKey:         createdBy,
+		SortKey:            uuid.New().String(),
+		Name:               name,
+		Channel:            channel,
+		Subject:            subject,
+		Body:               body,
+		ProviderTemplateId: providerTemplateId,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+}
@@ -2,8 +2,10 @@
 package scheduler
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 	awsScheduler "github.com/aws/aws-sdk-go/service/scheduler"
 )
 
+// schedules take any IANA time zone, these are kept for the existing callers.
 var (
 	TimeZoneETD = "America/New_York"
 	TimeZoneECT = "America/Los_Angeles"
@@ -21,6 +24,11 @@ var (
 	ErrInvalidDate = errors.New("Invalid Date was provided")
 	ErrInvalidTZ   = errors.New("Invalid time zone provided")
 	ErrNotFound    = errors.New("Schedule Not Found")
+	ErrConflict    = errors.New("Schedule already exists")
+)
+
+const (
+	expressionDateLayout = "2006-01-02T15:04:05"
 )
 
 type schedule struct {
@@ -30,6 +38,14 @@ type schedule struct {
 	Role     string
 	Target   string
 	Date     time.Time
+	Repeat   *Repeat // nil for one time schedules
+}
+
+// Repeat makes a schedule fire every EveryDays days starting on the schedule date, at the same wall clock time of the schedule time zone.
+// End is optional. eventbridge fires schedules repeated every few weeks every week, their target skips the weeks in between.
+type Repeat struct {
+	EveryDays int
+	End       *time.Time
 }
 
 type scheduler struct {
@@ -56,60 +72,151 @@ func NewSchedule(name, targetID, role, tz, payload string, date time.Time) *sche
 	}
 }
 
-// creates a schedule using aws eventbridge and returns the schedule name. important: schedule name must be unique.
-func (s *scheduler) CreateSchedule(sch *schedule, token string) (name string, err error) {
+// Every turns the schedule into a recurring one.
+func (sch *schedule) Every(days int, end *time.Time) *schedule {
+	sch.Repeat = &Repeat{
+		EveryDays: days,
+		End:       end,
+	}
+	return sch
+}
 
-	var expression string
-	var loc *time.Location
+// validate checks the schedule can be created and returns its location.
+func (sch *schedule) validate() (*time.Location, error) {
+	loc, err := LoadTimeZone(sch.TimeZone)
+
+	if err != nil {
+		return nil, err
+	}
 	// check current time
-	switch sch.TimeZone {
-	case TimeZoneETD:
-		loc, err = time.LoadLocation(TimeZoneETD)
+	if sch.Date.Before(time.Now().Add(time.Second * 30)) {
+		return nil, fmt.Errorf("date must be after at least 30s greater than the current time, Error: %w", ErrInvalidDate)
+	}
+	if sch.Repeat != nil && sch.Repeat.EveryDays < 1 {
+		return nil, fmt.Errorf("recurring schedules must repeat at least every day, Error: %w", ErrInvalidDate)
+	}
+	return loc, nil
+}
 
-		if err != nil {
-			return "", err
-		}
+// expression returns the eventbridge schedule expression and the time zone it is written in.
+// one time schedules use the at() expression in the schedule location. weekly schedules use a cron() expression in the location
+// so they keep their wall clock time when the clocks change, other recurring schedules a rate from their start date.
+func (sch *schedule) expression(loc *time.Location) (expression string, tz string) {
+	if sch.Repeat == nil {
+		return atExpression(sch.Date, loc)
+	}
+	if sch.Repeat.EveryDays%7 == 0 {
+		return weeklyExpression(sch.Date, loc), loc.String()
+	}
+	return fmt.Sprintf("rate(%d days)", sch.Repeat.EveryDays), loc.String()
+}
 
-	case TimeZoneECT:
-		loc, err = time.LoadLocation(TimeZoneETD)
+// description keeps what the expression can't tell, the time zone of the schedule and how often it repeats.
+func (sch *schedule) description() (*string, error) {
+	d := scheduleDescription{TimeZone: sch.TimeZone}
 
-		if err != nil {
-			return "", err
-		}
+	if sch.Repeat != nil {
+		d.EveryDays = sch.Repeat.EveryDays
+	}
+	b, err := json.Marshal(d)
 
-	default:
-		return "", ErrInvalidTZ
+	if err != nil {
+		return nil, err
 	}
-	if sch.Date.Before(time.Now().Add(time.Second * 30)) {
-		return "", fmt.Errorf("date must be after at least 30s greater than the current time, Error: %w", ErrInvalidDate)
+	return aws.String(string(b)), nil
+}
+
+// scheduleDescription is stored as the description of the eventbridge schedules.
+type scheduleDescription struct {
+	TimeZone  string `json:"timeZone"`
+	EveryDays int    `json:"everyDays,omitempty"`
+}
+
+// parseDescription returns the description of the schedule, schedules created before it was stored have an empty one.
+func parseDescription(description *string) scheduleDescription {
+	var d scheduleDescription
+
+	if description != nil {
+		_ = json.Unmarshal([]byte(*description), &d)
 	}
-	expression = fmt.Sprintf("at(%s)", sch.Date.In(loc).Format("2006-01-02T15:04:05"))
-	target := &awsScheduler.Target{
-		Arn:     &sch.Target,
-		RoleArn: &sch.Role,
-		Input:   &sch.Payload,
-		RetryPolicy: &awsScheduler.RetryPolicy{
-			MaximumRetryAttempts: aws.Int64(0),
-		},
+	return d
+}
+
+// actionAfterCompletion schedules are removed once they are done. recurring schedules without end never complete.
+func (sch *schedule) actionAfterCompletion() *string {
+	if sch.Repeat != nil && sch.Repeat.End == nil {
+		return aws.String("NONE")
+	}
+	return aws.String("DELETE")
+}
+
+// window returns the start and end dates for recurring schedules.
+func (sch *schedule) window() (start *time.Time, end *time.Time) {
+	if sch.Repeat == nil {
+		return nil, nil
+	}
+	return aws.Time(sch.Date), sch.Repeat.End
+}
+
+// creates a schedule using aws eventbridge and returns the schedule name. important: schedule name must be unique.
+func (s *scheduler) CreateSchedule(sch *schedule, token string) (name string, err error) {
+	input, err := sch.createInput(token)
+
+	if err != nil {
+		return "", err
+	}
+	_, err = s.ebScheduler.CreateSchedule(input)
+
+	if err != nil {
+		var conflict *awsScheduler.ConflictException
+		if errors.As(err, &conflict) {
+			return "", ErrConflict
+		}
+		return "", err
+	}
+	return sch.Name, nil
+}
+
+func (sch *schedule) createInput(token string) (*awsScheduler.CreateScheduleInput, error) {
+	loc, err := sch.validate()
+
+	if err != nil {
+		return nil, err
 	}
+	expression, tz := sch.expression(loc)
+	start, end := sch.window()
+	description, err := sch.description()
 
-	input := &awsScheduler.CreateScheduleInput{
+	if err != nil {
+		return nil, err
+	}
+	return &awsScheduler.CreateScheduleInput{
 		Name:                       &sch.Name,
+		Description:                description,
 		ScheduleExpression:         &expression,
-		ActionAfterCompletion:      aws.String("DELETE"),
-		Target:                     target,
-		ScheduleExpressionTimezone: &sch.TimeZone,
+		ActionAfterCompletion:      sch.actionAfterCompletion(),
+		Target:                     sch.target(),
+		ScheduleExpressionTimezone: &tz,
+		StartDate:                  start,
+		EndDate:                    end,
 		FlexibleTimeWindow: &awsScheduler.FlexibleTimeWindow{
 			Mode: aws.String("OFF"),
 		},
 		ClientToken: &token,
-	}
-	_, err = s.ebScheduler.CreateSchedule(input)
+	}, nil
+}
 
-	if err != nil {
-		return "", err
+// target is the target of the schedule on create and update.
+func (sch *schedule) target() *awsScheduler.Target {
+	return &awsScheduler.Target{
+		Arn:     &sch.Target,
+		RoleArn: &sch.Role,
+		Input:   &sch.Payload,
+		// failed channels are retried by the delivery service with their own schedules, retrying the target would send every channel again.
+		RetryPolicy: &awsScheduler.RetryPolicy{
+			MaximumRetryAttempts: aws.Int64(0),
+		},
 	}
-	return sch.Name, nil
 }
 
 func (s *scheduler) DeleteSchedule(name, token string) error {
@@ -139,33 +246,75 @@ func (s *scheduler) GetSchedule(name string) (*schedule, error) {
 		}
 		return nil, err
 	}
-	expression, err := time.Parse("2006-01-02T15:04:05", strings.TrimSuffix(strings.TrimPrefix(*output.ScheduleExpression, "at("), ")"))
+	description := parseDescription(output.Description)
+	// ambiguous dates are written in UTC, the schedule keeps the time zone it was created with.
+	tz := *output.ScheduleExpressionTimezone
+	if description.TimeZone != "" {
+		tz = description.TimeZone
+	}
+
+	switch expression := *output.ScheduleExpression; {
+	case strings.HasPrefix(expression, "cron("):
+		if description.EveryDays == 0 || output.StartDate == nil {
+			return nil, fmt.Errorf("error while parsing output expression='%s'", expression)
+		}
+		sch := NewSchedule(*output.Name, *output.Target.Arn, *output.Target.RoleArn, tz, *output.Target.Input, *output.StartDate)
+
+		return sch.Every(description.EveryDays, output.EndDate), nil
+	case strings.HasPrefix(expression, "rate("):
+		days, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(expression, "rate("), " days)"))
+
+		if err != nil || output.StartDate == nil {
+			return nil, fmt.Errorf("error while parsing output expression='%s'", expression)
+		}
+		sch := NewSchedule(*output.Name, *output.Target.Arn, *output.Target.RoleArn, tz, *output.Target.Input, *output.StartDate)
+
+		return sch.Every(days, output.EndDate), nil
+	}
+	date, err := parseAtExpression(*output.ScheduleExpression, *output.ScheduleExpressionTimezone)
 
 	if err != nil {
-		return nil, fmt.Errorf("error while parsing output expression error: %w", err)
+		return nil, err
 	}
-	sch := NewSchedule(*output.Name, *output.Target.Arn, *output.Target.RoleArn, *output.ScheduleExpressionTimezone, *output.Target.Input, expression)
+	sch := NewSchedule(*output.Name, *output.Target.Arn, *output.Target.RoleArn, tz, *output.Target.Input, date)
 
 	return sch, nil
 }
 
-func (s *scheduler) UpdateSchedule(sch *schedule) (name string, err error) {
-	expression := fmt.Sprintf("at(%s)", sch.Date.Format("2006-01-02T15:04:05"))
-	input := &awsScheduler.UpdateScheduleInput{
-		Name: &sch.Name,
-		Target: &awsScheduler.Target{
-			Arn:     &sch.Target,
-			RoleArn: &sch.Role,
-			Input:   &sch.Payload,
-		},
-		ScheduleExpression:         &expression,
-		ActionAfterCompletion:      aws.String("DELETE"),
-		ScheduleExpressionTimezone: &sch.TimeZone,
-		FlexibleTimeWindow: &awsScheduler.FlexibleTimeWindow{
-			Mode: aws.String("OFF"),
-		},
+// ListSchedules returns the names of the schedules that start with prefix, an empty prefix returns every schedule.
+func (s *scheduler) ListSchedules(prefix string) ([]string, error) {
+	input := &awsScheduler.ListSchedulesInput{}
+
+	if prefix != "" {
+		input.NamePrefix = aws.String(prefix)
+	}
+	names := make([]string, 0)
+
+	for {
+		output, err := s.ebScheduler.ListSchedules(input)
+
+		if err != nil {
+			return nil, err
+		}
+		for _, summary := range output.Schedules {
+			names = append(names, aws.StringValue(summary.Name))
+		}
+
+		if output.NextToken == nil {
+			break
+		}
+		input.NextToken = output.NextToken
 	}
+	return names, nil
+}
+
+// UpdateSchedule replaces the schedule, the date is written in the schedule time zone like on create.
+func (s *scheduler) UpdateSchedule(sch *schedule) (name string, err error) {
+	input, err := sch.updateInput()
 
+	if err != nil {
+		return "", err
+	}
 	_, err = s.ebScheduler.UpdateSchedule(input)
 
 	if err != nil {
@@ -175,3 +324,32 @@ func (s *scheduler) UpdateSchedule(sch *schedule) (name string, err error) {
 	return sch.Name, nil
 
 }
+
+// updateInput replaces every field of the schedule, the fields that are left out go back to their defaults.
+func (sch *schedule) updateInput() (*awsScheduler.UpdateScheduleInput, error) {
+	loc, err := LoadTimeZone(sch.TimeZone)
+
+	if err != nil {
+		return nil, err
+	}
+	expression, tz := sch.expression(loc)
+	start, end := sch.window()
+	description, err := sch.description()
+
+	if err != nil {
+		return nil, err
+	}
+	return &awsScheduler.UpdateScheduleInput{
+		Name:                       &sch.Name,
+		Description:                description,
+		Target:                     sch.target(),
+		ScheduleExpression:         &expression,
+		ActionAfterCompletion:      sch.actionAfterCompletion(),
+		ScheduleExpressionTimezone: &tz,
+		StartDate:                  start,
+		EndDate:                    end,
+		FlexibleTimeWindow: &awsScheduler.FlexibleTimeWindow{
+			Mode: aws.String("OFF"),
+		},
+	}, nil
+}
@@ -0,0 +1,34 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsScheduler "github.com/aws/aws-sdk-go/service/scheduler"
+)
+
+func TestScheduleInputsDisableTargetRetries(t *testing.T) {
+	date := time.Now().Add(time.Hour)
+	oneTime := NewSchedule("one-time", "arn:target", "arn:role", "America/New_York", "{}", date)
+	recurring := NewSchedule("recurring", "arn:target", "arn:role", "America/New_York", "{}", date).Every(7, nil)
+
+	for _, sch := range []*schedule{oneTime, recurring} {
+		created, err := sch.createInput("token")
+		if err != nil {
+			t.Fatal(err)
+		}
+		updated, err := sch.updateInput()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, target := range map[string]*awsScheduler.Target{"create": created.Target, "update": updated.Target} {
+			if aws.StringValue(target.Input) != "{}" {
+				t.Errorf("%s '%s': expected the payload as input, got: %+v", name, sch.Name, target)
+			}
+			if target.RetryPolicy == nil || target.RetryPolicy.MaximumRetryAttempts == nil || *target.RetryPolicy.MaximumRetryAttempts != 0 {
+				t.Errorf("%s '%s': expected no target retries, got: %+v", name, sch.Name, target.RetryPolicy)
+			}
+		}
+	}
+}
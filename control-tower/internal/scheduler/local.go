@@ -0,0 +1,346 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var localLogger = log.New(os.Stdout, "[Local Scheduler] ", log.Default().Flags())
+
+const (
+	// localRetryDelay is the wait before the first retry of a failed target, it doubles with each attempt up to localMaxRetryDelay.
+	localRetryDelay    = time.Second
+	localMaxRetryDelay = time.Minute * 10
+)
+
+// Invoker receives the payload of a schedule once it is due. it plays the role of the schedule target.
+type Invoker func(ctx context.Context, payload string) error
+
+// localSchedule is a schedule stored by the local scheduler.
+type localSchedule struct {
+	Schedule *schedule `json:"schedule"`
+	Token    string    `json:"token"`
+	Next     time.Time `json:"next"`               // next time the schedule fires
+	Attempts int       `json:"attempts,omitempty"` // failed calls to the target for the current run
+	inFlight bool      // the target is running, the schedule is not fired again until it returns
+}
+
+// localScheduler runs schedules in process. schedules are persisted to a json file so they survive restarts, an empty path keeps them in memory.
+type localScheduler struct {
+	mu        sync.Mutex
+	path      string
+	schedules map[string]*localSchedule
+	wake      chan struct{}
+}
+
+// NewLocalScheduler returns a scheduler that keeps its schedules in the file at path. previously persisted schedules are loaded.
+func NewLocalScheduler(path string) (*localScheduler, error) {
+	s := &localScheduler{
+		path:      path,
+		schedules: make(map[string]*localSchedule),
+		wake:      make(chan struct{}, 1),
+	}
+
+	if path == "" {
+		return s, nil
+	}
+	b, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading schedules file='%s' error: %w", path, err)
+	}
+
+	if err := json.Unmarshal(b, &s.schedules); err != nil {
+		return nil, fmt.Errorf("error parsing schedules file='%s' error: %w", path, err)
+	}
+	return s, nil
+}
+
+// CreateSchedule stores the schedule. creating the same schedule name with the same token again is a no-op, with a different token it fails with ErrConflict.
+func (s *localScheduler) CreateSchedule(sch *schedule, token string) (string, error) {
+	if _, err := sch.validate(); err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current, ok := s.schedules[sch.Name]; ok {
+		if token != "" && current.Token == token {
+			return sch.Name, nil
+		}
+		return "", ErrConflict
+	}
+	s.schedules[sch.Name] = &localSchedule{
+		Schedule: sch,
+		Token:    token,
+		Next:     sch.Date,
+	}
+
+	if err := s.persist(); err != nil {
+		delete(s.schedules, sch.Name)
+		return "", err
+	}
+	s.notify()
+	return sch.Name, nil
+}
+
+func (s *localScheduler) DeleteSchedule(name, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.schedules[name]
+
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.schedules, name)
+
+	if err := s.persist(); err != nil {
+		s.schedules[name] = current
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+func (s *localScheduler) GetSchedule(name string) (*schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.schedules[name]
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+	sch := *current.Schedule
+	return &sch, nil
+}
+
+func (s *localScheduler) UpdateSchedule(sch *schedule) (string, error) {
+	if _, err := LoadTimeZone(sch.TimeZone); err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.schedules[sch.Name]
+
+	if !ok {
+		return "", ErrNotFound
+	}
+	updated := &localSchedule{
+		Schedule: sch,
+		Token:    current.Token,
+		Next:     sch.Date,
+	}
+	// recurring schedules keep their start date, the next run is the first one still ahead.
+	if sch.Repeat != nil {
+		updated.Next = sch.nextRun(time.Now())
+	}
+	s.schedules[sch.Name] = updated
+
+	if err := s.persist(); err != nil {
+		s.schedules[sch.Name] = current
+		return "", err
+	}
+	s.notify()
+	return sch.Name, nil
+}
+
+// ListSchedules returns the sorted names of the schedules that start with prefix, an empty prefix returns every schedule.
+func (s *localScheduler) ListSchedules(prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.schedules))
+
+	for name := range s.schedules {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Start fires the due schedules with invoke until ctx is done. schedules that were missed while the process was down fire once right away.
+// a run is only completed once invoke returns nil, failed runs are retried with backoff and runs cut short by a crash fire again on restart.
+func (s *localScheduler) Start(ctx context.Context, invoke Invoker) {
+	go s.run(ctx, invoke)
+}
+
+func (s *localScheduler) run(ctx context.Context, invoke Invoker) {
+	for {
+		due, next := s.due(time.Now())
+
+		for _, p := range due {
+			go func(p duePayload) {
+				err := invoke(ctx, p.payload)
+
+				if err != nil {
+					localLogger.Printf("schedule='%s' target failed error='%s'\n", p.name, err)
+				}
+				s.complete(p, err, time.Now())
+			}(p)
+		}
+
+		wait := time.Hour
+		if next != nil {
+			wait = time.Until(*next)
+		}
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+type duePayload struct {
+	name     string
+	payload  string
+	schedule *localSchedule
+}
+
+// due marks the schedules due at now as in flight and returns their payloads with the date of the closest schedule left.
+// schedules stay stored until their run completes.
+func (s *localScheduler) due(now time.Time) ([]duePayload, *time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var payloads []duePayload
+	var next *time.Time
+
+	for name, ls := range s.schedules {
+		if ls.inFlight {
+			continue
+		}
+		if !ls.Next.After(now) {
+			ls.inFlight = true
+			payloads = append(payloads, duePayload{name: name, payload: ls.Schedule.Payload, schedule: ls})
+			continue
+		}
+		if next == nil || ls.Next.Before(*next) {
+			n := ls.Next
+			next = &n
+		}
+	}
+	return payloads, next
+}
+
+// complete records the result of a run. one time schedules are removed and recurring ones move to their next run after a success,
+// failed runs are retried. schedules that were updated or deleted while in flight are left as they are.
+func (s *localScheduler) complete(p duePayload, err error, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ls := p.schedule
+	ls.inFlight = false
+
+	if s.schedules[p.name] != ls {
+		return
+	}
+	switch {
+	case err != nil:
+		ls.Attempts++
+		ls.Next = now.Add(retryDelay(ls.Attempts))
+	case ls.Schedule.Repeat == nil:
+		delete(s.schedules, p.name)
+	default:
+		ls.Attempts = 0
+		ls.Next = ls.Schedule.nextRun(now)
+
+		if end := ls.Schedule.Repeat.End; end != nil && ls.Next.After(*end) {
+			delete(s.schedules, p.name)
+		}
+	}
+
+	if err := s.persist(); err != nil {
+		localLogger.Printf("failed to persist schedules error='%s'\n", err)
+	}
+	s.notify()
+}
+
+// retryDelay returns the wait before the given retry attempt.
+func retryDelay(attempt int) time.Duration {
+	delay := localRetryDelay
+
+	for i := 1; i < attempt && delay < localMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, localMaxRetryDelay)
+}
+
+// nextRun returns the first run of a recurring schedule after now. runs keep the wall clock time of the start date in the schedule
+// time zone, so they move with the clocks.
+func (sch *schedule) nextRun(now time.Time) time.Time {
+	if sch.Date.After(now) {
+		return sch.Date
+	}
+	loc, err := LoadTimeZone(sch.TimeZone)
+
+	if err != nil {
+		loc = time.UTC
+	}
+	start := sch.Date.In(loc)
+	every := time.Hour * 24 * time.Duration(sch.Repeat.EveryDays)
+
+	// the estimate is off by the clock changes at most, which is less than a run.
+	for runs := int(now.Sub(sch.Date) / every); ; runs++ {
+		if next := start.AddDate(0, 0, sch.Repeat.EveryDays*runs); next.After(now) {
+			return next.UTC()
+		}
+	}
+}
+
+// notify wakes up the run loop so it picks up the new closest schedule.
+func (s *localScheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// persist writes the schedules to the file. the file is replaced atomically so a crash never leaves it half written.
+func (s *localScheduler) persist() error {
+	if s.path == "" {
+		return nil
+	}
+	b, err := json.Marshal(s.schedules)
+
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+
+	if err != nil {
+		return fmt.Errorf("error persisting schedules error: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error persisting schedules error: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error persisting schedules error: %w", err)
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,244 @@
+package scheduler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/scheduler"
+)
+
+func TestLocalSchedulerToken(t *testing.T) {
+	s, err := scheduler.NewLocalScheduler("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sch := scheduler.NewSchedule("test", "", "", scheduler.TimeZoneETD, "{}", time.Now().Add(time.Hour))
+
+	if _, err := s.CreateSchedule(sch, "token"); err != nil {
+		t.Fatal(err)
+	}
+	// same token is a retry of the same request.
+	if _, err := s.CreateSchedule(sch, "token"); err != nil {
+		t.Fatalf("expected retry to succeed, got: %s", err)
+	}
+
+	if _, err := s.CreateSchedule(sch, "other"); !errors.Is(err, scheduler.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got: %v", err)
+	}
+
+	if err := s.DeleteSchedule("test", "token"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.GetSchedule("test"); !errors.Is(err, scheduler.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got: %v", err)
+	}
+}
+
+func TestLocalSchedulerRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	s, err := scheduler.NewLocalScheduler(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	once := scheduler.NewSchedule("once", "", "", scheduler.TimeZoneETD, "once", time.Now().Add(time.Hour))
+	weekly := scheduler.NewSchedule("weekly", "", "", scheduler.TimeZoneETD, "weekly", time.Now().Add(time.Hour)).Every(7, nil)
+
+	if _, err := s.CreateSchedule(once, "once"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateSchedule(weekly, "weekly"); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate the process being down while both schedules were due.
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored map[string]map[string]any
+	if err := json.Unmarshal(b, &stored); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour * 24 * 10)
+	for _, ls := range stored {
+		ls["next"] = past
+		ls["schedule"].(map[string]any)["Date"] = past
+	}
+	b, err = json.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, err := scheduler.NewLocalScheduler(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fired := make(chan string, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	restarted.Start(ctx, func(ctx context.Context, payload string) error {
+		fired <- payload
+		return nil
+	})
+
+	got := map[string]int{}
+	for i := 0; i < 2; i++ {
+		select {
+		case p := <-fired:
+			got[p]++
+		case <-time.After(time.Second * 5):
+			t.Fatalf("expected missed schedules to fire, got: %v", got)
+		}
+	}
+	if got["once"] != 1 || got["weekly"] != 1 {
+		t.Fatalf("expected each schedule to fire once, got: %v", got)
+	}
+
+	if _, err := restarted.GetSchedule("once"); !errors.Is(err, scheduler.ErrNotFound) {
+		t.Fatalf("expected one time schedule to be removed, got: %v", err)
+	}
+	if _, err := restarted.GetSchedule("weekly"); err != nil {
+		t.Fatalf("expected recurring schedule to be kept, got: %v", err)
+	}
+}
+
+func TestLocalSchedulerTimeZone(t *testing.T) {
+	s, err := scheduler.NewLocalScheduler("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	if _, err := s.CreateSchedule(scheduler.NewSchedule("invalid", "", "", "Eastern", "{}", date), "token"); !errors.Is(err, scheduler.ErrInvalidTZ) {
+		t.Fatalf("expected ErrInvalidTZ, got: %v", err)
+	}
+	if _, err := s.CreateSchedule(scheduler.NewSchedule("chicago", "", "", "America/Chicago", "{}", date), "token"); err != nil {
+		t.Fatal(err)
+	}
+
+	sch, err := s.GetSchedule("chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sch.TimeZone != "America/Chicago" || !sch.Date.Equal(date) {
+		t.Fatalf("unexpected schedule: %+v", sch)
+	}
+
+	sch.TimeZone = "Europe/Madrid"
+	sch.Date = date.Add(time.Hour)
+	if _, err := s.UpdateSchedule(sch); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := s.GetSchedule("chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.TimeZone != "Europe/Madrid" || !updated.Date.Equal(date.Add(time.Hour)) {
+		t.Fatalf("unexpected updated schedule: %+v", updated)
+	}
+
+	updated.TimeZone = "Local"
+	if _, err := s.UpdateSchedule(updated); !errors.Is(err, scheduler.ErrInvalidTZ) {
+		t.Fatalf("expected ErrInvalidTZ, got: %v", err)
+	}
+}
+
+func TestLocalSchedulerList(t *testing.T) {
+	s, err := scheduler.NewLocalScheduler("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"b", "a-retry-1", "a"} {
+		if _, err := s.CreateSchedule(scheduler.NewSchedule(name, "", "", scheduler.TimeZoneETD, "{}", time.Now().Add(time.Hour)), name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, err := s.ListSchedules("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 3 || names[0] != "a" || names[1] != "a-retry-1" || names[2] != "b" {
+		t.Fatalf("unexpected schedules: %v", names)
+	}
+
+	names, err = s.ListSchedules("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Fatalf("expected 2 schedules with prefix, got: %v", names)
+	}
+}
+
+func TestLocalSchedulerRetry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	s, err := scheduler.NewLocalScheduler(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sch := scheduler.NewSchedule("once", "", "", scheduler.TimeZoneETD, "once", time.Now().Add(time.Hour))
+	if _, err := s.CreateSchedule(sch, "once"); err != nil {
+		t.Fatal(err)
+	}
+	// updates don't check the date, the schedule is due right away.
+	sch.Date = time.Now().Add(-time.Minute)
+	if _, err := s.UpdateSchedule(sch); err != nil {
+		t.Fatal(err)
+	}
+	calls := make(chan chan error)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx, func(ctx context.Context, payload string) error {
+		result := make(chan error)
+		calls <- result
+		return <-result
+	})
+	call := func() chan error {
+		t.Helper()
+		select {
+		case result := <-calls:
+			return result
+		case <-time.After(time.Second * 5):
+			t.Fatal("expected the schedule to fire")
+		}
+		return nil
+	}
+
+	// the schedule is kept while the target runs, a crash at this point fires it again on restart.
+	result := call()
+	restarted, err := scheduler.NewLocalScheduler(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restarted.GetSchedule("once"); err != nil {
+		t.Fatalf("expected the in flight schedule to be persisted, got: %v", err)
+	}
+
+	// a failed run is retried.
+	result <- errors.New("target unavailable")
+	result = call()
+	if _, err := s.GetSchedule("once"); err != nil {
+		t.Fatalf("expected the failed schedule to be kept, got: %v", err)
+	}
+	result <- nil
+
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		if _, err := s.GetSchedule("once"); errors.Is(err, scheduler.ErrNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the schedule to be removed after a successful run")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
@@ -5,4 +5,5 @@ type Scheduler interface {
 	DeleteSchedule(name, token string) error
 	GetSchedule(name string) (*schedule, error)
 	UpdateSchedule(sch *schedule) (string, error)
+	ListSchedules(prefix string) ([]string, error)
 }
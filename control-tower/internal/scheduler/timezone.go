@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultTimeZone is used for creators and clients without a time zone.
+var DefaultTimeZone = TimeZoneETD
+
+// LoadTimeZone returns the location of an IANA time zone name like "America/Chicago".
+// empty names and "Local" are rejected, they depend on the machine the code runs on.
+func LoadTimeZone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("time zone='%s' error: %w", name, ErrInvalidTZ)
+	}
+	loc, err := time.LoadLocation(name)
+
+	if err != nil {
+		return nil, fmt.Errorf("time zone='%s' error: %w", name, ErrInvalidTZ)
+	}
+	return loc, nil
+}
+
+// atExpression returns the at() expression of the date and the time zone it is written in.
+// eventbridge reads the expression as a wall clock time, the times repeated when the clocks are set back
+// can't be told apart in the location so they are written in UTC.
+func atExpression(date time.Time, loc *time.Location) (expression string, tz string) {
+	if ambiguous(date, loc) {
+		return fmt.Sprintf("at(%s)", date.UTC().Format(expressionDateLayout)), time.UTC.String()
+	}
+	return fmt.Sprintf("at(%s)", date.In(loc).Format(expressionDateLayout)), loc.String()
+}
+
+// weeklyExpression returns the cron() expression that fires every week on the weekday and wall clock time of the date in the location.
+// eventbridge cron expressions have no seconds, schedule dates are on the minute.
+func weeklyExpression(date time.Time, loc *time.Location) string {
+	local := date.In(loc)
+	return fmt.Sprintf("cron(%d %d ? * %s *)", local.Minute(), local.Hour(), strings.ToUpper(local.Weekday().String()[:3]))
+}
+
+// ExpressionTimeZone returns the time zone the at() expression of a one time schedule on the date is written in.
+// it is UTC for the dates that are ambiguous in the time zone.
+func ExpressionTimeZone(date time.Time, tz string) string {
+	loc, err := LoadTimeZone(tz)
+
+	if err != nil {
+		return tz
+	}
+	_, written := atExpression(date, loc)
+	return written
+}
+
+// ambiguous reports if the wall clock time of the date in the location happens twice.
+func ambiguous(date time.Time, loc *time.Location) bool {
+	wall := date.In(loc).Format(expressionDateLayout)
+
+	// DST changes move the clocks 30 minutes or an hour.
+	for _, d := range []time.Duration{-time.Hour, -time.Minute * 30, time.Minute * 30, time.Hour} {
+		if date.Add(d).In(loc).Format(expressionDateLayout) == wall {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAtExpression returns the date of an at() expression written in the time zone.
+func parseAtExpression(expression, tz string) (time.Time, error) {
+	loc, err := LoadTimeZone(tz)
+
+	if err != nil {
+		return time.Time{}, err
+	}
+	date, err := time.ParseInLocation(expressionDateLayout, strings.TrimSuffix(strings.TrimPrefix(expression, "at("), ")"), loc)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error while parsing output expression error: %w", err)
+	}
+	return date.UTC(), nil
+}
@@ -0,0 +1,144 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoadTimeZone(t *testing.T) {
+	if loc, err := LoadTimeZone("America/Chicago"); err != nil || loc.String() != "America/Chicago" {
+		t.Fatalf("expected America/Chicago, got: %v, %v", loc, err)
+	}
+	for _, tz := range []string{"", "Local", "Mars/Olympus_Mons", "EST5EDT6"} {
+		if _, err := LoadTimeZone(tz); !errors.Is(err, ErrInvalidTZ) {
+			t.Errorf("time zone='%s' expected ErrInvalidTZ, got: %v", tz, err)
+		}
+	}
+}
+
+func TestAtExpressionRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		tz         string
+		date       time.Time
+		expression string
+		writtenIn  string
+	}{
+		{"before spring forward", "America/New_York", time.Date(2024, time.March, 10, 6, 30, 0, 0, time.UTC), "at(2024-03-10T01:30:00)", "America/New_York"},
+		{"after spring forward", "America/New_York", time.Date(2024, time.March, 10, 7, 30, 0, 0, time.UTC), "at(2024-03-10T03:30:00)", "America/New_York"},
+		{"first 1:30 on fall back", "America/New_York", time.Date(2024, time.November, 3, 5, 30, 0, 0, time.UTC), "at(2024-11-03T05:30:00)", "UTC"},
+		{"second 1:30 on fall back", "America/New_York", time.Date(2024, time.November, 3, 6, 30, 0, 0, time.UTC), "at(2024-11-03T06:30:00)", "UTC"},
+		{"after fall back", "America/New_York", time.Date(2024, time.November, 3, 7, 30, 0, 0, time.UTC), "at(2024-11-03T02:30:00)", "America/New_York"},
+		{"half hour shift", "Australia/Lord_Howe", time.Date(2024, time.April, 6, 15, 0, 0, 0, time.UTC), "at(2024-04-07T01:30:00)", "UTC"},
+		{"southern summer", "America/Sao_Paulo", time.Date(2024, time.January, 15, 18, 0, 0, 0, time.UTC), "at(2024-01-15T15:00:00)", "America/Sao_Paulo"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			loc, err := LoadTimeZone(tc.tz)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expression, tz := atExpression(tc.date, loc)
+
+			if tz != tc.writtenIn {
+				t.Fatalf("expected the expression in '%s', got: '%s'", tc.writtenIn, tz)
+			}
+			if tz == loc.String() && expression != tc.expression {
+				t.Fatalf("expected '%s', got: '%s'", tc.expression, expression)
+			}
+			date, err := parseAtExpression(expression, tz)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !date.Equal(tc.date) {
+				t.Fatalf("expected '%s' after the round trip, got: '%s'", tc.date, date)
+			}
+		})
+	}
+}
+
+func TestRecurringExpression(t *testing.T) {
+	ny, err := LoadTimeZone("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	monday := time.Date(2024, time.March, 4, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		date       time.Time
+		everyDays  int
+		expression string
+	}{
+		{"weekly in winter", monday, 7, "cron(0 15 ? * MON *)"},
+		{"weekly in summer", time.Date(2024, time.July, 1, 13, 30, 0, 0, time.UTC), 7, "cron(30 9 ? * MON *)"},
+		{"every two weeks", monday, 14, "cron(0 15 ? * MON *)"},
+		{"not weekly", monday, 3, "rate(3 days)"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sch := NewSchedule("test", "", "", ny.String(), "{}", tc.date).Every(tc.everyDays, nil)
+			expression, tz := sch.expression(ny)
+
+			if expression != tc.expression || tz != ny.String() {
+				t.Fatalf("expected '%s' in '%s', got: '%s' in '%s'", tc.expression, ny, expression, tz)
+			}
+			description, err := sch.description()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := parseDescription(description); d.TimeZone != ny.String() || d.EveryDays != tc.everyDays {
+				t.Fatalf("unexpected description: %+v", d)
+			}
+		})
+	}
+}
+
+func TestScheduleDescriptionKeepsTimeZone(t *testing.T) {
+	ny, err := LoadTimeZone("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first 1:30 on fall back is written in UTC.
+	sch := NewSchedule("test", "", "", ny.String(), "{}", time.Date(2024, time.November, 3, 5, 30, 0, 0, time.UTC))
+
+	if _, tz := sch.expression(ny); tz != time.UTC.String() {
+		t.Fatalf("expected the expression in UTC, got: '%s'", tz)
+	}
+	description, err := sch.description()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := parseDescription(description); d.TimeZone != ny.String() || d.EveryDays != 0 {
+		t.Fatalf("expected the schedule time zone in the description, got: %+v", d)
+	}
+	if d := parseDescription(nil); d.TimeZone != "" {
+		t.Fatalf("expected an empty description, got: %+v", d)
+	}
+}
+
+func TestNextRunAcrossDST(t *testing.T) {
+	tests := []struct {
+		name      string
+		start     time.Time
+		everyDays int
+		now       time.Time
+		want      time.Time
+	}{
+		{"spring forward", time.Date(2024, time.March, 4, 20, 0, 0, 0, time.UTC), 7, time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC), time.Date(2024, time.March, 11, 19, 0, 0, 0, time.UTC)},
+		{"fall back", time.Date(2024, time.October, 28, 19, 0, 0, 0, time.UTC), 7, time.Date(2024, time.October, 29, 0, 0, 0, 0, time.UTC), time.Date(2024, time.November, 4, 20, 0, 0, 0, time.UTC)},
+		{"every two weeks", time.Date(2024, time.March, 4, 20, 0, 0, 0, time.UTC), 14, time.Date(2024, time.March, 12, 0, 0, 0, 0, time.UTC), time.Date(2024, time.March, 18, 19, 0, 0, 0, time.UTC)},
+		{"on the run", time.Date(2024, time.March, 4, 20, 0, 0, 0, time.UTC), 7, time.Date(2024, time.March, 11, 19, 0, 0, 0, time.UTC), time.Date(2024, time.March, 18, 19, 0, 0, 0, time.UTC)},
+		{"months later", time.Date(2024, time.January, 1, 20, 0, 0, 0, time.UTC), 7, time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, time.June, 3, 19, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sch := NewSchedule("test", "", "", "America/New_York", "{}", tc.start).Every(tc.everyDays, nil)
+
+			if next := sch.nextRun(tc.now); !next.Equal(tc.want) {
+				t.Fatalf("expected %s, got: %s", tc.want, next)
+			}
+		})
+	}
+}
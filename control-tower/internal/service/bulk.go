@@ -0,0 +1,358 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/japb1998/control-tower/internal/database"
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+const (
+	BulkScheduled = "scheduled"
+	BulkSkipped   = "skipped"
+	BulkFailed    = "failed"
+)
+
+const (
+	// bulkMaxClients is the largest segment scheduled by a single request.
+	bulkMaxClients = 250
+	// bulkConcurrency is the number of notifications scheduled at the same time.
+	bulkConcurrency = 5
+	// bulkRate is the number of schedules created per second, well under the EventBridge Scheduler CreateSchedule quota.
+	bulkRate = 20
+)
+
+// bulkNamespace derives the ids of keyed jobs and the client tokens of their notifications.
+var bulkNamespace = uuid.MustParse("5b0e7c1e-2f4a-4c8e-9d3b-6a1f0e8c2d47")
+
+var (
+	ErrInvalidBulkSchedule = errors.New("invalid bulk schedule")
+	ErrBulkTooLarge        = fmt.Errorf("bulk schedules are limited to %d clients", bulkMaxClients)
+)
+
+// BulkScheduleInput schedules the same notification for the clients in ClientIds or the ones matching Filter.
+// the date is Date for every client or their LastSeen plus OffsetDays. sending the same IdempotencyKey again returns the
+// notifications of the first request instead of scheduling them twice.
+type BulkScheduleInput struct {
+	IdempotencyKey  string            `json:"-"`
+	ClientIds       []string          `json:"clientIds,omitempty" binding:"omitempty,max=250,dive,required"`
+	Filter          *BulkClientFilter `json:"filter,omitempty"`
+	Date            string            `json:"date,omitempty" binding:"omitempty,rfc3339"`
+	OffsetDays      *int              `json:"offsetDays,omitempty" binding:"omitempty,min=0,max=365"`
+	DeliveryMethods []int8            `json:"deliveryMethods" binding:"required,min=1,dive,number,min=0,max=2"`
+	TemplateId      string            `json:"templateId,omitempty" binding:"omitempty,uuid"`
+	Subject         string            `json:"subject,omitempty" binding:"max=200"`
+	Message         string            `json:"message,omitempty" binding:"max=1600"`
+}
+
+// BulkClientFilter selects the clients of a bulk schedule, the last seen range is in whole days before now.
+type BulkClientFilter struct {
+	Phone           string `json:"phone,omitempty" binding:"omitempty,e164"`
+	Email           string `json:"email,omitempty" binding:"omitempty,email"`
+	FirstName       string `json:"firstName,omitempty" binding:"omitempty,min=1"`
+	LastName        string `json:"lastName,omitempty" binding:"omitempty,min=1"`
+	LastSeenMinDays *int   `json:"lastSeenMinDays,omitempty" binding:"omitempty,min=0"` // last seen at least this many days ago.
+	LastSeenMaxDays *int   `json:"lastSeenMaxDays,omitempty" binding:"omitempty,min=0"` // last seen at most this many days ago.
+}
+
+type BulkResult struct {
+	ClientId       string `json:"clientId"`
+	Status         string `json:"status"`
+	NotificationId string `json:"notificationId,omitempty"`
+	Date           string `json:"date,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+}
+
+// BulkScheduleJob is the outcome of a bulk schedule, Id identifies the job in the logs. jobs with an idempotency key keep the same Id.
+type BulkScheduleJob struct {
+	Id        string       `json:"id"`
+	Scheduled int          `json:"scheduled"`
+	Skipped   int          `json:"skipped"`
+	Failed    int          `json:"failed"`
+	Results   []BulkResult `json:"results"`
+}
+
+func (j *BulkScheduleJob) count() {
+	for _, r := range j.Results {
+		switch r.Status {
+		case BulkScheduled:
+			j.Scheduled++
+		case BulkSkipped:
+			j.Skipped++
+		case BulkFailed:
+			j.Failed++
+		}
+	}
+}
+
+func (input *BulkScheduleInput) validate() error {
+	if (len(input.ClientIds) == 0) == (input.Filter == nil) {
+		return fmt.Errorf("%w: one of clientIds and filter is required", ErrInvalidBulkSchedule)
+	}
+	if len(input.IdempotencyKey) > 255 {
+		return fmt.Errorf("%w: the idempotency key is limited to 255 characters", ErrInvalidBulkSchedule)
+	}
+	if (input.Date == "") == (input.OffsetDays == nil) {
+		return fmt.Errorf("%w: one of date and offsetDays is required", ErrInvalidBulkSchedule)
+	}
+	if f := input.Filter; f != nil && f.LastSeenMinDays != nil && f.LastSeenMaxDays != nil && *f.LastSeenMinDays > *f.LastSeenMaxDays {
+		return fmt.Errorf("%w: lastSeenMinDays is greater than lastSeenMaxDays", ErrInvalidBulkSchedule)
+	}
+	return validateContent(input.TemplateId, input.Subject, input.Message, input.DeliveryMethods)
+}
+
+// matches reports if the client was last seen inside of the filter range.
+func (f *BulkClientFilter) matches(c model.ClientItem, now time.Time) bool {
+	if f.LastSeenMinDays == nil && f.LastSeenMaxDays == nil {
+		return true
+	}
+	if c.LastSeen == nil {
+		return false
+	}
+	days := int(now.Sub(*c.LastSeen) / (time.Hour * 24))
+
+	if f.LastSeenMinDays != nil && days < *f.LastSeenMinDays {
+		return false
+	}
+	return f.LastSeenMaxDays == nil || days <= *f.LastSeenMaxDays
+}
+
+// BulkSchedule schedules the notification for every client of the segment. the segment is checked before anything is scheduled,
+// then the notifications are created a few at a time under bulkRate. each client has a result, failures don't stop the job.
+// clients that already have a pending notification on the date are skipped, so retrying a job doesn't notify them twice.
+func (s *NotificationService) BulkSchedule(ctx context.Context, createdBy string, input *BulkScheduleInput) (*BulkScheduleJob, error) {
+	if s.clients == nil {
+		return nil, fmt.Errorf("bulk schedules need the client store")
+	}
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkTemplate(createdBy, input.TemplateId, input.DeliveryMethods); err != nil {
+		return nil, err
+	}
+	clients, missing, err := s.bulkClients(createdBy, input)
+
+	if err != nil {
+		return nil, err
+	}
+	if len(clients)+len(missing) > bulkMaxClients {
+		return nil, ErrBulkTooLarge
+	}
+	job := &BulkScheduleJob{
+		Id:      uuid.New().String(),
+		Results: make([]BulkResult, 0, len(clients)+len(missing)),
+	}
+	if input.IdempotencyKey != "" {
+		job.Id = uuid.NewSHA1(bulkNamespace, []byte(createdBy+"/"+input.IdempotencyKey)).String()
+	}
+	logger := notificationLogger.With(slog.String("jobId", job.Id), slog.String("createdBy", createdBy))
+	logger.Info("bulk schedule started", slog.Int("clients", len(clients)))
+
+	now := time.Now()
+	inputs := make([]*NotificationInput, len(clients))
+	tokens := make([]string, len(clients))
+
+	for i, c := range clients {
+		result := BulkResult{ClientId: c.SortKey}
+		date := input.Date
+
+		switch {
+		case !c.OptIn:
+			result.Status, result.Reason = BulkSkipped, "client has notifications disabled"
+		case input.OffsetDays != nil && c.LastSeen == nil:
+			result.Status, result.Reason = BulkSkipped, "client has no lastSeen"
+		case input.OffsetDays != nil:
+			d := c.LastSeen.AddDate(0, 0, *input.OffsetDays)
+
+			if !d.After(now) {
+				result.Status, result.Reason = BulkSkipped, "date already passed"
+			}
+			date = d.Format(time.RFC3339)
+		}
+		result.Date = date
+
+		if result.Status == "" {
+			inputs[i] = &NotificationInput{
+				Date:            date,
+				ClientId:        c.SortKey,
+				DeliveryMethods: input.DeliveryMethods,
+				TemplateId:      input.TemplateId,
+				Subject:         input.Subject,
+				Message:         input.Message,
+			}
+			tokens[i] = uuid.New().String()
+
+			if input.IdempotencyKey != "" {
+				tokens[i] = uuid.NewSHA1(bulkNamespace, []byte(job.Id+"/"+c.SortKey)).String()
+			}
+		}
+		job.Results = append(job.Results, result)
+	}
+	s.runBulk(ctx, createdBy, inputs, tokens, job.Results)
+
+	for _, id := range missing {
+		job.Results = append(job.Results, BulkResult{ClientId: id, Status: BulkFailed, Reason: ErrClientNotFound.Error()})
+	}
+	job.count()
+	logger.Info("bulk schedule finished", slog.Int("scheduled", job.Scheduled), slog.Int("skipped", job.Skipped), slog.Int("failed", job.Failed))
+	return job, nil
+}
+
+// bulkClients returns the clients of the segment and the requested ids that don't exist.
+func (s *NotificationService) bulkClients(createdBy string, input *BulkScheduleInput) ([]model.ClientItem, []string, error) {
+	clients := make([]model.ClientItem, 0)
+
+	if input.Filter == nil {
+		missing := make([]string, 0)
+		seen := make(map[string]bool, len(input.ClientIds))
+
+		for _, id := range input.ClientIds {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			c, err := s.clients.GetClientById(createdBy, id)
+
+			if err != nil {
+				notificationLogger.Error("error getting client", slog.String("clientId", id), slog.String("error", err.Error()))
+				return nil, nil, fmt.Errorf("error getting client ID='%s'", id)
+			}
+			if c == nil {
+				missing = append(missing, id)
+				continue
+			}
+			clients = append(clients, *c)
+		}
+		return clients, missing, nil
+	}
+	f := input.Filter
+	now := time.Now()
+	filters := database.PatchClientItem{
+		Phone:     f.Phone,
+		Email:     f.Email,
+		FirstName: f.FirstName,
+		LastName:  f.LastName,
+	}
+	err := s.clients.WalkClientsWithFilters(createdBy, filters, func(c model.ClientItem) error {
+		if !f.matches(c, now) {
+			return nil
+		}
+		if len(clients) == bulkMaxClients {
+			return ErrBulkTooLarge
+		}
+		clients = append(clients, c)
+		return nil
+	})
+
+	if err != nil {
+		if errors.Is(err, ErrBulkTooLarge) {
+			return nil, nil, err
+		}
+		notificationLogger.Error("error getting clients", slog.String("createdBy", createdBy), slog.String("error", err.Error()))
+		return nil, nil, fmt.Errorf("error getting clients")
+	}
+	return clients, nil, nil
+}
+
+// runBulk creates the notifications of inputs with bulkConcurrency workers, nil inputs were already skipped.
+// tokens are the client tokens of the notifications. results has the same order as inputs, the ones left when ctx is done fail.
+func (s *NotificationService) runBulk(ctx context.Context, createdBy string, inputs []*NotificationInput, tokens []string, results []BulkResult) {
+	jobs := make(chan int)
+	limiter := time.NewTicker(time.Second / bulkRate)
+	defer limiter.Stop()
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < bulkConcurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				existing, reason, err := s.bulkConflict(createdBy, inputs[i], tokens[i])
+
+				if err != nil {
+					notificationLogger.Error("error checking bulk notification", slog.String("clientId", inputs[i].ClientId), slog.String("error", err.Error()))
+					results[i].Status, results[i].Reason = BulkFailed, "error getting client notifications"
+					continue
+				}
+				if existing != nil {
+					results[i].Status, results[i].NotificationId, results[i].Date = BulkScheduled, existing.SortKey, existing.Date
+					continue
+				}
+				if reason != "" {
+					results[i].Status, results[i].Reason = BulkSkipped, reason
+					continue
+				}
+				n, err := s.scheduleNotification(createdBy, inputs[i], "", "", tokens[i])
+
+				if err != nil {
+					notificationLogger.Error("error scheduling bulk notification", slog.String("clientId", inputs[i].ClientId), slog.String("error", err.Error()))
+					results[i].Status, results[i].Reason = BulkFailed, err.Error()
+					continue
+				}
+				results[i].Status, results[i].NotificationId, results[i].Date = BulkScheduled, n.ID, n.Date
+			}
+		}()
+	}
+
+	for i, input := range inputs {
+		if input == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			results[i].Status, results[i].Reason = BulkFailed, ctx.Err().Error()
+			continue
+		case <-limiter.C:
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// bulkConflict returns the notification that an earlier request of the job created with token, or the reason to skip the
+// client when it already has a pending notification on the same day as input.
+func (s *NotificationService) bulkConflict(createdBy string, input *NotificationInput, token string) (*model.NotificationItem, string, error) {
+	items, err := s.store.GetNotificationsByClient(createdBy, input.ClientId)
+
+	if err != nil {
+		return nil, "", err
+	}
+	date, err := time.Parse(time.RFC3339, input.Date)
+
+	if err != nil {
+		return nil, "", err
+	}
+	for _, item := range items {
+		if item.ClientToken == token {
+			return &item, "", nil
+		}
+	}
+	for _, item := range items {
+		if item.Status != NotSentStatus.String() {
+			continue
+		}
+		// the send window may have moved the notification, the requested date counts too.
+		for _, d := range []string{item.Date, item.RequestedDate} {
+			if pending, err := time.Parse(time.RFC3339, d); err == nil && sameDay(pending, date, location(item.TimeZone)) {
+				return nil, "client already has a notification pending on that date", nil
+			}
+		}
+	}
+	return nil, "", nil
+}
+
+// sameDay reports if a and b fall on the same day in loc.
+func sameDay(a, b time.Time, loc *time.Location) bool {
+	y1, m1, d1 := a.In(loc).Date()
+	y2, m2, d2 := b.In(loc).Date()
+	return y1 == y2 && m1 == m2 && d1 == d2
+}
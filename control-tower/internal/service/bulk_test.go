@@ -0,0 +1,144 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/service"
+)
+
+func TestBulkSchedule(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	day := time.Hour * 24
+	now := time.Now().Truncate(time.Second)
+
+	recent := s.createClient(t, now.Add(-day*2))
+	inSegment := s.createClient(t, now.Add(-day*15))
+	late := s.createClient(t, now.Add(-day*20))
+	old := s.createClient(t, now.Add(-day*40))
+
+	// everyone last seen 14 to 21 days ago, reminded 21 days after their last visit.
+	offset := 21
+	job, err := s.notifications.BulkSchedule(ctx, creator, &service.BulkScheduleInput{
+		Filter:          &service.BulkClientFilter{LastSeenMinDays: intPtr(14), LastSeenMaxDays: intPtr(21)},
+		OffsetDays:      &offset,
+		DeliveryMethods: []int8{int8(service.SMS)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Id == "" || job.Scheduled != 2 || job.Skipped != 0 || job.Failed != 0 || len(job.Results) != 2 {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+	for _, r := range job.Results {
+		if r.ClientId != inSegment.Id && r.ClientId != late.Id {
+			t.Errorf("unexpected client in the segment: %+v", r)
+		}
+		lastSeen := now.Add(-day * 15)
+		if r.ClientId == late.Id {
+			lastSeen = now.Add(-day * 20)
+		}
+		if !sameTime(r.Date, lastSeen.AddDate(0, 0, offset)) {
+			t.Errorf("expected the date to be %s, got: %s", lastSeen.AddDate(0, 0, offset), r.Date)
+		}
+
+		if _, err := s.scheduler.GetSchedule(r.NotificationId); err != nil {
+			t.Errorf("expected the schedule to be created, got: %v", err)
+		}
+	}
+
+	// the offset date already passed for old, missing ids fail without stopping the job.
+	offset = 30
+	job, err = s.notifications.BulkSchedule(ctx, creator, &service.BulkScheduleInput{
+		ClientIds:       []string{recent.Id, old.Id, "missing", recent.Id},
+		OffsetDays:      &offset,
+		DeliveryMethods: []int8{int8(service.SMS)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Scheduled != 1 || job.Skipped != 1 || job.Failed != 1 {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+	for _, r := range job.Results {
+		switch r.ClientId {
+		case recent.Id:
+			if r.Status != service.BulkScheduled {
+				t.Errorf("expected recent to be scheduled, got: %+v", r)
+			}
+		case old.Id:
+			if r.Status != service.BulkSkipped {
+				t.Errorf("expected old to be skipped, got: %+v", r)
+			}
+		default:
+			if r.Status != service.BulkFailed {
+				t.Errorf("expected missing to fail, got: %+v", r)
+			}
+		}
+	}
+
+	date := now.Add(day).Format(time.RFC3339)
+	for _, input := range []service.BulkScheduleInput{
+		{DeliveryMethods: []int8{int8(service.SMS)}, Date: date},
+		{DeliveryMethods: []int8{int8(service.SMS)}, ClientIds: []string{recent.Id}},
+		{DeliveryMethods: []int8{int8(service.SMS)}, ClientIds: []string{recent.Id}, Date: date, OffsetDays: &offset},
+		{DeliveryMethods: []int8{int8(service.SMS)}, Date: date, Filter: &service.BulkClientFilter{LastSeenMinDays: intPtr(21), LastSeenMaxDays: intPtr(14)}},
+	} {
+		if _, err := s.notifications.BulkSchedule(ctx, creator, &input); !errors.Is(err, service.ErrInvalidBulkSchedule) {
+			t.Errorf("expected ErrInvalidBulkSchedule for %+v, got: %v", input, err)
+		}
+	}
+}
+
+func TestBulkScheduleIdempotency(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	c := s.createClient(t, time.Now().Add(-week))
+	input := service.BulkScheduleInput{
+		ClientIds:       []string{c.Id},
+		Date:            time.Now().Add(time.Hour * 48).Truncate(time.Second).Format(time.RFC3339),
+		DeliveryMethods: []int8{int8(service.SMS)},
+		IdempotencyKey:  "campaign-1",
+	}
+	first, err := s.notifications.BulkSchedule(ctx, creator, &input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Scheduled != 1 {
+		t.Fatalf("unexpected job: %+v", first)
+	}
+
+	// a retry with the same key returns the notification of the first request.
+	retry, err := s.notifications.BulkSchedule(ctx, creator, &input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retry.Id != first.Id || retry.Scheduled != 1 || retry.Results[0].NotificationId != first.Results[0].NotificationId {
+		t.Fatalf("expected the first job, got: %+v, %+v", retry, first)
+	}
+	if got := pendingReminders(t, s.store, c.Id); len(got) != 1 {
+		t.Fatalf("expected a single notification, got: %+v", got)
+	}
+
+	// other jobs skip the client, it already has a notification pending that day.
+	for _, key := range []string{"campaign-2", ""} {
+		input.IdempotencyKey = key
+		job, err := s.notifications.BulkSchedule(ctx, creator, &input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Id == first.Id || job.Skipped != 1 || job.Results[0].Status != service.BulkSkipped {
+			t.Fatalf("expected the client to be skipped, got: %+v", job)
+		}
+	}
+	if got := pendingReminders(t, s.store, c.Id); len(got) != 1 {
+		t.Fatalf("expected a single notification, got: %+v", got)
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"log/slog"
+	"slices"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/japb1998/control-tower/internal/database"
@@ -18,20 +20,44 @@ import (
 
 var (
 	ErrInvalidDateString = errors.New("provided input string is not a valid date.")
+	ErrClientNotFound    = errors.New("client not found")
+	ErrInvalidTimeZone   = errors.New("invalid time zone, use an IANA name like America/New_York")
 )
 
 type ClientRepository interface {
 	GetClientsByCreator(string) ([]model.ClientItem, error)
 	UpdateUser(createdBy string, userId string, client database.PatchClientItem) (model.ClientItem, error)
 	CreateClient(model.ClientItem) (model.ClientItem, error)
+	CreateClients(clients []model.ClientItem) (failed []model.ClientItem, err error)
 	DeleteClient(createdBy, id string) error
 	GetClientById(createdBy, id string) (*model.ClientItem, error)
 	GetClientWithFilters(createdBy string, clientDto database.PatchClientItem, p *database.PaginationOps) ([]model.ClientItem, error)
 	ClientCountWithFilters(createdBy string, clientPatch database.PatchClientItem) (int64, error)
+	WalkClientsWithFilters(createdBy string, clientPatch database.PatchClientItem, fn func(model.ClientItem) error) error
+	GetArchivedClients(createdBy string) ([]model.ClientItem, error)
+	ArchiveClient(createdBy, id string, at time.Time) error
+	RestoreClient(createdBy, id string) (model.ClientItem, error)
+	GetClientsByPhone(phone string) ([]model.ClientItem, error)
+	GetClientsByEmail(email string) ([]model.ClientItem, error)
+}
+
+// ClientNotificationSvc are the notification operations triggered by client changes.
+type ClientNotificationSvc interface {
+	RescheduleMaintenance(createdBy string, client ClientDto) (string, error)
+	ReassignClient(ctx context.Context, createdBy, fromClientId, toClientId string) error
+	DeleteClientNotifications(ctx context.Context, createdBy, clientId string) ([]string, error)
+	CancelClientNotifications(ctx context.Context, createdBy, clientId string) ([]string, error)
+}
+
+// NotificationBroadcaster pushes notification changes to the creator open websocket connections.
+type NotificationBroadcaster interface {
+	SendWsMessageByEmail(ctx context.Context, msg *NotificationUpdate) error
 }
 
 type ClientService struct {
-	Store ClientRepository
+	Store         ClientRepository
+	notifications ClientNotificationSvc
+	broadcaster   NotificationBroadcaster
 }
 type FiltersResponseDto struct {
 	Data  []ClientDto `json:"data"`
@@ -40,37 +66,53 @@ type FiltersResponseDto struct {
 	Total int64       `json:"total"`
 }
 type ClientDto struct {
-	Id           string  `json:"id"`
-	CreatedBy    string  `json:"createdBy"`
-	Phone        string  `json:"phone"`
-	Email        string  `json:"email"`
-	FirstName    string  `json:"firstName"`
-	LastName     string  `json:"lastName"`
-	LastSeen     *string `json:"lastSeen"`
-	CreatedAt    string  `json:"createdAt"`
-	LastUpdateAt string  `json:"lastUpdateAt"`
-	Description  string  `json:"description"`
-	OptIn        *bool   `json:"optIn"`
+	Id              string  `json:"id"`
+	CreatedBy       string  `json:"createdBy"`
+	Phone           string  `json:"phone"`
+	Email           string  `json:"email"`
+	FirstName       string  `json:"firstName"`
+	LastName        string  `json:"lastName"`
+	LastSeen        *string `json:"lastSeen"`
+	CreatedAt       string  `json:"createdAt"`
+	LastUpdateAt    string  `json:"lastUpdateAt"`
+	Description     string  `json:"description"`
+	OptIn           *bool   `json:"optIn"`
+	DeliveryMethods []int8  `json:"deliveryMethods"`
+	// DisabledChannels are never used for the client, emails that bounce or are reported as spam are disabled automatically.
+	DisabledChannels []int8  `json:"disabledChannels"`
+	DeletedAt        *string `json:"deletedAt,omitempty"`
+	// Language of the reminders, the creator default language is used when it is empty.
+	Language string `json:"language,omitempty"`
+	// TimeZone of the client, the creator time zone is used when it is empty.
+	TimeZone string `json:"timeZone,omitempty"`
 }
 
 type CreateClient struct {
-	Phone        string  `json:"phone" binding:"omitempty,e164"`
-	Email        string  `json:"email" binding:"omitempty,email"`
-	FirstName    string  `json:"firstName" binding:"required"`
-	LastName     string  `json:"lastName" binding:"required"`
-	CreatedAt    string  `json:"createdAt" binding:"omitempty,rfc3339"`
-	LastUpdateAt string  `json:"lastUpdateAt" binding:"omitempty,rfc3339"`
-	Description  string  `json:"description" binding:"omitempty,min=2,max=255"`
-	LastSeen     *string `json:"lastSeen" binding:"required,rfc3339"`
+	Phone           string  `json:"phone" binding:"omitempty,e164"`
+	Email           string  `json:"email" binding:"omitempty,email"`
+	FirstName       string  `json:"firstName" binding:"required"`
+	LastName        string  `json:"lastName" binding:"required"`
+	CreatedAt       string  `json:"createdAt" binding:"omitempty,rfc3339"`
+	LastUpdateAt    string  `json:"lastUpdateAt" binding:"omitempty,rfc3339"`
+	Description     string  `json:"description" binding:"omitempty,min=2,max=255"`
+	LastSeen        *string `json:"lastSeen" binding:"required,rfc3339"`
+	DeliveryMethods []int8  `json:"deliveryMethods" binding:"omitempty,dive,number,min=0,max=2"`
+	Language        string  `json:"language" binding:"omitempty,oneof=en es"`
+	TimeZone        string  `json:"timeZone" binding:"omitempty,timezone"`
 }
 type PatchClient struct {
-	Phone       string  `json:"phone" form:"phone" binding:"omitempty,e164"`
-	Email       string  `json:"email" form:"email" binding:"omitempty,email"`
-	FirstName   string  `json:"firstName" form:"firstName" binding:"omitempty,min=1"`
-	LastName    string  `json:"lastName" form:"lastName" binding:"omitempty,min=1"`
-	Description string  `json:"description" form:"description" binding:"omitempty,min=2,max=255"`
-	LastSeen    *string `json:"lastSeen" form:"lastSeen" binding:"omitempty,rfc3339"`
-	OptIn       *bool   `json:"optIn" form:"optIn" binding:"omitempty,boolean"`
+	Phone           string  `json:"phone" form:"phone" binding:"omitempty,e164"`
+	Email           string  `json:"email" form:"email" binding:"omitempty,email"`
+	FirstName       string  `json:"firstName" form:"firstName" binding:"omitempty,min=1"`
+	LastName        string  `json:"lastName" form:"lastName" binding:"omitempty,min=1"`
+	Description     string  `json:"description" form:"description" binding:"omitempty,min=2,max=255"`
+	LastSeen        *string `json:"lastSeen" form:"lastSeen" binding:"omitempty,rfc3339"`
+	OptIn           *bool   `json:"optIn" form:"optIn" binding:"omitempty,boolean"`
+	DeliveryMethods []int8  `json:"deliveryMethods" form:"-" binding:"omitempty,dive,number,min=0,max=2"`
+	// DisabledChannels replaces the disabled channels of the client, an empty list enables every channel.
+	DisabledChannels *[]int8 `json:"disabledChannels" form:"-" binding:"omitempty,dive,number,min=0,max=2"`
+	Language         string  `json:"language" form:"-" binding:"omitempty,oneof=en es"`
+	TimeZone         string  `json:"timeZone" form:"-" binding:"omitempty,timezone"`
 }
 
 type ClientPaginationDto struct {
@@ -95,22 +137,34 @@ func NewClient(createdBy, firstName, lastName, createdAt, lastUpdatedAt, descrip
 }
 
 func NewClientFromItem(ci model.ClientItem) *ClientDto {
-	var lastSeen *string
+	var lastSeen, deletedAt *string
 	if ci.LastSeen != nil {
 		lastSeen = aws.String(ci.LastSeen.Format(time.RFC3339))
 	}
+	if ci.DeletedAt != nil {
+		deletedAt = aws.String(ci.DeletedAt.Format(time.RFC3339))
+	}
+	disabled := ci.DisabledChannels
+	if disabled == nil {
+		disabled = make([]int8, 0)
+	}
 	return &ClientDto{
-		Id:           ci.SortKey,
-		CreatedBy:    ci.PrimaryKey,
-		Phone:        ci.Phone,
-		Email:        ci.Email,
-		FirstName:    ci.FirstName,
-		LastName:     ci.LastName,
-		CreatedAt:    ci.CreatedAt.Format(time.RFC3339),
-		LastUpdateAt: ci.LastUpdateAt.Format(time.RFC3339),
-		LastSeen:     lastSeen,
-		Description:  ci.Description,
-		OptIn:        &ci.OptIn,
+		Id:               ci.SortKey,
+		CreatedBy:        ci.PrimaryKey,
+		Phone:            ci.Phone,
+		Email:            ci.Email,
+		FirstName:        ci.FirstName,
+		LastName:         ci.LastName,
+		CreatedAt:        ci.CreatedAt.Format(time.RFC3339),
+		LastUpdateAt:     ci.LastUpdateAt.Format(time.RFC3339),
+		LastSeen:         lastSeen,
+		Description:      ci.Description,
+		OptIn:            &ci.OptIn,
+		DeliveryMethods:  ci.DeliveryMethods,
+		DisabledChannels: disabled,
+		DeletedAt:        deletedAt,
+		Language:         ci.Language,
+		TimeZone:         ci.TimeZone,
 	}
 }
 func NewClientSvc(s ClientRepository) *ClientService {
@@ -119,6 +173,67 @@ func NewClientSvc(s ClientRepository) *ClientService {
 		Store: s,
 	}
 }
+
+// WithNotifications sets the notification service used to keep the client reminders up to date.
+func (c *ClientService) WithNotifications(n ClientNotificationSvc) *ClientService {
+	c.notifications = n
+	return c
+}
+
+// WithBroadcaster sets the broadcaster used to tell the open connections about notifications removed with a client.
+func (c *ClientService) WithBroadcaster(b NotificationBroadcaster) *ClientService {
+	c.broadcaster = b
+	return c
+}
+
+// preferredDeliveryMethods returns the client delivery methods. when the client has none every available contact is used.
+// disabled channels are never returned.
+func (c *ClientDto) preferredDeliveryMethods() []int8 {
+	methods := make([]int8, 0, 2)
+
+	if len(c.DeliveryMethods) > 0 {
+		methods = append(methods, c.DeliveryMethods...)
+	} else {
+		if c.Phone != "" {
+			methods = append(methods, int8(Phone))
+		}
+		if c.Email != "" {
+			methods = append(methods, int8(Email))
+		}
+	}
+	return slices.DeleteFunc(methods, func(m int8) bool {
+		return slices.Contains(c.DisabledChannels, m)
+	})
+}
+
+// DisableChannel stops using the channel for the client. disabling a channel that is already disabled does nothing.
+func (c *ClientService) DisableChannel(ctx context.Context, createdBy, clientId string, channel ContactOptions) (ClientDto, error) {
+	current, err := c.Store.GetClientById(createdBy, clientId)
+
+	if err != nil {
+		clientLogger.Error(err.Error())
+		return ClientDto{}, err
+	}
+	if current == nil {
+		return ClientDto{}, ErrClientNotFound
+	}
+	if slices.Contains(current.DisabledChannels, int8(channel)) {
+		return *NewClientFromItem(*current), nil
+	}
+	disabled := append(slices.Clone(current.DisabledChannels), int8(channel))
+	item, err := c.Store.UpdateUser(createdBy, clientId, database.PatchClientItem{DisabledChannels: &disabled})
+
+	if err != nil {
+		clientLogger.Error(err.Error())
+		if errors.Is(err, database.ErrClientNotFound) {
+			return ClientDto{}, ErrClientNotFound
+		}
+		return ClientDto{}, err
+	}
+	clientLogger.Info("channel disabled", slog.String("clientId", clientId), slog.Int("channel", int(channel)))
+	return *NewClientFromItem(item), nil
+}
+
 func (c *ClientService) GetClientsByCreator(ctx context.Context, createdBy string) ([]ClientDto, error) {
 	clientList, err := c.Store.GetClientsByCreator(createdBy)
 
@@ -135,16 +250,24 @@ func (c *ClientService) GetClientsByCreator(ctx context.Context, createdBy strin
 }
 
 func (c *ClientService) UpdateUser(ctx context.Context, createdBy, clientId string, client PatchClient) (ClientDto, error) {
+	if err := checkTimeZone(client.TimeZone); err != nil {
+		return ClientDto{}, err
+	}
 
 	patch := database.PatchClientItem{
-		Phone:       client.Phone,
-		Email:       client.Email,
-		FirstName:   client.FirstName,
-		LastName:    client.LastName,
-		Description: client.Description,
-		OptIn:       client.OptIn,
+		Phone:            client.Phone,
+		Email:            client.Email,
+		FirstName:        client.FirstName,
+		LastName:         client.LastName,
+		Description:      client.Description,
+		OptIn:            client.OptIn,
+		DeliveryMethods:  client.DeliveryMethods,
+		DisabledChannels: client.DisabledChannels,
+		Language:         client.Language,
+		TimeZone:         client.TimeZone,
 	}
 
+	var previousLastSeen *time.Time
 	if client.LastSeen != nil {
 		lastSeen, err := time.Parse(time.RFC3339, *client.LastSeen)
 
@@ -153,6 +276,17 @@ func (c *ClientService) UpdateUser(ctx context.Context, createdBy, clientId stri
 			return ClientDto{}, fmt.Errorf("lastSeen could not me converted to date error='%s'", err)
 		}
 		patch.LastSeen = &lastSeen
+
+		current, err := c.Store.GetClientById(createdBy, clientId)
+
+		if err != nil {
+			clientLogger.Error(err.Error())
+			return ClientDto{}, err
+		}
+		if current == nil {
+			return ClientDto{}, ErrClientNotFound
+		}
+		previousLastSeen = current.LastSeen
 	}
 
 	clientLogger.Info("Updating User", slog.Any("patch", patch))
@@ -161,10 +295,23 @@ func (c *ClientService) UpdateUser(ctx context.Context, createdBy, clientId stri
 
 	if err != nil {
 		clientLogger.Error(err.Error())
+		if errors.Is(err, database.ErrClientNotFound) {
+			return ClientDto{}, ErrClientNotFound
+		}
 		return ClientDto{}, err
 	}
+	dto := *NewClientFromItem(item)
+
+	// a new visit moves lastSeen forward, the next maintenance reminder is scheduled from it.
+	if c.notifications != nil && patch.LastSeen != nil && (previousLastSeen == nil || patch.LastSeen.After(*previousLastSeen)) {
+		if id, err := c.notifications.RescheduleMaintenance(createdBy, dto); err != nil {
+			clientLogger.Error("failed to reschedule maintenance reminder", slog.String("clientId", clientId), slog.String("error", err.Error()))
+		} else if id != "" {
+			clientLogger.Info("maintenance reminder scheduled", slog.String("clientId", clientId), slog.String("notificationId", id))
+		}
+	}
 
-	return *NewClientFromItem(item), nil
+	return dto, nil
 }
 
 func (c *ClientService) CreateClient(ctx context.Context, createdBy string, client CreateClient) (ClientDto, error) {
@@ -173,7 +320,12 @@ func (c *ClientService) CreateClient(ctx context.Context, createdBy string, clie
 		log.Printf("failed to convert lastSeen error:'%s'\n", err)
 		return ClientDto{}, ErrInvalidDateString
 	}
-	item := model.NewClientItem(createdBy, client.Phone, client.Email, client.FirstName, client.LastName, client.Description, &lastSeen)
+	if err := checkTimeZone(client.TimeZone); err != nil {
+		return ClientDto{}, err
+	}
+	item := model.NewClientItem(createdBy, client.Phone, client.Email, client.FirstName, client.LastName, client.Description, &lastSeen, client.DeliveryMethods)
+	item.Language = client.Language
+	item.TimeZone = client.TimeZone
 
 	_, err = c.Store.CreateClient(*item)
 
@@ -185,7 +337,18 @@ func (c *ClientService) CreateClient(ctx context.Context, createdBy string, clie
 	return *NewClientFromItem(*item), nil
 }
 
-func (c *ClientService) DeleteClient(createdBy, id string) error {
+// DeleteClient deletes the client and every notification of the client. pending schedules are cancelled first so no reminder is sent to a missing client.
+func (c *ClientService) DeleteClient(ctx context.Context, createdBy, id string) error {
+	if c.notifications != nil {
+		ids, err := c.notifications.DeleteClientNotifications(ctx, createdBy, id)
+
+		if err != nil {
+			clientLogger.Error("error deleting client notifications", slog.String("clientId", id), slog.String("error", err.Error()))
+			return fmt.Errorf("error deleting client notifications")
+		}
+		defer c.broadcast(ctx, createdBy, NotificationDeletedAction, ids)
+	}
+
 	err := c.Store.DeleteClient(createdBy, id)
 
 	if err != nil {
@@ -195,38 +358,148 @@ func (c *ClientService) DeleteClient(createdBy, id string) error {
 	return nil
 }
 
-func (c *ClientService) GetClientById(ctx context.Context, createdBy, id string) (*ClientDto, error) {
-	item, err := c.Store.GetClientById(createdBy, id)
+// ArchiveClient hides the client until it is restored. pending notifications are cancelled, sent ones are kept.
+func (c *ClientService) ArchiveClient(ctx context.Context, createdBy, id string) error {
+	current, err := c.Store.GetClientById(createdBy, id)
 
 	if err != nil {
 		clientLogger.Error(err.Error())
-		return nil, err
+		return fmt.Errorf("error getting client")
+	}
+	if current == nil {
+		return ErrClientNotFound
 	}
 
-	return NewClientFromItem(*item), nil
+	if c.notifications != nil {
+		ids, err := c.notifications.CancelClientNotifications(ctx, createdBy, id)
+
+		if err != nil {
+			clientLogger.Error("error cancelling client notifications", slog.String("clientId", id), slog.String("error", err.Error()))
+			return fmt.Errorf("error cancelling client notifications")
+		}
+		defer c.broadcast(ctx, createdBy, NotificationUpdatedAction, ids)
+	}
+
+	if err := c.Store.ArchiveClient(createdBy, id, time.Now().UTC()); err != nil {
+		if errors.Is(err, database.ErrClientNotFound) {
+			return ErrClientNotFound
+		}
+		clientLogger.Error("error archiving client", slog.String("clientId", id), slog.String("error", err.Error()))
+		return fmt.Errorf("error archiving client")
+	}
+	clientLogger.Info("client archived", slog.String("clientId", id))
+	return nil
 }
 
-// GetClientWithFilters get clients with filters. Paginated, Zero indexed
-func (c *ClientService) GetClientWithFilters(ctx context.Context, createdBy string, dto ClientPaginationDto) (FiltersResponseDto, error) {
+// RestoreClient makes an archived client visible again and schedules its next maintenance reminder. cancelled notifications stay cancelled.
+func (c *ClientService) RestoreClient(ctx context.Context, createdBy, id string) (ClientDto, error) {
+	item, err := c.Store.RestoreClient(createdBy, id)
 
-	var lastSeen *time.Time
-	if dto.LastSeen != nil {
-		ls, err := time.Parse(time.RFC3339, *dto.LastSeen)
+	if err != nil {
+		if errors.Is(err, database.ErrClientNotFound) {
+			return ClientDto{}, ErrClientNotFound
+		}
+		clientLogger.Error("error restoring client", slog.String("clientId", id), slog.String("error", err.Error()))
+		return ClientDto{}, fmt.Errorf("error restoring client")
+	}
+	dto := *NewClientFromItem(item)
+
+	if c.notifications != nil {
+		if _, err := c.notifications.RescheduleMaintenance(createdBy, dto); err != nil {
+			clientLogger.Error("failed to reschedule maintenance reminder", slog.String("clientId", id), slog.String("error", err.Error()))
+		}
+	}
+	clientLogger.Info("client restored", slog.String("clientId", id))
+	return dto, nil
+}
+
+// GetArchivedClients returns the archived clients of the creator.
+func (c *ClientService) GetArchivedClients(ctx context.Context, createdBy string) ([]ClientDto, error) {
+	items, err := c.Store.GetArchivedClients(createdBy)
+
+	if err != nil {
+		clientLogger.Error("error getting archived clients", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error getting archived clients")
+	}
+	dtos := make([]ClientDto, 0, len(items))
+
+	for _, i := range items {
+		dtos = append(dtos, *NewClientFromItem(i))
+	}
+	return dtos, nil
+}
+
+// GetClientsByPhone returns the clients of every creator with the phone, it is used to find the senders of inbound messages.
+func (c *ClientService) GetClientsByPhone(ctx context.Context, phone string) ([]ClientDto, error) {
+	items, err := c.Store.GetClientsByPhone(NormalizePhone(phone))
+
+	if err != nil {
+		clientLogger.Error("error getting clients by phone", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error getting clients by phone")
+	}
+	dtos := make([]ClientDto, 0, len(items))
+
+	for _, i := range items {
+		dtos = append(dtos, *NewClientFromItem(i))
+	}
+	return dtos, nil
+}
+
+// GetClientsByEmail returns the clients of every creator with the email, it is used to find the senders of inbound emails.
+func (c *ClientService) GetClientsByEmail(ctx context.Context, email string) ([]ClientDto, error) {
+	items, err := c.Store.GetClientsByEmail(strings.TrimSpace(email))
+
+	if err != nil {
+		clientLogger.Error("error getting clients by email", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error getting clients by email")
+	}
+	dtos := make([]ClientDto, 0, len(items))
+
+	for _, i := range items {
+		dtos = append(dtos, *NewClientFromItem(i))
+	}
+	return dtos, nil
+}
+
+// broadcast notifies the creator connections about the notifications. failures are logged, connections refresh on reconnect.
+func (c *ClientService) broadcast(ctx context.Context, createdBy, action string, notificationIds []string) {
+	if c.broadcaster == nil {
+		return
+	}
+	for _, id := range notificationIds {
+		msg, err := NewNotificationUpdateMsg(createdBy, id).WithAction(action)
 
 		if err != nil {
 			clientLogger.Error(err.Error())
-			return FiltersResponseDto{}, fmt.Errorf("failed to convert lastSeen Date error='%s'", ErrInvalidDateString)
+			return
+		}
+		if err := c.broadcaster.SendWsMessageByEmail(ctx, msg); err != nil {
+			clientLogger.Error("error sending notification update", slog.String("notificationId", id), slog.String("error", err.Error()))
 		}
-		clientLogger.Info("lastSeen", slog.Time("at", ls))
-		lastSeen = &ls
 	}
+}
 
-	f := database.PatchClientItem{
-		Phone:     dto.Phone,
-		Email:     dto.Email,
-		FirstName: dto.FirstName,
-		LastName:  dto.LastName,
-		LastSeen:  lastSeen,
+func (c *ClientService) GetClientById(ctx context.Context, createdBy, id string) (*ClientDto, error) {
+	item, err := c.Store.GetClientById(createdBy, id)
+
+	if err != nil {
+		clientLogger.Error(err.Error())
+		return nil, err
+	}
+
+	if item == nil {
+		return nil, ErrClientNotFound
+	}
+
+	return NewClientFromItem(*item), nil
+}
+
+// GetClientWithFilters get clients with filters. Paginated, Zero indexed
+func (c *ClientService) GetClientWithFilters(ctx context.Context, createdBy string, dto ClientPaginationDto) (FiltersResponseDto, error) {
+	f, err := clientFilters(dto.PatchClient)
+
+	if err != nil {
+		return FiltersResponseDto{}, err
 	}
 
 	paginatioOps := database.PaginationOps{
@@ -291,12 +564,35 @@ func (c *ClientService) GetClientWithFilters(ctx context.Context, createdBy stri
 	}, nil
 }
 
+// clientFilters returns the repository filters of the dto.
+func clientFilters(dto PatchClient) (database.PatchClientItem, error) {
+	var lastSeen *time.Time
+	if dto.LastSeen != nil {
+		ls, err := time.Parse(time.RFC3339, *dto.LastSeen)
+
+		if err != nil {
+			clientLogger.Error(err.Error())
+			return database.PatchClientItem{}, fmt.Errorf("failed to convert lastSeen Date error='%s'", ErrInvalidDateString)
+		}
+		clientLogger.Info("lastSeen", slog.Time("at", ls))
+		lastSeen = &ls
+	}
+
+	return database.PatchClientItem{
+		Phone:     dto.Phone,
+		Email:     dto.Email,
+		FirstName: dto.FirstName,
+		LastName:  dto.LastName,
+		LastSeen:  lastSeen,
+	}, nil
+}
+
 func (c *ClientService) OptOut(ctx context.Context, createdBy, clientId string) error {
 	patch := database.PatchClientItem{
 		OptIn: aws.Bool(false),
 	}
 	if _, err := c.Store.UpdateUser(createdBy, clientId, patch); err != nil {
-		clientLogger.Error("Unable to unsubscribe user createdBy='%s', clientId='%s' error=%s", createdBy, clientId, err)
+		clientLogger.Error("Unable to unsubscribe user", slog.String("createdBy", createdBy), slog.String("clientId", clientId), slog.String("error", err.Error()))
 		return fmt.Errorf("Unable to unsubscribe user")
 	}
 
@@ -0,0 +1,276 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/database/memory"
+	"github.com/japb1998/control-tower/internal/model"
+	"github.com/japb1998/control-tower/internal/scheduler"
+	"github.com/japb1998/control-tower/internal/service"
+)
+
+const creator = "creator@test.com"
+
+type testServices struct {
+	clients       *service.ClientService
+	notifications *service.NotificationService
+	clientStore   *memory.ClientRepository
+	store         *memory.NotificationRepository
+	settings      *memory.SettingsRepository
+	scheduler     scheduler.Scheduler
+}
+
+func newTestServices(t *testing.T) *testServices {
+	sch, err := scheduler.NewLocalScheduler("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServices{
+		clientStore: memory.NewClientRepo(),
+		store:       memory.NewNotificationRepo(),
+		settings:    memory.NewSettingsRepo(),
+		scheduler:   sch,
+	}
+	s.notifications = service.NewNotificationService(s.store, sch, s.settings).WithClients(s.clientStore)
+	s.clients = service.NewClientSvc(s.clientStore).WithNotifications(s.notifications)
+	return s
+}
+
+func (s *testServices) createClient(t *testing.T, lastSeen time.Time) service.ClientDto {
+	ls := lastSeen.Format(time.RFC3339)
+	c, err := s.clients.CreateClient(context.Background(), creator, service.CreateClient{
+		FirstName: "Ana",
+		LastName:  "Perez",
+		Phone:     "+17860000001",
+		LastSeen:  &ls,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestGetClientByIdNotFound(t *testing.T) {
+	s := newTestServices(t)
+
+	if _, err := s.clients.GetClientById(context.Background(), creator, "missing"); !errors.Is(err, service.ErrClientNotFound) {
+		t.Fatalf("expected ErrClientNotFound, got: %v", err)
+	}
+
+	lastSeen := time.Now().Format(time.RFC3339)
+	if _, err := s.clients.UpdateUser(context.Background(), creator, "missing", service.PatchClient{LastSeen: &lastSeen}); !errors.Is(err, service.ErrClientNotFound) {
+		t.Fatalf("expected ErrClientNotFound, got: %v", err)
+	}
+}
+
+func TestUpdateUserReschedulesMaintenance(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+
+	if err := s.settings.SaveSettings(*model.NewSettingsItem(creator, 2)); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	c := s.createClient(t, now.Add(-week))
+
+	visit := now.Format(time.RFC3339)
+	if _, err := s.clients.UpdateUser(ctx, creator, c.Id, service.PatchClient{LastSeen: &visit}); err != nil {
+		t.Fatal(err)
+	}
+	pending := pendingReminders(t, s.store, c.Id)
+
+	if len(pending) != 1 || pending[0].Date != now.Add(week*2).Format(time.RFC3339) {
+		t.Fatalf("expected one reminder two weeks after the visit, got: %+v", pending)
+	}
+
+	// an older visit does not move the reminder.
+	older := now.Add(-time.Hour).Format(time.RFC3339)
+	if _, err := s.clients.UpdateUser(ctx, creator, c.Id, service.PatchClient{LastSeen: &older}); err != nil {
+		t.Fatal(err)
+	}
+	if got := pendingReminders(t, s.store, c.Id); len(got) != 1 || got[0].SortKey != pending[0].SortKey {
+		t.Fatalf("expected reminder to be kept, got: %+v", got)
+	}
+
+	// a newer visit replaces it.
+	newer := now.Add(time.Hour).Format(time.RFC3339)
+	if _, err := s.clients.UpdateUser(ctx, creator, c.Id, service.PatchClient{LastSeen: &newer}); err != nil {
+		t.Fatal(err)
+	}
+	got := pendingReminders(t, s.store, c.Id)
+
+	if len(got) != 1 || got[0].SortKey == pending[0].SortKey || got[0].Date != now.Add(time.Hour+week*2).Format(time.RFC3339) {
+		t.Fatalf("expected reminder to be rescheduled, got: %+v", got)
+	}
+}
+
+func TestRescheduleMaintenanceKeepsOtherReminders(t *testing.T) {
+	s := newTestServices(t)
+
+	if err := s.settings.SaveSettings(*model.NewSettingsItem(creator, 2)); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	c := s.createClient(t, now.Add(-week))
+
+	manual, err := s.notifications.ScheduleNotification(creator, &service.NotificationInput{
+		Date:            now.Add(time.Hour * 72).Format(time.RFC3339),
+		ClientId:        c.Id,
+		DeliveryMethods: []int8{int8(service.SMS)},
+		Message:         "your appointment is on Friday",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	visit := now.Format(time.RFC3339)
+	c.LastSeen = &visit
+	maintenance, err := s.notifications.RescheduleMaintenance(creator, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.notifications.GetNotification(creator, maintenance); err != nil || n.Kind != service.MaintenanceKind {
+		t.Fatalf("expected a maintenance reminder, got: %+v, %v", n, err)
+	}
+
+	// a replacement in the past is not scheduled and nothing is cancelled.
+	past := now.Add(-week * 3).Format(time.RFC3339)
+	c.LastSeen = &past
+	if id, err := s.notifications.RescheduleMaintenance(creator, c); err != nil || id != "" {
+		t.Fatalf("expected no reminder, got: '%s', %v", id, err)
+	}
+	if got := pendingReminders(t, s.store, c.Id); len(got) != 2 {
+		t.Fatalf("expected both reminders to be kept, got: %+v", got)
+	}
+
+	newer := now.Add(time.Hour).Format(time.RFC3339)
+	c.LastSeen = &newer
+	replacement, err := s.notifications.RescheduleMaintenance(creator, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool)
+	for _, i := range pendingReminders(t, s.store, c.Id) {
+		got[i.SortKey] = true
+	}
+	if len(got) != 2 || !got[manual] || !got[replacement] {
+		t.Fatalf("expected only the maintenance reminder to be replaced, got: %v", got)
+	}
+	if _, err := s.scheduler.GetSchedule(maintenance); !errors.Is(err, scheduler.ErrNotFound) {
+		t.Fatalf("expected the old schedule to be deleted, got: %v", err)
+	}
+}
+
+const week = time.Hour * 24 * 7
+
+func pendingReminders(t *testing.T, store *memory.NotificationRepository, clientId string) []model.NotificationItem {
+	items, err := store.GetNotificationsByClient(creator, clientId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := make([]model.NotificationItem, 0, len(items))
+	for _, i := range items {
+		if i.Status == service.NotSentStatus.String() {
+			pending = append(pending, i)
+		}
+	}
+	return pending
+}
+
+type recordedBroadcast struct {
+	msgs []*service.NotificationUpdate
+}
+
+func (r *recordedBroadcast) SendWsMessageByEmail(ctx context.Context, msg *service.NotificationUpdate) error {
+	r.msgs = append(r.msgs, msg)
+	return nil
+}
+
+func TestDeleteClientCascade(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	broadcast := &recordedBroadcast{}
+	s.clients.WithBroadcaster(broadcast)
+
+	c := s.createClient(t, time.Now().Add(-week))
+	id, err := s.notifications.ScheduleNotification(creator, &service.NotificationInput{
+		Date:            time.Now().Add(time.Hour).Format(time.RFC3339),
+		ClientId:        c.Id,
+		DeliveryMethods: []int8{int8(service.Phone)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.clients.DeleteClient(ctx, creator, c.Id); err != nil {
+		t.Fatal(err)
+	}
+	if items, err := s.store.GetNotificationsByClient(creator, c.Id); err != nil || len(items) != 0 {
+		t.Fatalf("expected client notifications to be deleted, got: %+v, %v", items, err)
+	}
+	if _, err := s.scheduler.GetSchedule(id); !errors.Is(err, scheduler.ErrNotFound) {
+		t.Fatalf("expected schedule to be cancelled, got: %v", err)
+	}
+	if len(broadcast.msgs) != 1 || broadcast.msgs[0].NotificationId != id || broadcast.msgs[0].Action != service.NotificationDeletedAction {
+		t.Fatalf("expected a notificationDeleted event, got: %+v", broadcast.msgs)
+	}
+}
+
+func TestArchiveAndRestoreClient(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+
+	if err := s.settings.SaveSettings(*model.NewSettingsItem(creator, 2)); err != nil {
+		t.Fatal(err)
+	}
+	c := s.createClient(t, time.Now().Add(-week))
+	visit := time.Now().Format(time.RFC3339)
+	if _, err := s.clients.UpdateUser(ctx, creator, c.Id, service.PatchClient{LastSeen: &visit}); err != nil {
+		t.Fatal(err)
+	}
+	pending := pendingReminders(t, s.store, c.Id)
+
+	if len(pending) != 1 {
+		t.Fatalf("expected a maintenance reminder, got: %+v", pending)
+	}
+
+	if err := s.clients.ArchiveClient(ctx, creator, c.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.clients.GetClientById(ctx, creator, c.Id); !errors.Is(err, service.ErrClientNotFound) {
+		t.Fatalf("expected archived client to be hidden, got: %v", err)
+	}
+	n, err := s.notifications.GetNotification(creator, pending[0].SortKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Status != service.CancelledStatus.String() {
+		t.Fatalf("expected reminder to be cancelled, got: %s", n.Status)
+	}
+	if _, err := s.scheduler.GetSchedule(n.ID); !errors.Is(err, scheduler.ErrNotFound) {
+		t.Fatalf("expected schedule to be cancelled, got: %v", err)
+	}
+	archived, err := s.clients.GetArchivedClients(ctx, creator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archived) != 1 || archived[0].DeletedAt == nil {
+		t.Fatalf("expected the archived client, got: %+v", archived)
+	}
+
+	restored, err := s.clients.RestoreClient(ctx, creator, c.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.DeletedAt != nil {
+		t.Fatalf("expected restored client, got: %+v", restored)
+	}
+	if got := pendingReminders(t, s.store, c.Id); len(got) != 1 || got[0].SortKey == pending[0].SortKey {
+		t.Fatalf("expected a new maintenance reminder, got: %+v", got)
+	}
+	if _, err := s.clients.RestoreClient(ctx, creator, c.Id); !errors.Is(err, service.ErrClientNotFound) {
+		t.Fatalf("expected ErrClientNotFound restoring an active client, got: %v", err)
+	}
+}
@@ -20,11 +20,13 @@ const (
 
 // ws-actions
 const (
-	NotificationUpdatedAction = "updateNotification"  // properties of an specific notification changed.
-	NotificationCreatedAction = "newNotification"     // new notification was created.
-	NotificationDeletedAction = "notificationDeleted" // a notification was deleted.
-	PingResponseAction        = "health-response"
-	PingAction                = "health" // ping action to keep the connection alive for longer than 10mins.
+	NotificationUpdatedAction   = "updateNotification"    // properties of an specific notification changed.
+	NotificationCreatedAction   = "newNotification"       // new notification was created.
+	NotificationDeletedAction   = "notificationDeleted"   // a notification was deleted.
+	NotificationConfirmedAction = "notificationConfirmed" // the client confirmed a notification.
+	NewMessageAction            = "newMessage"            // a message was added to the conversation with a client.
+	PingResponseAction          = "health-response"
+	PingAction                  = "health" // ping action to keep the connection alive for longer than 10mins.
 )
 
 type ConnectionSvc struct {
@@ -49,14 +51,17 @@ type webSocketMsg struct {
 	notificationUpdated = "updateNotifications" // properties of an specific notification changed.
 	notificationCreated = "newNotification" // new notification was created.
 	notificationDeleted = "notificationDeleted" // a notification was deleted.
+	notificationConfirmed = "notificationConfirmed" // the client confirmed a notification.
+	newMessage = "newMessage" // a message was added to the conversation with a client.
 	*/
 	Action string `json:"action"`
 }
 
 type NotificationUpdate struct {
 	webSocketMsg
-	Email          string `json:"email"`
-	NotificationId string `json:"notificationId"`
+	Email          string     `json:"email"`
+	NotificationId string     `json:"notificationId"`
+	Deliveries     []Delivery `json:"deliveries,omitempty"` // channel results of the last send.
 }
 
 func NewNotificationUpdateMsg(email, notificationId string) *NotificationUpdate {
@@ -66,6 +71,12 @@ func NewNotificationUpdateMsg(email, notificationId string) *NotificationUpdate
 	}
 }
 
+// WithDeliveries adds the channel results of the last send to the message.
+func (n *NotificationUpdate) WithDeliveries(deliveries []Delivery) *NotificationUpdate {
+	n.Deliveries = deliveries
+	return n
+}
+
 // WithAction - receives an action string and returns the *NotificationUpdate pointer or error if the action is invalid.
 func (n *NotificationUpdate) WithAction(action string) (*NotificationUpdate, error) {
 	switch action {
@@ -74,6 +85,8 @@ func (n *NotificationUpdate) WithAction(action string) (*NotificationUpdate, err
 	case NotificationCreatedAction:
 		fallthrough
 	case NotificationDeletedAction:
+		fallthrough
+	case NotificationConfirmedAction:
 		n.Action = action
 		return n, nil
 	}
@@ -99,13 +112,33 @@ func (c *ConnectionSvc) SendWsMessageByEmail(ctx context.Context, msg *Notificat
 	if msg.Action == "" {
 		return fmt.Errorf("message action can't be empty")
 	}
+	return c.post(ctx, msg.Email, msg)
+}
+
+// NewMessage is pushed when a message is added to the conversation with a client.
+type NewMessage struct {
+	webSocketMsg
+	Email   string  `json:"email"`
+	Message Message `json:"message"`
+}
+
+// SendNewMessage sends the message to all active connections of the creator.
+func (c *ConnectionSvc) SendNewMessage(ctx context.Context, createdBy string, m Message) error {
+	return c.post(ctx, createdBy, &NewMessage{
+		webSocketMsg: webSocketMsg{Action: NewMessageAction},
+		Email:        createdBy,
+		Message:      m,
+	})
+}
 
+// post sends the json of the message to all active connections for a user email.
+func (c *ConnectionSvc) post(ctx context.Context, email string, msg any) error {
 	var wg sync.WaitGroup
-	conns, err := c.store.GetConnectionIds(ctx, msg.Email)
+	conns, err := c.store.GetConnectionIds(ctx, email)
 
 	if err != nil {
 		connectionLogger.Error(err.Error())
-		return fmt.Errorf("error getting all active connections for email='%s'", msg.Email)
+		return fmt.Errorf("error getting all active connections for email='%s'", email)
 	}
 
 	d, err := json.Marshal(msg)
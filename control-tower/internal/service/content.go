@@ -0,0 +1,42 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/japb1998/control-tower/pkg/sms"
+)
+
+// ErrInvalidMessage is returned when the custom content of a notification can't be sent through its delivery methods.
+var ErrInvalidMessage = errors.New("invalid notification message")
+
+// validateContent checks the subject and message that replace the content of the reminder.
+// the message replaces the template, so both can't be set. the segments are counted before the variables are replaced.
+// whatsapp only accepts approved templates outside of a conversation, so custom messages can't be sent to it.
+func validateContent(templateId, subject, message string, deliveryMethods []int8) error {
+	if message != "" && templateId != "" {
+		return fmt.Errorf("notifications can't have a template and a message: %w", ErrInvalidMessage)
+	}
+	if subject != "" && !slices.Contains(deliveryMethods, int8(Email)) {
+		return fmt.Errorf("the subject is only sent by email: %w", ErrInvalidMessage)
+	}
+	if message != "" && slices.Contains(deliveryMethods, int8(Phone)) {
+		return fmt.Errorf("whatsapp only sends templates: %w", ErrInvalidMessage)
+	}
+	if message != "" && strings.TrimSpace(message) == "" {
+		return fmt.Errorf("the message is blank: %w", ErrInvalidMessage)
+	}
+	for _, text := range []string{subject, message} {
+		if _, err := templateVariableNames(text); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidMessage, err.Error())
+		}
+	}
+	if slices.Contains(deliveryMethods, int8(SMS)) {
+		if segments, _ := sms.Segments(message); segments > sms.MaxSegments {
+			return fmt.Errorf("the message takes %d text segments, the limit is %d: %w", segments, sms.MaxSegments, ErrInvalidMessage)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,505 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/japb1998/control-tower/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var tracer = otel.Tracer("github.com/japb1998/control-tower/internal/service")
+
+var (
+	ErrClientOptedOut = errors.New("client opted out of notifications")
+)
+
+// Delivery is one attempt to send the notification through a channel.
+type Delivery struct {
+	Channel    ContactOptions `json:"channel"`
+	Status     string         `json:"status"`
+	MessageId  string         `json:"messageId,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	Attempt    int            `json:"attempt"`
+	Occurrence int            `json:"occurrence,omitempty"`
+	StartedAt  string         `json:"startedAt"`
+	FinishedAt string         `json:"finishedAt"`
+}
+
+func newDeliveriesFromItems(items []model.DeliveryItem) []Delivery {
+	if len(items) == 0 {
+		return nil
+	}
+	deliveries := make([]Delivery, 0, len(items))
+
+	for _, d := range items {
+		deliveries = append(deliveries, Delivery{
+			Channel:    ContactOptions(d.Channel),
+			Status:     d.Status,
+			MessageId:  d.MessageId,
+			Error:      d.Error,
+			Attempt:    d.Attempt,
+			Occurrence: d.Occurrence,
+			StartedAt:  d.StartedAt,
+			FinishedAt: d.FinishedAt,
+		})
+	}
+	return deliveries
+}
+
+// newDeliveryItems returns the records of the results. attempts continue from the previous records of the same channel and occurrence.
+func newDeliveryItems(previous []model.DeliveryItem, occurrence int, results []ChannelResult) []model.DeliveryItem {
+	items := make([]model.DeliveryItem, 0, len(results))
+
+	for _, r := range results {
+		attempt := 1
+		for _, p := range previous {
+			if p.Channel == int8(r.Channel) && p.Occurrence == occurrence {
+				attempt++
+			}
+		}
+		items = append(items, model.DeliveryItem{
+			Channel:    int8(r.Channel),
+			Status:     r.Status,
+			MessageId:  r.MessageId,
+			Error:      r.Error,
+			Attempt:    attempt,
+			Occurrence: occurrence,
+			StartedAt:  r.StartedAt.Format(time.RFC3339),
+			FinishedAt: r.FinishedAt.Format(time.RFC3339),
+		})
+	}
+	return items
+}
+
+// latestDeliveries returns the last record of every channel in the occurrence.
+func latestDeliveries(items []model.DeliveryItem, occurrence int) map[int8]model.DeliveryItem {
+	latest := make(map[int8]model.DeliveryItem)
+	for _, d := range items {
+		if d.Occurrence == occurrence {
+			latest[d.Channel] = d
+		}
+	}
+	return latest
+}
+
+// failedChannels returns the channels whose last attempt in the occurrence failed and the highest of those attempts.
+func failedChannels(items []model.DeliveryItem, occurrence int) ([]int8, int) {
+	channels := make([]int8, 0)
+	attempt := 0
+
+	for ch, d := range latestDeliveries(items, occurrence) {
+		if d.Status != ChannelFailed {
+			continue
+		}
+		channels = append(channels, ch)
+		attempt = max(attempt, d.Attempt)
+	}
+	slices.Sort(channels)
+	return channels, attempt
+}
+
+// hasFailedChannels reports whether a channel that is not part of the results failed on its last attempt in the occurrence.
+func hasFailedChannels(items []model.DeliveryItem, occurrence int, results []ChannelResult) bool {
+	channels, _ := failedChannels(items, occurrence)
+
+	for _, ch := range channels {
+		if !slices.ContainsFunc(results, func(r ChannelResult) bool { return int8(r.Channel) == ch }) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryService sends the notifications triggered by the scheduler through the registered notifiers.
+type DeliveryService struct {
+	notifications *NotificationService
+	clients       *ClientService
+	connections   *ConnectionSvc
+	settings      SettingsRepository
+	notifiers     *NotifierRegistry
+	messages      *MessageService  // optional, keeps the sent reminders and the replies in the client conversation.
+	templates     *TemplateService // optional, sends the templates referenced by the notifications.
+}
+
+func NewDeliveryService(notifications *NotificationService, clients *ClientService, connections *ConnectionSvc, settings SettingsRepository, notifiers *NotifierRegistry) *DeliveryService {
+	return &DeliveryService{
+		notifications: notifications,
+		clients:       clients,
+		connections:   connections,
+		settings:      settings,
+		notifiers:     notifiers,
+	}
+}
+
+// WithMessages adds the sent reminders and the client replies to the client conversation.
+func (d *DeliveryService) WithMessages(messages *MessageService) *DeliveryService {
+	d.messages = messages
+	return d
+}
+
+// WithTemplates sends the templates referenced by the notifications instead of the channel defaults.
+func (d *DeliveryService) WithTemplates(templates *TemplateService) *DeliveryService {
+	d.templates = templates
+	return d
+}
+
+// SendNow delivers a pending one time notification immediately, its schedule is cancelled.
+// notifications that are no longer pending are returned as they are, so sending twice does not send the reminder again.
+func (d *DeliveryService) SendNow(ctx context.Context, createdBy, id string) (*Notification, error) {
+	if err := d.checkOptIn(ctx, createdBy, id); err != nil {
+		return nil, err
+	}
+	payload, err := d.notifications.PrepareSend(createdBy, id)
+
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		err := d.deliver(ctx, *payload)
+
+		// the schedule was cancelled, a send that stopped before recording its deliveries puts the notification back on schedule.
+		if restoreErr := d.notifications.RestoreSend(*payload); restoreErr != nil {
+			deliveryLogger.Error("failed to restore notification", slog.String("notificationId", id), slog.String("error", restoreErr.Error()))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return d.notifications.GetNotification(createdBy, id)
+}
+
+// Resend delivers the channels that failed on the last send of the notification immediately, a pending retry is cancelled.
+// channels that were sent are not sent again, so resending a sent notification does nothing.
+func (d *DeliveryService) Resend(ctx context.Context, createdBy, id string) (*Notification, error) {
+	if err := d.checkOptIn(ctx, createdBy, id); err != nil {
+		return nil, err
+	}
+	payload, err := d.notifications.PrepareResend(createdBy, id)
+
+	if err != nil {
+		return nil, err
+	}
+	return d.deliverNow(ctx, createdBy, id, payload)
+}
+
+// deliverNow delivers the payload when there is one and returns the notification.
+func (d *DeliveryService) deliverNow(ctx context.Context, createdBy, id string, payload *Notification) (*Notification, error) {
+	if payload != nil {
+		if err := d.Deliver(ctx, *payload); err != nil {
+			return nil, err
+		}
+	}
+	return d.notifications.GetNotification(createdBy, id)
+}
+
+// checkOptIn fails with ErrClientOptedOut when the client of the notification does not want to be notified.
+func (d *DeliveryService) checkOptIn(ctx context.Context, createdBy, id string) error {
+	n, err := d.notifications.GetNotification(createdBy, id)
+
+	if err != nil {
+		return err
+	}
+	client, err := d.clients.GetClientById(ctx, createdBy, n.ClientId)
+
+	if err != nil {
+		return err
+	}
+	if client.OptIn != nil && !*client.OptIn {
+		return ErrClientOptedOut
+	}
+	return nil
+}
+
+// Invoke receives the raw schedule payload. it can be used as the target of the local scheduler.
+func (d *DeliveryService) Invoke(ctx context.Context, payload string) error {
+	var event Notification
+
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		deliveryLogger.Error("invalid schedule payload", slog.String("error", err.Error()))
+		return fmt.Errorf("invalid schedule payload error: %w", err)
+	}
+	return d.Deliver(ctx, event)
+}
+
+// Deliver sends the notification through every delivery method, sets its status and notifies the active connections of the creator.
+// the first send of a one time notification claims it first, a notification another send claimed or sent is skipped.
+// recurring notifications are skipped on the weeks between their occurrences.
+func (d *DeliveryService) Deliver(ctx context.Context, event Notification) error {
+	if event.Recurrence != nil && event.Attempt <= 1 && !isOccurrenceWeek(event, time.Now()) {
+		deliveryLogger.Info("skipping week without occurrence", slog.String("notificationId", event.ID))
+		return nil
+	}
+	if event.Recurrence != nil || event.Attempt > 1 {
+		return d.deliver(ctx, event)
+	}
+	if err := d.notifications.ClaimSend(event.CreatedBy, event.ID); err != nil {
+		if errors.Is(err, ErrNotPending) {
+			deliveryLogger.Info("notification already sent", slog.String("notificationId", event.ID))
+			return nil
+		}
+		return err
+	}
+	err := d.deliver(ctx, event)
+
+	// sends that stopped before recording their deliveries leave the notification pending.
+	if _, releaseErr := d.notifications.ReleaseSend(event.CreatedBy, event.ID); releaseErr != nil {
+		deliveryLogger.Error("failed to release notification", slog.String("notificationId", event.ID), slog.String("error", releaseErr.Error()))
+	}
+	return err
+}
+
+func (d *DeliveryService) deliver(ctx context.Context, event Notification) error {
+	c, span := tracer.Start(ctx, "deliver")
+	defer span.End()
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+
+	err := validate.Struct(event)
+
+	if err != nil {
+
+		for _, ve := range err.(validator.ValidationErrors) {
+			deliveryLogger.Info("validation failed", slog.String("field", ve.Namespace()), slog.String("tag", ve.Tag()), slog.Any("value", ve.Value()), slog.String("param", ve.Param()))
+		}
+		return fmt.Errorf("error validation ocurred")
+	}
+
+	// get client span
+	getClientCtx, getClientSpan := tracer.Start(c, "get-client")
+	client, err := d.clients.GetClientById(getClientCtx, event.CreatedBy, event.ClientId)
+
+	if err != nil {
+		getClientSpan.End()
+		return err
+	}
+	if *client.OptIn == false {
+		deliveryLogger.Info("notification disabled.", slog.String("creator", event.CreatedBy), slog.String("client", event.ClientId))
+		getClientSpan.End()
+		return nil
+	}
+	getClientSpan.End()
+
+	settings, err := getSettingsItem(d.settings, event.CreatedBy)
+
+	if err != nil {
+		deliveryLogger.Error("error getting settings", slog.String("error", err.Error()))
+		return fmt.Errorf("error getting creator settings")
+	}
+
+	deliveryCtx, deliverySpan := tracer.Start(c, "delivery-methods-loop")
+	reminder := Reminder{
+		NotificationId: event.ID,
+		CreatedBy:      event.CreatedBy,
+		Client:         *client,
+		Values:         d.templateValues(*client, event, settings),
+		Template:       d.reminderTemplate(event),
+		Subject:        event.Subject,
+		Message:        event.Message,
+	}
+	results := d.notify(deliveryCtx, reminder, event.DeliveryMethods, append(slices.Clone(settings.DisabledChannels), client.DisabledChannels...))
+
+	status := SentStatus
+	for _, r := range results {
+		if r.Status == ChannelFailed {
+			status = FailedStatus
+		}
+	}
+	deliveryLogger.Info("delivery results", slog.String("notificationId", event.ID), slog.Any("results", results))
+	if status == FailedStatus {
+		deliverySpan.SetStatus(codes.Error, "delivery failed")
+	}
+	// end delivery span
+	deliverySpan.End()
+
+	attempt := max(event.Attempt, 1)
+	retrySchedule := ""
+	if channels, delay := d.retryChannels(results, attempt); len(channels) > 0 {
+		// the scheduler needs the date to be at least 30 seconds away.
+		retrySchedule, err = d.notifications.ScheduleRetry(event, channels, attempt+1, time.Now().Add(max(delay, time.Minute)))
+
+		// the notification is marked as failed when the retry can't be scheduled.
+		if err == nil {
+			status = RetryingStatus
+		}
+	}
+
+	_, statusSpan := tracer.Start(c, "set-status-span")
+	deliveries, err := d.notifications.RecordDelivery(event.CreatedBy, event.ID, DeliveryRecord{
+		Status:        status,
+		Retry:         attempt > 1,
+		RetrySchedule: retrySchedule,
+		Results:       results,
+	})
+
+	if err != nil {
+		statusSpan.SetStatus(codes.Error, err.Error())
+		statusSpan.End()
+		return err
+	}
+	statusSpan.End()
+	d.recordReminders(c, reminder, results)
+
+	// notify active connections - FE.
+	wsCtx, wsSpan := tracer.Start(c, "ws-span")
+	defer wsSpan.End()
+	msg, err := NewNotificationUpdateMsg(client.CreatedBy, event.ID).WithDeliveries(deliveries).WithAction(NotificationUpdatedAction)
+
+	// we do not return an error because an error here does not mean that the delivery failed
+	if err != nil {
+		deliveryLogger.Error("failed to generate new notification msg", slog.String("error", err.Error()))
+		wsSpan.SetAttributes(attribute.KeyValue{
+			Key:   attribute.Key("ws-delivery"),
+			Value: attribute.BoolValue(false),
+		})
+		return nil
+	}
+	deliveryLogger.Info("WS message", slog.Any("msg", msg))
+
+	err = d.connections.SendWsMessageByEmail(wsCtx, msg)
+	// we do not return an error because an error here does not mean that the delivery failed
+	if err != nil {
+		wsSpan.SetAttributes(attribute.KeyValue{
+			Key:   attribute.Key("ws-delivery"),
+			Value: attribute.BoolValue(false),
+		})
+		deliveryLogger.Error("failed to send WS message", slog.String("error", err.Error()))
+	}
+
+	return nil
+}
+
+// recordReminders adds the sent reminders to the client conversation. errors are logged, the reminders were already sent.
+func (d *DeliveryService) recordReminders(ctx context.Context, r Reminder, results []ChannelResult) {
+	if d.messages == nil {
+		return
+	}
+	for _, result := range results {
+		if result.Status != ChannelSent {
+			continue
+		}
+		if err := d.messages.RecordReminder(ctx, r.Client, r.NotificationId, result.Channel, d.reminderText(r, result.Channel), result.MessageId); err != nil {
+			deliveryLogger.Error("error recording reminder message", slog.String("notificationId", r.NotificationId), slog.String("error", err.Error()))
+		}
+	}
+}
+
+// templateValues returns the variables of the reminder, the appointment time is the date of the notification.
+func (d *DeliveryService) templateValues(client ClientDto, event Notification, settings *model.SettingsItem) TemplateValues {
+	apiUrl := ""
+	if d.templates != nil {
+		apiUrl = d.templates.apiUrl
+	}
+	// validate already checked the date.
+	appointment, _ := time.Parse(time.RFC3339, event.Date)
+	return newTemplateValues(client, apiUrl, appointment, settings)
+}
+
+// reminderTemplate returns the template of the notification. the channel defaults are sent when it can't be loaded.
+func (d *DeliveryService) reminderTemplate(event Notification) *Template {
+	if event.TemplateId == "" || d.templates == nil {
+		return nil
+	}
+	t, err := d.templates.reminderTemplate(event.CreatedBy, event.TemplateId)
+
+	if err != nil {
+		deliveryLogger.Error("error getting template, sending the defaults", slog.String("templateId", event.TemplateId), slog.String("error", err.Error()))
+		return nil
+	}
+	if t == nil {
+		deliveryLogger.Info("template was deleted, sending the defaults", slog.String("templateId", event.TemplateId))
+	}
+	return t
+}
+
+// reminderText is the text of the reminder sent through the channel.
+func (d *DeliveryService) reminderText(r Reminder, channel ContactOptions) string {
+	t := defaultTextTemplate(channel)
+
+	if n, ok := d.notifiers.Get(channel); ok {
+		if dt, ok := n.(DefaultTemplater); ok {
+			t = dt.DefaultTemplate()
+		}
+	}
+	return r.template(t).Render(r.Values).Body
+}
+
+// retryChannels returns the failed channels that can be retried after the attempt and the longest backoff between them.
+func (d *DeliveryService) retryChannels(results []ChannelResult, attempt int) ([]int8, time.Duration) {
+	channels := make([]int8, 0)
+	var delay time.Duration
+
+	for _, r := range results {
+		if r.Status != ChannelFailed || r.err == nil {
+			continue
+		}
+		policy := d.notifiers.RetryPolicy(r.Channel)
+
+		if !policy.shouldRetry(attempt, r.err) {
+			deliveryLogger.Info("channel will not be retried", slog.Int("channel", int(r.Channel)), slog.Int("attempt", attempt))
+			continue
+		}
+		channels = append(channels, int8(r.Channel))
+		delay = max(delay, policy.delay(attempt))
+	}
+	return channels, delay
+}
+
+// notify sends the reminder through every channel concurrently. results are in the order of the channels.
+func (d *DeliveryService) notify(ctx context.Context, r Reminder, channels []int8, disabled []int8) []ChannelResult {
+	var wg sync.WaitGroup
+	results := make([]ChannelResult, len(channels))
+
+	for i, ch := range channels {
+		channel := ContactOptions(ch)
+		now := time.Now().UTC()
+		results[i] = ChannelResult{Channel: channel, StartedAt: now, FinishedAt: now}
+
+		if slices.Contains(disabled, ch) {
+			deliveryLogger.Info("Skipping disabled channel", slog.Int("channel", int(ch)), slog.String("creator", r.CreatedBy))
+			results[i].Status = ChannelDisabled
+			continue
+		}
+		notifier, ok := d.notifiers.Get(channel)
+
+		if !ok {
+			deliveryLogger.Info("Invalid delivery method", slog.Int("method", int(ch)))
+			results[i].Status = ChannelFailed
+			results[i].Error = ErrUnsupportedChannel.Error()
+			continue
+		}
+
+		wg.Add(1)
+		go func(result *ChannelResult) {
+			defer wg.Done()
+			result.StartedAt = time.Now().UTC()
+			messageId, err := notifier.Notify(ctx, r)
+			result.FinishedAt = time.Now().UTC()
+
+			switch {
+			case errors.Is(err, ErrNoContact):
+				deliveryLogger.Info("Skipping delivery. reason='client has no contact for the channel.'", slog.Int("channel", int(result.Channel)))
+				result.Status = ChannelSkipped
+			case err != nil:
+				deliveryLogger.Info("failed to send notification", slog.Int("channel", int(result.Channel)), slog.String("error", err.Error()))
+				result.Status = ChannelFailed
+				result.Error = err.Error()
+				result.err = err
+			default:
+				result.Status = ChannelSent
+				result.MessageId = messageId
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,567 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/apigatewaymanagementapi"
+	"github.com/japb1998/control-tower/internal/database/memory"
+	"github.com/japb1998/control-tower/internal/model"
+	"github.com/japb1998/control-tower/internal/scheduler"
+	"github.com/japb1998/control-tower/internal/service"
+)
+
+type fakeNotifier struct {
+	channel   service.ContactOptions
+	messageId string
+	err       error
+
+	mu        sync.Mutex
+	reminders []service.Reminder
+}
+
+func (f *fakeNotifier) Channel() service.ContactOptions {
+	return f.channel
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, r service.Reminder) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reminders = append(f.reminders, r)
+	return f.messageId, f.err
+}
+
+type noopBroadcast struct{}
+
+func (noopBroadcast) PostToConnection(*apigatewaymanagementapi.PostToConnectionInput) (*apigatewaymanagementapi.PostToConnectionOutput, error) {
+	return &apigatewaymanagementapi.PostToConnectionOutput{}, nil
+}
+
+func (s *testServices) deliveryService(notifiers *service.NotifierRegistry) *service.DeliveryService {
+	connections := service.NewConnectionSvc(memory.NewConnectionRepo(), noopBroadcast{})
+	return service.NewDeliveryService(s.notifications, s.clients, connections, s.settings, notifiers)
+}
+
+// scheduleFor schedules a notification for the client and returns it as the scheduler delivers it.
+func (s *testServices) scheduleFor(t *testing.T, clientId string, methods ...service.ContactOptions) service.Notification {
+	deliveryMethods := make([]int8, 0, len(methods))
+	for _, m := range methods {
+		deliveryMethods = append(deliveryMethods, int8(m))
+	}
+	id, err := s.notifications.ScheduleNotification(creator, &service.NotificationInput{
+		Date:            time.Now().Add(time.Hour).Format(time.RFC3339),
+		ClientId:        clientId,
+		DeliveryMethods: deliveryMethods,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.notifications.GetNotification(creator, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *n
+}
+
+func TestDeliverNotifiers(t *testing.T) {
+	cases := []struct {
+		name     string
+		methods  []service.ContactOptions
+		errs     map[service.ContactOptions]error
+		disabled []int8
+		status   string
+		notified []service.ContactOptions
+	}{
+		{
+			name:     "sent",
+			methods:  []service.ContactOptions{service.Phone, service.Email},
+			status:   string(service.SentStatus),
+			notified: []service.ContactOptions{service.Phone, service.Email},
+		},
+		{
+			name:     "one channel fails",
+			methods:  []service.ContactOptions{service.Phone, service.Email},
+			errs:     map[service.ContactOptions]error{service.Email: errors.New("mailgun down")},
+			status:   string(service.FailedStatus),
+			notified: []service.ContactOptions{service.Phone, service.Email},
+		},
+		{
+			name:     "no contact is skipped",
+			methods:  []service.ContactOptions{service.Phone, service.Email},
+			errs:     map[service.ContactOptions]error{service.Email: service.ErrNoContact},
+			status:   string(service.SentStatus),
+			notified: []service.ContactOptions{service.Phone, service.Email},
+		},
+		{
+			name:     "disabled channel",
+			methods:  []service.ContactOptions{service.Phone, service.SMS},
+			disabled: []int8{int8(service.SMS)},
+			status:   string(service.SentStatus),
+			notified: []service.ContactOptions{service.Phone},
+		},
+		{
+			name:     "unsupported channel",
+			methods:  []service.ContactOptions{service.Phone, service.SMS},
+			status:   string(service.FailedStatus),
+			notified: []service.ContactOptions{service.Phone},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServices(t)
+			if tc.disabled != nil {
+				if err := s.settings.SaveSettings(model.SettingsItem{PrimaryKey: creator, DisabledChannels: tc.disabled}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			notifiers := map[service.ContactOptions]*fakeNotifier{
+				service.Phone: {channel: service.Phone, messageId: "SM1", err: tc.errs[service.Phone]},
+				service.Email: {channel: service.Email, messageId: "mg1", err: tc.errs[service.Email]},
+			}
+			d := s.deliveryService(service.NewNotifierRegistry(notifiers[service.Phone], notifiers[service.Email]))
+
+			c := s.createClient(t, time.Now().Add(-week))
+			event := s.scheduleFor(t, c.Id, tc.methods...)
+
+			if err := d.Deliver(context.Background(), event); err != nil {
+				t.Fatal(err)
+			}
+
+			n, err := s.notifications.GetNotification(creator, event.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n.Status != tc.status {
+				t.Errorf("expected status %s, got: %s", tc.status, n.Status)
+			}
+			if len(n.Deliveries) != len(tc.methods) {
+				t.Fatalf("expected one delivery per channel, got: %+v", n.Deliveries)
+			}
+			for _, delivery := range n.Deliveries {
+				if delivery.Attempt != 1 || delivery.StartedAt == "" || delivery.FinishedAt == "" {
+					t.Errorf("unexpected delivery %+v", delivery)
+				}
+				if delivery.Status == service.ChannelSent && delivery.MessageId != notifiers[delivery.Channel].messageId {
+					t.Errorf("expected the provider message id, got: %+v", delivery)
+				}
+				if delivery.Status == service.ChannelFailed && delivery.Error == "" {
+					t.Errorf("expected the failure reason, got: %+v", delivery)
+				}
+			}
+
+			for ch, f := range notifiers {
+				want := 0
+				for _, m := range tc.notified {
+					if m == ch {
+						want = 1
+					}
+				}
+				if len(f.reminders) != want {
+					t.Fatalf("channel %d: expected %d reminders, got: %d", ch, want, len(f.reminders))
+				}
+				if want == 1 && (f.reminders[0].Client.Id != c.Id || f.reminders[0].NotificationId != event.ID) {
+					t.Errorf("channel %d: unexpected reminder %+v", ch, f.reminders[0])
+				}
+			}
+		})
+	}
+}
+
+func TestDeliverOptedOutClient(t *testing.T) {
+	s := newTestServices(t)
+	phone := &fakeNotifier{channel: service.Phone}
+	d := s.deliveryService(service.NewNotifierRegistry(phone))
+
+	c := s.createClient(t, time.Now().Add(-week))
+	optIn := false
+	if _, err := s.clients.UpdateUser(context.Background(), creator, c.Id, service.PatchClient{OptIn: &optIn}); err != nil {
+		t.Fatal(err)
+	}
+	event := s.scheduleFor(t, c.Id, service.Phone)
+
+	if err := d.Deliver(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if len(phone.reminders) != 0 {
+		t.Fatalf("expected no reminders for an opted out client, got: %d", len(phone.reminders))
+	}
+}
+
+func TestDeliverAttempts(t *testing.T) {
+	s := newTestServices(t)
+	email := &fakeNotifier{channel: service.Email, err: errors.New("mailgun down")}
+	d := s.deliveryService(service.NewNotifierRegistry(&fakeNotifier{channel: service.Phone, messageId: "SM1"}, email))
+
+	c := s.createClient(t, time.Now().Add(-week))
+	event := s.scheduleFor(t, c.Id, service.Phone, service.Email)
+
+	// a replayed first send is skipped, the notification was already sent.
+	for i := 0; i < 2; i++ {
+		if err := d.Deliver(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	retry := event
+	retry.Attempt = 2
+	if err := d.Deliver(context.Background(), retry); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.notifications.GetNotification(creator, event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := make(map[service.ContactOptions][]int)
+	for _, delivery := range n.Deliveries {
+		attempts[delivery.Channel] = append(attempts[delivery.Channel], delivery.Attempt)
+	}
+	for _, ch := range []service.ContactOptions{service.Phone, service.Email} {
+		if len(attempts[ch]) != 2 || attempts[ch][0] != 1 || attempts[ch][1] != 2 {
+			t.Errorf("channel %d: expected attempts [1 2], got: %v", ch, attempts[ch])
+		}
+	}
+}
+
+var errTemporary = errors.New("temporary")
+
+func retryTemporary(err error) bool {
+	return errors.Is(err, errTemporary)
+}
+
+// retryPayload returns the notification scheduled by the retry of the attempt.
+func (s *testServices) retryPayload(t *testing.T, id string, attempt int) service.Notification {
+	sch, err := s.scheduler.GetSchedule(fmt.Sprintf("%s-retry-%d", id, attempt))
+	if err != nil {
+		t.Fatalf("expected retry %d to be scheduled, got: %v", attempt, err)
+	}
+	var n service.Notification
+	if err := json.Unmarshal([]byte(sch.Payload), &n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestDeliverRetriesFailedChannels(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	phone := &fakeNotifier{channel: service.Phone, messageId: "SM1"}
+	email := &fakeNotifier{channel: service.Email, err: errTemporary}
+	d := s.deliveryService(service.NewNotifierRegistry(phone, email).WithRetryPolicy(service.Email, service.NewRetryPolicy(retryTemporary)))
+
+	c := s.createClient(t, time.Now().Add(-week))
+	event := s.scheduleFor(t, c.Id, service.Phone, service.Email)
+
+	if err := d.Deliver(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.notifications.GetNotification(creator, event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Status != string(service.RetryingStatus) {
+		t.Fatalf("expected status %s, got: %s", service.RetryingStatus, n.Status)
+	}
+
+	retry := s.retryPayload(t, event.ID, 2)
+	if retry.Attempt != 2 || len(retry.DeliveryMethods) != 1 || retry.DeliveryMethods[0] != int8(service.Email) {
+		t.Fatalf("expected a retry of the email channel only, got: %+v", retry)
+	}
+
+	email.err = nil
+	if err := d.Deliver(ctx, retry); err != nil {
+		t.Fatal(err)
+	}
+	n, err = s.notifications.GetNotification(creator, event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Status != string(service.SentStatus) {
+		t.Errorf("expected status %s, got: %s", service.SentStatus, n.Status)
+	}
+	if len(phone.reminders) != 1 || len(email.reminders) != 2 {
+		t.Errorf("expected phone to be sent once and email twice, got: %d %d", len(phone.reminders), len(email.reminders))
+	}
+	if last := n.Deliveries[len(n.Deliveries)-1]; last.Channel != service.Email || last.Attempt != 2 || last.Status != service.ChannelSent {
+		t.Errorf("unexpected last delivery %+v", last)
+	}
+}
+
+func TestDeliverDoesNotRetry(t *testing.T) {
+	cases := []struct {
+		name    string
+		err     error
+		attempt int
+	}{
+		{name: "permanent error", err: errors.New("invalid recipient"), attempt: 1},
+		{name: "max attempts", err: errTemporary, attempt: 3},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServices(t)
+			d := s.deliveryService(service.NewNotifierRegistry(&fakeNotifier{channel: service.Email, err: tc.err}).WithRetryPolicy(service.Email, service.NewRetryPolicy(retryTemporary)))
+
+			c := s.createClient(t, time.Now().Add(-week))
+			event := s.scheduleFor(t, c.Id, service.Email)
+			event.Attempt = tc.attempt
+
+			if err := d.Deliver(context.Background(), event); err != nil {
+				t.Fatal(err)
+			}
+			n, err := s.notifications.GetNotification(creator, event.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n.Status != string(service.FailedStatus) {
+				t.Errorf("expected status %s, got: %s", service.FailedStatus, n.Status)
+			}
+			if _, err := s.scheduler.GetSchedule(fmt.Sprintf("%s-retry-%d", event.ID, tc.attempt+1)); !errors.Is(err, scheduler.ErrNotFound) {
+				t.Errorf("expected no retry schedule, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestRetryFailedChannels(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	d := s.deliveryService(service.NewNotifierRegistry(&fakeNotifier{channel: service.Phone}, &fakeNotifier{channel: service.Email, err: errors.New("invalid recipient")}))
+
+	c := s.createClient(t, time.Now().Add(-week))
+	event := s.scheduleFor(t, c.Id, service.Phone, service.Email)
+
+	if _, err := s.notifications.RetryFailedChannels(creator, event.ID); !errors.Is(err, service.ErrNothingToRetry) {
+		t.Fatalf("expected ErrNothingToRetry before the first send, got: %v", err)
+	}
+	if err := d.Deliver(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.notifications.RetryFailedChannels(creator, event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Status != string(service.RetryingStatus) {
+		t.Errorf("expected status %s, got: %s", service.RetryingStatus, n.Status)
+	}
+	retry := s.retryPayload(t, event.ID, 2)
+	if len(retry.DeliveryMethods) != 1 || retry.DeliveryMethods[0] != int8(service.Email) {
+		t.Fatalf("expected a retry of the email channel only, got: %+v", retry)
+	}
+	if _, err := s.notifications.RetryFailedChannels(creator, event.ID); !errors.Is(err, service.ErrRetryPending) {
+		t.Fatalf("expected ErrRetryPending, got: %v", err)
+	}
+
+	// deleting the notification cancels the pending retry.
+	if err := s.notifications.DeleteNotification(creator, event.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.scheduler.GetSchedule(fmt.Sprintf("%s-retry-2", event.ID)); !errors.Is(err, scheduler.ErrNotFound) {
+		t.Errorf("expected the retry schedule to be deleted, got: %v", err)
+	}
+}
+
+func TestScheduleRetryConflict(t *testing.T) {
+	s := newTestServices(t)
+	c := s.createClient(t, time.Now().Add(-week))
+	event := s.scheduleFor(t, c.Id, service.Email)
+	at := time.Now().Add(time.Hour)
+
+	// the same attempt is only scheduled once.
+	for i := 0; i < 2; i++ {
+		if name, err := s.notifications.ScheduleRetry(event, []int8{int8(service.Email)}, 2, at); err != nil || name != event.ID+"-retry-2" {
+			t.Fatalf("expected the retry schedule, got: '%s', %v", name, err)
+		}
+	}
+	// a new attempt has its own token.
+	if _, err := s.notifications.ScheduleRetry(event, []int8{int8(service.Email)}, 3, at); err != nil {
+		t.Fatal(err)
+	}
+
+	// a conflicting schedule that exists is the retry.
+	name := event.ID + "-retry-4"
+	if _, err := s.scheduler.CreateSchedule(scheduler.NewSchedule(name, "", "", "UTC", "{}", at), "other-token"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.notifications.ScheduleRetry(event, []int8{int8(service.Email)}, 4, at); err != nil || got != name {
+		t.Fatalf("expected the existing retry schedule, got: '%s', %v", got, err)
+	}
+}
+
+func TestSendNow(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	phone := &fakeNotifier{channel: service.Phone, messageId: "SM1"}
+	d := s.deliveryService(service.NewNotifierRegistry(phone))
+
+	c := s.createClient(t, time.Now().Add(-week))
+	event := s.scheduleFor(t, c.Id, service.Phone)
+
+	n, err := d.SendNow(ctx, creator, event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Status != string(service.SentStatus) || len(phone.reminders) != 1 {
+		t.Fatalf("expected the notification to be sent once, got: %s %d", n.Status, len(phone.reminders))
+	}
+	if _, err := s.scheduler.GetSchedule(event.ID); !errors.Is(err, scheduler.ErrNotFound) {
+		t.Errorf("expected the schedule to be cancelled, got: %v", err)
+	}
+
+	// sending again does not send the reminder twice.
+	if _, err := d.SendNow(ctx, creator, event.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(phone.reminders) != 1 {
+		t.Errorf("expected a single reminder, got: %d", len(phone.reminders))
+	}
+
+	if _, err := d.SendNow(ctx, creator, "missing"); !errors.Is(err, service.ErrNotificationNotFound) {
+		t.Errorf("expected ErrNotificationNotFound, got: %v", err)
+	}
+}
+
+func TestSendNowConcurrent(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	phone := &fakeNotifier{channel: service.Phone, messageId: "SM1"}
+	d := s.deliveryService(service.NewNotifierRegistry(phone))
+
+	c := s.createClient(t, time.Now().Add(-week))
+	event := s.scheduleFor(t, c.Id, service.Phone)
+
+	// send now clicked twice while the schedule fires.
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if i == 0 {
+				err = d.Deliver(ctx, event)
+			} else {
+				_, err = d.SendNow(ctx, creator, event.ID)
+			}
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	n, err := s.notifications.GetNotification(creator, event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Status != string(service.SentStatus) || len(phone.reminders) != 1 {
+		t.Fatalf("expected the notification to be sent once, got: %s %d", n.Status, len(phone.reminders))
+	}
+}
+
+// failingSettings fails every settings read.
+type failingSettings struct {
+	*memory.SettingsRepository
+}
+
+func (failingSettings) GetSettings(createdBy string) (*model.SettingsItem, error) {
+	return nil, errors.New("settings unavailable")
+}
+
+func TestSendNowRestoresFailedSend(t *testing.T) {
+	s := newTestServices(t)
+	phone := &fakeNotifier{channel: service.Phone, messageId: "SM1"}
+	connections := service.NewConnectionSvc(memory.NewConnectionRepo(), noopBroadcast{})
+	d := service.NewDeliveryService(s.notifications, s.clients, connections, failingSettings{s.settings}, service.NewNotifierRegistry(phone))
+
+	c := s.createClient(t, time.Now().Add(-week))
+	event := s.scheduleFor(t, c.Id, service.Phone)
+
+	if _, err := d.SendNow(context.Background(), creator, event.ID); err == nil {
+		t.Fatal("expected the send to fail")
+	}
+	n, err := s.notifications.GetNotification(creator, event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Status != string(service.NotSentStatus) || len(phone.reminders) != 0 {
+		t.Fatalf("expected the notification to stay pending, got: %s %d", n.Status, len(phone.reminders))
+	}
+	sch, err := s.scheduler.GetSchedule(event.ID)
+	if err != nil {
+		t.Fatalf("expected the notification to be scheduled again, got: %v", err)
+	}
+	if sch.Date.Before(time.Now().Add(time.Minute * 59)) {
+		t.Errorf("expected the schedule to keep the notification date, got: %s", sch.Date)
+	}
+}
+
+func TestSendNowOptedOutClient(t *testing.T) {
+	s := newTestServices(t)
+	d := s.deliveryService(service.NewNotifierRegistry(&fakeNotifier{channel: service.Phone}))
+
+	c := s.createClient(t, time.Now().Add(-week))
+	event := s.scheduleFor(t, c.Id, service.Phone)
+	optIn := false
+	if _, err := s.clients.UpdateUser(context.Background(), creator, c.Id, service.PatchClient{OptIn: &optIn}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.SendNow(context.Background(), creator, event.ID); !errors.Is(err, service.ErrClientOptedOut) {
+		t.Fatalf("expected ErrClientOptedOut, got: %v", err)
+	}
+	if _, err := s.scheduler.GetSchedule(event.ID); err != nil {
+		t.Errorf("expected the schedule to be kept, got: %v", err)
+	}
+}
+
+func TestResend(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	phone := &fakeNotifier{channel: service.Phone}
+	email := &fakeNotifier{channel: service.Email, err: errTemporary}
+	d := s.deliveryService(service.NewNotifierRegistry(phone, email).WithRetryPolicy(service.Email, service.NewRetryPolicy(retryTemporary)))
+
+	c := s.createClient(t, time.Now().Add(-week))
+	event := s.scheduleFor(t, c.Id, service.Phone, service.Email)
+
+	// nothing failed before the first send.
+	if _, err := d.Resend(ctx, creator, event.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(phone.reminders)+len(email.reminders) != 0 {
+		t.Fatal("expected resend to do nothing before the first send")
+	}
+
+	if err := d.Deliver(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	email.err = nil
+
+	n, err := d.Resend(ctx, creator, event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Status != string(service.SentStatus) {
+		t.Errorf("expected status %s, got: %s", service.SentStatus, n.Status)
+	}
+	if len(phone.reminders) != 1 || len(email.reminders) != 2 {
+		t.Errorf("expected only the failed channel to be resent, got: %d %d", len(phone.reminders), len(email.reminders))
+	}
+	if _, err := s.scheduler.GetSchedule(fmt.Sprintf("%s-retry-2", event.ID)); !errors.Is(err, scheduler.ErrNotFound) {
+		t.Errorf("expected the pending retry to be cancelled, got: %v", err)
+	}
+
+	// resending a sent notification does nothing.
+	if _, err := d.Resend(ctx, creator, event.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(email.reminders) != 2 {
+		t.Errorf("expected no more reminders, got: %d", len(email.reminders))
+	}
+}
@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/japb1998/control-tower/internal/model"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	DuplicatePhone = "phone"
+	DuplicateEmail = "email"
+	DuplicateName  = "name"
+
+	// names at least this long match when they are one edit away, shorter ones must be equal.
+	fuzzyNameMinLength = 8
+)
+
+var (
+	ErrInvalidMerge = errors.New("invalid merge")
+)
+
+// DuplicateGroup are clients that are likely the same person. Reasons has the fields that matched.
+type DuplicateGroup struct {
+	Reasons []string    `json:"reasons"`
+	Clients []ClientDto `json:"clients"`
+}
+
+type MergeClients struct {
+	DuplicateIds []string `json:"duplicateIds" binding:"required,min=1,dive,required"`
+}
+
+// MergeError is a merge that stopped while the duplicates were deleted. the Merged duplicates were deleted after their notifications moved
+// to the client, the merge can be retried with the Remaining ones.
+type MergeError struct {
+	Merged    []string `json:"merged"`
+	Remaining []string `json:"remaining"`
+	Err       error    `json:"-"`
+}
+
+func (e *MergeError) Error() string {
+	return fmt.Sprintf("merge stopped after %d of %d duplicates error: %s", len(e.Merged), len(e.Merged)+len(e.Remaining), e.Err)
+}
+
+func (e *MergeError) Unwrap() error {
+	return e.Err
+}
+
+// FindDuplicates groups the clients of the creator that share a phone, an email or a similar name.
+func (c *ClientService) FindDuplicates(ctx context.Context, createdBy string) ([]DuplicateGroup, error) {
+	clients, err := c.Store.GetClientsByCreator(createdBy)
+
+	if err != nil {
+		clientLogger.Error("error getting clients", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error getting clients")
+	}
+
+	parent := make([]int, len(clients))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	reasons := make(map[int]map[string]bool)
+	union := func(a, b int, reason string) {
+		ra, rb := find(a), find(b)
+		if ra != rb {
+			parent[rb] = ra
+			for r := range reasons[rb] {
+				addReason(reasons, ra, r)
+			}
+			delete(reasons, rb)
+		}
+		addReason(reasons, ra, reason)
+	}
+
+	// exact matches on phone and email.
+	first := make(map[string]int)
+	names := make([]string, len(clients))
+	for i, cl := range clients {
+		keys := dedupeKeys(cl.Phone, cl.Email)
+		for _, k := range keys {
+			if j, ok := first[k]; ok {
+				reason := DuplicatePhone
+				if strings.HasPrefix(k, "email#") {
+					reason = DuplicateEmail
+				}
+				union(j, i, reason)
+				continue
+			}
+			first[k] = i
+		}
+		names[i] = normalizeName(cl.FirstName + " " + cl.LastName)
+	}
+
+	// fuzzy matches on the full name.
+	for i := range clients {
+		for j := i + 1; j < len(clients); j++ {
+			if similarNames(names[i], names[j]) {
+				union(i, j, DuplicateName)
+			}
+		}
+	}
+
+	members := make(map[int][]ClientDto)
+	for i, cl := range clients {
+		r := find(i)
+		members[r] = append(members[r], *NewClientFromItem(cl))
+	}
+
+	groups := make([]DuplicateGroup, 0)
+	for r, m := range members {
+		if len(m) < 2 {
+			continue
+		}
+		g := DuplicateGroup{Clients: m, Reasons: make([]string, 0, len(reasons[r]))}
+		for reason := range reasons[r] {
+			g.Reasons = append(g.Reasons, reason)
+		}
+		sort.Strings(g.Reasons)
+		groups = append(groups, g)
+	}
+	// groups are sorted by their first client id so the result is stable.
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Clients[0].Id < groups[j].Clients[0].Id
+	})
+	return groups, nil
+}
+
+// MergeClients merges the duplicates into the client. empty fields are filled from the duplicates, lastSeen is the most recent one
+// and the client keeps opt in only if every duplicate did. the notifications of the duplicates are moved to the client before they are deleted.
+// every step can run again, a failure before the duplicates are deleted leaves them in place and a failure while they are deleted
+// returns a MergeError with the duplicates left.
+func (c *ClientService) MergeClients(ctx context.Context, createdBy, clientId string, merge MergeClients) (ClientDto, error) {
+	if c.notifications == nil {
+		return ClientDto{}, fmt.Errorf("notifications are required to merge clients")
+	}
+	survivor, err := c.Store.GetClientById(createdBy, clientId)
+
+	if err != nil {
+		clientLogger.Error(err.Error())
+		return ClientDto{}, fmt.Errorf("error getting client")
+	}
+	if survivor == nil {
+		return ClientDto{}, ErrClientNotFound
+	}
+
+	duplicates := make([]model.ClientItem, 0, len(merge.DuplicateIds))
+	seen := make(map[string]bool, len(merge.DuplicateIds))
+	for _, id := range merge.DuplicateIds {
+		if id == clientId || seen[id] {
+			return ClientDto{}, fmt.Errorf("client id='%s' can't be merged twice: %w", id, ErrInvalidMerge)
+		}
+		seen[id] = true
+
+		d, err := c.Store.GetClientById(createdBy, id)
+
+		if err != nil {
+			clientLogger.Error(err.Error())
+			return ClientDto{}, fmt.Errorf("error getting client")
+		}
+		if d == nil {
+			return ClientDto{}, fmt.Errorf("duplicate id='%s': %w", id, ErrClientNotFound)
+		}
+		duplicates = append(duplicates, *d)
+	}
+
+	patch := mergePatch(*survivor, duplicates)
+
+	for _, d := range duplicates {
+		if err := c.notifications.ReassignClient(ctx, createdBy, d.SortKey, clientId); err != nil {
+			clientLogger.Error("error reassigning notifications", slog.String("clientId", d.SortKey), slog.String("error", err.Error()))
+			return ClientDto{}, fmt.Errorf("error moving notifications of client id='%s'", d.SortKey)
+		}
+	}
+
+	// updating after the notifications are moved lets a newer lastSeen replace every pending reminder with a single one.
+	dto, err := c.UpdateUser(ctx, createdBy, clientId, patch)
+
+	if err != nil {
+		return ClientDto{}, err
+	}
+
+	for i, d := range duplicates {
+		// notifications scheduled for the duplicate since they were moved would point to a deleted client.
+		err := c.notifications.ReassignClient(ctx, createdBy, d.SortKey, clientId)
+
+		if err == nil {
+			err = c.Store.DeleteClient(createdBy, d.SortKey)
+		}
+		if err != nil {
+			clientLogger.Error("error deleting duplicate", slog.String("clientId", d.SortKey), slog.String("error", err.Error()))
+			mergeErr := &MergeError{Merged: make([]string, 0, i), Remaining: make([]string, 0, len(duplicates)-i), Err: err}
+
+			for j, d := range duplicates {
+				if j < i {
+					mergeErr.Merged = append(mergeErr.Merged, d.SortKey)
+				} else {
+					mergeErr.Remaining = append(mergeErr.Remaining, d.SortKey)
+				}
+			}
+			return ClientDto{}, mergeErr
+		}
+	}
+	clientLogger.Info("clients merged", slog.String("clientId", clientId), slog.Any("duplicates", merge.DuplicateIds))
+	return dto, nil
+}
+
+// mergePatch returns the patch that combines the duplicates into the client.
+func mergePatch(client model.ClientItem, duplicates []model.ClientItem) PatchClient {
+	patch := PatchClient{}
+	optIn := client.OptIn
+	lastSeen := client.LastSeen
+	methods := make(map[int8]bool)
+	for _, m := range client.DeliveryMethods {
+		methods[m] = true
+	}
+
+	fill := func(current string, dst *string, value string) {
+		if current == "" && *dst == "" && value != "" {
+			*dst = value
+		}
+	}
+	for _, d := range duplicates {
+		fill(client.Phone, &patch.Phone, d.Phone)
+		fill(client.Email, &patch.Email, d.Email)
+		fill(client.FirstName, &patch.FirstName, d.FirstName)
+		fill(client.LastName, &patch.LastName, d.LastName)
+		fill(client.Description, &patch.Description, d.Description)
+
+		optIn = optIn && d.OptIn
+		if d.LastSeen != nil && (lastSeen == nil || d.LastSeen.After(*lastSeen)) {
+			lastSeen = d.LastSeen
+		}
+		for _, m := range d.DeliveryMethods {
+			methods[m] = true
+		}
+	}
+
+	patch.OptIn = &optIn
+	if lastSeen != nil && (client.LastSeen == nil || lastSeen.After(*client.LastSeen)) {
+		ls := lastSeen.Format(time.RFC3339)
+		patch.LastSeen = &ls
+	}
+	if len(methods) > len(client.DeliveryMethods) {
+		patch.DeliveryMethods = make([]int8, 0, len(methods))
+		for m := range methods {
+			patch.DeliveryMethods = append(patch.DeliveryMethods, m)
+		}
+		sort.Slice(patch.DeliveryMethods, func(i, j int) bool { return patch.DeliveryMethods[i] < patch.DeliveryMethods[j] })
+	}
+	return patch
+}
+
+// normalizeName lower cases the name and drops accents, punctuation and extra spaces.
+func normalizeName(name string) string {
+	var b strings.Builder
+	space := false
+
+	for _, r := range norm.NFD.String(name) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case unicode.IsLetter(r):
+			if space && b.Len() > 0 {
+				b.WriteRune(' ')
+			}
+			space = false
+			b.WriteRune(unicode.ToLower(r))
+		case unicode.IsSpace(r):
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// similarNames matches equal names and long names that are one edit away.
+func similarNames(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	if a == b {
+		return true
+	}
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) < fuzzyNameMinLength || len(rb) < fuzzyNameMinLength || len(ra)-len(rb) > 1 || len(rb)-len(ra) > 1 {
+		return false
+	}
+	return oneEdit(ra, rb)
+}
+
+// oneEdit reports whether a can be turned into b with at most one insertion, deletion or substitution.
+func oneEdit(a, b []rune) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	i, j, edits := 0, 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i] == b[j] {
+			i++
+			j++
+			continue
+		}
+		edits++
+		if edits > 1 {
+			return false
+		}
+		if len(a) == len(b) {
+			i++
+		}
+		j++
+	}
+	return edits+(len(b)-j)+(len(a)-i) <= 1
+}
+
+func addReason(reasons map[int]map[string]bool, root int, reason string) {
+	if reasons[root] == nil {
+		reasons[root] = make(map[string]bool)
+	}
+	reasons[root][reason] = true
+}
@@ -0,0 +1,219 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/database/memory"
+	"github.com/japb1998/control-tower/internal/service"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	lastSeen := time.Now().UTC().Format(time.RFC3339)
+
+	clients := []service.CreateClient{
+		{FirstName: "Ana", LastName: "Perez", Phone: "+17860000001"},
+		{FirstName: "Ana", LastName: "P", Email: "ana@test.com"},
+		{FirstName: "Anita", LastName: "Perez", Phone: "+17860000001", Email: "ANITA@test.com"},
+		{FirstName: "Maria José", LastName: "Gómez"},
+		{FirstName: "maria jose", LastName: "gomes"},
+		{FirstName: "Bea", LastName: "Lopez", Email: "ana@test.com"},
+		{FirstName: "Carla", LastName: "Diaz"},
+	}
+	ids := make([]string, len(clients))
+	for i, c := range clients {
+		c.LastSeen = &lastSeen
+		dto, err := s.clients.CreateClient(ctx, creator, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = dto.Id
+	}
+
+	groups, err := s.clients.FindDuplicates(ctx, creator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 3 {
+		t.Fatalf("expected 3 groups, got: %+v", groups)
+	}
+
+	byClient := make(map[string]service.DuplicateGroup)
+	for _, g := range groups {
+		for _, c := range g.Clients {
+			byClient[c.Id] = g
+		}
+	}
+	expected := []struct {
+		a, b   int
+		reason string
+	}{
+		{0, 2, service.DuplicatePhone},
+		{1, 5, service.DuplicateEmail},
+		{3, 4, service.DuplicateName},
+	}
+	for _, e := range expected {
+		g := byClient[ids[e.a]]
+		if len(g.Clients) != 2 || byClient[ids[e.b]].Clients[0].Id != g.Clients[0].Id {
+			t.Fatalf("expected clients %d and %d in the same group, got: %+v", e.a, e.b, g)
+		}
+		if len(g.Reasons) != 1 || g.Reasons[0] != e.reason {
+			t.Fatalf("expected clients %d and %d to match on %s, got: %v", e.a, e.b, e.reason, g.Reasons)
+		}
+	}
+	if _, ok := byClient[ids[6]]; ok {
+		t.Fatalf("expected Carla to have no duplicates")
+	}
+}
+
+func TestMergeClients(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	survivor := s.createClient(t, now.Add(-week))
+	newer := now.Format(time.RFC3339)
+	duplicate, err := s.clients.CreateClient(ctx, creator, service.CreateClient{FirstName: "Ana", LastName: "Perez", Email: "ana@test.com", Description: "prefers mornings", LastSeen: &newer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	optIn := false
+	if _, err := s.clients.UpdateUser(ctx, creator, duplicate.Id, service.PatchClient{OptIn: &optIn}); err != nil {
+		t.Fatal(err)
+	}
+	id, err := s.notifications.ScheduleNotification(creator, &service.NotificationInput{
+		Date:            now.Add(time.Hour).Format(time.RFC3339),
+		ClientId:        duplicate.Id,
+		DeliveryMethods: []int8{int8(service.Email)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.clients.MergeClients(ctx, creator, survivor.Id, service.MergeClients{DuplicateIds: []string{survivor.Id}}); !errors.Is(err, service.ErrInvalidMerge) {
+		t.Fatalf("expected ErrInvalidMerge, got: %v", err)
+	}
+	if _, err := s.clients.MergeClients(ctx, creator, survivor.Id, service.MergeClients{DuplicateIds: []string{"missing"}}); !errors.Is(err, service.ErrClientNotFound) {
+		t.Fatalf("expected ErrClientNotFound, got: %v", err)
+	}
+
+	merged, err := s.clients.MergeClients(ctx, creator, survivor.Id, service.MergeClients{DuplicateIds: []string{duplicate.Id}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Phone != survivor.Phone || merged.Email != "ana@test.com" || merged.Description != "prefers mornings" {
+		t.Fatalf("expected fields to be combined, got: %+v", merged)
+	}
+	if *merged.OptIn || *merged.LastSeen != newer {
+		t.Fatalf("expected opt out and the newest lastSeen, got: optIn=%v lastSeen=%s", *merged.OptIn, *merged.LastSeen)
+	}
+	if _, err := s.clients.GetClientById(ctx, creator, duplicate.Id); !errors.Is(err, service.ErrClientNotFound) {
+		t.Fatalf("expected duplicate to be deleted, got: %v", err)
+	}
+
+	n, err := s.notifications.GetNotification(creator, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.ClientId != survivor.Id {
+		t.Fatalf("expected notification to point to %s, got: %s", survivor.Id, n.ClientId)
+	}
+	sch, err := s.scheduler.GetSchedule(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload service.Notification
+	if err := json.Unmarshal([]byte(sch.Payload), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.ClientId != survivor.Id {
+		t.Fatalf("expected schedule payload to point to %s, got: %s", survivor.Id, payload.ClientId)
+	}
+}
+
+func TestMergeClientsReassignsRetries(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	email := &fakeNotifier{channel: service.Email, err: errTemporary}
+	d := s.deliveryService(service.NewNotifierRegistry(&fakeNotifier{channel: service.Phone, messageId: "SM1"}, email).WithRetryPolicy(service.Email, service.NewRetryPolicy(retryTemporary)))
+
+	survivor := s.createClient(t, time.Now().Add(-week))
+	ls := time.Now().Format(time.RFC3339)
+	duplicate, err := s.clients.CreateClient(ctx, creator, service.CreateClient{FirstName: "Ana", LastName: "Perez", Phone: "+17860000002", Email: "ana@test.com", LastSeen: &ls})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := s.scheduleFor(t, duplicate.Id, service.Phone, service.Email)
+	if err := d.Deliver(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	if retry := s.retryPayload(t, event.ID, 2); retry.ClientId != duplicate.Id {
+		t.Fatalf("expected the retry of the duplicate, got: %+v", retry)
+	}
+
+	if _, err := s.clients.MergeClients(ctx, creator, survivor.Id, service.MergeClients{DuplicateIds: []string{duplicate.Id}}); err != nil {
+		t.Fatal(err)
+	}
+	if retry := s.retryPayload(t, event.ID, 2); retry.ClientId != survivor.Id {
+		t.Fatalf("expected the retry payload to point to %s, got: %s", survivor.Id, retry.ClientId)
+	}
+}
+
+// failingDelete fails to delete the client with id.
+type failingDelete struct {
+	*memory.ClientRepository
+	id string
+}
+
+func (f *failingDelete) DeleteClient(createdBy, id string) error {
+	if id == f.id {
+		return errors.New("delete failed")
+	}
+	return f.ClientRepository.DeleteClient(createdBy, id)
+}
+
+func TestMergeClientsResume(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	survivor := s.createClient(t, now.Add(-week))
+	first := s.createClient(t, now.Add(-week))
+	second := s.createClient(t, now.Add(-week))
+	store := &failingDelete{ClientRepository: s.clientStore, id: second.Id}
+	clients := service.NewClientSvc(store).WithNotifications(s.notifications)
+
+	id, err := s.notifications.ScheduleNotification(creator, &service.NotificationInput{
+		Date:            now.Add(time.Hour).Format(time.RFC3339),
+		ClientId:        second.Id,
+		DeliveryMethods: []int8{int8(service.SMS)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = clients.MergeClients(ctx, creator, survivor.Id, service.MergeClients{DuplicateIds: []string{first.Id, second.Id}})
+	var mergeErr *service.MergeError
+	if !errors.As(err, &mergeErr) || len(mergeErr.Merged) != 1 || mergeErr.Merged[0] != first.Id || len(mergeErr.Remaining) != 1 || mergeErr.Remaining[0] != second.Id {
+		t.Fatalf("expected the partial merge to be reported, got: %v", err)
+	}
+	if n, err := s.notifications.GetNotification(creator, id); err != nil || n.ClientId != survivor.Id {
+		t.Fatalf("expected the notification to be moved, got: %+v, %v", n, err)
+	}
+
+	// the merge is retried with the remaining duplicates.
+	store.id = ""
+	if _, err := clients.MergeClients(ctx, creator, survivor.Id, service.MergeClients{DuplicateIds: mergeErr.Remaining}); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{first.Id, second.Id} {
+		if _, err := clients.GetClientById(ctx, creator, d); !errors.Is(err, service.ErrClientNotFound) {
+			t.Fatalf("expected duplicate '%s' to be deleted, got: %v", d, err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+const NDJSONFormat = "ndjson"
+
+var (
+	ErrUnsupportedExport = errors.New("unsupported export format")
+)
+
+// exportColumns are the csv columns of the export, the names match the import headers so exports can be imported back.
+var exportColumns = []string{"id", "firstName", "lastName", "phone", "email", "description", "optIn", "lastSeen", "createdAt", "lastUpdateAt", "language"}
+
+// formulaPrefixes are the first characters that make spreadsheets read a cell as a formula.
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeFormula prefixes cells that would be read as a formula with a quote, the import removes it.
+func escapeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune(formulaPrefixes, rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// unescapeFormula removes the quote added by escapeFormula.
+func unescapeFormula(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
+
+// ExportClients writes every client matching the filters to w as CSV or NDJSON. clients are written while the store is read.
+func (c *ClientService) ExportClients(ctx context.Context, createdBy string, filters PatchClient, format string, w io.Writer) error {
+	if format != CSVFormat && format != NDJSONFormat {
+		return fmt.Errorf("format='%s': %w", format, ErrUnsupportedExport)
+	}
+	f, err := clientFilters(filters)
+
+	if err != nil {
+		return err
+	}
+	var write func(ClientDto) error
+	var flush func() error
+
+	switch format {
+	case CSVFormat:
+		cw := csv.NewWriter(w)
+
+		if err := cw.Write(exportColumns); err != nil {
+			return err
+		}
+		write = func(dto ClientDto) error {
+			lastSeen := ""
+			if dto.LastSeen != nil {
+				lastSeen = *dto.LastSeen
+			}
+			record := []string{dto.Id, dto.FirstName, dto.LastName, dto.Phone, dto.Email, dto.Description,
+				strconv.FormatBool(dto.OptIn != nil && *dto.OptIn), lastSeen, dto.CreatedAt, dto.LastUpdateAt, dto.Language}
+
+			for i := range record {
+				record[i] = escapeFormula(record[i])
+			}
+			return cw.Write(record)
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case NDJSONFormat:
+		enc := json.NewEncoder(w)
+		write = func(dto ClientDto) error {
+			return enc.Encode(dto)
+		}
+		flush = func() error { return nil }
+	}
+
+	count := 0
+	err = c.Store.WalkClientsWithFilters(createdBy, f, func(ci model.ClientItem) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		count++
+		return write(*NewClientFromItem(ci))
+	})
+
+	if err != nil {
+		clientLogger.Error("error exporting clients", slog.String("createdBy", createdBy), slog.String("error", err.Error()))
+		return fmt.Errorf("error exporting clients error: %w", err)
+	}
+	if err := flush(); err != nil {
+		return fmt.Errorf("error exporting clients error: %w", err)
+	}
+	clientLogger.Info("clients exported", slog.String("createdBy", createdBy), slog.String("format", format), slog.Int("count", count))
+	return nil
+}
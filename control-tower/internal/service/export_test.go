@@ -0,0 +1,106 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/service"
+)
+
+func TestExportClients(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	ana := s.createClient(t, time.Now().Add(-week))
+
+	lastSeen := time.Now().UTC().Format(time.RFC3339)
+	if _, err := s.clients.CreateClient(ctx, creator, service.CreateClient{FirstName: "Bea", LastName: "Lopez", Email: "bea@test.com", LastSeen: &lastSeen}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := s.clients.ExportClients(ctx, creator, service.PatchClient{FirstName: "Ana"}, service.CSVFormat, &buf); err != nil {
+			t.Fatal(err)
+		}
+		records, err := csv.NewReader(&buf).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 2 {
+			t.Fatalf("expected header and one client, got: %v", records)
+		}
+		if records[1][0] != ana.Id || records[1][6] != "true" || records[1][7] != *ana.LastSeen {
+			t.Fatalf("unexpected client row: %v", records[1])
+		}
+
+		// exports can be imported back.
+		rows, err := service.ParseImport(service.CSVFormat, strings.NewReader(strings.Join(records[0], ",")+"\n"+strings.Join(records[1], ",")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 || rows[0].Client.Phone != ana.Phone || *rows[0].Client.LastSeen != *ana.LastSeen {
+			t.Fatalf("unexpected import rows: %+v", rows)
+		}
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := s.clients.ExportClients(ctx, creator, service.PatchClient{}, service.NDJSONFormat, &buf); err != nil {
+			t.Fatal(err)
+		}
+		dec := json.NewDecoder(&buf)
+		count := 0
+		for dec.More() {
+			var dto service.ClientDto
+			if err := dec.Decode(&dto); err != nil {
+				t.Fatal(err)
+			}
+			if dto.OptIn == nil || dto.LastSeen == nil {
+				t.Fatalf("expected optIn and lastSeen to be exported, got: %+v", dto)
+			}
+			count++
+		}
+		if count != 2 {
+			t.Fatalf("expected 2 clients, got: %d", count)
+		}
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := s.clients.ExportClients(ctx, creator, service.PatchClient{}, "xlsx", &buf); !errors.Is(err, service.ErrUnsupportedExport) || buf.Len() != 0 {
+			t.Fatalf("expected ErrUnsupportedExport without output, got: %v, %q", err, buf.String())
+		}
+	})
+
+	t.Run("csv formulas", func(t *testing.T) {
+		formula := `=HYPERLINK("http://evil.test","click")`
+		if _, err := s.clients.CreateClient(ctx, creator, service.CreateClient{FirstName: "Cleo", LastName: formula, Phone: "+17860000003", LastSeen: &lastSeen}); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := s.clients.ExportClients(ctx, creator, service.PatchClient{FirstName: "Cleo"}, service.CSVFormat, &buf); err != nil {
+			t.Fatal(err)
+		}
+		records, err := csv.NewReader(bytes.NewReader(buf.Bytes())).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 2 || records[1][2] != "'"+formula || records[1][3] != "'+17860000003" || records[1][1] != "Cleo" {
+			t.Fatalf("expected formula cells to be escaped, got: %v", records)
+		}
+
+		rows, err := service.ParseImport(service.CSVFormat, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 || rows[0].Client.LastName != formula || rows[0].Client.Phone != "+17860000003" {
+			t.Fatalf("expected the import to remove the escaping, got: %+v", rows)
+		}
+	})
+}
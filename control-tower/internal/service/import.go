@@ -0,0 +1,384 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+const (
+	CSVFormat    = "csv"
+	BooksyFormat = "booksy"
+
+	// phones without country code are considered US numbers.
+	defaultCountryCode = "1"
+)
+
+const (
+	ImportCreated = "created"
+	ImportSkipped = "skipped"
+	ImportFailed  = "failed"
+)
+
+var (
+	ErrInvalidImport = errors.New("invalid import file")
+)
+
+// ImportRow is a client read from an import file. Row is the 1 based position of the client in the file.
+type ImportRow struct {
+	Row    int
+	Client CreateClient
+}
+
+type ImportRowResult struct {
+	Row      int    `json:"row"`
+	Status   string `json:"status"`
+	ClientId string `json:"clientId,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+type ImportReport struct {
+	Created int               `json:"created"`
+	Skipped int               `json:"skipped"`
+	Failed  int               `json:"failed"`
+	Rows    []ImportRowResult `json:"rows"`
+}
+
+func (r *ImportReport) add(result ImportRowResult) {
+	switch result.Status {
+	case ImportCreated:
+		r.Created++
+	case ImportSkipped:
+		r.Skipped++
+	case ImportFailed:
+		r.Failed++
+	}
+	r.Rows = append(r.Rows, result)
+}
+
+// importValidator checks the rows with the same rules the api uses for CreateClient.
+var importValidator = func() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	v.RegisterValidation("rfc3339", func(fl validator.FieldLevel) bool {
+		if fl.Field().String() == "" {
+			return true
+		}
+		_, err := time.Parse(time.RFC3339, fl.Field().String())
+		return err == nil
+	})
+	return v
+}()
+
+// ParseImport reads the clients of a CSV or Booksy JSON export.
+func ParseImport(format string, r io.Reader) ([]ImportRow, error) {
+	switch format {
+	case CSVFormat:
+		return parseCSV(r)
+	case BooksyFormat:
+		return parseBooksy(r)
+	}
+	return nil, fmt.Errorf("format='%s' not supported: %w", format, ErrInvalidImport)
+}
+
+// csvColumns maps the accepted headers to the client fields. headers are compared lower case without spaces, dashes or underscores.
+var csvColumns = map[string]string{
+	"firstname":   "firstName",
+	"name":        "firstName",
+	"lastname":    "lastName",
+	"phone":       "phone",
+	"cellphone":   "phone",
+	"mobile":      "phone",
+	"email":       "email",
+	"description": "description",
+	"notes":       "description",
+	"lastseen":    "lastSeen",
+	"lastvisit":   "lastSeen",
+	"language":    "language",
+	"idioma":      "language",
+}
+
+func parseCSV(r io.Reader) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read csv header error='%s': %w", err, ErrInvalidImport)
+	}
+	columns := make(map[string]int)
+
+	for i, h := range header {
+		key := strings.Map(func(r rune) rune {
+			if r == ' ' || r == '_' || r == '-' || r == '\uFEFF' {
+				return -1
+			}
+			return unicode.ToLower(r)
+		}, h)
+
+		if field, ok := csvColumns[key]; ok {
+			columns[field] = i
+		}
+	}
+	if _, ok := columns["firstName"]; !ok {
+		return nil, fmt.Errorf("csv header must have a firstName column: %w", ErrInvalidImport)
+	}
+
+	rows := make([]ImportRow, 0)
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read csv row=%d error='%s': %w", n, err, ErrInvalidImport)
+		}
+		get := func(field string) string {
+			if i, ok := columns[field]; ok && i < len(record) {
+				return strings.TrimSpace(unescapeFormula(record[i]))
+			}
+			return ""
+		}
+		rows = append(rows, ImportRow{
+			Row: n,
+			Client: CreateClient{
+				FirstName:   get("firstName"),
+				LastName:    get("lastName"),
+				Phone:       get("phone"),
+				Email:       get("email"),
+				Description: get("description"),
+				LastSeen:    optionalString(normalizeDate(get("lastSeen"))),
+				Language:    NormalizeLanguage(get("language")),
+			},
+		})
+	}
+	return rows, nil
+}
+
+// booksyCustomer is a customer of the Booksy customers.json export.
+type booksyCustomer struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	FullName  string `json:"full_name"`
+	CellPhone string `json:"cell_phone"`
+	Phone     string `json:"phone"`
+	Email     string `json:"email"`
+	Notes     string `json:"notes"`
+	LastVisit string `json:"last_visit"`
+}
+
+func parseBooksy(r io.Reader) ([]ImportRow, error) {
+	b, err := io.ReadAll(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file error='%s': %w", err, ErrInvalidImport)
+	}
+	var customers []booksyCustomer
+
+	// exports are either a list of customers or an object with the customers list.
+	if err := json.Unmarshal(b, &customers); err != nil {
+		var export struct {
+			Customers []booksyCustomer `json:"customers"`
+		}
+		if err := json.Unmarshal(b, &export); err != nil {
+			return nil, fmt.Errorf("unable to parse booksy export error='%s': %w", err, ErrInvalidImport)
+		}
+		customers = export.Customers
+	}
+
+	rows := make([]ImportRow, 0, len(customers))
+	for i, c := range customers {
+		firstName, lastName := strings.TrimSpace(c.FirstName), strings.TrimSpace(c.LastName)
+
+		if firstName == "" && lastName == "" {
+			firstName, lastName, _ = strings.Cut(strings.TrimSpace(c.FullName), " ")
+		}
+		phone := c.CellPhone
+		if phone == "" {
+			phone = c.Phone
+		}
+		rows = append(rows, ImportRow{
+			Row: i + 1,
+			Client: CreateClient{
+				FirstName:   firstName,
+				LastName:    strings.TrimSpace(lastName),
+				Phone:       strings.TrimSpace(phone),
+				Email:       strings.TrimSpace(c.Email),
+				Description: strings.TrimSpace(c.Notes),
+				LastSeen:    optionalString(normalizeDate(c.LastVisit)),
+			},
+		})
+	}
+	return rows, nil
+}
+
+// ImportClients creates the valid rows that are not already clients of the creator. the report has the result of every row.
+func (c *ClientService) ImportClients(ctx context.Context, createdBy string, rows []ImportRow) (ImportReport, error) {
+	existing, err := c.Store.GetClientsByCreator(createdBy)
+
+	if err != nil {
+		clientLogger.Error("error getting clients", slog.String("error", err.Error()))
+		return ImportReport{}, fmt.Errorf("error getting existing clients")
+	}
+	seen := make(map[string]string)
+	for _, e := range existing {
+		for _, k := range dedupeKeys(e.Phone, e.Email) {
+			seen[k] = e.SortKey
+		}
+	}
+
+	report := ImportReport{Rows: make([]ImportRowResult, 0, len(rows))}
+	items := make([]model.ClientItem, 0, len(rows))
+	itemRows := make([]int, 0, len(rows))
+
+	for _, r := range rows {
+		client := r.Client
+		client.Email = strings.ToLower(client.Email)
+		client.Phone = NormalizePhone(client.Phone)
+
+		if err := importValidator.Struct(client); err != nil {
+			report.add(ImportRowResult{Row: r.Row, Status: ImportFailed, Reason: validationReason(err)})
+			continue
+		}
+		lastSeen, err := time.Parse(time.RFC3339, *client.LastSeen)
+
+		if err != nil {
+			report.add(ImportRowResult{Row: r.Row, Status: ImportFailed, Reason: ErrInvalidDateString.Error()})
+			continue
+		}
+
+		if id, ok := duplicateOf(seen, client.Phone, client.Email); ok {
+			report.add(ImportRowResult{Row: r.Row, Status: ImportSkipped, ClientId: id, Reason: "duplicate client"})
+			continue
+		}
+		item := model.NewClientItem(createdBy, client.Phone, client.Email, client.FirstName, client.LastName, client.Description, &lastSeen, client.DeliveryMethods)
+		item.Language = client.Language
+
+		for _, k := range dedupeKeys(item.Phone, item.Email) {
+			seen[k] = item.SortKey
+		}
+		items = append(items, *item)
+		itemRows = append(itemRows, r.Row)
+	}
+
+	failed, err := c.Store.CreateClients(items)
+
+	if err != nil {
+		clientLogger.Error("error importing clients", slog.String("error", err.Error()))
+	}
+	failedIds := make(map[string]bool, len(failed))
+	for _, f := range failed {
+		failedIds[f.SortKey] = true
+	}
+
+	for i, item := range items {
+		if failedIds[item.SortKey] {
+			report.add(ImportRowResult{Row: itemRows[i], Status: ImportFailed, Reason: "error while creating client"})
+			continue
+		}
+		report.add(ImportRowResult{Row: itemRows[i], Status: ImportCreated, ClientId: item.SortKey})
+	}
+	// rows are reported in file order.
+	sort.SliceStable(report.Rows, func(i, j int) bool {
+		return report.Rows[i].Row < report.Rows[j].Row
+	})
+
+	clientLogger.Info("clients imported", slog.String("createdBy", createdBy), slog.Int("created", report.Created), slog.Int("skipped", report.Skipped), slog.Int("failed", report.Failed))
+	return report, nil
+}
+
+// NormalizePhone returns the phone in E.164 format. numbers without country code get the default one.
+// values that can't be normalized are returned as they are so validation reports them.
+func NormalizePhone(phone string) string {
+	phone = strings.TrimSpace(phone)
+	if phone == "" {
+		return ""
+	}
+	international := strings.HasPrefix(phone, "+") || strings.HasPrefix(phone, "00")
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+
+	switch {
+	case strings.HasPrefix(phone, "00"):
+		digits = strings.TrimPrefix(digits, "00")
+	case international:
+	case len(digits) == 10:
+		digits = defaultCountryCode + digits
+	case len(digits) == 11 && strings.HasPrefix(digits, defaultCountryCode):
+	default:
+		return phone
+	}
+	return "+" + digits
+}
+
+func dedupeKeys(phone, email string) []string {
+	keys := make([]string, 0, 2)
+	if phone != "" {
+		keys = append(keys, "phone#"+NormalizePhone(phone))
+	}
+	if email != "" {
+		keys = append(keys, "email#"+strings.ToLower(email))
+	}
+	return keys
+}
+
+func duplicateOf(seen map[string]string, phone, email string) (string, bool) {
+	for _, k := range dedupeKeys(phone, email) {
+		if id, ok := seen[k]; ok {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+func validationReason(err error) string {
+	var ve validator.ValidationErrors
+
+	if !errors.As(err, &ve) {
+		return err.Error()
+	}
+	reasons := make([]string, 0, len(ve))
+	for _, fe := range ve {
+		reasons = append(reasons, fmt.Sprintf("%s failed on %s", fe.Field(), fe.Tag()))
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// normalizeDate accepts RFC3339 dates and plain dates and returns them in RFC3339.
+func normalizeDate(d string) string {
+	d = strings.TrimSpace(d)
+	if d == "" {
+		return ""
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, d); err == nil {
+			return t.UTC().Format(time.RFC3339)
+		}
+	}
+	return d
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
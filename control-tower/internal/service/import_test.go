@@ -0,0 +1,115 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/service"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	cases := map[string]string{
+		"":                  "",
+		"(786) 000-0002":    "+17860000002",
+		"1 786 000 0002":    "+17860000002",
+		"+34 600 000 000":   "+34600000000",
+		"0034600000000":     "+34600000000",
+		"12345":             "12345",
+		" +1 (786)000-0002": "+17860000002",
+	}
+	for in, want := range cases {
+		if got := service.NormalizePhone(in); got != want {
+			t.Errorf("NormalizePhone(%q) expected %q, got: %q", in, want, got)
+		}
+	}
+}
+
+func TestParseImport(t *testing.T) {
+	t.Run("csv", func(t *testing.T) {
+		rows, err := service.ParseImport(service.CSVFormat, strings.NewReader("First Name,last_name,Phone,E-mail,Last Visit,Idioma\nBea,Lopez,(786) 000-0002,bea@test.com,2024-01-02,Español\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 || rows[0].Row != 1 {
+			t.Fatalf("expected one row, got: %+v", rows)
+		}
+		c := rows[0].Client
+		if c.FirstName != "Bea" || c.LastName != "Lopez" || c.Phone != "(786) 000-0002" || c.Email != "bea@test.com" || c.Language != service.Spanish {
+			t.Fatalf("unexpected client: %+v", c)
+		}
+		if c.LastSeen == nil || *c.LastSeen != "2024-01-02T00:00:00Z" {
+			t.Fatalf("expected lastSeen to be normalized, got: %v", c.LastSeen)
+		}
+	})
+
+	t.Run("booksy", func(t *testing.T) {
+		export := `{"customers":[{"full_name":"Carla Maria Lopez","cell_phone":"7860000003","last_visit":"2024-01-02T10:00:00"}]}`
+		rows, err := service.ParseImport(service.BooksyFormat, strings.NewReader(export))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 || rows[0].Client.FirstName != "Carla" || rows[0].Client.LastName != "Maria Lopez" || rows[0].Client.Phone != "7860000003" {
+			t.Fatalf("unexpected rows: %+v", rows)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		invalid := []struct {
+			format string
+			body   string
+		}{
+			{service.CSVFormat, "phone,email\n"},
+			{service.BooksyFormat, "not json"},
+			{"xlsx", ""},
+		}
+		for _, tc := range invalid {
+			if _, err := service.ParseImport(tc.format, strings.NewReader(tc.body)); !errors.Is(err, service.ErrInvalidImport) {
+				t.Errorf("format=%s expected ErrInvalidImport, got: %v", tc.format, err)
+			}
+		}
+	})
+}
+
+func TestImportClients(t *testing.T) {
+	s := newTestServices(t)
+	existing := s.createClient(t, time.Now().Add(-week))
+
+	csv := "firstName,lastName,phone,email,lastSeen\n" +
+		"Ana,Perez,786-000-0001,,2024-01-02\n" + // same phone as the existing client
+		"Bea,Lopez,,BEA@test.com,2024-01-02\n" +
+		"Bea,Lopez,,bea@test.com,2024-01-02\n" + // duplicate of the previous row
+		"Carla,,7860000003,,2024-01-02\n" + // missing last name
+		"Dana,Diaz,7860000004,,\n" // missing last seen
+	rows, err := service.ParseImport(service.CSVFormat, strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := s.clients.ImportClients(context.Background(), creator, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 1 || report.Skipped != 2 || report.Failed != 2 || len(report.Rows) != 5 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	want := []string{service.ImportSkipped, service.ImportCreated, service.ImportSkipped, service.ImportFailed, service.ImportFailed}
+	for i, r := range report.Rows {
+		if r.Row != i+1 || r.Status != want[i] {
+			t.Fatalf("row %d expected status %s, got: %+v", i+1, want[i], r)
+		}
+	}
+	if report.Rows[0].ClientId != existing.Id || report.Rows[2].ClientId != report.Rows[1].ClientId {
+		t.Fatalf("expected skipped rows to point at the duplicated client, got: %+v", report.Rows)
+	}
+
+	created, err := s.clients.GetClientById(context.Background(), creator, report.Rows[1].ClientId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Email != "bea@test.com" {
+		t.Fatalf("expected email to be lower cased, got: %s", created.Email)
+	}
+}
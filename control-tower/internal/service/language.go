@@ -0,0 +1,69 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// reminder languages, the codes are ISO 639-1.
+const (
+	English = "en"
+	Spanish = "es"
+
+	// DefaultLanguage is used when neither the client nor the creator chose a language.
+	DefaultLanguage = English
+)
+
+// Languages are the languages the reminders can be sent in.
+var Languages = []string{English, Spanish}
+
+// languageNames are the names the imports use for the languages.
+var languageNames = map[string]string{
+	"english": English,
+	"ingles":  English,
+	"inglés":  English,
+	"spanish": Spanish,
+	"espanol": Spanish,
+	"español": Spanish,
+}
+
+// NormalizeLanguage returns the code of the language name or tag, like "Español" or "es-MX". unknown languages are empty.
+func NormalizeLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+
+	if code, ok := languageNames[language]; ok {
+		return code
+	}
+	code, _, _ := strings.Cut(strings.ReplaceAll(language, "_", "-"), "-")
+
+	for _, l := range Languages {
+		if l == code {
+			return l
+		}
+	}
+	return ""
+}
+
+// reminderLanguage is the language of the client, the creator default or DefaultLanguage in that order.
+func reminderLanguage(clientLanguage, creatorLanguage string) string {
+	for _, l := range []string{clientLanguage, creatorLanguage} {
+		if l != "" {
+			return l
+		}
+	}
+	return DefaultLanguage
+}
+
+var (
+	spanishWeekdays = [...]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"}
+	spanishMonths   = [...]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"}
+)
+
+// formatAppointment writes the date the way it is read in the language.
+func formatAppointment(t time.Time, language string) string {
+	if language == Spanish {
+		return fmt.Sprintf("%s %d de %s a las %s", spanishWeekdays[t.Weekday()], t.Day(), spanishMonths[t.Month()-1], t.Format("3:04 PM"))
+	}
+	return t.Format("Monday, January 2 at 3:04 PM")
+}
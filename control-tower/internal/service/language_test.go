@@ -0,0 +1,158 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/database/memory"
+	"github.com/japb1998/control-tower/internal/service"
+)
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := map[string]string{
+		"en":       service.English,
+		" Español": service.Spanish,
+		"es-MX":    service.Spanish,
+		"spanish":  service.Spanish,
+		"Inglés":   service.English,
+		"fr":       "",
+		"":         "",
+	}
+	for in, want := range tests {
+		if got := service.NormalizeLanguage(in); got != want {
+			t.Errorf("NormalizeLanguage(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLocalizedReminders(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	store := memory.NewTemplateRepo()
+	s.notifications.WithTemplates(store)
+	templates := service.NewTemplateService(store, s.clients, s.settings, "https://api.test")
+
+	text := &fakeNotifier{channel: service.SMS, messageId: "SM1"}
+	whatsapp := &fakeNotifier{channel: service.Phone, messageId: "WA1"}
+	notifiers := service.NewNotifierRegistry(text, whatsapp)
+	messages := s.messageService(notifiers, nil)
+	d := s.deliveryService(notifiers).WithMessages(messages).WithTemplates(templates)
+
+	tmpl, err := templates.CreateTemplate(ctx, creator, service.TemplateInput{
+		Name:    "whatsapp",
+		Channel: service.Phone,
+		Body:    "Hi {{firstName}}, see you {{appointmentTime}}",
+		Variants: map[string]service.TemplateVariant{
+			service.Spanish: {Body: "Hola {{firstName}}, te esperamos el {{appointmentTime}}"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Language != service.English {
+		t.Errorf("expected templates to be in english by default, got: %s", tmpl.Language)
+	}
+
+	c := s.createClient(t, time.Now().Add(-3*week))
+	if _, err := s.clients.UpdateUser(ctx, creator, c.Id, service.PatchClient{Language: service.Spanish}); err != nil {
+		t.Fatal(err)
+	}
+	// appointment times are written in the default time zone, new york.
+	date := time.Date(2030, time.March, 4, 20, 30, 0, 0, time.UTC)
+
+	preview, err := templates.PreviewTemplate(ctx, creator, tmpl.Id, service.PreviewInput{ClientId: c.Id, Date: date.Format(time.RFC3339)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Language != service.Spanish || preview.Body != "Hola Ana, te esperamos el lunes 4 de marzo a las 3:30 PM" {
+		t.Errorf("unexpected spanish preview: %+v", preview)
+	}
+	preview, err = templates.PreviewTemplate(ctx, creator, tmpl.Id, service.PreviewInput{ClientId: c.Id, Date: date.Format(time.RFC3339), Language: service.English})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Body != "Hi Ana, see you Monday, March 4 at 3:30 PM" {
+		t.Errorf("unexpected english preview: %+v", preview)
+	}
+
+	input := service.NotificationInput{
+		Date:            time.Now().Add(time.Hour).Format(time.RFC3339),
+		ClientId:        c.Id,
+		DeliveryMethods: []int8{int8(service.SMS), int8(service.Phone)},
+		TemplateId:      tmpl.Id,
+	}
+	id, err := s.notifications.ScheduleNotification(creator, &input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := s.notifications.GetNotification(creator, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Deliver(ctx, *event); err != nil {
+		t.Fatal(err)
+	}
+	if len(whatsapp.reminders) != 1 || whatsapp.reminders[0].Values.Language != service.Spanish {
+		t.Fatalf("expected a spanish reminder, got: %+v", whatsapp.reminders)
+	}
+
+	thread, err := messages.GetMessages(ctx, creator, c.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodies := make(map[service.ContactOptions]string)
+	for _, m := range thread {
+		bodies[m.Channel] = m.Body
+	}
+	if !strings.HasPrefix(bodies[service.Phone], "Hola Ana, te esperamos el") {
+		t.Errorf("expected the spanish variant by whatsapp, got: %s", bodies[service.Phone])
+	}
+	// the channels without a template send their default in the client language.
+	if !strings.Contains(bodies[service.SMS], "Responde BAJA") {
+		t.Errorf("expected the spanish default by sms, got: %s", bodies[service.SMS])
+	}
+}
+
+func TestLanguageFallback(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	templates := service.NewTemplateService(memory.NewTemplateRepo(), s.clients, s.settings, "")
+	settings := service.NewSettingsService(s.settings)
+
+	tmpl, err := templates.CreateTemplate(ctx, creator, service.TemplateInput{Name: "text", Channel: service.SMS, Body: "Hi {{firstName}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := s.createClient(t, time.Now().Add(-week))
+
+	weeks := 4
+	if _, err := settings.SaveSettings(ctx, creator, service.PutSettings{MaintenanceIntervalWeeks: &weeks, DefaultLanguage: service.Spanish}); err != nil {
+		t.Fatal(err)
+	}
+	// clients without a language get the creator language, templates without a variant are sent as they are.
+	preview, err := templates.PreviewTemplate(ctx, creator, tmpl.Id, service.PreviewInput{ClientId: c.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Language != service.English || preview.Body != "Hi Ana" {
+		t.Errorf("expected the template language when there is no variant, got: %+v", preview)
+	}
+
+	if _, err := templates.UpdateTemplate(ctx, creator, tmpl.Id, service.TemplateInput{
+		Name:     "text",
+		Channel:  service.SMS,
+		Body:     "Hi {{firstName}}",
+		Variants: map[string]service.TemplateVariant{service.Spanish: {Body: "Hola {{firstName}}"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	preview, err = templates.PreviewTemplate(ctx, creator, tmpl.Id, service.PreviewInput{ClientId: c.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Language != service.Spanish || preview.Body != "Hola Ana" {
+		t.Errorf("expected the creator language, got: %+v", preview)
+	}
+}
@@ -23,3 +23,27 @@ var (
 	connectionHandler = slog.NewTextHandler(os.Stdout, nil).WithAttrs([]slog.Attr{slog.String("name", "Connection Service")})
 	connectionLogger  = slog.New(connectionHandler)
 )
+
+// Settings Logger
+var (
+	settingsHandler = slog.NewTextHandler(os.Stdout, nil).WithAttrs([]slog.Attr{slog.String("name", "Settings Service")})
+	settingsLogger  = slog.New(settingsHandler)
+)
+
+// Delivery Logger
+var (
+	deliveryHandler = slog.NewTextHandler(os.Stdout, nil).WithAttrs([]slog.Attr{slog.String("name", "Delivery Service")})
+	deliveryLogger  = slog.New(deliveryHandler)
+)
+
+// Message Logger
+var (
+	messageHandler = slog.NewTextHandler(os.Stdout, nil).WithAttrs([]slog.Attr{slog.String("name", "Message Service")})
+	messageLogger  = slog.New(messageHandler)
+)
+
+// Template Logger
+var (
+	templateHandler = slog.NewTextHandler(os.Stdout, nil).WithAttrs([]slog.Attr{slog.String("name", "Template Service")})
+	templateLogger  = slog.New(templateHandler)
+)
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/database"
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+var (
+	ErrChannelDisabled = errors.New("channel is disabled for the client")
+)
+
+type MessageRepository interface {
+	// SaveMessage fails with database.ErrMessageExists when the thread has a message with the sort key.
+	SaveMessage(message model.MessageItem) error
+	GetMessages(createdBy, clientId string) ([]model.MessageItem, error)
+}
+
+// Message is a message of the conversation with a client, reminders and replies are outbound and the client answers inbound.
+type Message struct {
+	Id             string         `json:"id"`
+	ClientId       string         `json:"clientId"`
+	Direction      string         `json:"direction"`
+	Channel        ContactOptions `json:"channel"`
+	Subject        string         `json:"subject,omitempty"`
+	Body           string         `json:"body"`
+	MessageId      string         `json:"messageId,omitempty"`
+	NotificationId string         `json:"notificationId,omitempty"`
+	CreatedAt      string         `json:"createdAt"`
+}
+
+func newMessageFromItem(item model.MessageItem) Message {
+	return Message{
+		Id:             item.Id,
+		ClientId:       item.ClientId,
+		Direction:      item.Direction,
+		Channel:        ContactOptions(item.Channel),
+		Subject:        item.Subject,
+		Body:           item.Body,
+		MessageId:      item.MessageId,
+		NotificationId: item.NotificationId,
+		CreatedAt:      item.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// Reply is a message the creator sends to the client. the subject is only used by emails.
+// the channel is required, whatsapp (0) only reaches clients that wrote in the last 24 hours.
+type Reply struct {
+	Channel *ContactOptions `json:"channel" binding:"required,min=0,max=2"`
+	Subject string          `json:"subject" binding:"max=200"`
+	Body    string          `json:"body" binding:"required,max=1600"`
+}
+
+// Replier is implemented by the notifiers that can send free text messages to a client.
+type Replier interface {
+	Reply(ctx context.Context, client ClientDto, subject, body string) (string, error)
+}
+
+// MessageBroadcaster pushes the new messages to the creator open websocket connections.
+type MessageBroadcaster interface {
+	SendNewMessage(ctx context.Context, createdBy string, m Message) error
+}
+
+type MessageService struct {
+	store       MessageRepository
+	clients     *ClientService
+	notifiers   *NotifierRegistry
+	broadcaster MessageBroadcaster
+}
+
+func NewMessageService(store MessageRepository, clients *ClientService, notifiers *NotifierRegistry, broadcaster MessageBroadcaster) *MessageService {
+	return &MessageService{
+		store:       store,
+		clients:     clients,
+		notifiers:   notifiers,
+		broadcaster: broadcaster,
+	}
+}
+
+// GetMessages returns the conversation with the client, oldest message first.
+func (s *MessageService) GetMessages(ctx context.Context, createdBy, clientId string) ([]Message, error) {
+	if _, err := s.clients.GetClientById(ctx, createdBy, clientId); err != nil {
+		return nil, err
+	}
+	items, err := s.store.GetMessages(createdBy, clientId)
+
+	if err != nil {
+		messageLogger.Error("error getting messages", slog.String("clientId", clientId), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error getting messages")
+	}
+	// replies are keyed by their provider id, the thread is ordered by date.
+	slices.SortStableFunc(items, func(a, b model.MessageItem) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	messages := make([]Message, 0, len(items))
+
+	for _, i := range items {
+		messages = append(messages, newMessageFromItem(i))
+	}
+	return messages, nil
+}
+
+// Reply sends the message to the client through the channel and adds it to the conversation.
+// clients that opted out or disabled the channel are not messaged.
+func (s *MessageService) Reply(ctx context.Context, createdBy, clientId string, r Reply) (*Message, error) {
+	client, err := s.clients.GetClientById(ctx, createdBy, clientId)
+
+	if err != nil {
+		return nil, err
+	}
+	if r.Channel == nil {
+		return nil, fmt.Errorf("channel is required: %w", ErrUnsupportedChannel)
+	}
+	channel := *r.Channel
+
+	if client.OptIn != nil && !*client.OptIn {
+		return nil, ErrClientOptedOut
+	}
+	if slices.Contains(client.DisabledChannels, int8(channel)) {
+		return nil, ErrChannelDisabled
+	}
+	n, ok := s.notifiers.Get(channel)
+	replier, canReply := n.(Replier)
+
+	if !ok || !canReply {
+		return nil, fmt.Errorf("channel=%d can't send replies: %w", channel, ErrUnsupportedChannel)
+	}
+	// subjects are only sent by email.
+	subject := r.Subject
+	if channel != Email {
+		subject = ""
+	}
+	messageId, err := replier.Reply(ctx, *client, subject, r.Body)
+
+	if err != nil {
+		if errors.Is(err, ErrNoContact) {
+			return nil, err
+		}
+		messageLogger.Error("error sending reply", slog.String("clientId", clientId), slog.Int("channel", int(channel)), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error sending reply")
+	}
+	item := model.NewMessageItem(createdBy, clientId, model.MessageOutbound, int8(channel), subject, r.Body, time.Now())
+	item.MessageId = messageId
+
+	return s.save(ctx, item)
+}
+
+// RecordReminder adds the reminder sent by the notification to the conversation.
+func (s *MessageService) RecordReminder(ctx context.Context, client ClientDto, notificationId string, channel ContactOptions, body, messageId string) error {
+	item := model.NewMessageItem(client.CreatedBy, client.Id, model.MessageOutbound, int8(channel), "", body, time.Now())
+	item.NotificationId = notificationId
+	item.MessageId = messageId
+
+	_, err := s.save(ctx, item)
+	return err
+}
+
+// RecordInbound adds a message sent by the client to the conversation. providers retry their webhooks, a message id that is
+// already in the conversation is not added again.
+func (s *MessageService) RecordInbound(ctx context.Context, client ClientDto, channel ContactOptions, subject, body, messageId string) error {
+	item := model.NewMessageItem(client.CreatedBy, client.Id, model.MessageInbound, int8(channel), subject, body, time.Now())
+	item.MessageId = messageId
+
+	if messageId != "" {
+		item.SortKey = model.InboundMessageKey(messageId)
+	}
+	_, err := s.save(ctx, item)
+
+	if errors.Is(err, database.ErrMessageExists) {
+		messageLogger.Info("ignoring message already in the conversation", slog.String("clientId", client.Id), slog.String("messageId", messageId))
+		return nil
+	}
+	return err
+}
+
+// save stores the message and pushes it to the creator connections. push errors are logged, the message is already stored.
+func (s *MessageService) save(ctx context.Context, item *model.MessageItem) (*Message, error) {
+	if err := s.store.SaveMessage(*item); err != nil {
+		if errors.Is(err, database.ErrMessageExists) {
+			return nil, err
+		}
+		messageLogger.Error("error saving message", slog.String("clientId", item.ClientId), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error saving message")
+	}
+	m := newMessageFromItem(*item)
+
+	if s.broadcaster != nil {
+		if err := s.broadcaster.SendNewMessage(ctx, item.CreatedBy, m); err != nil {
+			messageLogger.Error("failed to send WS message", slog.String("error", err.Error()))
+		}
+	}
+	return &m, nil
+}
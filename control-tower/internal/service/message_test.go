@@ -0,0 +1,231 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/database/memory"
+	"github.com/japb1998/control-tower/internal/model"
+	"github.com/japb1998/control-tower/internal/service"
+	"github.com/japb1998/control-tower/pkg/email"
+	"github.com/japb1998/control-tower/pkg/sms"
+)
+
+type fakeReplier struct {
+	*fakeNotifier
+	replies []string
+}
+
+func (f *fakeReplier) Reply(ctx context.Context, client service.ClientDto, subject, body string) (string, error) {
+	f.replies = append(f.replies, body)
+	return f.messageId, f.err
+}
+
+type recordedMessages struct {
+	msgs []service.Message
+}
+
+func (r *recordedMessages) SendNewMessage(ctx context.Context, createdBy string, m service.Message) error {
+	r.msgs = append(r.msgs, m)
+	return nil
+}
+
+func (s *testServices) messageService(notifiers *service.NotifierRegistry, broadcaster service.MessageBroadcaster) *service.MessageService {
+	return service.NewMessageService(memory.NewMessageRepo(), s.clients, notifiers, broadcaster)
+}
+
+func TestReply(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	phone := &fakeReplier{fakeNotifier: &fakeNotifier{channel: service.Phone, messageId: "SM1"}}
+	broadcast := &recordedMessages{}
+	m := s.messageService(service.NewNotifierRegistry(phone, &fakeNotifier{channel: service.SMS}), broadcast)
+
+	c := s.createClient(t, time.Now().Add(-week))
+
+	msg, err := m.Reply(ctx, creator, c.Id, service.Reply{Channel: channelPtr(service.Phone), Subject: "ignored", Body: "see you tomorrow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Direction != model.MessageOutbound || msg.MessageId != "SM1" || msg.Subject != "" {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+	if len(phone.replies) != 1 || phone.replies[0] != "see you tomorrow" {
+		t.Errorf("expected the reply to be sent, got: %v", phone.replies)
+	}
+	if len(broadcast.msgs) != 1 || broadcast.msgs[0].Id != msg.Id {
+		t.Errorf("expected the message to be broadcasted, got: %+v", broadcast.msgs)
+	}
+
+	messages, err := m.GetMessages(ctx, creator, c.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].Id != msg.Id {
+		t.Errorf("expected the reply in the conversation, got: %+v", messages)
+	}
+
+	// replies without a channel are not sent on whatsapp by default.
+	if _, err := m.Reply(ctx, creator, c.Id, service.Reply{Body: "hi"}); !errors.Is(err, service.ErrUnsupportedChannel) || len(phone.replies) != 1 {
+		t.Errorf("expected ErrUnsupportedChannel without a channel, got: %v, %v", err, phone.replies)
+	}
+	if _, err := m.Reply(ctx, creator, c.Id, service.Reply{Channel: channelPtr(service.SMS), Body: "hi"}); !errors.Is(err, service.ErrUnsupportedChannel) {
+		t.Errorf("expected ErrUnsupportedChannel, got: %v", err)
+	}
+	if _, err := m.GetMessages(ctx, creator, "missing"); !errors.Is(err, service.ErrClientNotFound) {
+		t.Errorf("expected ErrClientNotFound, got: %v", err)
+	}
+}
+
+func TestReplyRejected(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	phone := &fakeReplier{fakeNotifier: &fakeNotifier{channel: service.Phone}}
+	m := s.messageService(service.NewNotifierRegistry(phone), nil)
+
+	c := s.createClient(t, time.Now().Add(-week))
+
+	if _, err := s.clients.DisableChannel(ctx, c.CreatedBy, c.Id, service.Phone); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Reply(ctx, creator, c.Id, service.Reply{Channel: channelPtr(service.Phone), Body: "hi"}); !errors.Is(err, service.ErrChannelDisabled) {
+		t.Errorf("expected ErrChannelDisabled, got: %v", err)
+	}
+
+	if err := s.clients.OptOut(ctx, c.CreatedBy, c.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Reply(ctx, creator, c.Id, service.Reply{Channel: channelPtr(service.Phone), Body: "hi"}); !errors.Is(err, service.ErrClientOptedOut) {
+		t.Errorf("expected ErrClientOptedOut, got: %v", err)
+	}
+	if len(phone.replies) != 0 {
+		t.Errorf("expected no replies to be sent, got: %v", phone.replies)
+	}
+}
+
+func TestConversation(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	notifiers := service.NewNotifierRegistry(&fakeNotifier{channel: service.Phone, messageId: "SM1"}, &fakeNotifier{channel: service.Email, err: errors.New("mailgun down")})
+	m := s.messageService(notifiers, nil)
+	d := s.deliveryService(notifiers).WithMessages(m)
+
+	ls := time.Now().Add(-week).Format(time.RFC3339)
+	c, err := s.clients.CreateClient(ctx, creator, service.CreateClient{FirstName: "Ana", Phone: "+17860000001", Email: "ana@test.com", LastSeen: &ls})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := s.scheduleFor(t, c.Id, service.Phone, service.Email)
+
+	if err := d.Deliver(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.HandleInboundMessage(ctx, sms.InboundMessage{MessageSid: "SM2", From: "whatsapp:" + c.Phone, Body: "see you there"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.HandleInboundEmail(ctx, &email.InboundEmail{MessageId: "m1", Sender: "ana@test.com", Subject: "Re: reminder", Body: "thanks"}); err != nil {
+		t.Fatal(err)
+	}
+	// emails of unknown senders are ignored.
+	if err := d.HandleInboundEmail(ctx, &email.InboundEmail{MessageId: "m2", Sender: "someone@test.com", Body: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	// retried webhooks don't add the replies again.
+	if err := d.HandleInboundMessage(ctx, sms.InboundMessage{MessageSid: "SM2", From: "whatsapp:" + c.Phone, Body: "see you there"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.HandleInboundEmail(ctx, &email.InboundEmail{MessageId: "m1", Sender: "ana@test.com", Subject: "Re: reminder", Body: "thanks"}); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := m.GetMessages(ctx, creator, c.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the failed email reminder is not part of the conversation.
+	want := []struct {
+		direction string
+		channel   service.ContactOptions
+		messageId string
+	}{
+		{model.MessageOutbound, service.Phone, "SM1"},
+		{model.MessageInbound, service.Phone, "SM2"},
+		{model.MessageInbound, service.Email, "m1"},
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("expected %d messages, got: %+v", len(want), messages)
+	}
+	for i, w := range want {
+		got := messages[i]
+		if got.Direction != w.direction || got.Channel != w.channel || got.MessageId != w.messageId {
+			t.Errorf("message %d: expected %+v, got: %+v", i, w, got)
+		}
+	}
+	if messages[0].NotificationId != event.ID || messages[0].Body == "" {
+		t.Errorf("expected the reminder to reference the notification, got: %+v", messages[0])
+	}
+}
+
+func TestInboundRepliesStayWithRecipient(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	notifiers := service.NewNotifierRegistry(&fakeNotifier{channel: service.Phone, messageId: "SM1"}, &fakeNotifier{channel: service.Email, messageId: "EM1"})
+	m := s.messageService(notifiers, nil)
+	d := s.deliveryService(notifiers).WithMessages(m)
+
+	ls := time.Now().Add(-week).Format(time.RFC3339)
+	input := service.CreateClient{FirstName: "Ana", Phone: "+17860000001", Email: "ana@test.com", LastSeen: &ls}
+	c, err := s.clients.CreateClient(ctx, creator, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.clients.CreateClient(ctx, "other@test.com", input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nobody messaged the contact yet, the replies are dropped.
+	if err := d.HandleInboundMessage(ctx, sms.InboundMessage{MessageSid: "SM0", From: c.Phone, Body: "hello?"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.HandleInboundEmail(ctx, &email.InboundEmail{MessageId: "m0", Sender: c.Email, Body: "hello?"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, cl := range []service.ClientDto{c, other} {
+		if messages, err := m.GetMessages(ctx, cl.CreatedBy, cl.Id); err != nil || len(messages) != 0 {
+			t.Fatalf("expected no messages for the client of %s, got: %+v, %v", cl.CreatedBy, messages, err)
+		}
+	}
+
+	if err := d.Deliver(ctx, s.scheduleFor(t, c.Id, service.Phone, service.Email)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.HandleInboundMessage(ctx, sms.InboundMessage{MessageSid: "SM2", From: c.Phone, Body: "see you there"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.HandleInboundEmail(ctx, &email.InboundEmail{MessageId: "m1", Sender: c.Email, Body: "thanks"}); err != nil {
+		t.Fatal(err)
+	}
+	inbound := make(map[string]bool)
+	messages, err := m.GetMessages(ctx, creator, c.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range messages {
+		if msg.Direction == model.MessageInbound {
+			inbound[msg.MessageId] = true
+		}
+	}
+	if len(inbound) != 2 || !inbound["SM2"] || !inbound["m1"] {
+		t.Fatalf("expected the replies in the conversation of the creator that sent the reminder, got: %+v", messages)
+	}
+	if messages, err := m.GetMessages(ctx, other.CreatedBy, other.Id); err != nil || len(messages) != 0 {
+		t.Fatalf("expected the replies to not reach the other creator, got: %+v, %v", messages, err)
+	}
+}
+
+func channelPtr(c service.ContactOptions) *service.ContactOptions {
+	return &c
+}
@@ -1,11 +1,15 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/japb1998/control-tower/internal/database"
@@ -24,20 +28,39 @@ const (
 
 // used as enums
 const (
-	Phone ContactOptions = iota
+	Phone ContactOptions = iota // whatsapp template message.
 	Email
+	SMS // plain text message.
 )
 const (
-	SentStatus    notificationStatus = "SENT"
-	FailedStatus  notificationStatus = "FAILED"
-	NotSentStatus notificationStatus = "NOT_SENT"
+	SentStatus      notificationStatus = "SENT"
+	FailedStatus    notificationStatus = "FAILED"
+	NotSentStatus   notificationStatus = "NOT_SENT"
+	CancelledStatus notificationStatus = "CANCELLED" // the client was archived before it was sent.
+	RetryingStatus  notificationStatus = "RETRYING"  // some channels failed and a retry is scheduled.
+	SendingStatus   notificationStatus = "SENDING"   // a send claimed the notification, other sends skip it.
 )
 
+// MaintenanceKind marks the reminders scheduled from the client lastSeen and the creator maintenance interval.
+const MaintenanceKind = "maintenance"
+
+// updateRetries is the number of times a change to a notification is applied when other writes keep changing it in between.
+const updateRetries = 5
+
 var (
 	ErrInvalidMethod        = errors.New("invalid delivery method provided")
 	ErrInvalidDate          = errors.New("invalid date provided")
 	ErrInvalidStatus        = errors.New("invalid notification status")
 	ErrNotificationNotFound = errors.New("notification not found")
+	ErrNothingToRetry       = errors.New("notification has no failed channels to retry")
+	ErrRetryPending         = errors.New("notification already has a retry scheduled")
+	ErrRecurringSend        = errors.New("recurring notifications can't be sent now")
+	ErrDeliveryNotFound     = errors.New("delivery not found")
+	ErrDeliveryPending      = errors.New("delivery of the message is not recorded yet")
+	ErrNothingToConfirm     = errors.New("no sent notification to confirm")
+	ErrNotPending           = errors.New("notification is no longer pending")
+	// errNoChange stops modifyItem without storing the notification.
+	errNoChange = errors.New("notification not changed")
 )
 
 // ContactOptions are the different type of notifications
@@ -53,6 +76,16 @@ type NotificationRepository interface {
 	GetNotificationsByCreator(createdBy string, ops *database.PaginationOps) (*database.PaginatedNotifications, error)
 	UpdateNotification(createdBy, id string, notification database.PatchNotificationItem) (*model.NotificationItem, error)
 	SetStatus(partitionKey string, sortKey string, status string) error
+	ChangeStatus(partitionKey, sortKey, from, to string) error
+	Update(notification model.NotificationItem) (model.NotificationItem, error)
+	GetNotificationsByClient(createdBy, clientId string) ([]model.NotificationItem, error)
+	GetNotificationsByStatus(status string) ([]model.NotificationItem, error)
+	// the outbox keeps the scheduler operation of a notification write until it is applied.
+	CreateWithOutbox(notification model.NotificationItem, entry model.OutboxItem) error
+	UpdateNotificationWithOutbox(createdBy, id string, notification database.PatchNotificationItem, entry model.OutboxItem) (*model.NotificationItem, error)
+	GetOutbox(limit int) ([]model.OutboxItem, error)
+	DeleteOutbox(id string) error
+	RecordOutboxAttempt(id, lastError string) error
 }
 
 func (s notificationStatus) String() string {
@@ -73,36 +106,62 @@ type PaginatedNotifications struct {
 }
 
 type NotificationInput struct {
-	Date            string `json:"date" binding:"required,rfc3339"`
-	ClientId        string `json:"clientId" binding:"required"`
-	DeliveryMethods []int8 `json:"deliveryMethods" binding:"required,min=1,dive,number,min=0,max=1"`
+	Date            string      `json:"date" binding:"required,rfc3339"`
+	ClientId        string      `json:"clientId" binding:"required"`
+	DeliveryMethods []int8      `json:"deliveryMethods" binding:"required,min=1,dive,number,min=0,max=2"`
+	Recurrence      *Recurrence `json:"recurrence,omitempty"`
+	TemplateId      string      `json:"templateId,omitempty" binding:"omitempty,uuid"`
+	Subject         string      `json:"subject,omitempty" binding:"max=200"`  // replaces the subject of the email reminder.
+	Message         string      `json:"message,omitempty" binding:"max=1600"` // replaces the reminder in every channel, it can use the template variables.
 }
 type Notification struct {
-	ID              string `json:"id,omitempty" validate:"required,uuid"`
-	Status          string `json:"status" validate:"required"`
-	Date            string `json:"date" validate:"required"`
-	ClientId        string `json:"clientId" validate:"required,uuid"`
-	CreatedBy       string `json:"createdBy" validate:"required,email"`
-	ClientToken     string `json:"clientToken" validate:"uuid"`
-	DeliveryMethods []int8 `json:"deliveryMethods" validate:"required,min=1,dive,min=0,max=1"`
+	ID              string       `json:"id,omitempty" validate:"required,uuid"`
+	Status          string       `json:"status" validate:"required"`
+	Date            string       `json:"date" validate:"required"`
+	ClientId        string       `json:"clientId" validate:"required,uuid"`
+	CreatedBy       string       `json:"createdBy" validate:"required,email"`
+	ClientToken     string       `json:"clientToken" validate:"uuid"`
+	DeliveryMethods []int8       `json:"deliveryMethods" validate:"required,min=1,dive,min=0,max=2"`
+	Recurrence      *Recurrence  `json:"recurrence,omitempty"`
+	Occurrences     []Occurrence `json:"occurrences,omitempty"`
+	Deliveries      []Delivery   `json:"deliveries,omitempty"`
+	Attempt         int          `json:"attempt,omitempty"` // set on the payload of retries, first sends are attempt 1.
+	ConfirmedAt     string       `json:"confirmedAt,omitempty"`
+	TemplateId      string       `json:"templateId,omitempty"`
+	Subject         string       `json:"subject,omitempty"`
+	Message         string       `json:"message,omitempty"`
+	TimeZone        string       `json:"timeZone,omitempty"` // time zone of the schedule, the client one or the creator one.
+	// RequestedDate is the date that was asked for when the send window moved the notification to Date.
+	RequestedDate string `json:"requestedDate,omitempty"`
+	Kind          string `json:"kind,omitempty"` // MaintenanceKind for the automatic reminders, empty for the ones scheduled by the creator.
+	// SchedulePending is set when the notification was stored but its schedule could not be changed yet, the outbox relay changes it later.
+	SchedulePending bool `json:"schedulePending,omitempty"`
 }
 
 type PatchNotification struct {
-	Date            string `json:"date" binding:"omitempty,rfc3339"`
-	ClientId        string `json:"clientId" binding:"omitempty,uuid"`
-	Status          string `json:"status"`
-	DeliveryMethods []int8 `json:"deliveryMethods,omitempty" binding:"omitempty,dive,number,min=0,max=1"`
+	Date            string      `json:"date" binding:"omitempty,rfc3339"`
+	ClientId        string      `json:"clientId" binding:"omitempty,uuid"`
+	Status          string      `json:"status"`
+	DeliveryMethods []int8      `json:"deliveryMethods,omitempty" binding:"omitempty,dive,number,min=0,max=2"`
+	Recurrence      *Recurrence `json:"recurrence,omitempty"`
+	TemplateId      string      `json:"templateId,omitempty" binding:"omitempty,uuid"`
+	Subject         string      `json:"subject,omitempty" binding:"max=200"`
+	Message         string      `json:"message,omitempty" binding:"max=1600"`
 }
 
 func NewNotification(createdBy, id, clientToken string, input *NotificationInput) *Notification {
 	return &Notification{
 		ID:              id,
-		Status:          fmt.Sprintf("%s", NotSentStatus),
+		Status:          NotSentStatus.String(),
 		DeliveryMethods: input.DeliveryMethods,
 		Date:            input.Date,
 		ClientId:        input.ClientId,
 		CreatedBy:       createdBy,
 		ClientToken:     clientToken,
+		Recurrence:      input.Recurrence,
+		TemplateId:      input.TemplateId,
+		Subject:         input.Subject,
+		Message:         input.Message,
 	}
 }
 func NewNotificationFromItem(item *model.NotificationItem) *Notification {
@@ -113,19 +172,90 @@ func NewNotificationFromItem(item *model.NotificationItem) *Notification {
 		Date:            item.Date,
 		ClientId:        item.ClientId,
 		CreatedBy:       item.PartitionKey,
+		ClientToken:     item.ClientToken,
+		Recurrence:      newRecurrenceFromItem(item.Recurrence),
+		Occurrences:     newOccurrencesFromItems(item.Occurrences),
+		Deliveries:      newDeliveriesFromItems(item.Deliveries),
+		ConfirmedAt:     item.ConfirmedAt,
+		TemplateId:      item.TemplateId,
+		Subject:         item.Subject,
+		Message:         item.Message,
+		TimeZone:        item.TimeZone,
+		RequestedDate:   item.RequestedDate,
+		Kind:            item.Kind,
 	}
 }
 
 type NotificationService struct {
 	store     NotificationRepository
 	scheduler scheduler.Scheduler
+	settings  SettingsRepository
+	templates TemplateRepository // optional, checks the templates referenced by the notifications.
+	clients   ClientRepository   // optional, schedules the notifications in the client time zone.
 }
 
-func NewNotificationService(store NotificationRepository, scheduler scheduler.Scheduler) *NotificationService {
+func NewNotificationService(store NotificationRepository, scheduler scheduler.Scheduler, settings SettingsRepository) *NotificationService {
 	return &NotificationService{
-		store,
-		scheduler,
+		store:     store,
+		scheduler: scheduler,
+		settings:  settings,
+	}
+}
+
+// WithTemplates checks that the templates referenced by the notifications exist and are for one of their delivery methods.
+func (s *NotificationService) WithTemplates(templates TemplateRepository) *NotificationService {
+	s.templates = templates
+	return s
+}
+
+// WithClients schedules the notifications in the time zone of their client, the creator time zone is used without it.
+func (s *NotificationService) WithClients(clients ClientRepository) *NotificationService {
+	s.clients = clients
+	return s
+}
+
+// timeZone returns the time zone of the client, the creator one or the default one in that order.
+// errors are logged and the next time zone is used, the time zone only changes how the schedule is written.
+func (s *NotificationService) timeZone(createdBy, clientId string) string {
+	var clientTz, creatorTz string
+
+	if s.clients != nil {
+		client, err := s.clients.GetClientById(createdBy, clientId)
+
+		if err != nil {
+			notificationLogger.Error("error getting client time zone", slog.String("clientId", clientId), slog.String("error", err.Error()))
+		} else if client != nil {
+			clientTz = client.TimeZone
+		}
+	}
+	settings, err := s.settings.GetSettings(createdBy)
+
+	if err != nil {
+		notificationLogger.Error("error getting creator time zone", slog.String("error", err.Error()))
+	} else if settings != nil {
+		creatorTz = settings.TimeZone
+	}
+	return timeZone(clientTz, creatorTz)
+}
+
+// checkTemplate fails with ErrTemplateNotFound or ErrTemplateChannel when the template can't be sent by the notification.
+func (s *NotificationService) checkTemplate(createdBy, templateId string, deliveryMethods []int8) error {
+	if templateId == "" || s.templates == nil {
+		return nil
+	}
+	t, err := s.templates.GetTemplate(createdBy, templateId)
+
+	if err != nil {
+		notificationLogger.Error("error getting template", slog.String("templateId", templateId), slog.String("error", err.Error()))
+		return fmt.Errorf("error getting template")
+	}
+	if t == nil {
+		return ErrTemplateNotFound
+	}
+	if !slices.Contains(deliveryMethods, t.Channel) {
+		return ErrTemplateChannel
 	}
+	return nil
 }
 
 // DeleteNotification is used to cleanup the notification in the db.
@@ -149,6 +279,9 @@ func (s *NotificationService) DeleteNotification(createdBy, id string) error {
 			}
 		}
 	}
+	if err := s.cancelRetry(i); err != nil {
+		return err
+	}
 
 	err = s.store.Delete(createdBy, id)
 
@@ -162,53 +295,291 @@ func (s *NotificationService) DeleteNotification(createdBy, id string) error {
 
 // ScheduleNotification schedules a new execution and stores the schedule ID in the db
 func (s *NotificationService) ScheduleNotification(createdBy string, notification *NotificationInput) (id string, err error) {
+	n, err := s.scheduleNotification(createdBy, notification, "", "", "")
+
+	if err != nil {
+		return "", err
+	}
+	return n.ID, nil
+}
+
+// CreateNotification schedules the notification and returns it, RequestedDate is set when the send window moved it.
+func (s *NotificationService) CreateNotification(createdBy string, notification *NotificationInput) (*Notification, error) {
+	return s.scheduleNotification(createdBy, notification, "", "", "")
+}
+
+// scheduleNotification schedules the notification inside of the creator send window, an empty windowPolicy uses the creator one.
+// kind records what scheduled the notification and an empty clientToken generates a new one.
+func (s *NotificationService) scheduleNotification(createdBy string, notification *NotificationInput, windowPolicy, kind, clientToken string) (*Notification, error) {
 	// we parse the incoming date in UTC
 	date, err := time.Parse(time.RFC3339, notification.Date)
 	if err != nil {
-		return "", fmt.Errorf("invalid notification date error: %w", err)
+		return nil, fmt.Errorf("invalid notification date error: %w", err)
+	}
+	if err := validateContent(notification.TemplateId, notification.Subject, notification.Message, notification.DeliveryMethods); err != nil {
+		return nil, err
+	}
+	if err := s.checkTemplate(createdBy, notification.TemplateId, notification.DeliveryMethods); err != nil {
+		return nil, err
+	}
+	tz := s.timeZone(createdBy, notification.ClientId)
+	sendDate, err := s.applySendWindow(createdBy, date, tz, windowPolicy)
+
+	if err != nil {
+		if errors.Is(err, ErrOutsideSendWindow) {
+			return nil, err
+		}
+		notificationLogger.Error("error applying send window", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error while scheduling notification")
 	}
 	// item uuid is generated here.
-	i := model.NewNotificationItem(createdBy, NotSentStatus.String(), notification.ClientId, date, notification.DeliveryMethods)
-	// set id to be returned here.
-	id = i.SortKey
+	i := model.NewNotificationItem(createdBy, NotSentStatus.String(), notification.ClientId, sendDate, notification.DeliveryMethods)
+	i.TemplateId = notification.TemplateId
+	i.Subject = notification.Subject
+	i.Message = notification.Message
+	i.TimeZone = tz
+	i.Kind = kind
+	if clientToken != "" {
+		i.ClientToken = clientToken
+	}
+	if !sendDate.Equal(date) {
+		i.RequestedDate = notification.Date
+	}
+	date = sendDate
+
+	var last *time.Time
+	if notification.Recurrence != nil {
+		start := date.In(location(tz))
 
+		if err := notification.Recurrence.validate(start); err != nil {
+			return nil, err
+		}
+		// validate already checked the recurrence.
+		last, _ = notification.Recurrence.last(start)
+		i.WithRecurrence(notification.Recurrence.toItem(), last)
+	}
 	newNotification := NewNotification(createdBy, i.SortKey, i.ClientToken, notification)
+	newNotification.Date = i.Date
+	newNotification.TimeZone = i.TimeZone
+	newNotification.RequestedDate = i.RequestedDate
+	newNotification.Kind = i.Kind
 
 	payload, err := json.Marshal(newNotification)
 
 	if err != nil {
 		notificationLogger.Error("error while marshalling notification payload", slog.String("error", err.Error()))
-		return "", fmt.Errorf("error while scheduling notification")
+		return nil, fmt.Errorf("error while scheduling notification")
 	}
 
-	// create event bridge event
-	handler := os.Getenv("NOTIFICATION_LAMBDA")
-	role := os.Getenv("SCHEDULER_ROLE")
-	sch := scheduler.NewSchedule(i.SortKey, handler, role, scheduler.TimeZoneETD, string(payload), date)
-	_, err = s.scheduler.CreateSchedule(sch, i.ClientToken)
+	// the event bridge schedule is created from the outbox entry stored with the notification.
+	var repeat *scheduler.Repeat
+	if notification.Recurrence != nil {
+		repeat = &scheduler.Repeat{EveryDays: notification.Recurrence.Interval * 7, End: scheduleEnd(last)}
+	}
+	entry := newOutboxEntry(OutboxCreate, createdBy, i.SortKey, i.ClientToken, os.Getenv("NOTIFICATION_LAMBDA"), os.Getenv("SCHEDULER_ROLE"), i.TimeZone, string(payload), date, repeat)
 
-	if err != nil {
+	if err := s.store.CreateWithOutbox(*i, entry); err != nil {
+		notificationLogger.Error("error while storing notification", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error while scheduling notification")
+	}
+
+	if err := s.commitOutbox(entry); err != nil {
 		notificationLogger.Error("error while creating schedule", slog.String("error", err.Error()))
+		// the notification is removed before its entry so the relay drops the entry if the cleanup stops half way.
+		if err := s.store.Delete(createdBy, i.SortKey); err != nil {
+			notificationLogger.Error("failed to cleanup notification", slog.String("error", err.Error()))
+		} else if err := s.store.DeleteOutbox(entry.Id); err != nil {
+			notificationLogger.Error("failed to cleanup outbox entry", slog.String("error", err.Error()))
+		}
 		if errors.Is(err, scheduler.ErrInvalidDate) {
-			return "", ErrInvalidDate
+			return nil, ErrInvalidDate
 		}
-		return "", fmt.Errorf("error while scheduling notification")
+		return nil, fmt.Errorf("error while scheduling notification")
 	}
-	// store in dynamo .
-	err = s.store.Create(*i)
+
+	notificationLogger.Info("notification created", slog.String("notificationId", newNotification.ID))
+
+	return newNotification, nil
+}
+
+// RescheduleMaintenance replaces the pending maintenance reminders of a client with a new one at lastSeen + the creator maintenance interval.
+// the new reminder is scheduled before the old ones are cancelled, nothing changes when it can't be scheduled.
+// it returns the new notification ID or an empty string when no reminder was scheduled.
+func (s *NotificationService) RescheduleMaintenance(createdBy string, client ClientDto) (string, error) {
+	settings, err := getSettingsItem(s.settings, createdBy)
 
 	if err != nil {
-		notificationLogger.Error("error while storing notification", slog.String("error", err.Error()))
-		// clean up the notification
-		err = s.scheduler.DeleteSchedule(newNotification.ID, i.ClientToken)
-		notificationLogger.Error("failed to cleanup notification", slog.String("error", err.Error()))
+		notificationLogger.Error("error getting settings", slog.String("error", err.Error()))
+		return "", fmt.Errorf("error while scheduling maintenance")
+	}
 
-		return "", fmt.Errorf("error while scheduling notification")
+	if settings.MaintenanceIntervalWeeks == 0 || client.LastSeen == nil || (client.OptIn != nil && !*client.OptIn) {
+		return "", nil
 	}
 
-	notificationLogger.Info("notification created", slog.String("notificationId", newNotification.ID))
+	lastSeen, err := time.Parse(time.RFC3339, *client.LastSeen)
+
+	if err != nil {
+		return "", fmt.Errorf("invalid lastSeen error: %w", err)
+	}
+
+	date := lastSeen.Add(week * time.Duration(settings.MaintenanceIntervalWeeks))
+
+	if date.Before(time.Now()) {
+		notificationLogger.Info("skipping maintenance reminder in the past", slog.String("clientId", client.Id), slog.Time("date", date))
+		return "", nil
+	}
+	methods := client.preferredDeliveryMethods()
+
+	if len(methods) == 0 {
+		notificationLogger.Info("skipping maintenance reminder, client has no contact methods", slog.String("clientId", client.Id))
+		return "", nil
+	}
+
+	// automatic reminders are moved into the send window instead of being dropped.
+	n, err := s.scheduleNotification(createdBy, &NotificationInput{
+		Date:            date.Format(time.RFC3339),
+		ClientId:        client.Id,
+		DeliveryMethods: methods,
+	}, OutOfWindowShift, MaintenanceKind, "")
+
+	if err != nil {
+		return "", err
+	}
+	// a failure leaves the old reminders next to the new one, the next visit cancels them.
+	if err := s.cancelMaintenance(createdBy, client.Id, n.ID); err != nil {
+		return n.ID, err
+	}
+	return n.ID, nil
+}
 
-	return id, nil
+// cancelMaintenance deletes the maintenance reminders of the client that have not been sent yet, except keep.
+func (s *NotificationService) cancelMaintenance(createdBy, clientId, keep string) error {
+	items, err := s.store.GetNotificationsByClient(createdBy, clientId)
+
+	if err != nil {
+		notificationLogger.Error("error getting client notifications", slog.String("error", err.Error()))
+		return fmt.Errorf("error getting pending notifications")
+	}
+
+	for _, i := range items {
+		if i.Kind != MaintenanceKind || i.Status != NotSentStatus.String() || i.SortKey == keep {
+			continue
+		}
+		if err := s.DeleteNotification(createdBy, i.SortKey); err != nil && !errors.Is(err, ErrNotificationNotFound) {
+			return err
+		}
+		notificationLogger.Info("pending maintenance reminder cancelled", slog.String("notificationId", i.SortKey))
+	}
+	return nil
+}
+
+// DeleteClientNotifications cancels the pending schedules of the client and deletes every notification of the client. it returns the deleted ids.
+func (s *NotificationService) DeleteClientNotifications(ctx context.Context, createdBy, clientId string) ([]string, error) {
+	items, err := s.store.GetNotificationsByClient(createdBy, clientId)
+
+	if err != nil {
+		notificationLogger.Error("error getting client notifications", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error getting client notifications")
+	}
+	ids := make([]string, 0, len(items))
+
+	for _, i := range items {
+		if err := s.DeleteNotification(createdBy, i.SortKey); err != nil && !errors.Is(err, ErrNotificationNotFound) {
+			return ids, err
+		}
+		ids = append(ids, i.SortKey)
+	}
+	return ids, nil
+}
+
+// CancelClientNotifications cancels the pending schedules of the client and marks them as cancelled. it returns the cancelled ids.
+func (s *NotificationService) CancelClientNotifications(ctx context.Context, createdBy, clientId string) ([]string, error) {
+	items, err := s.store.GetNotificationsByClient(createdBy, clientId)
+
+	if err != nil {
+		notificationLogger.Error("error getting client notifications", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error getting client notifications")
+	}
+	ids := make([]string, 0, len(items))
+
+	for _, i := range items {
+		if i.Status != NotSentStatus.String() && i.RetrySchedule == "" {
+			continue
+		}
+		if err := s.scheduler.DeleteSchedule(i.SortKey, i.ClientToken); err != nil && !errors.Is(err, scheduler.ErrNotFound) {
+			notificationLogger.Error("error while removing schedule", slog.String("notificationId", i.SortKey), slog.String("error", err.Error()))
+			return ids, fmt.Errorf("failed to delete schedule")
+		}
+		if err := s.cancelRetry(&i); err != nil {
+			return ids, err
+		}
+		if err := s.SetNotificationStatus(createdBy, i.SortKey, CancelledStatus); err != nil {
+			return ids, err
+		}
+		ids = append(ids, i.SortKey)
+	}
+	return ids, nil
+}
+
+// ReassignClient moves every notification of a client to another one. pending notifications and pending retries get their schedule payload updated too.
+func (s *NotificationService) ReassignClient(ctx context.Context, createdBy, fromClientId, toClientId string) error {
+	items, err := s.store.GetNotificationsByClient(createdBy, fromClientId)
+
+	if err != nil {
+		notificationLogger.Error("error getting client notifications", slog.String("error", err.Error()))
+		return fmt.Errorf("error getting client notifications")
+	}
+
+	for _, i := range items {
+		if i.Status == NotSentStatus.String() {
+			if err := s.reassignSchedule(i.SortKey, toClientId); err != nil {
+				return err
+			}
+		}
+		if i.RetrySchedule != "" {
+			if err := s.reassignSchedule(i.RetrySchedule, toClientId); err != nil {
+				return err
+			}
+		}
+		if _, err := s.store.UpdateNotification(createdBy, i.SortKey, database.PatchNotificationItem{ClientId: toClientId}); err != nil {
+			notificationLogger.Error("error reassigning notification", slog.String("notificationId", i.SortKey), slog.String("error", err.Error()))
+			return fmt.Errorf("error reassigning notification")
+		}
+		notificationLogger.Info("notification reassigned", slog.String("notificationId", i.SortKey), slog.String("clientId", toClientId))
+	}
+	return nil
+}
+
+// reassignSchedule points the payload of a pending schedule or retry to the client. a schedule that no longer exists is ignored.
+func (s *NotificationService) reassignSchedule(id, clientId string) error {
+	sch, err := s.scheduler.GetSchedule(id)
+
+	if err != nil {
+		if errors.Is(err, scheduler.ErrNotFound) {
+			return nil
+		}
+		notificationLogger.Error("error getting schedule", slog.String("notificationId", id), slog.String("error", err.Error()))
+		return fmt.Errorf("error getting notification schedule")
+	}
+	var input Notification
+
+	if err := json.Unmarshal([]byte(sch.Payload), &input); err != nil {
+		return fmt.Errorf("error retrieving schedule payload error: %w", err)
+	}
+	input.ClientId = clientId
+	payload, err := json.Marshal(input)
+
+	if err != nil {
+		return fmt.Errorf("error setting new payload err: %w", err)
+	}
+	sch.Payload = string(payload)
+
+	if _, err := s.scheduler.UpdateSchedule(sch); err != nil {
+		notificationLogger.Error("error updating schedule", slog.String("notificationId", id), slog.String("error", err.Error()))
+		return fmt.Errorf("error updating notification schedule")
+	}
+	return nil
 }
 
 // UpdateNotification function allows you to update all notification fields except status for status use SetNotificationStatus.
@@ -229,6 +600,7 @@ func (s *NotificationService) UpdateNotification(createdBy string, name string,
 
 	if ps.ClientId != "" {
 		input.ClientId = ps.ClientId
+		patchItem.ClientId = ps.ClientId
 	}
 
 	if ps.DeliveryMethods != nil && len(ps.DeliveryMethods) != 0 {
@@ -255,6 +627,88 @@ func (s *NotificationService) UpdateNotification(createdBy string, name string,
 		patchItem.Date = t
 	}
 
+	if ps.Recurrence != nil {
+		input.Recurrence = ps.Recurrence
+	}
+
+	if ps.TemplateId != "" {
+		input.TemplateId = ps.TemplateId
+		patchItem.TemplateId = ps.TemplateId
+	}
+	if ps.Subject != "" {
+		input.Subject = ps.Subject
+		patchItem.Subject = ps.Subject
+	}
+	if ps.Message != "" {
+		input.Message = ps.Message
+		patchItem.Message = ps.Message
+	}
+	if ps.TemplateId != "" || len(ps.DeliveryMethods) != 0 || ps.Subject != "" || ps.Message != "" {
+		if err := validateContent(input.TemplateId, input.Subject, input.Message, input.DeliveryMethods); err != nil {
+			return Notification{}, err
+		}
+	}
+	if ps.TemplateId != "" || len(ps.DeliveryMethods) != 0 {
+		if err := s.checkTemplate(createdBy, input.TemplateId, input.DeliveryMethods); err != nil {
+			return Notification{}, err
+		}
+	}
+	// the client or creator time zone could have changed since the notification was scheduled.
+	input.TimeZone = s.timeZone(createdBy, input.ClientId)
+	sch.TimeZone = input.TimeZone
+	patchItem.TimeZone = input.TimeZone
+
+	// a new date or client, which can have another time zone, is checked against the send window.
+	if ps.Date != "" || ps.ClientId != "" {
+		requested := ps.Date
+		if requested == "" {
+			requested = input.Date
+		}
+		date, err := time.Parse(time.RFC3339, requested)
+
+		if err != nil {
+			return Notification{}, fmt.Errorf("unable to format notification date:  %w", err)
+		}
+		sendDate, err := s.applySendWindow(createdBy, date, input.TimeZone, "")
+
+		if err != nil {
+			if errors.Is(err, ErrOutsideSendWindow) {
+				return Notification{}, err
+			}
+			notificationLogger.Error("error applying send window", slog.String("error", err.Error()))
+			return Notification{}, fmt.Errorf("Unable to update notification")
+		}
+		input.RequestedDate = ""
+		if !sendDate.Equal(date) {
+			input.RequestedDate = requested
+		}
+		patchItem.RequestedDate = &input.RequestedDate
+		input.Date = sendDate.Format(time.RFC3339)
+		sch.Date = sendDate
+		patchItem.Date = sendDate
+	}
+
+	// recurring notifications keep schedule end and item TTL in sync with the start date.
+	if input.Recurrence != nil {
+		start, err := time.Parse(time.RFC3339, input.Date)
+
+		if err != nil {
+			return Notification{}, fmt.Errorf("unable to format notification date:  %w", err)
+		}
+		start = start.In(location(input.TimeZone))
+
+		if err := input.Recurrence.validate(start); err != nil {
+			return Notification{}, err
+		}
+		last, _ := input.Recurrence.last(start)
+		sch.Every(input.Recurrence.Interval*7, scheduleEnd(last))
+
+		patchItem.Recurrence = input.Recurrence.toItem()
+		if last != nil {
+			patchItem.TTL = last.Add(time.Hour * 24).Unix()
+		}
+	}
+
 	// new schedule payload
 	payload, err := json.Marshal(input)
 
@@ -262,19 +716,20 @@ func (s *NotificationService) UpdateNotification(createdBy string, name string,
 		return Notification{}, fmt.Errorf("error setting new payload err: %w", err)
 	}
 	sch.Payload = string(payload)
-	_, err = s.scheduler.UpdateSchedule(sch)
-
-	if err != nil {
-		return Notification{}, nil
-	}
+	entry := newOutboxEntry(OutboxUpdate, createdBy, name, input.ClientToken, sch.Target, sch.Role, sch.TimeZone, sch.Payload, sch.Date, sch.Repeat)
 
-	_, err = s.store.UpdateNotification(createdBy, name, patchItem)
+	_, err = s.store.UpdateNotificationWithOutbox(createdBy, name, patchItem, entry)
 
 	if err != nil {
 		notificationLogger.Error(err.Error())
-		// TODO: if error when updating the db -  rollback
 		return Notification{}, fmt.Errorf("DB failed to updated")
 	}
+
+	if err := s.commitOutbox(entry); err != nil {
+		// the update is stored, the relay updates the schedule later.
+		notificationLogger.Error("error updating schedule", slog.String("notificationId", name), slog.String("error", err.Error()))
+		input.SchedulePending = true
+	}
 	return input, nil
 }
 
@@ -288,29 +743,642 @@ func (s *NotificationService) SetNotificationStatus(createdBy string, id string,
 	return nil
 }
 
+// DeliveryRecord is the outcome of one send of a notification.
+type DeliveryRecord struct {
+	Status        notificationStatus
+	Retry         bool   // the send retried the failed channels of a previous one.
+	RetrySchedule string // schedule of the next retry, empty when there is none.
+	Results       []ChannelResult
+}
+
+// RecordDelivery stores the channel results of one send and the notification status, recurring notifications record the occurrence.
+// retries update the occurrence they retry. it returns the delivery records that were added.
+func (s *NotificationService) RecordDelivery(createdBy string, id string, record DeliveryRecord) ([]Delivery, error) {
+	var deliveries []model.DeliveryItem
+	var occurrenceErr error
+
+	_, err := s.modifyItem(createdBy, id, func(item *model.NotificationItem) error {
+		status := record.Status
+		occurrence := 0
+		if item.Recurrence != nil {
+			occurrence = len(item.Occurrences)
+		}
+		// channels that failed for good on an earlier attempt keep the send failed.
+		if record.Retry && status == SentStatus && hasFailedChannels(item.Deliveries, occurrence, record.Results) {
+			status = FailedStatus
+		}
+
+		switch {
+		case item.Recurrence == nil:
+			item.Status = status.String()
+		case record.Retry && occurrence > 0:
+			item.Occurrences[occurrence-1].Status = status.String()
+			if recurrenceEnded(item) {
+				item.Status = status.String()
+			}
+		default:
+			if occurrence, occurrenceErr = addOccurrence(item, status); occurrenceErr != nil {
+				return occurrenceErr
+			}
+		}
+		added := newDeliveryItems(item.Deliveries, occurrence, record.Results)
+		item.Deliveries = append(item.Deliveries, added...)
+		item.RetrySchedule = record.RetrySchedule
+		// events that arrived during the send apply to the new deliveries.
+		item.PendingEvents = applyPendingEvents(item)
+		deliveries = slices.Clone(item.Deliveries[len(item.Deliveries)-len(added):])
+		return nil
+	})
+
+	switch {
+	case errors.Is(err, database.ErrNotificationNotFound):
+		return nil, fmt.Errorf("error occurred error: %w", ErrNotificationNotFound)
+	case occurrenceErr != nil:
+		return nil, occurrenceErr
+	case err != nil:
+		notificationLogger.Error("error storing delivery", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error when recording delivery")
+	}
+	return newDeliveriesFromItems(deliveries), nil
+}
+
+// ScheduleRetry schedules a send of the channels at the date. attempt is the number of the retry send.
+// it returns the name of the schedule, creating the same retry again is not an error when the schedule exists.
+func (s *NotificationService) ScheduleRetry(n Notification, channels []int8, attempt int, at time.Time) (string, error) {
+	n.DeliveryMethods = channels
+	n.Attempt = attempt
+	n.Deliveries = nil
+	n.Occurrences = nil
+	payload, err := json.Marshal(n)
+
+	if err != nil {
+		notificationLogger.Error("error while marshalling retry payload", slog.String("error", err.Error()))
+		return "", fmt.Errorf("error while scheduling retry")
+	}
+	// retries are kept inside of the send window.
+	if shifted, err := s.applySendWindow(n.CreatedBy, at, timeZone(n.TimeZone), OutOfWindowShift); err != nil {
+		notificationLogger.Error("error applying send window to retry", slog.String("notificationId", n.ID), slog.String("error", err.Error()))
+	} else {
+		at = shifted
+	}
+	name := retryScheduleName(n.ID, attempt)
+	sch := scheduler.NewSchedule(name, os.Getenv("NOTIFICATION_LAMBDA"), os.Getenv("SCHEDULER_ROLE"), timeZone(n.TimeZone), string(payload), at)
+
+	_, err = s.scheduler.CreateSchedule(sch, retryToken(n.ClientToken, attempt))
+
+	if errors.Is(err, scheduler.ErrConflict) {
+		// the conflict is only a replay of this retry when the schedule is there.
+		if _, err = s.scheduler.GetSchedule(name); err == nil {
+			notificationLogger.Info("retry already scheduled", slog.String("notificationId", n.ID), slog.Int("attempt", attempt))
+			return name, nil
+		}
+	}
+	if err != nil {
+		notificationLogger.Error("error while creating retry schedule", slog.String("notificationId", n.ID), slog.String("error", err.Error()))
+		return "", fmt.Errorf("error while scheduling retry")
+	}
+	notificationLogger.Info("retry scheduled", slog.String("notificationId", n.ID), slog.Int("attempt", attempt), slog.Time("date", at))
+	return name, nil
+}
+
+// RetryFailedChannels schedules a send of the channels that failed on the last send of the notification, channels that succeeded are not sent again.
+func (s *NotificationService) RetryFailedChannels(createdBy, id string) (*Notification, error) {
+	item, err := s.store.GetNotification(createdBy, id)
+
+	if err != nil {
+		if errors.Is(err, database.ErrNotificationNotFound) {
+			return nil, fmt.Errorf("error occurred error: %w", ErrNotificationNotFound)
+		}
+		notificationLogger.Error("error getting notification", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error when retrying notification")
+	}
+
+	if item.RetrySchedule != "" {
+		return nil, ErrRetryPending
+	}
+	occurrence := 0
+	if item.Recurrence != nil {
+		occurrence = len(item.Occurrences)
+	}
+	channels, attempt := failedChannels(item.Deliveries, occurrence)
+
+	if len(channels) == 0 {
+		return nil, ErrNothingToRetry
+	}
+	// the scheduler needs the date to be at least 30 seconds away.
+	name, err := s.ScheduleRetry(*NewNotificationFromItem(item), channels, attempt+1, time.Now().Add(time.Minute))
+
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.modifyItem(createdBy, id, func(current *model.NotificationItem) error {
+		// a retry scheduled since the notification was read wins.
+		if current.RetrySchedule != "" && current.RetrySchedule != name {
+			return ErrRetryPending
+		}
+		current.RetrySchedule = name
+		if current.Recurrence == nil {
+			current.Status = RetryingStatus.String()
+		} else if n := len(current.Occurrences); n > 0 {
+			current.Occurrences[n-1].Status = RetryingStatus.String()
+		}
+		return nil
+	})
+
+	if err != nil {
+		notificationLogger.Error("error storing retry", slog.String("error", err.Error()))
+		if err := s.scheduler.DeleteSchedule(name, retryToken(item.ClientToken, attempt+1)); err != nil {
+			notificationLogger.Error("failed to cleanup retry schedule", slog.String("error", err.Error()))
+		}
+		if errors.Is(err, ErrRetryPending) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error when retrying notification")
+	}
+	return NewNotificationFromItem(updated), nil
+}
+
+// ChannelEvent is a change of a sent message reported by the provider.
+type ChannelEvent struct {
+	Channel   ContactOptions
+	MessageId string
+	Status    string
+	Error     string
+	At        time.Time
+	// Keep stores the event until the delivery of the message is recorded instead of failing with ErrDeliveryPending,
+	// for providers that do not send the events again.
+	Keep bool
+}
+
+func (e ChannelEvent) toItem() model.ChannelEventItem {
+	return model.ChannelEventItem{
+		Channel:   int8(e.Channel),
+		MessageId: e.MessageId,
+		Status:    e.Status,
+		Error:     e.Error,
+		At:        e.At.UTC().Format(time.RFC3339),
+	}
+}
+
+func newChannelEventFromItem(item model.ChannelEventItem) ChannelEvent {
+	// the item was stored with the RFC3339 format.
+	at, _ := time.Parse(time.RFC3339, item.At)
+	return ChannelEvent{
+		Channel:   ContactOptions(item.Channel),
+		MessageId: item.MessageId,
+		Status:    item.Status,
+		Error:     item.Error,
+		At:        at,
+	}
+}
+
+const (
+	// deliveryRecordWait is how long after an event a delivery that is not recorded yet can still be recorded by the send in flight.
+	deliveryRecordWait = time.Hour
+	// maxPendingEvents is the number of kept events of a notification, the oldest ones are dropped.
+	maxPendingEvents = 20
+)
+
+// channelProgress orders the statuses of a sent message, events never move a delivery back.
+var channelProgress = map[string]int{
+	ChannelSent:      1,
+	ChannelDelivered: 2,
+	ChannelOpened:    3,
+}
+
+// RecordChannelEvent updates the delivery of the message with the provider event, a failed message fails the send.
+// it returns nil when the event does not change the delivery, like a delivered event for a message that was opened.
+// events without status do not change the delivery, they only check the message was sent by the notification.
+// events that arrive while the notification is being sent, before its deliveries are recorded, fail with ErrDeliveryPending
+// or are kept until the deliveries are recorded.
+func (s *NotificationService) RecordChannelEvent(createdBy, id string, e ChannelEvent) (*Delivery, error) {
+	var updated model.DeliveryItem
+	kept := false
+
+	_, err := s.modifyItem(createdBy, id, func(item *model.NotificationItem) error {
+		d, err := applyChannelEvent(item, e)
+
+		if errors.Is(err, ErrDeliveryNotFound) && sendInFlight(item) && time.Since(e.At) < deliveryRecordWait {
+			if !e.Keep || e.Status == "" {
+				return ErrDeliveryPending
+			}
+			item.PendingEvents = append(item.PendingEvents, e.toItem())
+			if n := len(item.PendingEvents); n > maxPendingEvents {
+				item.PendingEvents = item.PendingEvents[n-maxPendingEvents:]
+			}
+			kept = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		updated = *d
+		return nil
+	})
+
+	switch {
+	case errors.Is(err, errNoChange), err == nil && kept:
+		return nil, nil
+	case errors.Is(err, ErrDeliveryNotFound), errors.Is(err, ErrDeliveryPending):
+		return nil, err
+	case errors.Is(err, database.ErrNotificationNotFound):
+		return nil, fmt.Errorf("error occurred error: %w", ErrNotificationNotFound)
+	case err != nil:
+		notificationLogger.Error("error storing channel event", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error when recording channel event")
+	}
+	delivery := newDeliveriesFromItems([]model.DeliveryItem{updated})[0]
+	return &delivery, nil
+}
+
+// LastDeliveryStart returns when the last message on any of the channels was handed to the provider for the client, an empty string when none was.
+func (s *NotificationService) LastDeliveryStart(createdBy, clientId string, channels ...ContactOptions) (string, error) {
+	items, err := s.store.GetNotificationsByClient(createdBy, clientId)
+
+	if err != nil {
+		notificationLogger.Error("error getting client notifications", slog.String("clientId", clientId), slog.String("error", err.Error()))
+		return "", fmt.Errorf("error getting client notifications")
+	}
+	last := ""
+
+	for _, i := range items {
+		for _, d := range i.Deliveries {
+			if slices.Contains(channels, ContactOptions(d.Channel)) && d.MessageId != "" && d.StartedAt > last {
+				last = d.StartedAt
+			}
+		}
+	}
+	return last, nil
+}
+
+// ConfirmNotification marks the last sent notification of the client as confirmed by the client, recurring notifications confirm
+// their last occurrence. it fails with ErrNothingToConfirm when the client has no sent notification waiting for confirmation.
+func (s *NotificationService) ConfirmNotification(createdBy, clientId string, at time.Time) (*Notification, error) {
+	items, err := s.store.GetNotificationsByClient(createdBy, clientId)
+
+	if err != nil {
+		notificationLogger.Error("error getting client notifications", slog.String("clientId", clientId), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error getting client notifications")
+	}
+	var last *model.NotificationItem
+	lastDate := ""
+
+	for i := range items {
+		confirmedAt, date := unconfirmedSend(&items[i])
+
+		if confirmedAt == nil {
+			continue
+		}
+		if last == nil || date > lastDate {
+			last, lastDate = &items[i], date
+		}
+	}
+
+	if last == nil {
+		return nil, ErrNothingToConfirm
+	}
+	item, err := s.modifyItem(createdBy, last.SortKey, func(item *model.NotificationItem) error {
+		confirmedAt, _ := unconfirmedSend(item)
+
+		// another confirmation or a newer occurrence got in first.
+		if confirmedAt == nil {
+			return ErrNothingToConfirm
+		}
+		*confirmedAt = at.UTC().Format(time.RFC3339)
+		return nil
+	})
+
+	if errors.Is(err, ErrNothingToConfirm) || errors.Is(err, database.ErrNotificationNotFound) {
+		return nil, ErrNothingToConfirm
+	}
+	if err != nil {
+		notificationLogger.Error("error confirming notification", slog.String("id", last.SortKey), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error confirming notification")
+	}
+	notificationLogger.Info("notification confirmed", slog.String("notificationId", item.SortKey))
+	return NewNotificationFromItem(item), nil
+}
+
+// unconfirmedSend returns the confirmation field of the last send of the item and the send date, when the send reached the client
+// and was not confirmed yet. the last send of a recurring notification is its last occurrence.
+func unconfirmedSend(item *model.NotificationItem) (*string, string) {
+	if item.Recurrence == nil {
+		if item.Status == SentStatus.String() && item.ConfirmedAt == "" {
+			return &item.ConfirmedAt, item.Date
+		}
+		return nil, ""
+	}
+	if n := len(item.Occurrences); n > 0 {
+		o := &item.Occurrences[n-1]
+
+		if o.Status == SentStatus.String() && o.ConfirmedAt == "" {
+			return &o.ConfirmedAt, o.Date
+		}
+	}
+	return nil, ""
+}
+
+// PrepareSend cancels the pending schedule of a one time notification so it can be sent now and returns its payload.
+// it returns nil when the notification is no longer pending.
+func (s *NotificationService) PrepareSend(createdBy, id string) (*Notification, error) {
+	item, err := s.store.GetNotification(createdBy, id)
+
+	if err != nil {
+		if errors.Is(err, database.ErrNotificationNotFound) {
+			return nil, fmt.Errorf("error occurred error: %w", ErrNotificationNotFound)
+		}
+		notificationLogger.Error("error getting notification", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error when sending notification")
+	}
+
+	if item.Status != NotSentStatus.String() {
+		return nil, nil
+	}
+	if item.Recurrence != nil {
+		return nil, ErrRecurringSend
+	}
+	// only the send that claims the notification delivers it.
+	if err := s.ClaimSend(createdBy, id); err != nil {
+		if errors.Is(err, ErrNotPending) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if err := s.scheduler.DeleteSchedule(id, item.ClientToken); err != nil && !errors.Is(err, scheduler.ErrNotFound) {
+		notificationLogger.Error("error while removing schedule", slog.String("notificationId", id), slog.String("error", err.Error()))
+		if _, err := s.ReleaseSend(createdBy, id); err != nil {
+			notificationLogger.Error("failed to release notification", slog.String("notificationId", id), slog.String("error", err.Error()))
+		}
+		return nil, fmt.Errorf("failed to delete schedule")
+	}
+	n := NewNotificationFromItem(item)
+	n.Status = SendingStatus.String()
+	return n, nil
+}
+
+// ClaimSend marks a pending notification as SENDING so a single send delivers it.
+// it fails with ErrNotPending when another send claimed it or it was already sent.
+func (s *NotificationService) ClaimSend(createdBy, id string) error {
+	err := s.store.ChangeStatus(createdBy, id, NotSentStatus.String(), SendingStatus.String())
+
+	switch {
+	case errors.Is(err, database.ErrStatusChanged):
+		return ErrNotPending
+	case errors.Is(err, database.ErrNotificationNotFound):
+		return fmt.Errorf("error occurred error: %w", ErrNotificationNotFound)
+	case err != nil:
+		notificationLogger.Error("error claiming notification", slog.String("notificationId", id), slog.String("error", err.Error()))
+		return fmt.Errorf("error when sending notification")
+	}
+	return nil
+}
+
+// ReleaseSend moves a notification that is still SENDING back to NOT_SENT, for sends that stopped before recording their deliveries.
+// it returns false when the send was recorded.
+func (s *NotificationService) ReleaseSend(createdBy, id string) (bool, error) {
+	err := s.store.ChangeStatus(createdBy, id, SendingStatus.String(), NotSentStatus.String())
+
+	if errors.Is(err, database.ErrStatusChanged) || errors.Is(err, database.ErrNotificationNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		notificationLogger.Error("error releasing notification", slog.String("notificationId", id), slog.String("error", err.Error()))
+		return false, fmt.Errorf("error when releasing notification")
+	}
+	return true, nil
+}
+
+// RestoreSend releases a notification whose send now failed before it was recorded and schedules it again, at its date or
+// right away when the date passed.
+func (s *NotificationService) RestoreSend(n Notification) error {
+	released, err := s.ReleaseSend(n.CreatedBy, n.ID)
+
+	if err != nil || !released {
+		return err
+	}
+	date, err := time.Parse(time.RFC3339, n.Date)
+
+	if err != nil {
+		return fmt.Errorf("invalid notification date error: %w", err)
+	}
+	// the scheduler needs the date to be at least 30 seconds away.
+	if soonest := time.Now().Add(time.Minute); date.Before(soonest) {
+		date = soonest
+	}
+	n.Status = NotSentStatus.String()
+	payload, err := json.Marshal(n)
+
+	if err != nil {
+		return fmt.Errorf("error while marshalling notification payload error: %w", err)
+	}
+	sch := scheduler.NewSchedule(n.ID, os.Getenv("NOTIFICATION_LAMBDA"), os.Getenv("SCHEDULER_ROLE"), timeZone(n.TimeZone), string(payload), date)
+
+	if _, err := s.scheduler.CreateSchedule(sch, n.ClientToken); err != nil && !errors.Is(err, scheduler.ErrConflict) {
+		notificationLogger.Error("error restoring schedule", slog.String("notificationId", n.ID), slog.String("error", err.Error()))
+		return fmt.Errorf("error while restoring schedule")
+	}
+	notificationLogger.Info("send failed, notification scheduled again", slog.String("notificationId", n.ID), slog.Time("date", date))
+	return nil
+}
+
+// PrepareResend cancels the pending retry of the notification and returns the payload that sends its failed channels again.
+// it returns nil when no channel failed on the last send.
+func (s *NotificationService) PrepareResend(createdBy, id string) (*Notification, error) {
+	item, err := s.store.GetNotification(createdBy, id)
+
+	if err != nil {
+		if errors.Is(err, database.ErrNotificationNotFound) {
+			return nil, fmt.Errorf("error occurred error: %w", ErrNotificationNotFound)
+		}
+		notificationLogger.Error("error getting notification", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error when resending notification")
+	}
+	occurrence := 0
+	if item.Recurrence != nil {
+		occurrence = len(item.Occurrences)
+	}
+	channels, attempt := failedChannels(item.Deliveries, occurrence)
+
+	if len(channels) == 0 {
+		return nil, nil
+	}
+
+	if item.RetrySchedule != "" {
+		if err := s.cancelRetry(item); err != nil {
+			return nil, err
+		}
+		cancelled := item.RetrySchedule
+		_, err := s.modifyItem(createdBy, id, func(item *model.NotificationItem) error {
+			// a newer retry was scheduled since it was read.
+			if item.RetrySchedule != cancelled {
+				return errNoChange
+			}
+			item.RetrySchedule = ""
+			return nil
+		})
+
+		if err != nil && !errors.Is(err, errNoChange) {
+			notificationLogger.Error("error clearing retry", slog.String("error", err.Error()))
+			return nil, fmt.Errorf("error when resending notification")
+		}
+	}
+	n := NewNotificationFromItem(item)
+	n.DeliveryMethods = channels
+	n.Attempt = attempt + 1
+	n.Deliveries = nil
+	n.Occurrences = nil
+	return n, nil
+}
+
+// applyChannelEvent updates the delivery of the event message and returns it. it fails with ErrDeliveryNotFound when the item
+// has no delivery of the message and with errNoChange when the event does not move the delivery forward.
+func applyChannelEvent(item *model.NotificationItem, e ChannelEvent) (*model.DeliveryItem, error) {
+	i := slices.IndexFunc(item.Deliveries, func(d model.DeliveryItem) bool {
+		return d.Channel == int8(e.Channel) && e.MessageId != "" && d.MessageId == e.MessageId
+	})
+
+	if i < 0 {
+		return nil, ErrDeliveryNotFound
+	}
+	d := &item.Deliveries[i]
+	current, ok := channelProgress[d.Status]
+
+	if !ok || e.Status == "" {
+		// failed, skipped and disabled deliveries are final.
+		return nil, errNoChange
+	}
+	if next, ok := channelProgress[e.Status]; ok && next <= current {
+		return nil, errNoChange
+	}
+	d.Status = e.Status
+	d.Error = e.Error
+	d.UpdatedAt = e.At.UTC().Format(time.RFC3339)
+
+	if e.Status == ChannelFailed {
+		switch {
+		case item.Recurrence == nil:
+			item.Status = FailedStatus.String()
+		case d.Occurrence > 0 && d.Occurrence <= len(item.Occurrences):
+			item.Occurrences[d.Occurrence-1].Status = FailedStatus.String()
+		}
+	}
+	return d, nil
+}
+
+// applyPendingEvents applies the kept events of the item to the deliveries that were recorded since and returns the events
+// still waiting for their delivery. events older than deliveryRecordWait are dropped.
+func applyPendingEvents(item *model.NotificationItem) []model.ChannelEventItem {
+	var waiting []model.ChannelEventItem
+
+	for _, p := range item.PendingEvents {
+		e := newChannelEventFromItem(p)
+
+		if _, err := applyChannelEvent(item, e); errors.Is(err, ErrDeliveryNotFound) && time.Since(e.At) < deliveryRecordWait {
+			waiting = append(waiting, p)
+		}
+	}
+	return waiting
+}
+
+// sendInFlight reports whether a send of the item can still record deliveries: one time notifications being sent or retried
+// and recurring notifications with occurrences left.
+func sendInFlight(item *model.NotificationItem) bool {
+	switch item.Status {
+	case SendingStatus.String(), RetryingStatus.String():
+		return true
+	case NotSentStatus.String():
+		return item.Recurrence != nil
+	}
+	return false
+}
+
+// modifyItem applies fn to the stored notification and stores the result when no other write changed the notification in between,
+// otherwise fn is applied again to the new notification. errors from fn are returned without storing the notification.
+func (s *NotificationService) modifyItem(createdBy, id string, fn func(item *model.NotificationItem) error) (*model.NotificationItem, error) {
+	for attempt := 1; ; attempt++ {
+		item, err := s.store.GetNotification(createdBy, id)
+
+		if err != nil {
+			return nil, err
+		}
+		if err := fn(item); err != nil {
+			return nil, err
+		}
+		updated, err := s.store.Update(*item)
+
+		if errors.Is(err, database.ErrVersionConflict) && attempt < updateRetries {
+			notificationLogger.Info("notification changed while updating, retrying", slog.String("notificationId", id), slog.Int("attempt", attempt))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &updated, nil
+	}
+}
+
+// cancelRetry deletes the pending retry schedule of the item. a schedule that no longer exists is ignored.
+func (s *NotificationService) cancelRetry(item *model.NotificationItem) error {
+	if item.RetrySchedule == "" {
+		return nil
+	}
+	attempt, _ := strconv.Atoi(strings.TrimPrefix(item.RetrySchedule, item.SortKey+"-retry-"))
+
+	if err := s.scheduler.DeleteSchedule(item.RetrySchedule, retryToken(item.ClientToken, attempt)); err != nil && !errors.Is(err, scheduler.ErrNotFound) {
+		notificationLogger.Error("error while removing retry schedule", slog.String("notificationId", item.SortKey), slog.String("error", err.Error()))
+		return fmt.Errorf("failed to delete retry schedule")
+	}
+	return nil
+}
+
+func retryScheduleName(id string, attempt int) string {
+	return fmt.Sprintf("%s-retry-%d", id, attempt)
+}
+
+// retryToken is the client token of a retry schedule. every attempt has its own token so a new retry is never taken as a replay of an older one.
+func retryToken(clientToken string, attempt int) string {
+	return fmt.Sprintf("%s-retry-%d", clientToken, attempt)
+}
+
+// addOccurrence appends the next occurrence of the recurring item and returns its number. the status changes after the last occurrence.
+func addOccurrence(item *model.NotificationItem, status notificationStatus) (int, error) {
+	recurrence := newRecurrenceFromItem(item.Recurrence)
+	start, err := time.Parse(time.RFC3339, item.Date)
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid notification date error: %w", err)
+	}
+	start = start.In(location(item.TimeZone))
+
+	n := len(item.Occurrences) + 1
+	item.Occurrences = append(item.Occurrences, model.OccurrenceItem{
+		Number: n,
+		Date:   recurrence.occurrenceDate(start, n).Format(time.RFC3339),
+		SentAt: time.Now().UTC().Format(time.RFC3339),
+		Status: status.String(),
+	})
+
+	if recurrence.isLast(start, n) {
+		item.Status = status.String()
+	}
+	return n, nil
+}
+
 // GetNotification gets notification by dynamo Key
 func (s *NotificationService) GetNotification(createdBy, name string) (*Notification, error) {
 	n, err := s.store.GetNotification(createdBy, name)
 
 	if err != nil {
 		if errors.Is(err, database.ErrNotificationNotFound) {
-			notificationLogger.Error("Notification NOT FOUND", name)
+			notificationLogger.Error("Notification NOT FOUND", slog.String("id", name))
 			return nil, fmt.Errorf("error occurred error: %w", ErrNotificationNotFound)
 		}
 		notificationLogger.Error("Error getting notification", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("error getting notification")
 	}
 
-	notification := Notification{
-		ID:              n.SortKey,
-		Status:          n.Status,
-		Date:            n.Date,
-		ClientId:        n.ClientId,
-		CreatedBy:       n.PartitionKey,
-		DeliveryMethods: n.DeliveryMethods,
-		ClientToken:     n.ClientToken,
-	}
-	return &notification, nil
+	return NewNotificationFromItem(n), nil
 }
 
 // GetNotificationsByCreator gets notification by creator
@@ -330,17 +1398,7 @@ func (s *NotificationService) GetNotificationsByCreator(createdBy string, ops *P
 	notifications := make([]Notification, 0, len(paginatedItems.Data))
 
 	for i := 0; i < len(paginatedItems.Data); i++ {
-		n := (paginatedItems.Data)[i]
-		notification := Notification{
-			ID:              n.SortKey,
-			Status:          n.Status,
-			Date:            n.Date,
-			ClientId:        n.ClientId,
-			CreatedBy:       n.PartitionKey,
-			DeliveryMethods: n.DeliveryMethods,
-			ClientToken:     n.ClientToken,
-		}
-		notifications = append(notifications, notification)
+		notifications = append(notifications, *NewNotificationFromItem(&paginatedItems.Data[i]))
 	}
 
 	return &PaginatedNotifications{
@@ -349,3 +1407,12 @@ func (s *NotificationService) GetNotificationsByCreator(createdBy string, ops *P
 		Data:  notifications,
 	}, nil
 }
+
+// scheduleEnd gives the scheduler some room after the last occurrence so it is still sent.
+func scheduleEnd(last *time.Time) *time.Time {
+	if last == nil {
+		return nil
+	}
+	end := last.Add(time.Hour)
+	return &end
+}
@@ -0,0 +1,359 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/japb1998/control-tower/internal/model"
+	"github.com/japb1998/control-tower/internal/service"
+	"github.com/japb1998/control-tower/pkg/sms"
+)
+
+func TestNotificationLifecycle(t *testing.T) {
+	s := newTestServices(t)
+	c := s.createClient(t, time.Now())
+	date := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+
+	id, err := s.notifications.ScheduleNotification(creator, &service.NotificationInput{
+		Date:            date.Format(time.RFC3339),
+		ClientId:        c.Id,
+		DeliveryMethods: []int8{int8(service.Phone)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.notifications.GetNotification(creator, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Status != service.NotSentStatus.String() || n.ClientId != c.Id {
+		t.Fatalf("unexpected notification: %+v", n)
+	}
+
+	newDate := date.Add(time.Hour * 24)
+	if _, err := s.notifications.UpdateNotification(creator, id, service.PatchNotification{Date: newDate.Format(time.RFC3339)}); err != nil {
+		t.Fatal(err)
+	}
+	page, err := s.notifications.GetNotificationsByCreator(creator, &service.PaginationOps{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || len(page.Data) != 1 {
+		t.Fatalf("expected one notification, got: %+v", page)
+	}
+	if stored, _ := time.Parse(time.RFC3339, page.Data[0].Date); !stored.Equal(newDate) {
+		t.Fatalf("expected date '%s', got: '%s'", newDate, page.Data[0].Date)
+	}
+
+	if err := s.notifications.SetNotificationStatus(creator, id, service.SentStatus); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.notifications.DeleteNotification(creator, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.notifications.GetNotification(creator, id); !errors.Is(err, service.ErrNotificationNotFound) {
+		t.Fatalf("expected ErrNotificationNotFound, got: %v", err)
+	}
+}
+
+func TestRecordDelivery(t *testing.T) {
+	s := newTestServices(t)
+	c := s.createClient(t, time.Now())
+	sent := func(messageId string) service.DeliveryRecord {
+		return service.DeliveryRecord{
+			Status:  service.SentStatus,
+			Results: []service.ChannelResult{{Channel: service.Phone, Status: service.ChannelSent, MessageId: messageId, StartedAt: time.Now(), FinishedAt: time.Now()}},
+		}
+	}
+
+	id, err := s.notifications.ScheduleNotification(creator, &service.NotificationInput{
+		Date:            time.Now().UTC().Add(time.Hour).Format(time.RFC3339),
+		ClientId:        c.Id,
+		DeliveryMethods: []int8{int8(service.Phone)},
+		Recurrence:      &service.Recurrence{Frequency: service.WeeklyFrequency, Interval: 1, Count: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := s.notifications.RecordDelivery(creator, id, sent("SM1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Occurrence != 1 || deliveries[0].MessageId != "SM1" {
+		t.Fatalf("expected the delivery of the first occurrence, got: %+v", deliveries)
+	}
+	n, err := s.notifications.GetNotification(creator, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Status != service.NotSentStatus.String() || len(n.Occurrences) != 1 {
+		t.Fatalf("expected first occurrence to keep the notification pending, got: %+v", n)
+	}
+
+	if _, err := s.notifications.RecordDelivery(creator, id, service.DeliveryRecord{Status: service.FailedStatus}); err != nil {
+		t.Fatal(err)
+	}
+	n, err = s.notifications.GetNotification(creator, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Status != service.FailedStatus.String() || len(n.Occurrences) != 2 {
+		t.Fatalf("expected last occurrence to set the status, got: %+v", n)
+	}
+
+	// one time notifications only change their status.
+	oneTime := s.scheduleFor(t, c.Id, service.Phone)
+	if _, err := s.notifications.RecordDelivery(creator, oneTime.ID, sent("SM2")); err != nil {
+		t.Fatal(err)
+	}
+	n, err = s.notifications.GetNotification(creator, oneTime.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Status != service.SentStatus.String() || len(n.Occurrences) != 0 || len(n.Deliveries) != 1 {
+		t.Fatalf("expected the one time notification to be sent, got: %+v", n)
+	}
+}
+
+func TestConfirmRecurringNotification(t *testing.T) {
+	s := newTestServices(t)
+	c := s.createClient(t, time.Now())
+
+	id, err := s.notifications.ScheduleNotification(creator, &service.NotificationInput{
+		Date:            time.Now().UTC().Add(time.Hour).Format(time.RFC3339),
+		ClientId:        c.Id,
+		DeliveryMethods: []int8{int8(service.Phone)},
+		Recurrence:      &service.Recurrence{Frequency: service.WeeklyFrequency, Interval: 1, Count: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.notifications.ConfirmNotification(creator, c.Id, time.Now()); !errors.Is(err, service.ErrNothingToConfirm) {
+		t.Fatalf("expected ErrNothingToConfirm before the first occurrence, got: %v", err)
+	}
+
+	for occurrence := 1; occurrence <= 2; occurrence++ {
+		if _, err := s.notifications.RecordDelivery(creator, id, service.DeliveryRecord{Status: service.SentStatus}); err != nil {
+			t.Fatal(err)
+		}
+		n, err := s.notifications.ConfirmNotification(creator, c.Id, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n.ID != id || len(n.Occurrences) != occurrence || n.Occurrences[occurrence-1].ConfirmedAt == "" {
+			t.Fatalf("expected occurrence %d to be confirmed, got: %+v", occurrence, n.Occurrences)
+		}
+		if _, err := s.notifications.ConfirmNotification(creator, c.Id, time.Now()); !errors.Is(err, service.ErrNothingToConfirm) {
+			t.Fatalf("expected ErrNothingToConfirm once the occurrence is confirmed, got: %v", err)
+		}
+	}
+}
+
+func TestCustomMessage(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	c := s.createClient(t, time.Now().Add(-2*week))
+
+	input := service.NotificationInput{
+		Date:            time.Now().Add(time.Hour).Format(time.RFC3339),
+		ClientId:        c.Id,
+		DeliveryMethods: []int8{int8(service.SMS)},
+		Subject:         "See you soon",
+		Message:         "Hi {{firstName}}, your touch up is {{appointmentTime}}",
+	}
+	invalid := map[string]func(in *service.NotificationInput){
+		"subject without email": func(in *service.NotificationInput) {},
+		"template and message":  func(in *service.NotificationInput) { in.Subject = ""; in.TemplateId = uuid.New().String() },
+		"unknown variable":      func(in *service.NotificationInput) { in.Subject = ""; in.Message = "Hi {{nickname}}" },
+		"too many segments": func(in *service.NotificationInput) {
+			in.Subject = ""
+			in.Message = strings.Repeat("á", 67*sms.MaxSegments+1)
+		},
+		"whatsapp message": func(in *service.NotificationInput) {
+			in.Subject = ""
+			in.DeliveryMethods = []int8{int8(service.SMS), int8(service.Phone)}
+		},
+	}
+	for name, modify := range invalid {
+		in := input
+		modify(&in)
+		if _, err := s.notifications.ScheduleNotification(creator, &in); !errors.Is(err, service.ErrInvalidMessage) {
+			t.Errorf("%s: expected ErrInvalidMessage, got: %v", name, err)
+		}
+	}
+
+	input.DeliveryMethods = []int8{int8(service.SMS), int8(service.Email)}
+	id, err := s.notifications.ScheduleNotification(creator, &input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sch, err := s.scheduler.GetSchedule(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload service.Notification
+	if err := json.Unmarshal([]byte(sch.Payload), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Subject != input.Subject || payload.Message != input.Message {
+		t.Fatalf("expected the message in the schedule payload, got: %+v", payload)
+	}
+
+	if _, err := s.notifications.UpdateNotification(creator, id, service.PatchNotification{TemplateId: uuid.New().String()}); !errors.Is(err, service.ErrInvalidMessage) {
+		t.Errorf("expected ErrInvalidMessage, got: %v", err)
+	}
+	updated, err := s.notifications.UpdateNotification(creator, id, service.PatchNotification{Message: "Hi {{firstName}}, it's been {{weeks}} weeks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Message != "Hi {{firstName}}, it's been {{weeks}} weeks" || updated.Subject != input.Subject {
+		t.Fatalf("unexpected updated notification: %+v", updated)
+	}
+
+	text := &fakeNotifier{channel: service.SMS, messageId: "SM1"}
+	email := &fakeNotifier{channel: service.Email, messageId: "m1"}
+	notifiers := service.NewNotifierRegistry(text, email)
+	messages := s.messageService(notifiers, nil)
+	d := s.deliveryService(notifiers).WithMessages(messages)
+
+	event, err := s.notifications.GetNotification(creator, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Deliver(ctx, *event); err != nil {
+		t.Fatal(err)
+	}
+	if len(email.reminders) != 1 || email.reminders[0].Subject != input.Subject {
+		t.Fatalf("expected the custom subject, got: %+v", email.reminders)
+	}
+	thread, err := messages.GetMessages(ctx, creator, c.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thread) != 2 {
+		t.Fatalf("expected 2 messages, got: %+v", thread)
+	}
+	// the message replaces the default reminder in every channel.
+	for _, m := range thread {
+		if m.Body != "Hi Ana, it's been 2 weeks" {
+			t.Errorf("channel %d: unexpected body: %s", m.Channel, m.Body)
+		}
+	}
+}
+
+func TestNotificationTimeZone(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	settings := service.NewSettingsService(s.settings)
+	weeks := 0
+
+	if _, err := settings.SaveSettings(ctx, creator, service.PutSettings{MaintenanceIntervalWeeks: &weeks, TimeZone: "Central"}); !errors.Is(err, service.ErrInvalidTimeZone) {
+		t.Fatalf("expected ErrInvalidTimeZone, got: %v", err)
+	}
+	c := s.createClient(t, time.Now())
+	if _, err := s.clients.UpdateUser(ctx, creator, c.Id, service.PatchClient{TimeZone: "Local"}); !errors.Is(err, service.ErrInvalidTimeZone) {
+		t.Fatalf("expected ErrInvalidTimeZone, got: %v", err)
+	}
+
+	schedule := func(date time.Time) (string, string) {
+		t.Helper()
+		id, err := s.notifications.ScheduleNotification(creator, &service.NotificationInput{
+			Date:            date.Format(time.RFC3339),
+			ClientId:        c.Id,
+			DeliveryMethods: []int8{int8(service.SMS)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sch, err := s.scheduler.GetSchedule(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id, sch.TimeZone
+	}
+	date := time.Date(time.Now().Year()+1, time.March, 1, 7, 30, 0, 0, time.UTC)
+	if _, tz := schedule(date); tz != "America/New_York" {
+		t.Errorf("expected the default time zone, got: %s", tz)
+	}
+
+	if _, err := settings.SaveSettings(ctx, creator, service.PutSettings{MaintenanceIntervalWeeks: &weeks, TimeZone: "America/Chicago"}); err != nil {
+		t.Fatal(err)
+	}
+	id, tz := schedule(date)
+	if tz != "America/Chicago" {
+		t.Errorf("expected the creator time zone, got: %s", tz)
+	}
+	n, err := s.notifications.GetNotification(creator, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.TimeZone != "America/Chicago" {
+		t.Errorf("expected the time zone to be stored, got: %+v", n)
+	}
+
+	if _, err := s.clients.UpdateUser(ctx, creator, c.Id, service.PatchClient{TimeZone: "Europe/Madrid"}); err != nil {
+		t.Fatal(err)
+	}
+	newDate := date.AddDate(0, 0, 30)
+	updated, err := s.notifications.UpdateNotification(creator, id, service.PatchNotification{Date: newDate.Format(time.RFC3339)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sch, err := s.scheduler.GetSchedule(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.TimeZone != "Europe/Madrid" || sch.TimeZone != "Europe/Madrid" || !sch.Date.Equal(newDate) {
+		t.Errorf("expected the schedule in the client time zone at '%s', got: %+v", newDate, sch)
+	}
+}
+
+func TestRecordChannelEventConcurrent(t *testing.T) {
+	s := newTestServices(t)
+	c := s.createClient(t, time.Now())
+	now := time.Now().UTC()
+
+	item := model.NewNotificationItem(creator, service.SentStatus.String(), c.Id, now, []int8{int8(service.Email), int8(service.SMS)})
+	for i, channel := range []service.ContactOptions{service.Email, service.SMS} {
+		item.Deliveries = append(item.Deliveries, model.DeliveryItem{Channel: int8(channel), Status: service.ChannelSent, MessageId: fmt.Sprintf("msg-%d", i), Attempt: 1})
+	}
+	if err := s.store.Create(*item); err != nil {
+		t.Fatal(err)
+	}
+
+	// events of different channels arriving together are all stored.
+	var wg sync.WaitGroup
+	for i, channel := range []service.ContactOptions{service.Email, service.SMS} {
+		wg.Add(1)
+		go func(i int, channel service.ContactOptions) {
+			defer wg.Done()
+			e := service.ChannelEvent{Channel: channel, MessageId: fmt.Sprintf("msg-%d", i), Status: service.ChannelDelivered, At: now}
+			if _, err := s.notifications.RecordChannelEvent(creator, item.SortKey, e); err != nil {
+				t.Error(err)
+			}
+		}(i, channel)
+	}
+	wg.Wait()
+
+	stored, err := s.store.GetNotification(creator, item.SortKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range stored.Deliveries {
+		if d.Status != service.ChannelDelivered {
+			t.Fatalf("expected every delivery to be delivered, got: %+v", stored.Deliveries)
+		}
+	}
+	if stored.Version != 2 {
+		t.Fatalf("expected one write per event, got version: %d", stored.Version)
+	}
+}
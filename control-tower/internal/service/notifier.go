@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/japb1998/control-tower/pkg/email"
+	"github.com/japb1998/control-tower/pkg/sms"
+)
+
+// channel results
+const (
+	ChannelSent     = "sent"
+	ChannelFailed   = "failed"
+	ChannelSkipped  = "skipped"  // the client has no contact for the channel.
+	ChannelDisabled = "disabled" // the creator disabled the channel.
+
+	// reported by the providers after the send.
+	ChannelDelivered = "delivered"
+	ChannelOpened    = "opened" // emails opened and whatsapp messages read.
+)
+
+var (
+	// ErrNoContact is returned by notifiers when the client can't be reached through their channel.
+	ErrNoContact          = errors.New("client has no contact for the channel")
+	ErrUnsupportedChannel = errors.New("unsupported delivery channel")
+)
+
+// Reminder is what a notifier sends to the client.
+type Reminder struct {
+	NotificationId string
+	CreatedBy      string
+	Client         ClientDto
+	Values         TemplateValues
+	Template       *Template // template of the notification, the notifiers send their default on the other channels.
+	Subject        string    // custom subject of the email.
+	Message        string    // custom message, it replaces the template in every channel but whatsapp.
+}
+
+// template returns the template of the notification when it is for the channel of the default, in the language of the client.
+// templates without a variant for the language are sent in their own language.
+// the custom message is sent as the body without provider template. whatsapp keeps its approved template, free form messages are
+// rejected outside of a conversation.
+func (r Reminder) template(defaultTemplate Template) Template {
+	t := defaultTemplate
+	if r.Template != nil && r.Template.Channel == defaultTemplate.Channel {
+		t = *r.Template
+	}
+	t = t.localize(r.Values.Language)
+
+	if r.Message != "" && t.Channel != Phone {
+		t.Body = r.Message
+		t.ProviderTemplateId = ""
+		t.Variants = nil
+	}
+	if r.Subject != "" && t.Channel == Email {
+		t.Subject = r.Subject
+	}
+	return t
+}
+
+// metadata is sent with the message and returned by the provider webhooks to find the notification of the message.
+func (r Reminder) metadata() map[string]string {
+	return map[string]string{
+		notificationIdVariable: r.NotificationId,
+		createdByVariable:      r.CreatedBy,
+	}
+}
+
+// Notifier sends reminders through one channel. Notify returns the provider message id.
+type Notifier interface {
+	Channel() ContactOptions
+	Notify(ctx context.Context, r Reminder) (string, error)
+}
+
+// DefaultTemplater is implemented by the notifiers that send a default template when the notification has none for their channel.
+type DefaultTemplater interface {
+	DefaultTemplate() Template
+}
+
+// ChannelResult is the outcome of a reminder in one channel.
+type ChannelResult struct {
+	Channel    ContactOptions `json:"channel"`
+	Status     string         `json:"status"`
+	MessageId  string         `json:"messageId,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	StartedAt  time.Time      `json:"startedAt"`
+	FinishedAt time.Time      `json:"finishedAt"`
+	err        error          // notifier error used to decide if the channel is retried.
+}
+
+// NotifierRegistry keeps one notifier and retry policy per channel.
+type NotifierRegistry struct {
+	notifiers map[ContactOptions]Notifier
+	policies  map[ContactOptions]RetryPolicy
+}
+
+func NewNotifierRegistry(notifiers ...Notifier) *NotifierRegistry {
+	r := &NotifierRegistry{
+		notifiers: make(map[ContactOptions]Notifier),
+		policies:  make(map[ContactOptions]RetryPolicy),
+	}
+	for _, n := range notifiers {
+		r.Register(n)
+	}
+	return r
+}
+
+// Register adds the notifier, a notifier registered for the same channel is replaced.
+func (r *NotifierRegistry) Register(n Notifier) {
+	r.notifiers[n.Channel()] = n
+}
+
+func (r *NotifierRegistry) Get(channel ContactOptions) (Notifier, bool) {
+	n, ok := r.notifiers[channel]
+	return n, ok
+}
+
+// WithRetryPolicy sets how failed deliveries of the channel are retried, channels without a policy are not retried.
+func (r *NotifierRegistry) WithRetryPolicy(channel ContactOptions, p RetryPolicy) *NotifierRegistry {
+	r.policies[channel] = p
+	return r
+}
+
+func (r *NotifierRegistry) RetryPolicy(channel ContactOptions) RetryPolicy {
+	return r.policies[channel]
+}
+
+// Channels returns the registered channels in order.
+func (r *NotifierRegistry) Channels() []ContactOptions {
+	channels := make([]ContactOptions, 0, len(r.notifiers))
+	for c := range r.notifiers {
+		channels = append(channels, c)
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i] < channels[j] })
+	return channels
+}
+
+const (
+	emailFrom    = "no-reply@lashroombyeli.me"
+	replySubject = "Lash Room" // replies sent without subject.
+)
+
+// default reminders of the channels.
+const (
+	defaultEmailTemplateId = "lashroom"
+	defaultEmailSubject    = "Lash Room - {{weeks}} Weeks Maintenance Reminder"
+	// the whatsapp content template numbers firstName as 1 and weeks as 2.
+	defaultReminderBody = "Hi {{firstName}}! It's been {{weeks}} weeks since your last visit to Lash Room, it's time for your maintenance appointment."
+	// twilio handles the STOP replies.
+	defaultTextBody = defaultReminderBody + " Reply STOP to opt out."
+
+	// spanish variants, the emails are sent as plain text since the mailgun template is in english.
+	spanishEmailSubject = "Lash Room - Recordatorio de mantenimiento de {{weeks}} semanas"
+	spanishReminderBody = "¡Hola {{firstName}}! Han pasado {{weeks}} semanas desde tu última visita a Lash Room, es hora de tu cita de mantenimiento."
+	spanishEmailBody    = spanishReminderBody + "\n\nSi no quieres recibir más recordatorios visita {{optOutUrl}}"
+	spanishTextBody     = spanishReminderBody + " Responde BAJA para no recibir más mensajes."
+)
+
+// defaultTextTemplate is the plain text reminder of the channel.
+func defaultTextTemplate(channel ContactOptions) Template {
+	return Template{
+		Channel:  channel,
+		Language: English,
+		Body:     defaultTextBody,
+		Variants: map[string]TemplateVariant{
+			Spanish: {Body: spanishTextBody},
+		},
+	}
+}
+
+// EmailNotifier sends the reminder template, the mailgun "lashroom" template by default.
+type EmailNotifier struct {
+	emailSvc *email.EmailService
+	apiUrl   string // used to build the opt out url
+	replyTo  string // address the client answers go to.
+	template Template
+}
+
+func NewEmailNotifier(emailSvc *email.EmailService, apiUrl string) *EmailNotifier {
+	return &EmailNotifier{
+		emailSvc: emailSvc,
+		apiUrl:   apiUrl,
+		template: Template{
+			Channel:            Email,
+			Subject:            defaultEmailSubject,
+			Body:               defaultReminderBody,
+			ProviderTemplateId: defaultEmailTemplateId,
+			Language:           English,
+			Variants: map[string]TemplateVariant{
+				Spanish: {Subject: spanishEmailSubject, Body: spanishEmailBody},
+			},
+		},
+	}
+}
+
+func (n *EmailNotifier) DefaultTemplate() Template {
+	return n.template
+}
+
+// WithReplyTo sets the address the client answers to the emails go to.
+func (n *EmailNotifier) WithReplyTo(address string) *EmailNotifier {
+	n.replyTo = address
+	return n
+}
+
+func (n *EmailNotifier) Channel() ContactOptions {
+	return Email
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, r Reminder) (string, error) {
+	if r.Client.Email == "" {
+		return "", ErrNoContact
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+
+	values := r.Values
+	if values.OptOutUrl == "" {
+		values.OptOutUrl = optOutUrl(n.apiUrl, r.Client.CreatedBy, r.Client.Id)
+	}
+	t := r.template(n.template)
+	rendered := t.Render(values)
+
+	var message *email.Email
+	if t.ProviderTemplateId != "" {
+		tempVars := map[string]any{
+			// used by the lashroom template.
+			"customer_name": values.ClientName,
+			"op_out_url":    values.OptOutUrl,
+		}
+		for name, value := range values.variables() {
+			tempVars[name] = value
+		}
+		message = email.NewEmail(t.ProviderTemplateId, "", rendered.Subject, emailFrom, &tempVars, []string{r.Client.Email}, nil)
+	} else {
+		message = email.NewEmail("", rendered.Body, rendered.Subject, emailFrom, nil, []string{r.Client.Email}, nil)
+	}
+	message.UserVariables = r.metadata()
+	message.ReplyTo = n.replyTo
+	return n.emailSvc.Send(ctx, message)
+}
+
+// Reply sends the body as a plain email.
+func (n *EmailNotifier) Reply(ctx context.Context, client ClientDto, subject, body string) (string, error) {
+	if client.Email == "" {
+		return "", ErrNoContact
+	}
+	if subject == "" {
+		subject = replySubject
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+
+	message := email.NewEmail("", body, subject, emailFrom, nil, []string{client.Email}, nil)
+	message.ReplyTo = n.replyTo
+	return n.emailSvc.Send(ctx, message)
+}
+
+// WhatsappNotifier sends the twilio whatsapp content template.
+// templates without a content template are sent as free form messages, twilio only delivers them within 24 hours of the last client message.
+// clients get the english content template when there is none for their language.
+type WhatsappNotifier struct {
+	msgSvc   *sms.MsgSvc
+	template Template
+}
+
+func NewWhatsappNotifier(msgSvc *sms.MsgSvc, templateId string) *WhatsappNotifier {
+	return &WhatsappNotifier{
+		msgSvc: msgSvc,
+		template: Template{
+			Channel:            Phone,
+			Language:           English,
+			Body:               defaultReminderBody,
+			ProviderTemplateId: templateId,
+		},
+	}
+}
+
+// WithLocalizedTemplate sets the content template sent to the clients of the language, empty ids are ignored.
+// the content template must number firstName as 1 and weeks as 2 like the default one.
+func (n *WhatsappNotifier) WithLocalizedTemplate(language, templateId string) *WhatsappNotifier {
+	if templateId == "" {
+		return n
+	}
+	if n.template.Variants == nil {
+		n.template.Variants = make(map[string]TemplateVariant)
+	}
+	body := defaultReminderBody
+	if language == Spanish {
+		body = spanishReminderBody
+	}
+	n.template.Variants[language] = TemplateVariant{Body: body, ProviderTemplateId: templateId}
+	return n
+}
+
+func (n *WhatsappNotifier) DefaultTemplate() Template {
+	return n.template
+}
+
+func (n *WhatsappNotifier) Channel() ContactOptions {
+	return Phone
+}
+
+func (n *WhatsappNotifier) Notify(ctx context.Context, r Reminder) (string, error) {
+	if r.Client.Phone == "" {
+		return "", ErrNoContact
+	}
+	t := r.template(n.template)
+
+	if t.ProviderTemplateId == "" {
+		return n.msgSvc.SendWhatsappText(&sms.TextMsg{
+			To:       r.Client.Phone,
+			Body:     t.Render(r.Values).Body,
+			Metadata: r.metadata(),
+		})
+	}
+	templateVariables, err := json.Marshal(t.contentVariables(r.Values))
+	if err != nil {
+		return "", err
+	}
+
+	return n.msgSvc.SendMessage(&sms.Msg{
+		To:                r.Client.Phone,
+		TemplateVariables: templateVariables,
+		TemplateId:        t.ProviderTemplateId,
+		Metadata:          r.metadata(),
+	})
+}
+
+// Reply sends the body as a free form whatsapp message.
+func (n *WhatsappNotifier) Reply(ctx context.Context, client ClientDto, subject, body string) (string, error) {
+	if client.Phone == "" {
+		return "", ErrNoContact
+	}
+	return n.msgSvc.SendWhatsappText(&sms.TextMsg{
+		To:   client.Phone,
+		Body: body,
+	})
+}
+
+// SMSNotifier sends the reminder as a plain text message for clients that don't use whatsapp.
+type SMSNotifier struct {
+	msgSvc   *sms.MsgSvc
+	template Template
+}
+
+func NewSMSNotifier(msgSvc *sms.MsgSvc) *SMSNotifier {
+	return &SMSNotifier{
+		msgSvc:   msgSvc,
+		template: defaultTextTemplate(SMS),
+	}
+}
+
+func (n *SMSNotifier) DefaultTemplate() Template {
+	return n.template
+}
+
+func (n *SMSNotifier) Channel() ContactOptions {
+	return SMS
+}
+
+func (n *SMSNotifier) Notify(ctx context.Context, r Reminder) (string, error) {
+	if r.Client.Phone == "" {
+		return "", ErrNoContact
+	}
+	body := r.template(n.template).Render(r.Values).Body
+	segments, encoding := sms.Segments(body)
+	deliveryLogger.Info("sending text message", slog.Int("segments", segments), slog.String("encoding", encoding))
+
+	return n.msgSvc.SendText(&sms.TextMsg{
+		To:       r.Client.Phone,
+		Body:     body,
+		Metadata: r.metadata(),
+	})
+}
+
+// Reply sends the body as a plain text message.
+func (n *SMSNotifier) Reply(ctx context.Context, client ClientDto, subject, body string) (string, error) {
+	if client.Phone == "" {
+		return "", ErrNoContact
+	}
+	return n.msgSvc.SendText(&sms.TextMsg{
+		To:   client.Phone,
+		Body: body,
+	})
+}
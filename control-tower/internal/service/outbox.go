@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/database"
+	"github.com/japb1998/control-tower/internal/model"
+	"github.com/japb1998/control-tower/internal/scheduler"
+)
+
+// scheduler operations kept in the outbox.
+const (
+	OutboxCreate = "create"
+	OutboxUpdate = "update"
+)
+
+// relayBatch is the number of outbox entries applied by each relay run.
+const relayBatch = 100
+
+// RelayReport counts the outbox entries of a relay run.
+type RelayReport struct {
+	Applied int `json:"applied"`
+	Dropped int `json:"dropped"` // entries that can never be applied, the reconciler reports what they leave behind.
+	Failed  int `json:"failed"`  // kept for the next run.
+}
+
+// newOutboxEntry returns the entry that creates or replaces the schedule of the notification.
+func newOutboxEntry(operation, createdBy, id, clientToken, target, role, tz, payload string, date time.Time, repeat *scheduler.Repeat) model.OutboxItem {
+	entry := model.NewOutboxItem(operation, createdBy, id, clientToken)
+	entry.Target = target
+	entry.Role = role
+	entry.TimeZone = tz
+	entry.Payload = payload
+	entry.Date = date.Format(time.RFC3339)
+
+	if repeat != nil {
+		entry.EveryDays = repeat.EveryDays
+
+		if repeat.End != nil {
+			entry.End = repeat.End.Format(time.RFC3339)
+		}
+	}
+	return *entry
+}
+
+// applyOutbox runs the scheduler operation of the entry. it can run more than once, creates use the notification client token
+// and a schedule that already exists is taken as created.
+func (s *NotificationService) applyOutbox(entry model.OutboxItem) error {
+	date, err := time.Parse(time.RFC3339, entry.Date)
+
+	if err != nil {
+		return fmt.Errorf("invalid outbox date error: %w", scheduler.ErrInvalidDate)
+	}
+	sch := scheduler.NewSchedule(entry.Schedule, entry.Target, entry.Role, entry.TimeZone, entry.Payload, date)
+
+	if entry.EveryDays > 0 {
+		var end *time.Time
+
+		if entry.End != "" {
+			t, err := time.Parse(time.RFC3339, entry.End)
+
+			if err != nil {
+				return fmt.Errorf("invalid outbox end date error: %w", scheduler.ErrInvalidDate)
+			}
+			end = &t
+		}
+		sch.Every(entry.EveryDays, end)
+	}
+
+	switch entry.Operation {
+	case OutboxCreate:
+		_, err = s.scheduler.CreateSchedule(sch, entry.ClientToken)
+
+		if errors.Is(err, scheduler.ErrConflict) {
+			return nil
+		}
+	case OutboxUpdate:
+		_, err = s.scheduler.UpdateSchedule(sch)
+	default:
+		err = fmt.Errorf("unknown outbox operation='%s'", entry.Operation)
+	}
+	return err
+}
+
+// commitOutbox applies the entry right after it was stored and removes it. a failure to remove it is only logged,
+// the relay applies it again.
+func (s *NotificationService) commitOutbox(entry model.OutboxItem) error {
+	if err := s.applyOutbox(entry); err != nil {
+		return err
+	}
+	if err := s.store.DeleteOutbox(entry.Id); err != nil {
+		notificationLogger.Error("error removing outbox entry", slog.String("id", entry.Id), slog.String("error", err.Error()))
+	}
+	return nil
+}
+
+// permanent reports if the scheduler operation can never succeed.
+func permanent(err error) bool {
+	return errors.Is(err, scheduler.ErrInvalidDate) || errors.Is(err, scheduler.ErrInvalidTZ)
+}
+
+// RelayOutbox applies the pending outbox entries in the order they were written. entries of notifications that were deleted since,
+// entries a newer write of the notification replaced and the ones that can never be applied are dropped, the rest are kept for the
+// next run when they fail.
+func (s *NotificationService) RelayOutbox(ctx context.Context) (*RelayReport, error) {
+	entries, err := s.store.GetOutbox(relayBatch)
+
+	if err != nil {
+		notificationLogger.Error("error getting outbox", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error getting outbox: %w", err)
+	}
+	report := &RelayReport{}
+	// later operations on a schedule wait until the failed one is applied.
+	failed := make(map[string]bool)
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if failed[entry.Schedule] {
+			report.Failed++
+			continue
+		}
+		item, err := s.store.GetNotification(entry.CreatedBy, entry.NotificationId)
+
+		if errors.Is(err, database.ErrNotificationNotFound) {
+			s.dropOutbox(entry, "notification does not exist", report)
+			continue
+		}
+		if err == nil {
+			if detail, stale := staleOutbox(item, entry); stale {
+				s.dropOutbox(entry, detail, report)
+				continue
+			}
+			err = s.applyOutbox(entry)
+		}
+
+		if err != nil {
+			if permanent(err) {
+				s.dropOutbox(entry, err.Error(), report)
+				continue
+			}
+			notificationLogger.Error("error applying outbox entry", slog.String("id", entry.Id), slog.String("notificationId", entry.NotificationId), slog.String("error", err.Error()))
+			failed[entry.Schedule] = true
+			report.Failed++
+
+			if err := s.store.RecordOutboxAttempt(entry.Id, err.Error()); err != nil {
+				notificationLogger.Error("error recording outbox attempt", slog.String("id", entry.Id), slog.String("error", err.Error()))
+			}
+			continue
+		}
+		if err := s.store.DeleteOutbox(entry.Id); err != nil {
+			notificationLogger.Error("error removing outbox entry", slog.String("id", entry.Id), slog.String("error", err.Error()))
+		}
+		report.Applied++
+	}
+	notificationLogger.Info("relayed outbox", slog.Int("applied", report.Applied), slog.Int("dropped", report.Dropped), slog.Int("failed", report.Failed))
+	return report, nil
+}
+
+// staleOutbox reports if the entry no longer describes the schedule of the notification. a newer write changed the notification
+// and applied its own schedule, applying the entry would roll the schedule back.
+func staleOutbox(item *model.NotificationItem, entry model.OutboxItem) (string, bool) {
+	date, err := time.Parse(time.RFC3339, entry.Date)
+
+	if err != nil {
+		return "", false
+	}
+	var payload Notification
+
+	if err := json.Unmarshal([]byte(entry.Payload), &payload); err != nil {
+		return "", false
+	}
+	if issue, ok := compareSchedule(item, date, entry.TimeZone, payload); ok {
+		return "replaced by a newer write, " + issue.Detail, true
+	}
+	return "", false
+}
+
+func (s *NotificationService) dropOutbox(entry model.OutboxItem, reason string, report *RelayReport) {
+	notificationLogger.Error("dropping outbox entry", slog.String("id", entry.Id), slog.String("notificationId", entry.NotificationId), slog.String("reason", reason))
+
+	if err := s.store.DeleteOutbox(entry.Id); err != nil {
+		notificationLogger.Error("error removing outbox entry", slog.String("id", entry.Id), slog.String("error", err.Error()))
+		report.Failed++
+		return
+	}
+	report.Dropped++
+}
@@ -0,0 +1,221 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/database"
+	"github.com/japb1998/control-tower/internal/model"
+	"github.com/japb1998/control-tower/internal/service"
+)
+
+func TestNotificationOutbox(t *testing.T) {
+	s := newTestServices(t)
+	c := s.createClient(t, time.Now())
+	input := func(date time.Time) *service.NotificationInput {
+		return &service.NotificationInput{
+			Date:            date.Format(time.RFC3339),
+			ClientId:        c.Id,
+			DeliveryMethods: []int8{int8(service.SMS)},
+		}
+	}
+	outbox := func() []model.OutboxItem {
+		t.Helper()
+		entries, err := s.store.GetOutbox(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return entries
+	}
+
+	// the notification is removed when its schedule can't be created.
+	if _, err := s.notifications.ScheduleNotification(creator, input(time.Now().Add(-time.Hour))); !errors.Is(err, service.ErrInvalidDate) {
+		t.Fatalf("expected ErrInvalidDate, got: %v", err)
+	}
+	page, err := s.store.GetNotificationsByCreator(creator, &database.PaginationOps{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 0 || len(outbox()) != 0 {
+		t.Fatalf("expected the failed notification to be removed, got: %+v, %+v", page.Data, outbox())
+	}
+
+	id, err := s.notifications.ScheduleNotification(creator, input(time.Now().Add(time.Hour*24)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.scheduler.GetSchedule(id); err != nil || len(outbox()) != 0 {
+		t.Fatalf("expected the schedule to be created and the entry removed, got: %v, %+v", err, outbox())
+	}
+
+	date := time.Now().Add(time.Hour * 48).Truncate(time.Second)
+	if _, err := s.notifications.UpdateNotification(creator, id, service.PatchNotification{Date: date.Format(time.RFC3339)}); err != nil {
+		t.Fatal(err)
+	}
+	sch, err := s.scheduler.GetSchedule(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sch.Date.Equal(date) || len(outbox()) != 0 {
+		t.Fatalf("expected the schedule to be updated and the entry removed, got: %s, %+v", sch.Date, outbox())
+	}
+}
+
+func TestRelayOutbox(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	c := s.createClient(t, time.Now())
+	date := time.Now().Add(time.Hour * 24)
+
+	// notifications stored before the process stopped, their schedules were never created.
+	store := func(date time.Time) (*model.NotificationItem, *model.OutboxItem) {
+		t.Helper()
+		item := model.NewNotificationItem(creator, service.NotSentStatus.String(), c.Id, date, []int8{int8(service.SMS)})
+		entry := model.NewOutboxItem(service.OutboxCreate, creator, item.SortKey, item.ClientToken)
+		entry.TimeZone = "America/New_York"
+		entry.Date = item.Date
+		b, err := json.Marshal(service.NewNotificationFromItem(item))
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry.Payload = string(b)
+
+		if err := s.store.CreateWithOutbox(*item, *entry); err != nil {
+			t.Fatal(err)
+		}
+		return item, entry
+	}
+	pending, entry := store(date)
+	deleted, _ := store(date)
+	if err := s.store.Delete(creator, deleted.SortKey); err != nil {
+		t.Fatal(err)
+	}
+	store(time.Now().Add(-time.Hour))
+
+	reconciled, err := s.notifications.Reconcile(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reconciled.Pending != 3 || len(reconciled.Issues) != 0 {
+		t.Fatalf("expected the pending entries to be skipped, got: %+v", reconciled)
+	}
+
+	report, err := s.notifications.RelayOutbox(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Applied != 1 || report.Dropped != 2 || report.Failed != 0 {
+		t.Fatalf("unexpected relay report: %+v", report)
+	}
+	if _, err := s.scheduler.GetSchedule(pending.SortKey); err != nil {
+		t.Fatalf("expected the schedule to be created, got: %v", err)
+	}
+	if entries, _ := s.store.GetOutbox(0); len(entries) != 0 {
+		t.Fatalf("expected the outbox to be empty, got: %+v", entries)
+	}
+
+	// applying an entry again, like when removing it failed, is a no-op.
+	if err := s.store.CreateWithOutbox(*pending, *entry); err != nil {
+		t.Fatal(err)
+	}
+	if report, err := s.notifications.RelayOutbox(ctx); err != nil || report.Applied != 1 {
+		t.Fatalf("expected the entry to be applied again, got: %+v, %v", report, err)
+	}
+}
+
+func TestRelayOutboxStaleEntry(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	c := s.createClient(t, time.Now())
+
+	id, err := s.notifications.ScheduleNotification(creator, &service.NotificationInput{
+		Date:            time.Now().Add(time.Hour * 24).Format(time.RFC3339),
+		ClientId:        c.Id,
+		DeliveryMethods: []int8{int8(service.SMS)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an update was stored but its schedule change failed.
+	item, err := s.store.GetNotification(creator, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item.Date = time.Now().Add(time.Hour * 48).UTC().Truncate(time.Second).Format(time.RFC3339)
+	b, err := json.Marshal(service.NewNotificationFromItem(item))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := model.NewOutboxItem(service.OutboxUpdate, creator, id, item.ClientToken)
+	entry.Schedule = id
+	entry.TimeZone = "America/New_York"
+	entry.Date = item.Date
+	entry.Payload = string(b)
+
+	if err := s.store.CreateWithOutbox(*item, *entry); err != nil {
+		t.Fatal(err)
+	}
+	// a newer update changed the schedule itself.
+	date := time.Now().Add(time.Hour * 72).Truncate(time.Second)
+	n, err := s.notifications.UpdateNotification(creator, id, service.PatchNotification{Date: date.Format(time.RFC3339)})
+	if err != nil || n.SchedulePending {
+		t.Fatalf("expected the schedule to be updated, got: %+v, %v", n, err)
+	}
+
+	report, err := s.notifications.RelayOutbox(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Applied != 0 || report.Dropped != 1 {
+		t.Fatalf("expected the older entry to be dropped, got: %+v", report)
+	}
+	sch, err := s.scheduler.GetSchedule(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sch.Date.Equal(date) {
+		t.Fatalf("expected the schedule to keep the newer date, got: %s", sch.Date)
+	}
+}
+
+func TestUpdateNotificationSchedulePending(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	c := s.createClient(t, time.Now())
+
+	id, err := s.notifications.ScheduleNotification(creator, &service.NotificationInput{
+		Date:            time.Now().Add(time.Hour * 24).Format(time.RFC3339),
+		ClientId:        c.Id,
+		DeliveryMethods: []int8{int8(service.SMS)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the scheduler rejects the time zone the client was given since, after the notification is stored.
+	if _, err := s.clientStore.UpdateUser(creator, c.Id, database.PatchClientItem{TimeZone: "Mars/Olympus_Mons"}); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.notifications.UpdateNotification(creator, id, service.PatchNotification{Message: "see you soon"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !n.SchedulePending {
+		t.Fatalf("expected the schedule change to be pending, got: %+v", n)
+	}
+	stored, err := s.notifications.GetNotification(creator, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Message != "see you soon" {
+		t.Fatalf("expected the update to be stored, got: %+v", stored)
+	}
+	if entries, err := s.store.GetOutbox(0); err != nil || len(entries) != 1 {
+		t.Fatalf("expected the entry to be kept for the relay, got: %+v, %v", entries, err)
+	}
+	if report, err := s.notifications.Reconcile(ctx, false); err != nil || report.Pending != 1 {
+		t.Fatalf("expected the notification to wait on the relay, got: %+v, %v", report, err)
+	}
+}
@@ -0,0 +1,375 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/database"
+	"github.com/japb1998/control-tower/internal/model"
+	"github.com/japb1998/control-tower/internal/scheduler"
+)
+
+// differences found between the schedules and the notifications.
+const (
+	OrphanSchedule     = "orphanSchedule"     // schedule without a pending notification.
+	OrphanNotification = "orphanNotification" // pending notification without a schedule.
+	DateMismatch       = "dateMismatch"
+	PayloadMismatch    = "payloadMismatch"
+)
+
+// repairs applied to the differences, the notification item is taken as the source of truth.
+const (
+	RepairDeleteSchedule = "deleteSchedule"
+	RepairCreateSchedule = "createSchedule"
+	RepairUpdateSchedule = "updateSchedule"
+	RepairMarkFailed     = "markFailed" // the notification date passed without a schedule, it won't be sent.
+)
+
+// missedScheduleGrace is how long after its date a one time notification without schedule is left alone. eventbridge deletes the schedule
+// when it fires, the notification is still NOT_SENT until the target claims it.
+const missedScheduleGrace = time.Minute * 15
+
+// ReconcileIssue is a difference between a schedule and its notification. Repair is empty when it has to be fixed by hand.
+type ReconcileIssue struct {
+	Kind           string `json:"kind"`
+	CreatedBy      string `json:"createdBy,omitempty"`
+	NotificationId string `json:"notificationId,omitempty"`
+	Schedule       string `json:"schedule,omitempty"`
+	Detail         string `json:"detail"`
+	Repair         string `json:"repair,omitempty"`
+	Repaired       bool   `json:"repaired"`
+	Error          string `json:"error,omitempty"`
+}
+
+// ReconcileReport lists the differences found, they are only repaired when Apply is set.
+type ReconcileReport struct {
+	Apply         bool             `json:"apply"`
+	Creators      int              `json:"creators"`
+	Schedules     int              `json:"schedules"`
+	Notifications int              `json:"notifications"`
+	Pending       int              `json:"pending"` // notifications with a schedule operation waiting in the outbox, they are not compared.
+	Issues        []ReconcileIssue `json:"issues"`
+}
+
+// Reconcile compares the schedules with the NOT_SENT notifications of every creator.
+// orphans and schedules with another date or payload than their notification are reported, with apply they are repaired.
+func (s *NotificationService) Reconcile(ctx context.Context, apply bool) (*ReconcileReport, error) {
+	names, err := s.scheduler.ListSchedules("")
+
+	if err != nil {
+		notificationLogger.Error("error listing schedules", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error listing schedules: %w", err)
+	}
+	items, err := s.store.GetNotificationsByStatus(NotSentStatus.String())
+
+	if err != nil {
+		notificationLogger.Error("error listing pending notifications", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error listing pending notifications: %w", err)
+	}
+	entries, err := s.store.GetOutbox(0)
+
+	if err != nil {
+		notificationLogger.Error("error getting outbox", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error getting outbox: %w", err)
+	}
+	report := &ReconcileReport{
+		Apply:         apply,
+		Notifications: len(items),
+		Issues:        make([]ReconcileIssue, 0),
+	}
+	// the relay will apply them.
+	inFlight := make(map[string]bool, len(entries))
+
+	for _, entry := range entries {
+		inFlight[entry.CreatedBy+"/"+entry.NotificationId] = true
+	}
+	report.Pending = len(inFlight)
+	creators := make(map[string]bool)
+	pending := make(map[string]*model.NotificationItem, len(items))
+
+	for i := range items {
+		pending[items[i].PartitionKey+"/"+items[i].SortKey] = &items[i]
+		creators[items[i].PartitionKey] = true
+	}
+	// other targets can share the schedule group.
+	target := os.Getenv("NOTIFICATION_LAMBDA")
+	scheduled := make(map[string]bool)
+
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		sch, err := s.scheduler.GetSchedule(name)
+
+		if errors.Is(err, scheduler.ErrNotFound) {
+			// it fired or was removed while reconciling.
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting schedule='%s': %w", name, err)
+		}
+		if target != "" && sch.Target != target {
+			continue
+		}
+		report.Schedules++
+		var payload Notification
+
+		if err := json.Unmarshal([]byte(sch.Payload), &payload); err != nil || payload.ID == "" || payload.CreatedBy == "" {
+			report.add(ReconcileIssue{Kind: OrphanSchedule, Schedule: name, Detail: "schedule payload is not a notification"})
+			continue
+		}
+		creators[payload.CreatedBy] = true
+		key := payload.CreatedBy + "/" + payload.ID
+
+		if inFlight[key] {
+			scheduled[key] = true
+			continue
+		}
+
+		if name != payload.ID {
+			// retries belong to the notification waiting on them.
+			if s.isPendingRetry(payload.CreatedBy, payload.ID, name) {
+				continue
+			}
+			report.add(s.repair(apply, ReconcileIssue{Kind: OrphanSchedule, CreatedBy: payload.CreatedBy, NotificationId: payload.ID, Schedule: name, Detail: "retry schedule of a notification that is not retrying", Repair: RepairDeleteSchedule}, nil, retryToken(payload.ClientToken, payload.Attempt)))
+			continue
+		}
+		item, ok := pending[key]
+
+		if !ok {
+			report.add(s.repair(apply, ReconcileIssue{Kind: OrphanSchedule, CreatedBy: payload.CreatedBy, NotificationId: payload.ID, Schedule: name, Detail: s.orphanScheduleDetail(payload.CreatedBy, payload.ID), Repair: RepairDeleteSchedule}, nil, payload.ClientToken))
+			continue
+		}
+		scheduled[key] = true
+
+		if issue, ok := compareSchedule(item, sch.Date, sch.TimeZone, payload); ok {
+			issue.Repair = RepairUpdateSchedule
+			report.add(s.repair(apply, issue, item, item.ClientToken))
+		}
+	}
+
+	for _, item := range items {
+		if key := item.PartitionKey + "/" + item.SortKey; scheduled[key] || inFlight[key] {
+			continue
+		}
+		if issue, ok := orphanNotification(&item, time.Now()); ok {
+			report.add(s.repair(apply, issue, &item, item.ClientToken))
+		}
+	}
+	report.Creators = len(creators)
+
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		if report.Issues[i].CreatedBy != report.Issues[j].CreatedBy {
+			return report.Issues[i].CreatedBy < report.Issues[j].CreatedBy
+		}
+		return report.Issues[i].NotificationId < report.Issues[j].NotificationId
+	})
+	notificationLogger.Info("reconciled schedules", slog.Bool("apply", apply), slog.Int("schedules", report.Schedules), slog.Int("notifications", report.Notifications), slog.Int("issues", len(report.Issues)))
+	return report, nil
+}
+
+func (r *ReconcileReport) add(issue ReconcileIssue) {
+	r.Issues = append(r.Issues, issue)
+}
+
+// isPendingRetry reports if the schedule is the retry the notification is waiting on. recurring notifications wait on retries
+// while they are NOT_SENT and one time ones while they are being sent, so the status is not checked.
+func (s *NotificationService) isPendingRetry(createdBy, id, name string) bool {
+	item, err := s.store.GetNotification(createdBy, id)
+
+	if err != nil {
+		return false
+	}
+	return item.RetrySchedule == name
+}
+
+// orphanScheduleDetail explains why a schedule has no pending notification.
+func (s *NotificationService) orphanScheduleDetail(createdBy, id string) string {
+	item, err := s.store.GetNotification(createdBy, id)
+
+	if errors.Is(err, database.ErrNotificationNotFound) {
+		return "notification does not exist"
+	}
+	if err != nil {
+		return fmt.Sprintf("notification could not be read: %s", err)
+	}
+	return fmt.Sprintf("notification status is %s", item.Status)
+}
+
+// compareSchedule returns the issue when the schedule date, time zone or payload are not the ones of the notification.
+func compareSchedule(item *model.NotificationItem, date time.Time, tz string, payload Notification) (ReconcileIssue, bool) {
+	issue := ReconcileIssue{
+		CreatedBy:      item.PartitionKey,
+		NotificationId: item.SortKey,
+		Schedule:       item.SortKey,
+	}
+	itemDate, err := time.Parse(time.RFC3339, item.Date)
+
+	if err == nil && !itemDate.Equal(date) {
+		issue.Kind = DateMismatch
+		issue.Detail = fmt.Sprintf("schedule date is %s, notification date is %s", date.UTC().Format(time.RFC3339), itemDate.UTC().Format(time.RFC3339))
+		return issue, true
+	}
+	fields := make([]string, 0)
+
+	// dates repeated when the clocks are set back were written in UTC by schedules that did not keep their time zone.
+	if tz != timeZone(item.TimeZone) && (item.Recurrence != nil || tz != scheduler.ExpressionTimeZone(itemDate, timeZone(item.TimeZone))) {
+		fields = append(fields, "schedule timeZone")
+	}
+	if payloadDate, err := time.Parse(time.RFC3339, payload.Date); err != nil || !payloadDate.Equal(itemDate) {
+		fields = append(fields, "date")
+	}
+	if payload.ClientId != item.ClientId {
+		fields = append(fields, "clientId")
+	}
+	if !slices.Equal(payload.DeliveryMethods, item.DeliveryMethods) {
+		fields = append(fields, "deliveryMethods")
+	}
+	if !sameRecurrence(payload.Recurrence, newRecurrenceFromItem(item.Recurrence)) {
+		fields = append(fields, "recurrence")
+	}
+	if payload.TemplateId != item.TemplateId {
+		fields = append(fields, "templateId")
+	}
+	if payload.Subject != item.Subject {
+		fields = append(fields, "subject")
+	}
+	if payload.Message != item.Message {
+		fields = append(fields, "message")
+	}
+	if payload.TimeZone != item.TimeZone {
+		fields = append(fields, "timeZone")
+	}
+
+	if len(fields) == 0 {
+		return issue, false
+	}
+	issue.Kind = PayloadMismatch
+	issue.Detail = "schedule differs on " + strings.Join(fields, ", ")
+	return issue, true
+}
+
+func sameRecurrence(a, b *Recurrence) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// orphanNotification returns the issue of a pending notification without schedule. future notifications get their schedule back,
+// one time notifications that are past due are marked as failed, recurring ones that already started are left to be fixed by hand.
+// one time notifications that were due less than missedScheduleGrace ago can still be sending, they are not an issue yet.
+func orphanNotification(item *model.NotificationItem, now time.Time) (ReconcileIssue, bool) {
+	issue := ReconcileIssue{
+		Kind:           OrphanNotification,
+		CreatedBy:      item.PartitionKey,
+		NotificationId: item.SortKey,
+		Detail:         "notification has no schedule",
+	}
+	date, err := time.Parse(time.RFC3339, item.Date)
+
+	switch {
+	case err != nil:
+		issue.Detail = fmt.Sprintf("notification has no schedule and an invalid date='%s'", item.Date)
+	case date.After(now.Add(time.Minute)):
+		issue.Repair = RepairCreateSchedule
+	case item.Recurrence == nil && date.After(now.Add(-missedScheduleGrace)):
+		return issue, false
+	case item.Recurrence == nil:
+		issue.Detail = "notification date passed without a schedule"
+		issue.Repair = RepairMarkFailed
+	default:
+		issue.Detail = "recurring notification started without a schedule"
+	}
+	return issue, true
+}
+
+// repair applies the issue repair when apply is set. item is nil for schedules without notification.
+func (s *NotificationService) repair(apply bool, issue ReconcileIssue, item *model.NotificationItem, token string) ReconcileIssue {
+	if !apply || issue.Repair == "" {
+		return issue
+	}
+	var err error
+
+	switch issue.Repair {
+	case RepairDeleteSchedule:
+		err = s.scheduler.DeleteSchedule(issue.Schedule, token)
+
+		if errors.Is(err, scheduler.ErrNotFound) {
+			err = nil
+		}
+	case RepairCreateSchedule, RepairUpdateSchedule:
+		err = s.restoreSchedule(issue.Repair, item)
+	case RepairMarkFailed:
+		// the item is a snapshot, a send that claimed the notification since is not overwritten.
+		err = s.store.ChangeStatus(item.PartitionKey, item.SortKey, NotSentStatus.String(), FailedStatus.String())
+
+		if errors.Is(err, database.ErrStatusChanged) || errors.Is(err, database.ErrNotificationNotFound) {
+			issue.Detail = "notification changed while reconciling, it was left as is"
+			return issue
+		}
+	}
+
+	if err != nil {
+		notificationLogger.Error("error repairing notification schedule", slog.String("notificationId", issue.NotificationId), slog.String("repair", issue.Repair), slog.String("error", err.Error()))
+		issue.Error = err.Error()
+		return issue
+	}
+	notificationLogger.Info("repaired notification schedule", slog.String("notificationId", issue.NotificationId), slog.String("repair", issue.Repair))
+	issue.Repaired = true
+	return issue
+}
+
+// restoreSchedule creates or replaces the schedule of the notification with the one its item describes.
+func (s *NotificationService) restoreSchedule(repair string, item *model.NotificationItem) error {
+	date, err := time.Parse(time.RFC3339, item.Date)
+
+	if err != nil {
+		return fmt.Errorf("invalid notification date error: %w", err)
+	}
+	n := NewNotificationFromItem(item)
+	n.Occurrences = nil
+	n.Deliveries = nil
+	n.ConfirmedAt = ""
+
+	payload, err := json.Marshal(n)
+
+	if err != nil {
+		return fmt.Errorf("error setting schedule payload err: %w", err)
+	}
+	if repair == RepairCreateSchedule {
+		sch := scheduler.NewSchedule(item.SortKey, os.Getenv("NOTIFICATION_LAMBDA"), os.Getenv("SCHEDULER_ROLE"), timeZone(item.TimeZone), string(payload), date)
+
+		if n.Recurrence != nil {
+			last, _ := n.Recurrence.last(date.In(location(item.TimeZone)))
+			sch.Every(n.Recurrence.Interval*7, scheduleEnd(last))
+		}
+		_, err = s.scheduler.CreateSchedule(sch, item.ClientToken)
+		return err
+	}
+	// updates keep the target and role of the schedule.
+	sch, err := s.scheduler.GetSchedule(item.SortKey)
+
+	if err != nil {
+		return err
+	}
+	sch.Date = date
+	sch.TimeZone = timeZone(item.TimeZone)
+	sch.Payload = string(payload)
+	sch.Repeat = nil
+
+	if n.Recurrence != nil {
+		last, _ := n.Recurrence.last(date.In(location(item.TimeZone)))
+		sch.Every(n.Recurrence.Interval*7, scheduleEnd(last))
+	}
+	_, err = s.scheduler.UpdateSchedule(sch)
+	return err
+}
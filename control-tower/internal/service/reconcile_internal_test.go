@@ -0,0 +1,71 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/database/memory"
+	"github.com/japb1998/control-tower/internal/model"
+	"github.com/japb1998/control-tower/internal/scheduler"
+)
+
+func TestCompareScheduleAmbiguousTimeZone(t *testing.T) {
+	// the first 1:30 am on fall back, it is written in UTC.
+	date := time.Date(2024, time.November, 3, 5, 30, 0, 0, time.UTC)
+	item := model.NewNotificationItem("creator@test.com", NotSentStatus.String(), "client", date, []int8{int8(SMS)})
+	item.TimeZone = "America/New_York"
+	payload := *NewNotificationFromItem(item)
+
+	for _, tz := range []string{"America/New_York", "UTC"} {
+		if issue, ok := compareSchedule(item, date, tz, payload); ok {
+			t.Errorf("schedule in '%s' expected to match, got: %+v", tz, issue)
+		}
+	}
+	if issue, ok := compareSchedule(item, date, "America/Chicago", payload); !ok || issue.Kind != PayloadMismatch {
+		t.Errorf("expected a time zone mismatch, got: %+v", issue)
+	}
+
+	// dates that are not ambiguous are never written in UTC.
+	item.Date = date.Add(time.Hour * 2).Format(time.RFC3339)
+	payload.Date = item.Date
+	if _, ok := compareSchedule(item, date.Add(time.Hour*2), "UTC", payload); !ok {
+		t.Error("expected a time zone mismatch")
+	}
+}
+
+func TestRepairMarkFailed(t *testing.T) {
+	store := memory.NewNotificationRepo()
+	sch, err := scheduler.NewLocalScheduler("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewNotificationService(store, sch, memory.NewSettingsRepo())
+	now := time.Now()
+
+	item := model.NewNotificationItem("creator@test.com", NotSentStatus.String(), "client", now.Add(-time.Hour), []int8{int8(SMS)})
+	if err := store.Create(*item); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := orphanNotification(item, now.Add(-time.Hour+missedScheduleGrace/2)); ok {
+		t.Fatal("expected a notification inside of the grace period to be left alone")
+	}
+	issue, ok := orphanNotification(item, now)
+	if !ok || issue.Repair != RepairMarkFailed {
+		t.Fatalf("expected the notification to be marked as failed, got: %+v", issue)
+	}
+
+	// a send claimed the notification after it was read.
+	if err := store.ChangeStatus(item.PartitionKey, item.SortKey, NotSentStatus.String(), SendingStatus.String()); err != nil {
+		t.Fatal(err)
+	}
+	if repaired := s.repair(true, issue, item, item.ClientToken); repaired.Repaired || repaired.Error != "" {
+		t.Fatalf("expected the repair to be skipped, got: %+v", repaired)
+	}
+	stored, err := store.GetNotification(item.PartitionKey, item.SortKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != SendingStatus.String() {
+		t.Fatalf("expected the claimed notification to keep its status, got: %s", stored.Status)
+	}
+}
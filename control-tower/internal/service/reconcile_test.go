@@ -0,0 +1,164 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/model"
+	"github.com/japb1998/control-tower/internal/scheduler"
+	"github.com/japb1998/control-tower/internal/service"
+)
+
+func TestReconcile(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	c := s.createClient(t, time.Now())
+	date := time.Now().Add(time.Hour * 48).Truncate(time.Second)
+
+	schedule := func() string {
+		t.Helper()
+		id, err := s.notifications.ScheduleNotification(creator, &service.NotificationInput{
+			Date:            date.Format(time.RFC3339),
+			ClientId:        c.Id,
+			DeliveryMethods: []int8{int8(service.SMS)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	schedule() // in sync
+
+	orphanSchedule := schedule()
+	if err := s.store.Delete(creator, orphanSchedule); err != nil {
+		t.Fatal(err)
+	}
+	orphanNotification := schedule()
+	if err := s.scheduler.DeleteSchedule(orphanNotification, ""); err != nil {
+		t.Fatal(err)
+	}
+	past := model.NewNotificationItem(creator, service.NotSentStatus.String(), c.Id, time.Now().Add(-time.Hour), []int8{int8(service.SMS)})
+	if err := s.store.Create(*past); err != nil {
+		t.Fatal(err)
+	}
+	// its schedule just fired, the target has not claimed it yet.
+	fired := model.NewNotificationItem(creator, service.NotSentStatus.String(), c.Id, time.Now().Add(-time.Minute*5), []int8{int8(service.SMS)})
+	if err := s.store.Create(*fired); err != nil {
+		t.Fatal(err)
+	}
+
+	// recurring notifications stay NOT_SENT while they wait on a retry.
+	recurring, err := s.notifications.ScheduleNotification(creator, &service.NotificationInput{
+		Date:            date.Format(time.RFC3339),
+		ClientId:        c.Id,
+		DeliveryMethods: []int8{int8(service.SMS)},
+		Recurrence:      &service.Recurrence{Frequency: service.WeeklyFrequency, Interval: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.notifications.GetNotification(creator, recurring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retry, err := s.notifications.ScheduleRetry(*n, []int8{int8(service.SMS)}, 2, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := s.store.GetNotification(creator, recurring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item.RetrySchedule = retry
+	if _, err := s.store.Update(*item); err != nil {
+		t.Fatal(err)
+	}
+
+	// the schedule was updated but the notification item was not.
+	moved := schedule()
+	sch, err := s.scheduler.GetSchedule(moved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sch.Date = date.Add(time.Hour)
+	if _, err := s.scheduler.UpdateSchedule(sch); err != nil {
+		t.Fatal(err)
+	}
+	edited := schedule()
+	sch, err = s.scheduler.GetSchedule(edited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload service.Notification
+	if err := json.Unmarshal([]byte(sch.Payload), &payload); err != nil {
+		t.Fatal(err)
+	}
+	payload.Message = "see you soon"
+	b, _ := json.Marshal(payload)
+	sch.Payload = string(b)
+	if _, err := s.scheduler.UpdateSchedule(sch); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		orphanSchedule:     service.RepairDeleteSchedule,
+		orphanNotification: service.RepairCreateSchedule,
+		past.SortKey:       service.RepairMarkFailed,
+		moved:              service.RepairUpdateSchedule,
+		edited:             service.RepairUpdateSchedule,
+	}
+	check := func(report *service.ReconcileReport, repaired bool) {
+		t.Helper()
+		if len(report.Issues) != len(want) {
+			t.Fatalf("expected %d issues, got: %+v", len(want), report.Issues)
+		}
+		for _, issue := range report.Issues {
+			if repair, ok := want[issue.NotificationId]; !ok || issue.Repair != repair || issue.Repaired != repaired || issue.Error != "" {
+				t.Errorf("unexpected issue: %+v", issue)
+			}
+		}
+	}
+
+	report, err := s.notifications.Reconcile(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(report, false)
+	if report.Creators != 1 || report.Schedules != 6 || report.Notifications != 7 {
+		t.Errorf("unexpected counts: %+v", report)
+	}
+	// dry runs don't change anything.
+	if _, err := s.scheduler.GetSchedule(orphanSchedule); err != nil {
+		t.Errorf("expected the orphan schedule to be kept, got: %v", err)
+	}
+
+	report, err = s.notifications.Reconcile(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(report, true)
+
+	if _, err := s.scheduler.GetSchedule(orphanSchedule); !errors.Is(err, scheduler.ErrNotFound) {
+		t.Errorf("expected the orphan schedule to be deleted, got: %v", err)
+	}
+	if _, err := s.scheduler.GetSchedule(orphanNotification); err != nil {
+		t.Errorf("expected the schedule to be created, got: %v", err)
+	}
+	if n, err := s.notifications.GetNotification(creator, past.SortKey); err != nil || n.Status != service.FailedStatus.String() {
+		t.Errorf("expected the past notification to fail, got: %+v, %v", n, err)
+	}
+	if _, err := s.scheduler.GetSchedule(retry); err != nil {
+		t.Errorf("expected the retry to be kept, got: %v", err)
+	}
+
+	report, err = s.notifications.Reconcile(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Issues) != 0 {
+		t.Fatalf("expected the stores to be in sync, got: %+v", report.Issues)
+	}
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+const (
+	WeeklyFrequency = "WEEKLY"
+	week            = time.Hour * 24 * 7
+)
+
+var (
+	ErrInvalidRecurrence = errors.New("invalid recurrence provided")
+)
+
+// Recurrence repeats a notification every Interval weeks. it ends on the Until date or after Count sends, when neither is provided it never ends.
+type Recurrence struct {
+	Frequency string `json:"frequency" binding:"required,oneof=WEEKLY" validate:"required,oneof=WEEKLY"`
+	Interval  int    `json:"interval" binding:"required,min=1,max=52" validate:"required,min=1,max=52"`
+	Until     string `json:"until,omitempty" binding:"omitempty,rfc3339"`
+	Count     int    `json:"count,omitempty" binding:"omitempty,min=1,excluded_with=Until" validate:"omitempty,min=1"`
+}
+
+// Occurrence is the result of one send of a recurring notification.
+type Occurrence struct {
+	Number      int    `json:"number"`
+	Date        string `json:"date"`
+	SentAt      string `json:"sentAt"`
+	Status      string `json:"status"`
+	ConfirmedAt string `json:"confirmedAt,omitempty"`
+}
+
+// every returns the time between occurrences.
+func (r *Recurrence) every() time.Duration {
+	return week * time.Duration(r.Interval)
+}
+
+// occurrenceDate returns the date of the occurrence number n in UTC. occurrences start at 1.
+// start is in the time zone of the notification, the occurrences keep its wall clock time when the clocks change.
+func (r *Recurrence) occurrenceDate(start time.Time, n int) time.Time {
+	return start.AddDate(0, 0, 7*r.Interval*(n-1)).UTC()
+}
+
+// isOccurrenceWeek reports if the date falls on a week of the recurrence. schedules of longer intervals fire every week,
+// the weeks in between are skipped. start and date are in the time zone of the notification.
+func (r *Recurrence) isOccurrenceWeek(start, date time.Time) bool {
+	day := func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	days := int(day(date).Sub(day(start)).Hours() / 24)
+
+	return days >= 0 && (days/7)%r.Interval == 0
+}
+
+// last returns the date of the last occurrence or nil when the recurrence never ends.
+func (r *Recurrence) last(start time.Time) (*time.Time, error) {
+	switch {
+	case r.Count > 0:
+		l := r.occurrenceDate(start, r.Count)
+		return &l, nil
+	case r.Until != "":
+		until, err := time.Parse(time.RFC3339, r.Until)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid until date error='%s': %w", err, ErrInvalidRecurrence)
+		}
+		if until.Before(start) {
+			return nil, fmt.Errorf("until must be after the notification date: %w", ErrInvalidRecurrence)
+		}
+		// the estimate is off by the clock changes at most.
+		n := int(until.Sub(start)/r.every()) + 1
+		for n > 1 && r.occurrenceDate(start, n).After(until) {
+			n--
+		}
+		for !r.occurrenceDate(start, n+1).After(until) {
+			n++
+		}
+		l := r.occurrenceDate(start, n)
+		return &l, nil
+	}
+	return nil, nil
+}
+
+// isLast reports if the occurrence number n is the last one.
+func (r *Recurrence) isLast(start time.Time, n int) bool {
+	last, err := r.last(start)
+
+	if err != nil || last == nil {
+		return false
+	}
+	return !r.occurrenceDate(start, n).Before(*last)
+}
+
+// validate checks the recurrence against the notification start date.
+func (r *Recurrence) validate(start time.Time) error {
+	if r.Frequency != WeeklyFrequency {
+		return fmt.Errorf("frequency='%s' not supported: %w", r.Frequency, ErrInvalidRecurrence)
+	}
+	if r.Interval < 1 {
+		return fmt.Errorf("interval must be at least 1: %w", ErrInvalidRecurrence)
+	}
+	if r.Count > 0 && r.Until != "" {
+		return fmt.Errorf("count and until can't be used together: %w", ErrInvalidRecurrence)
+	}
+	_, err := r.last(start)
+	return err
+}
+
+func (r *Recurrence) toItem() *model.Recurrence {
+	if r == nil {
+		return nil
+	}
+	return &model.Recurrence{
+		Frequency: r.Frequency,
+		Interval:  r.Interval,
+		Until:     r.Until,
+		Count:     r.Count,
+	}
+}
+
+// recurrenceEnded reports whether the last recorded occurrence of the item is its final one.
+func recurrenceEnded(item *model.NotificationItem) bool {
+	start, err := time.Parse(time.RFC3339, item.Date)
+
+	if err != nil {
+		return false
+	}
+	return newRecurrenceFromItem(item.Recurrence).isLast(start.In(location(item.TimeZone)), len(item.Occurrences))
+}
+
+// isOccurrenceWeek reports if the recurring notification has an occurrence on the week of the date. payloads with an invalid date are sent.
+func isOccurrenceWeek(n Notification, date time.Time) bool {
+	start, err := time.Parse(time.RFC3339, n.Date)
+
+	if err != nil {
+		return true
+	}
+	loc := location(n.TimeZone)
+	return n.Recurrence.isOccurrenceWeek(start.In(loc), date.In(loc))
+}
+
+func newRecurrenceFromItem(item *model.Recurrence) *Recurrence {
+	if item == nil {
+		return nil
+	}
+	return &Recurrence{
+		Frequency: item.Frequency,
+		Interval:  item.Interval,
+		Until:     item.Until,
+		Count:     item.Count,
+	}
+}
+
+func newOccurrencesFromItems(items []model.OccurrenceItem) []Occurrence {
+	if len(items) == 0 {
+		return nil
+	}
+	occurrences := make([]Occurrence, 0, len(items))
+
+	for _, o := range items {
+		occurrences = append(occurrences, Occurrence{
+			Number:      o.Number,
+			Date:        o.Date,
+			SentAt:      o.SentAt,
+			Status:      o.Status,
+			ConfirmedAt: o.ConfirmedAt,
+		})
+	}
+	return occurrences
+}
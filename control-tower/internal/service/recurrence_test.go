@@ -0,0 +1,155 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+func TestRecurrenceValidate(t *testing.T) {
+	start := time.Date(2024, time.March, 4, 15, 0, 0, 0, time.UTC)
+
+	if err := (&Recurrence{Frequency: WeeklyFrequency, Interval: 2, Count: 3}).validate(start); err != nil {
+		t.Fatalf("expected a valid recurrence, got: %v", err)
+	}
+	tests := []struct {
+		name       string
+		recurrence Recurrence
+	}{
+		{"unknown frequency", Recurrence{Frequency: "DAILY", Interval: 1}},
+		{"no interval", Recurrence{Frequency: WeeklyFrequency}},
+		{"count and until", Recurrence{Frequency: WeeklyFrequency, Interval: 1, Count: 2, Until: start.AddDate(0, 1, 0).Format(time.RFC3339)}},
+		{"until before start", Recurrence{Frequency: WeeklyFrequency, Interval: 1, Until: start.AddDate(0, 0, -1).Format(time.RFC3339)}},
+		{"invalid until", Recurrence{Frequency: WeeklyFrequency, Interval: 1, Until: "next month"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.recurrence.validate(start); !errors.Is(err, ErrInvalidRecurrence) {
+				t.Fatalf("expected ErrInvalidRecurrence, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestRecurrenceLast(t *testing.T) {
+	start := time.Date(2024, time.March, 4, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		recurrence Recurrence
+		want       *time.Time
+	}{
+		{"count", Recurrence{Frequency: WeeklyFrequency, Interval: 2, Count: 3}, ptr(start.Add(week * 4))},
+		{"until between occurrences", Recurrence{Frequency: WeeklyFrequency, Interval: 1, Until: start.AddDate(0, 0, 20).Format(time.RFC3339)}, ptr(start.Add(week * 2))},
+		{"until on an occurrence", Recurrence{Frequency: WeeklyFrequency, Interval: 1, Until: start.Add(week).Format(time.RFC3339)}, ptr(start.Add(week))},
+		{"never ends", Recurrence{Frequency: WeeklyFrequency, Interval: 1}, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			last, err := tc.recurrence.last(start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (last == nil) != (tc.want == nil) || (last != nil && !last.Equal(*tc.want)) {
+				t.Fatalf("expected %v, got: %v", tc.want, last)
+			}
+		})
+	}
+}
+
+func TestRecurrenceOccurrenceDate(t *testing.T) {
+	start := time.Date(2024, time.March, 4, 15, 0, 0, 0, time.UTC)
+	r := &Recurrence{Frequency: WeeklyFrequency, Interval: 2}
+
+	if d := r.occurrenceDate(start, 1); !d.Equal(start) {
+		t.Fatalf("expected the first occurrence on the start date, got: %s", d)
+	}
+	if d := r.occurrenceDate(start, 3); !d.Equal(start.Add(week * 4)) {
+		t.Fatalf("expected the third occurrence four weeks later, got: %s", d)
+	}
+}
+
+func TestRecurrenceAcrossDST(t *testing.T) {
+	ny := location("America/New_York")
+	// 3 pm on the monday before the clocks spring forward.
+	start := time.Date(2024, time.March, 4, 20, 0, 0, 0, time.UTC).In(ny)
+	r := &Recurrence{Frequency: WeeklyFrequency, Interval: 1, Until: "2024-03-18T19:00:00Z"}
+
+	if d := r.occurrenceDate(start, 2); !d.Equal(time.Date(2024, time.March, 11, 19, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the second occurrence at 3 pm EDT, got: %s", d)
+	}
+	last, err := r.last(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, time.March, 18, 19, 0, 0, 0, time.UTC); last == nil || !last.Equal(want) {
+		t.Fatalf("expected the until date to be the last occurrence, got: %v", last)
+	}
+
+	// back to EST in november.
+	fall := time.Date(2024, time.October, 28, 19, 0, 0, 0, time.UTC).In(ny)
+	if d := r.occurrenceDate(fall, 2); !d.Equal(time.Date(2024, time.November, 4, 20, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the second occurrence at 3 pm EST, got: %s", d)
+	}
+}
+
+func TestRecurrenceIsOccurrenceWeek(t *testing.T) {
+	ny := location("America/New_York")
+	start := time.Date(2024, time.March, 4, 20, 0, 0, 0, time.UTC).In(ny)
+	r := &Recurrence{Frequency: WeeklyFrequency, Interval: 2}
+
+	tests := []struct {
+		name string
+		date time.Time
+		want bool
+	}{
+		{"first occurrence", start, true},
+		{"week in between", start.AddDate(0, 0, 7), false},
+		{"second occurrence", start.AddDate(0, 0, 14), true},
+		{"late run of the second occurrence", start.AddDate(0, 0, 15), true},
+		{"before the start", start.AddDate(0, 0, -14), false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := r.isOccurrenceWeek(start, tc.date.In(ny)); got != tc.want {
+				t.Fatalf("expected %t, got: %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestAddOccurrence(t *testing.T) {
+	start := time.Date(2024, time.March, 4, 15, 0, 0, 0, time.UTC)
+	item := &model.NotificationItem{
+		Status:     NotSentStatus.String(),
+		Date:       start.Format(time.RFC3339),
+		Recurrence: &model.Recurrence{Frequency: WeeklyFrequency, Interval: 1, Count: 2},
+		TimeZone:   "UTC",
+	}
+
+	n, err := addOccurrence(item, SentStatus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || item.Status != NotSentStatus.String() || item.Occurrences[0].Status != SentStatus.String() || item.Occurrences[0].Date != start.Format(time.RFC3339) {
+		t.Fatalf("unexpected first occurrence: %d, %+v", n, item)
+	}
+	// the status only changes with the last occurrence.
+	n, err = addOccurrence(item, FailedStatus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || item.Status != FailedStatus.String() || item.Occurrences[1].Date != start.Add(week).Format(time.RFC3339) {
+		t.Fatalf("unexpected last occurrence: %d, %+v", n, item)
+	}
+
+	item.Date = "tomorrow"
+	if _, err := addOccurrence(item, SentStatus); err == nil {
+		t.Fatal("expected an error for an invalid date")
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"time"
+)
+
+// RetryPolicy describes how failed deliveries of a channel are retried. the zero value never retries.
+type RetryPolicy struct {
+	MaxAttempts int              // sends including the first one.
+	Backoff     time.Duration    // delay before the first retry, doubled on every attempt.
+	MaxBackoff  time.Duration    // longest delay between attempts, 0 means no limit.
+	Retryable   func(error) bool // reports whether the error is temporary. nil retries every error.
+}
+
+// NewRetryPolicy returns the default policy, 3 attempts starting 5 minutes apart up to an hour.
+func NewRetryPolicy(retryable func(error) bool) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     time.Minute * 5,
+		MaxBackoff:  time.Hour,
+		Retryable:   retryable,
+	}
+}
+
+// shouldRetry reports whether a send that failed with err on the attempt number can be retried.
+func (p RetryPolicy) shouldRetry(attempt int, err error) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// delay returns the time to wait after the attempt number failed.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
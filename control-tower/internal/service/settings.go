@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+type SettingsRepository interface {
+	GetSettings(createdBy string) (*model.SettingsItem, error)
+	SaveSettings(settings model.SettingsItem) error
+}
+
+type SettingsService struct {
+	store SettingsRepository
+}
+
+// Settings are the creator preferences. MaintenanceIntervalWeeks set to 0 disables automatic maintenance reminders.
+// DisabledChannels are the delivery methods that are skipped for every client of the creator.
+// DefaultLanguage is the language of the reminders of clients without a language.
+// TimeZone is the IANA time zone of the creator, it is used for the clients without one.
+// SendWindow limits the hours the reminders are sent at, there is no limit without it.
+type Settings struct {
+	CreatedBy                string      `json:"createdBy"`
+	MaintenanceIntervalWeeks int         `json:"maintenanceIntervalWeeks"`
+	DisabledChannels         []int8      `json:"disabledChannels"`
+	DefaultLanguage          string      `json:"defaultLanguage"`
+	TimeZone                 string      `json:"timeZone"`
+	SendWindow               *SendWindow `json:"sendWindow,omitempty"`
+	LastUpdateAt             string      `json:"lastUpdateAt,omitempty"`
+}
+
+// PutSettings replaces the provided settings. DisabledChannels, DefaultLanguage, TimeZone and SendWindow are kept when they are not provided.
+// RemoveSendWindow removes the send window, reminders are then sent at any time.
+type PutSettings struct {
+	MaintenanceIntervalWeeks *int        `json:"maintenanceIntervalWeeks" binding:"required,min=0,max=52"`
+	DisabledChannels         []int8      `json:"disabledChannels" binding:"omitempty,dive,number,min=0,max=2"`
+	DefaultLanguage          string      `json:"defaultLanguage" binding:"omitempty,oneof=en es"`
+	TimeZone                 string      `json:"timeZone" binding:"omitempty,timezone"`
+	SendWindow               *SendWindow `json:"sendWindow"`
+	RemoveSendWindow         bool        `json:"removeSendWindow"`
+}
+
+func NewSettingsService(store SettingsRepository) *SettingsService {
+	return &SettingsService{
+		store: store,
+	}
+}
+
+func NewSettingsFromItem(item *model.SettingsItem) *Settings {
+	disabled := item.DisabledChannels
+	if disabled == nil {
+		disabled = make([]int8, 0)
+	}
+	return &Settings{
+		CreatedBy:                item.PrimaryKey,
+		MaintenanceIntervalWeeks: item.MaintenanceIntervalWeeks,
+		DisabledChannels:         disabled,
+		DefaultLanguage:          reminderLanguage(item.DefaultLanguage, ""),
+		TimeZone:                 timeZone(item.TimeZone),
+		SendWindow:               newSendWindowFromItem(item.SendWindow),
+		LastUpdateAt:             item.LastUpdateAt.Format(time.RFC3339),
+	}
+}
+
+// GetSettings returns the creator settings. creators that never saved their settings get the default ones.
+func (s *SettingsService) GetSettings(ctx context.Context, createdBy string) (*Settings, error) {
+	item, err := getSettingsItem(s.store, createdBy)
+
+	if err != nil {
+		settingsLogger.Error("error getting settings", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error getting settings")
+	}
+	return NewSettingsFromItem(item), nil
+}
+
+// SaveSettings replaces the creator settings.
+func (s *SettingsService) SaveSettings(ctx context.Context, createdBy string, input PutSettings) (*Settings, error) {
+	if err := checkTimeZone(input.TimeZone); err != nil {
+		return nil, err
+	}
+	var window *model.SendWindowItem
+	if input.SendWindow != nil {
+		w, err := input.SendWindow.item()
+
+		if err != nil {
+			return nil, err
+		}
+		window = w
+	}
+	item, err := getSettingsItem(s.store, createdBy)
+
+	if err != nil {
+		settingsLogger.Error("error getting settings", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error saving settings")
+	}
+
+	if input.MaintenanceIntervalWeeks != nil {
+		item.MaintenanceIntervalWeeks = *input.MaintenanceIntervalWeeks
+	}
+	if input.DisabledChannels != nil {
+		item.DisabledChannels = input.DisabledChannels
+	}
+	if input.DefaultLanguage != "" {
+		item.DefaultLanguage = input.DefaultLanguage
+	}
+	if input.TimeZone != "" {
+		item.TimeZone = input.TimeZone
+	}
+	if window != nil {
+		item.SendWindow = window
+	}
+	if input.RemoveSendWindow {
+		item.SendWindow = nil
+	}
+	item.LastUpdateAt = time.Now().UTC()
+
+	if err := s.store.SaveSettings(*item); err != nil {
+		settingsLogger.Error("error saving settings", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error saving settings")
+	}
+	return NewSettingsFromItem(item), nil
+}
+
+// getSettingsItem returns the stored settings or a new item when the creator has none.
+func getSettingsItem(store SettingsRepository, createdBy string) (*model.SettingsItem, error) {
+	item, err := store.GetSettings(createdBy)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if item == nil {
+		item = model.NewSettingsItem(createdBy, 0)
+	}
+	return item, nil
+}
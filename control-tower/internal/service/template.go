@@ -0,0 +1,499 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("template not found")
+	ErrInvalidTemplate  = errors.New("invalid template")
+	ErrTemplateChannel  = errors.New("template channel is not a delivery method of the notification")
+)
+
+type TemplateRepository interface {
+	SaveTemplate(template model.TemplateItem) error
+	GetTemplate(createdBy, id string) (*model.TemplateItem, error)
+	GetTemplates(createdBy string) ([]model.TemplateItem, error)
+	DeleteTemplate(createdBy, id string) error
+}
+
+// template variables, used in the subject and body as {{name}}.
+const (
+	ClientNameVariable      = "clientName"
+	FirstNameVariable       = "firstName"
+	WeeksVariable           = "weeks"
+	OptOutUrlVariable       = "optOutUrl"
+	AppointmentTimeVariable = "appointmentTime"
+)
+
+// TemplateVariable describes a value the templates can use.
+type TemplateVariable struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"` // string, number, url or time
+	Description string `json:"description"`
+}
+
+// TemplateVariables are the variables the templates can use, any other variable is rejected.
+var TemplateVariables = []TemplateVariable{
+	{Name: ClientNameVariable, Type: "string", Description: "first and last name of the client"},
+	{Name: FirstNameVariable, Type: "string", Description: "first name of the client"},
+	{Name: WeeksVariable, Type: "number", Description: "weeks since the last visit of the client"},
+	{Name: OptOutUrlVariable, Type: "url", Description: "link the client opens to stop the reminders"},
+	{Name: AppointmentTimeVariable, Type: "time", Description: "date the reminder is scheduled for"},
+}
+
+var templateVariablePattern = regexp.MustCompile(`{{\s*([A-Za-z]+)\s*}}`)
+
+// TemplateValues are the values of the template variables for one reminder.
+type TemplateValues struct {
+	ClientName      string
+	FirstName       string
+	Weeks           int
+	OptOutUrl       string
+	AppointmentTime time.Time
+	Language        string // language of the reminder, selects the template variant and how dates are written.
+}
+
+func (v TemplateValues) get(name string) string {
+	switch name {
+	case ClientNameVariable:
+		return v.ClientName
+	case FirstNameVariable:
+		return v.FirstName
+	case WeeksVariable:
+		return strconv.Itoa(v.Weeks)
+	case OptOutUrlVariable:
+		return v.OptOutUrl
+	case AppointmentTimeVariable:
+		if v.AppointmentTime.IsZero() {
+			return ""
+		}
+		return formatAppointment(v.AppointmentTime, v.Language)
+	}
+	return ""
+}
+
+// variables returns the value of every variable by name.
+func (v TemplateValues) variables() map[string]string {
+	vars := make(map[string]string, len(TemplateVariables))
+	for _, tv := range TemplateVariables {
+		vars[tv.Name] = v.get(tv.Name)
+	}
+	return vars
+}
+
+// defaultReminderWeeks is used for clients without a last visit when the creator has no maintenance interval.
+const defaultReminderWeeks = 2
+
+// newTemplateValues returns the values of the reminder of the client. weeks falls back to the maintenance interval when the last visit is unknown
+// and the language to the creator language. the appointment time is written in the time zone of the client.
+func newTemplateValues(client ClientDto, apiUrl string, appointment time.Time, settings *model.SettingsItem) TemplateValues {
+	weeks := settings.MaintenanceIntervalWeeks
+	if weeks == 0 {
+		weeks = defaultReminderWeeks
+	}
+	if client.LastSeen != nil {
+		if lastSeen, err := time.Parse(time.RFC3339, *client.LastSeen); err == nil {
+			weeks = int(time.Since(lastSeen) / week)
+		}
+	}
+	values := TemplateValues{
+		ClientName:      strings.TrimSpace(fmt.Sprintf("%s %s", client.FirstName, client.LastName)),
+		FirstName:       client.FirstName,
+		Weeks:           max(weeks, 0),
+		AppointmentTime: appointment.In(location(timeZone(client.TimeZone, settings.TimeZone))),
+		Language:        reminderLanguage(client.Language, settings.DefaultLanguage),
+	}
+	if apiUrl != "" {
+		values.OptOutUrl = optOutUrl(apiUrl, client.CreatedBy, client.Id)
+	}
+	return values
+}
+
+// optOutUrl is the unsubscribe link of the client.
+func optOutUrl(apiUrl, createdBy, clientId string) string {
+	return fmt.Sprintf("%s/unsubscribe/%s/%s", apiUrl, createdBy, clientId)
+}
+
+// templateVariableNames returns the variables of the text in order of appearance, unknown variables are an error.
+func templateVariableNames(text string) ([]string, error) {
+	matches := templateVariablePattern.FindAllStringSubmatch(text, -1)
+	names := make([]string, 0, len(matches))
+
+	for _, m := range matches {
+		known := false
+		for _, tv := range TemplateVariables {
+			known = known || tv.Name == m[1]
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown variable '%s': %w", m[1], ErrInvalidTemplate)
+		}
+		names = append(names, m[1])
+	}
+	return names, nil
+}
+
+func renderTemplateText(text string, v TemplateValues) string {
+	return templateVariablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		return v.get(templateVariablePattern.FindStringSubmatch(match)[1])
+	})
+}
+
+// Template is a reminder of a creator for one channel.
+// ProviderTemplateId sends the mailgun template or the twilio content template instead of the body,
+// the body of whatsapp templates lists the content template variables in the order they are numbered.
+// Variants translate the template, the template itself is written in Language.
+type Template struct {
+	Id                 string                     `json:"id"`
+	Name               string                     `json:"name"`
+	Channel            ContactOptions             `json:"channel"`
+	Language           string                     `json:"language"`
+	Subject            string                     `json:"subject,omitempty"`
+	Body               string                     `json:"body"`
+	ProviderTemplateId string                     `json:"providerTemplateId,omitempty"`
+	Variants           map[string]TemplateVariant `json:"variants,omitempty"`
+	CreatedAt          string                     `json:"createdAt,omitempty"`
+	UpdatedAt          string                     `json:"updatedAt,omitempty"`
+}
+
+// TemplateVariant is the template in another language. it replaces the subject, body and provider template of the template.
+type TemplateVariant struct {
+	Subject            string `json:"subject,omitempty" binding:"max=200"`
+	Body               string `json:"body" binding:"required,max=1600"`
+	ProviderTemplateId string `json:"providerTemplateId,omitempty" binding:"max=200"`
+}
+
+func newTemplateFromItem(item model.TemplateItem) Template {
+	var variants map[string]TemplateVariant
+	if len(item.Variants) > 0 {
+		variants = make(map[string]TemplateVariant, len(item.Variants))
+		for language, v := range item.Variants {
+			variants[language] = TemplateVariant{Subject: v.Subject, Body: v.Body, ProviderTemplateId: v.ProviderTemplateId}
+		}
+	}
+	return Template{
+		Id:                 item.SortKey,
+		Name:               item.Name,
+		Channel:            ContactOptions(item.Channel),
+		Language:           reminderLanguage(item.Language, ""),
+		Subject:            item.Subject,
+		Body:               item.Body,
+		ProviderTemplateId: item.ProviderTemplateId,
+		Variants:           variants,
+		CreatedAt:          item.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:          item.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// localize returns the template in the language. templates without a variant for the language are returned as they are.
+func (t Template) localize(language string) Template {
+	v, ok := t.Variants[language]
+
+	if !ok || language == t.Language {
+		return t
+	}
+	t.Language = language
+	t.Subject = v.Subject
+	t.Body = v.Body
+	t.ProviderTemplateId = v.ProviderTemplateId
+	return t
+}
+
+// Render replaces the variables of the subject and body of the template in the language of the values.
+func (t Template) Render(v TemplateValues) RenderedTemplate {
+	t = t.localize(v.Language)
+
+	return RenderedTemplate{
+		Channel:            t.Channel,
+		Language:           t.Language,
+		Subject:            renderTemplateText(t.Subject, v),
+		Body:               renderTemplateText(t.Body, v),
+		ProviderTemplateId: t.ProviderTemplateId,
+	}
+}
+
+// contentVariables returns the twilio content template variables, numbered in the order they appear in the body.
+// the template must be localized first.
+func (t Template) contentVariables(v TemplateValues) map[int]string {
+	// templates are validated when they are saved.
+	names, _ := templateVariableNames(t.Body)
+	vars := make(map[int]string, len(names))
+
+	for i, name := range names {
+		vars[i+1] = v.get(name)
+	}
+	return vars
+}
+
+// RenderedTemplate is the reminder the client receives.
+type RenderedTemplate struct {
+	Channel            ContactOptions `json:"channel"`
+	Language           string         `json:"language"`
+	Subject            string         `json:"subject,omitempty"`
+	Body               string         `json:"body"`
+	ProviderTemplateId string         `json:"providerTemplateId,omitempty"`
+}
+
+// TemplateInput creates or replaces a template.
+type TemplateInput struct {
+	Name               string                     `json:"name" binding:"required,max=100"`
+	Channel            ContactOptions             `json:"channel" binding:"min=0,max=2"`
+	Language           string                     `json:"language" binding:"omitempty,oneof=en es"` // language of the subject and body, DefaultLanguage when empty.
+	Subject            string                     `json:"subject" binding:"max=200"`
+	Body               string                     `json:"body" binding:"required,max=1600"`
+	ProviderTemplateId string                     `json:"providerTemplateId" binding:"max=200"`
+	Variants           map[string]TemplateVariant `json:"variants" binding:"omitempty,dive,keys,oneof=en es,endkeys"`
+}
+
+func (in TemplateInput) validate() error {
+	texts := []TemplateVariant{{Subject: in.Subject, Body: in.Body}}
+	for language, v := range in.Variants {
+		if NormalizeLanguage(language) != language {
+			return fmt.Errorf("unsupported language '%s': %w", language, ErrInvalidTemplate)
+		}
+		texts = append(texts, v)
+	}
+
+	for _, t := range texts {
+		if in.Channel == Email && strings.TrimSpace(t.Subject) == "" {
+			return fmt.Errorf("email templates need a subject: %w", ErrInvalidTemplate)
+		}
+		if strings.TrimSpace(t.Body) == "" {
+			return fmt.Errorf("templates need a body: %w", ErrInvalidTemplate)
+		}
+		for _, text := range []string{t.Subject, t.Body} {
+			if _, err := templateVariableNames(text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// template returns the unsaved template of the input.
+func (in TemplateInput) template() Template {
+	subject := in.Subject
+	// subjects are only sent by email.
+	if in.Channel != Email {
+		subject = ""
+	}
+	var variants map[string]TemplateVariant
+	if len(in.Variants) > 0 {
+		variants = make(map[string]TemplateVariant, len(in.Variants))
+		for language, v := range in.Variants {
+			if in.Channel != Email {
+				v.Subject = ""
+			}
+			variants[language] = v
+		}
+	}
+	return Template{
+		Name:               in.Name,
+		Channel:            in.Channel,
+		Language:           reminderLanguage(in.Language, ""),
+		Subject:            subject,
+		Body:               in.Body,
+		ProviderTemplateId: in.ProviderTemplateId,
+		Variants:           variants,
+	}
+}
+
+// item returns the stored template of the creator.
+func (t Template) item(createdBy string) *model.TemplateItem {
+	item := model.NewTemplateItem(createdBy, t.Name, int8(t.Channel), t.Subject, t.Body, t.ProviderTemplateId)
+	item.Language = t.Language
+
+	if len(t.Variants) > 0 {
+		item.Variants = make(map[string]model.TemplateVariantItem, len(t.Variants))
+		for language, v := range t.Variants {
+			item.Variants[language] = model.TemplateVariantItem{Subject: v.Subject, Body: v.Body, ProviderTemplateId: v.ProviderTemplateId}
+		}
+	}
+	return item
+}
+
+// PreviewInput selects the values of a preview, sample values are used without a client.
+type PreviewInput struct {
+	ClientId string `json:"clientId" binding:"omitempty,uuid"`
+	Date     string `json:"date" binding:"omitempty,rfc3339"`         // appointment time, defaults to now.
+	Language string `json:"language" binding:"omitempty,oneof=en es"` // defaults to the language the client receives.
+}
+
+// DraftPreview renders a template that is not saved yet.
+type DraftPreview struct {
+	Template TemplateInput `json:"template" binding:"required"`
+	PreviewInput
+}
+
+type TemplateService struct {
+	store    TemplateRepository
+	clients  *ClientService
+	settings SettingsRepository
+	apiUrl   string // used to build the opt out url
+}
+
+func NewTemplateService(store TemplateRepository, clients *ClientService, settings SettingsRepository, apiUrl string) *TemplateService {
+	return &TemplateService{
+		store:    store,
+		clients:  clients,
+		settings: settings,
+		apiUrl:   apiUrl,
+	}
+}
+
+func (s *TemplateService) CreateTemplate(ctx context.Context, createdBy string, in TemplateInput) (*Template, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	item := in.template().item(createdBy)
+
+	if err := s.store.SaveTemplate(*item); err != nil {
+		templateLogger.Error("error saving template", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error saving template")
+	}
+	created := newTemplateFromItem(*item)
+	return &created, nil
+}
+
+// UpdateTemplate replaces the template, notifications that reference it send the new version.
+func (s *TemplateService) UpdateTemplate(ctx context.Context, createdBy, id string, in TemplateInput) (*Template, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	item, err := s.getTemplateItem(createdBy, id)
+
+	if err != nil {
+		return nil, err
+	}
+	replaced := in.template().item(createdBy)
+	replaced.SortKey = item.SortKey
+	replaced.CreatedAt = item.CreatedAt
+	item = replaced
+
+	if err := s.store.SaveTemplate(*item); err != nil {
+		templateLogger.Error("error saving template", slog.String("templateId", id), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error saving template")
+	}
+	updated := newTemplateFromItem(*item)
+	return &updated, nil
+}
+
+func (s *TemplateService) GetTemplate(ctx context.Context, createdBy, id string) (*Template, error) {
+	item, err := s.getTemplateItem(createdBy, id)
+
+	if err != nil {
+		return nil, err
+	}
+	t := newTemplateFromItem(*item)
+	return &t, nil
+}
+
+func (s *TemplateService) GetTemplates(ctx context.Context, createdBy string) ([]Template, error) {
+	items, err := s.store.GetTemplates(createdBy)
+
+	if err != nil {
+		templateLogger.Error("error getting templates", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error getting templates")
+	}
+	templates := make([]Template, 0, len(items))
+
+	for _, i := range items {
+		templates = append(templates, newTemplateFromItem(i))
+	}
+	return templates, nil
+}
+
+// DeleteTemplate removes the template, notifications that reference it send the channel defaults.
+func (s *TemplateService) DeleteTemplate(ctx context.Context, createdBy, id string) error {
+	if _, err := s.getTemplateItem(createdBy, id); err != nil {
+		return err
+	}
+	if err := s.store.DeleteTemplate(createdBy, id); err != nil {
+		templateLogger.Error("error deleting template", slog.String("templateId", id), slog.String("error", err.Error()))
+		return fmt.Errorf("error deleting template")
+	}
+	return nil
+}
+
+// PreviewTemplate renders the saved template with the values of the client.
+func (s *TemplateService) PreviewTemplate(ctx context.Context, createdBy, id string, p PreviewInput) (*RenderedTemplate, error) {
+	t, err := s.GetTemplate(ctx, createdBy, id)
+
+	if err != nil {
+		return nil, err
+	}
+	return s.preview(ctx, createdBy, *t, p)
+}
+
+// PreviewDraft renders a template before it is saved.
+func (s *TemplateService) PreviewDraft(ctx context.Context, createdBy string, d DraftPreview) (*RenderedTemplate, error) {
+	if err := d.Template.validate(); err != nil {
+		return nil, err
+	}
+	return s.preview(ctx, createdBy, d.Template.template(), d.PreviewInput)
+}
+
+func (s *TemplateService) preview(ctx context.Context, createdBy string, t Template, p PreviewInput) (*RenderedTemplate, error) {
+	appointment := time.Now()
+	if p.Date != "" {
+		date, err := time.Parse(time.RFC3339, p.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid preview date error: %w", err)
+		}
+		appointment = date
+	}
+	settings, err := getSettingsItem(s.settings, createdBy)
+
+	if err != nil {
+		templateLogger.Error("error getting settings", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error getting creator settings")
+	}
+	client := ClientDto{Id: "client-id", CreatedBy: createdBy, FirstName: "Jane", LastName: "Doe"}
+
+	if p.ClientId != "" {
+		c, err := s.clients.GetClientById(ctx, createdBy, p.ClientId)
+		if err != nil {
+			return nil, err
+		}
+		client = *c
+	}
+	values := newTemplateValues(client, s.apiUrl, appointment, settings)
+	if p.Language != "" {
+		values.Language = p.Language
+	}
+	rendered := t.Render(values)
+	return &rendered, nil
+}
+
+// reminderTemplate returns the template referenced by a notification, nil when it was deleted.
+func (s *TemplateService) reminderTemplate(createdBy, id string) (*Template, error) {
+	item, err := s.store.GetTemplate(createdBy, id)
+
+	if err != nil || item == nil {
+		return nil, err
+	}
+	t := newTemplateFromItem(*item)
+	return &t, nil
+}
+
+func (s *TemplateService) getTemplateItem(createdBy, id string) (*model.TemplateItem, error) {
+	item, err := s.store.GetTemplate(createdBy, id)
+
+	if err != nil {
+		templateLogger.Error("error getting template", slog.String("templateId", id), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("error getting template")
+	}
+	if item == nil {
+		return nil, ErrTemplateNotFound
+	}
+	return item, nil
+}
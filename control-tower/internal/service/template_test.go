@@ -0,0 +1,162 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/japb1998/control-tower/internal/database/memory"
+	"github.com/japb1998/control-tower/internal/service"
+)
+
+func TestTemplates(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	templates := service.NewTemplateService(memory.NewTemplateRepo(), s.clients, s.settings, "https://api.test")
+
+	invalid := []service.TemplateInput{
+		{Name: "unknown", Channel: service.SMS, Body: "Hi {{nickname}}"},
+		{Name: "no subject", Channel: service.Email, Body: "Hi {{firstName}}"},
+	}
+	for _, in := range invalid {
+		if _, err := templates.CreateTemplate(ctx, creator, in); !errors.Is(err, service.ErrInvalidTemplate) {
+			t.Errorf("%s: expected ErrInvalidTemplate, got: %v", in.Name, err)
+		}
+	}
+
+	tmpl, err := templates.CreateTemplate(ctx, creator, service.TemplateInput{
+		Name:    "text",
+		Channel: service.SMS,
+		Subject: "ignored",
+		Body:    "Hi {{ clientName }}, it's been {{weeks}} weeks. See you {{appointmentTime}}. {{optOutUrl}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Subject != "" {
+		t.Errorf("expected text templates to have no subject, got: %s", tmpl.Subject)
+	}
+
+	c := s.createClient(t, time.Now().Add(-3*week-time.Hour))
+	// appointment times are written in the default time zone, new york.
+	date := time.Date(2030, time.March, 4, 20, 30, 0, 0, time.UTC)
+
+	rendered, err := templates.PreviewTemplate(ctx, creator, tmpl.Id, service.PreviewInput{ClientId: c.Id, Date: date.Format(time.RFC3339)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Hi Ana Perez, it's been 3 weeks. See you Monday, March 4 at 3:30 PM. https://api.test/unsubscribe/" + creator + "/" + c.Id
+	if rendered.Body != want {
+		t.Errorf("expected body '%s', got: '%s'", want, rendered.Body)
+	}
+
+	draft, err := templates.PreviewDraft(ctx, creator, service.DraftPreview{Template: service.TemplateInput{Name: "draft", Channel: service.Email, Subject: "{{weeks}} weeks", Body: "Hi {{firstName}}"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sample values use the default weeks without a maintenance interval.
+	if draft.Subject != "2 weeks" || draft.Body != "Hi Jane" {
+		t.Errorf("unexpected draft preview: %+v", draft)
+	}
+	if _, err := templates.PreviewTemplate(ctx, creator, tmpl.Id, service.PreviewInput{ClientId: uuid.New().String()}); !errors.Is(err, service.ErrClientNotFound) {
+		t.Errorf("expected ErrClientNotFound, got: %v", err)
+	}
+
+	updated, err := templates.UpdateTemplate(ctx, creator, tmpl.Id, service.TemplateInput{Name: "text", Channel: service.SMS, Body: "Hi {{firstName}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Body != "Hi {{firstName}}" || updated.CreatedAt != tmpl.CreatedAt {
+		t.Errorf("unexpected updated template: %+v", updated)
+	}
+
+	if err := templates.DeleteTemplate(ctx, creator, tmpl.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := templates.GetTemplate(ctx, creator, tmpl.Id); !errors.Is(err, service.ErrTemplateNotFound) {
+		t.Errorf("expected ErrTemplateNotFound, got: %v", err)
+	}
+	if err := templates.DeleteTemplate(ctx, creator, tmpl.Id); !errors.Is(err, service.ErrTemplateNotFound) {
+		t.Errorf("expected ErrTemplateNotFound, got: %v", err)
+	}
+}
+
+func TestDeliverTemplate(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	store := memory.NewTemplateRepo()
+	s.notifications.WithTemplates(store)
+	templates := service.NewTemplateService(store, s.clients, s.settings, "https://api.test")
+
+	text := &fakeNotifier{channel: service.SMS, messageId: "SM1"}
+	email := &fakeNotifier{channel: service.Email, messageId: "m1"}
+	notifiers := service.NewNotifierRegistry(text, email)
+	messages := s.messageService(notifiers, nil)
+	d := s.deliveryService(notifiers).WithMessages(messages).WithTemplates(templates)
+
+	tmpl, err := templates.CreateTemplate(ctx, creator, service.TemplateInput{Name: "text", Channel: service.SMS, Body: "Hi {{firstName}}, it's been {{weeks}} weeks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := s.createClient(t, time.Now().Add(-5*week))
+
+	input := service.NotificationInput{
+		Date:            time.Now().Add(time.Hour).Format(time.RFC3339),
+		ClientId:        c.Id,
+		DeliveryMethods: []int8{int8(service.Email)},
+		TemplateId:      tmpl.Id,
+	}
+	if _, err := s.notifications.ScheduleNotification(creator, &input); !errors.Is(err, service.ErrTemplateChannel) {
+		t.Fatalf("expected ErrTemplateChannel, got: %v", err)
+	}
+	input.TemplateId = uuid.New().String()
+	if _, err := s.notifications.ScheduleNotification(creator, &input); !errors.Is(err, service.ErrTemplateNotFound) {
+		t.Fatalf("expected ErrTemplateNotFound, got: %v", err)
+	}
+
+	input.TemplateId = tmpl.Id
+	input.DeliveryMethods = []int8{int8(service.SMS), int8(service.Email)}
+	id, err := s.notifications.ScheduleNotification(creator, &input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := s.notifications.GetNotification(creator, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.TemplateId != tmpl.Id {
+		t.Fatalf("expected the notification to reference the template, got: %+v", event)
+	}
+
+	if err := d.Deliver(ctx, *event); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []*fakeNotifier{text, email} {
+		if len(f.reminders) != 1 {
+			t.Fatalf("channel %d: expected 1 reminder, got: %d", f.channel, len(f.reminders))
+		}
+		r := f.reminders[0]
+		if r.Template == nil || r.Template.Id != tmpl.Id || r.Values.Weeks != 5 || r.Values.FirstName != "Ana" {
+			t.Errorf("channel %d: unexpected reminder: %+v", f.channel, r)
+		}
+	}
+
+	thread, err := messages.GetMessages(ctx, creator, c.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodies := make(map[service.ContactOptions]string)
+	for _, m := range thread {
+		bodies[m.Channel] = m.Body
+	}
+	// the template is only sent through its channel, the other channels send their default.
+	if bodies[service.SMS] != "Hi Ana, it's been 5 weeks" {
+		t.Errorf("unexpected text reminder: %s", bodies[service.SMS])
+	}
+	if !strings.Contains(bodies[service.Email], "It's been 5 weeks") {
+		t.Errorf("expected the default reminder by email, got: %s", bodies[service.Email])
+	}
+}
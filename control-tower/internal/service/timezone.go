@@ -0,0 +1,43 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/scheduler"
+)
+
+// checkTimeZone fails with ErrInvalidTimeZone when the name is not in the tz database, empty names are valid.
+func checkTimeZone(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, err := scheduler.LoadTimeZone(name); err != nil {
+		return fmt.Errorf("time zone='%s': %w", name, ErrInvalidTimeZone)
+	}
+	return nil
+}
+
+// timeZone returns the first time zone set, usually the client one and then the creator one, or the default time zone.
+func timeZone(names ...string) string {
+	for _, name := range names {
+		if name != "" {
+			return name
+		}
+	}
+	return scheduler.DefaultTimeZone
+}
+
+// location returns the location of the time zone. time zones were validated when they were stored,
+// the default location is used if the tz database of the machine doesn't have it.
+func location(name string) *time.Location {
+	if loc, err := scheduler.LoadTimeZone(name); err == nil {
+		return loc
+	}
+	loc, err := scheduler.LoadTimeZone(scheduler.DefaultTimeZone)
+
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/japb1998/control-tower/pkg/email"
+	"github.com/japb1998/control-tower/pkg/sms"
+)
+
+// metadata of the sent messages.
+const (
+	notificationIdVariable = "notificationId"
+	createdByVariable      = "createdBy"
+)
+
+// keywords of the inbound replies.
+const (
+	replyStop    = "stop"
+	replyConfirm = "confirm"
+)
+
+// replyKeywords are compared with the whole reply in upper case without accents or punctuation.
+var replyKeywords = map[string]string{
+	"STOP":    replyStop,
+	"BAJA":    replyStop,
+	"CONFIRM": replyConfirm,
+	"SI":      replyConfirm,
+}
+
+// HandleEmailEvent records a mailgun event on the delivery of the message. clients that hard bounce or complain get email disabled,
+// so they are not emailed again. events of messages that were not sent by a notification are ignored, events that arrive
+// before the delivery of the message is recorded fail with ErrDeliveryPending so mailgun sends them again.
+func (d *DeliveryService) HandleEmailEvent(ctx context.Context, e *email.EventData) error {
+	createdBy, id := e.Variable(createdByVariable), e.Variable(notificationIdVariable)
+
+	if createdBy == "" || id == "" {
+		deliveryLogger.Info("ignoring event of unknown message", slog.String("event", e.Event), slog.String("messageId", e.MessageId()))
+		return nil
+	}
+	event := ChannelEvent{
+		Channel:   Email,
+		MessageId: e.MessageId(),
+		At:        e.Time(),
+	}
+	disable := false
+
+	switch e.Event {
+	case email.EventDelivered:
+		event.Status = ChannelDelivered
+	case email.EventOpened:
+		event.Status = ChannelOpened
+	case email.EventFailed:
+		// temporary failures are retried by mailgun.
+		if e.Severity != email.SeverityPermanent {
+			return nil
+		}
+		event.Status = ChannelFailed
+		event.Error = emailFailureReason(e)
+		disable = true
+	case email.EventComplained:
+		// the message was delivered, the complaint only stops the next ones so the delivery is not changed.
+		disable = true
+	default:
+		return nil
+	}
+
+	found, err := d.recordChannelEvent(ctx, createdBy, id, event)
+
+	// the client of a message that is not one of the notification deliveries is not changed either.
+	if err != nil || !found || !disable {
+		return err
+	}
+	return d.disableClientChannel(ctx, createdBy, id, Email)
+}
+
+// HandleMessageStatus records a twilio status callback on the delivery of the message. callbacks of messages that were not sent by a notification are ignored,
+// callbacks that arrive before the delivery of the message is recorded are applied once it is.
+func (d *DeliveryService) HandleMessageStatus(ctx context.Context, s sms.StatusCallback) error {
+	createdBy, id := s.Metadata[createdByVariable], s.Metadata[notificationIdVariable]
+
+	if createdBy == "" || id == "" {
+		deliveryLogger.Info("ignoring status of unknown message", slog.String("status", s.MessageStatus), slog.String("messageId", s.MessageSid))
+		return nil
+	}
+	event := ChannelEvent{
+		Channel:   SMS,
+		MessageId: s.MessageSid,
+		At:        s.At,
+		// twilio does not send the callbacks again, callbacks of messages that are still being sent are kept.
+		Keep: true,
+	}
+	// callbacks without the time of the receipt arrive when the status changes.
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	if s.IsWhatsapp() {
+		event.Channel = Phone
+	}
+
+	switch s.MessageStatus {
+	case sms.StatusDelivered:
+		event.Status = ChannelDelivered
+	case sms.StatusRead:
+		event.Status = ChannelOpened
+	case sms.StatusFailed, sms.StatusUndelivered:
+		event.Status = ChannelFailed
+		event.Error = fmt.Sprintf("%s error code=%s", s.MessageStatus, s.ErrorCode)
+		if s.ErrorMessage != "" {
+			event.Error += ": " + s.ErrorMessage
+		}
+	default:
+		// queued, accepted and sent are reported when the message is sent.
+		return nil
+	}
+
+	_, err := d.recordChannelEvent(ctx, createdBy, id, event)
+	return err
+}
+
+// HandleInboundMessage processes the replies of the clients, they are added to the conversation of the client that was texted.
+// STOP or BAJA opts the client out of the notifications, CONFIRM or SI confirms the last reminder sent to the client.
+// the number is shared by every creator, replies only go to the client of the creator that texted the number last.
+func (d *DeliveryService) HandleInboundMessage(ctx context.Context, m sms.InboundMessage) error {
+	keyword := replyKeyword(m.Body)
+
+	if keyword == "" && d.messages == nil {
+		deliveryLogger.Info("ignoring inbound message", slog.String("messageId", m.MessageSid))
+		return nil
+	}
+	clients, err := d.clients.GetClientsByPhone(ctx, m.Phone())
+
+	if err != nil {
+		return err
+	}
+	if len(clients) == 0 {
+		deliveryLogger.Info("inbound message from unknown number", slog.String("messageId", m.MessageSid))
+		return nil
+	}
+	// clients answer whatsapp messages by sms too.
+	c, err := d.replyRecipient(clients, SMS, Phone)
+
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		deliveryLogger.Info("ignoring reply without a recipient", slog.String("messageId", m.MessageSid), slog.String("keyword", keyword))
+		return nil
+	}
+	channel := SMS
+	if m.IsWhatsapp() {
+		channel = Phone
+	}
+
+	if d.messages != nil {
+		if err := d.messages.RecordInbound(ctx, *c, channel, "", m.Body, m.MessageSid); err != nil {
+			return err
+		}
+	}
+	if keyword == "" {
+		return nil
+	}
+
+	switch keyword {
+	case replyStop:
+		if err := d.clients.OptOut(ctx, c.CreatedBy, c.Id); err != nil {
+			return err
+		}
+		deliveryLogger.Info("client opted out by reply", slog.String("createdBy", c.CreatedBy), slog.String("clientId", c.Id))
+	case replyConfirm:
+		n, err := d.notifications.ConfirmNotification(c.CreatedBy, c.Id, time.Now())
+
+		if errors.Is(err, ErrNothingToConfirm) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		d.broadcast(ctx, NewNotificationUpdateMsg(c.CreatedBy, n.ID), NotificationConfirmedAction)
+	}
+	return nil
+}
+
+// HandleInboundEmail adds the email to the conversation of the client of the creator that emailed the sender last.
+// emails of unknown senders or of senders that were never emailed are ignored.
+func (d *DeliveryService) HandleInboundEmail(ctx context.Context, e *email.InboundEmail) error {
+	if d.messages == nil {
+		deliveryLogger.Info("ignoring inbound email", slog.String("messageId", e.MessageId))
+		return nil
+	}
+	clients, err := d.clients.GetClientsByEmail(ctx, e.Sender)
+
+	if err != nil {
+		return err
+	}
+	if len(clients) == 0 {
+		deliveryLogger.Info("inbound email from unknown sender", slog.String("messageId", e.MessageId))
+		return nil
+	}
+	c, err := d.replyRecipient(clients, Email)
+
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		deliveryLogger.Info("ignoring email without a recipient", slog.String("messageId", e.MessageId))
+		return nil
+	}
+	return d.messages.RecordInbound(ctx, *c, Email, e.Subject, e.Body, e.MessageId)
+}
+
+// replyRecipient returns the client of the creator that sent the last message on any of the channels to the contact, a contact of a
+// single client is always that client. it returns nil when none of the creators messaged the contact.
+func (d *DeliveryService) replyRecipient(clients []ClientDto, channels ...ContactOptions) (*ClientDto, error) {
+	if len(clients) == 1 {
+		return &clients[0], nil
+	}
+	var recipient *ClientDto
+	last := ""
+
+	for i, c := range clients {
+		started, err := d.notifications.LastDeliveryStart(c.CreatedBy, c.Id, channels...)
+
+		if err != nil {
+			return nil, err
+		}
+		if started > last {
+			recipient, last = &clients[i], started
+		}
+	}
+	return recipient, nil
+}
+
+// recordChannelEvent records the event and notifies the creator connections when the delivery changed.
+// it returns false when the message is not one of the notification deliveries.
+func (d *DeliveryService) recordChannelEvent(ctx context.Context, createdBy, id string, event ChannelEvent) (bool, error) {
+	delivery, err := d.notifications.RecordChannelEvent(createdBy, id, event)
+
+	switch {
+	case errors.Is(err, ErrNotificationNotFound), errors.Is(err, ErrDeliveryNotFound):
+		deliveryLogger.Info("ignoring event without delivery", slog.String("notificationId", id), slog.String("status", event.Status), slog.String("error", err.Error()))
+		return false, nil
+	case err != nil:
+		return false, err
+	case delivery != nil:
+		d.broadcast(ctx, NewNotificationUpdateMsg(createdBy, id).WithDeliveries([]Delivery{*delivery}), NotificationUpdatedAction)
+	}
+	return true, nil
+}
+
+// disableClientChannel disables the channel of the client of the notification.
+func (d *DeliveryService) disableClientChannel(ctx context.Context, createdBy, id string, channel ContactOptions) error {
+	n, err := d.notifications.GetNotification(createdBy, id)
+
+	if err != nil {
+		if errors.Is(err, ErrNotificationNotFound) {
+			return nil
+		}
+		return err
+	}
+	_, err = d.clients.DisableChannel(ctx, createdBy, n.ClientId, channel)
+
+	if errors.Is(err, ErrClientNotFound) {
+		return nil
+	}
+	return err
+}
+
+// broadcast notifies the active connections of the creator. errors are logged, the change is already stored.
+func (d *DeliveryService) broadcast(ctx context.Context, update *NotificationUpdate, action string) {
+	msg, err := update.WithAction(action)
+
+	if err != nil {
+		deliveryLogger.Error("failed to generate new notification msg", slog.String("error", err.Error()))
+		return
+	}
+	if err := d.connections.SendWsMessageByEmail(ctx, msg); err != nil {
+		deliveryLogger.Error("failed to send WS message", slog.String("error", err.Error()))
+	}
+}
+
+func emailFailureReason(e *email.EventData) string {
+	reasons := make([]string, 0, 2)
+	for _, r := range []string{e.Reason, e.DeliveryStatus.Message, e.DeliveryStatus.Description} {
+		if r != "" && !slices.Contains(reasons, r) {
+			reasons = append(reasons, r)
+		}
+	}
+	return strings.Join(reasons, ": ")
+}
+
+// accents are removed from the replies, clients answer "sí" as often as "si".
+var accents = strings.NewReplacer("Á", "A", "É", "E", "Í", "I", "Ó", "O", "Ú", "U")
+
+// replyKeyword returns the keyword of the reply or an empty string when the reply is not a keyword.
+func replyKeyword(body string) string {
+	word := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) {
+			return r
+		}
+		return -1
+	}, accents.Replace(strings.ToUpper(body)))
+
+	return replyKeywords[word]
+}
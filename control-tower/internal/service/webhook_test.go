@@ -0,0 +1,332 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/service"
+	"github.com/japb1998/control-tower/pkg/email"
+	"github.com/japb1998/control-tower/pkg/sms"
+)
+
+func emailEvent(event, severity, notificationId, messageId string, at time.Time) *email.EventData {
+	e := &email.EventData{
+		Event:     event,
+		Severity:  severity,
+		Timestamp: float64(at.Unix()),
+		UserVariables: map[string]any{
+			"createdBy":      creator,
+			"notificationId": notificationId,
+		},
+	}
+	e.Message.Headers.MessageId = messageId
+	return e
+}
+
+func TestHandleEmailEvent(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	d := s.deliveryService(service.NewNotifierRegistry(&fakeNotifier{channel: service.Email, messageId: "msg-1@mg.test"}))
+
+	c := s.createClient(t, time.Now().Add(-week))
+	event := s.scheduleFor(t, c.Id, service.Email)
+
+	if _, err := d.SendNow(ctx, creator, event.ID); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	steps := []struct {
+		event  *email.EventData
+		status string
+	}{
+		{emailEvent(email.EventDelivered, "", event.ID, "msg-1@mg.test", now), service.ChannelDelivered},
+		{emailEvent(email.EventOpened, "", event.ID, "msg-1@mg.test", now), service.ChannelOpened},
+		// events never move the delivery back.
+		{emailEvent(email.EventDelivered, "", event.ID, "msg-1@mg.test", now), service.ChannelOpened},
+		// events of other messages are ignored.
+		{emailEvent(email.EventFailed, email.SeverityPermanent, event.ID, "other@mg.test", now), service.ChannelOpened},
+		{emailEvent(email.EventDelivered, "", "missing", "msg-1@mg.test", now), service.ChannelOpened},
+	}
+
+	for i, step := range steps {
+		if err := d.HandleEmailEvent(ctx, step.event); err != nil {
+			t.Fatalf("step %d: %s", i, err)
+		}
+		n, err := s.notifications.GetNotification(creator, event.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(n.Deliveries) != 1 || n.Deliveries[0].Status != step.status {
+			t.Fatalf("step %d: expected delivery status %s, got: %+v", i, step.status, n.Deliveries)
+		}
+		if n.Status != string(service.SentStatus) {
+			t.Errorf("step %d: expected the notification to stay sent, got: %s", i, n.Status)
+		}
+	}
+
+	client, err := s.clients.GetClientById(ctx, creator, c.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(client.DisabledChannels) != 0 {
+		t.Errorf("expected email to stay enabled, got: %v", client.DisabledChannels)
+	}
+}
+
+func TestHandleEmailEventDisablesEmail(t *testing.T) {
+	cases := []struct {
+		name     string
+		event    string
+		severity string
+		status   string
+		disabled bool
+	}{
+		{"temporary failure", email.EventFailed, "temporary", service.ChannelSent, false},
+		{"hard bounce", email.EventFailed, email.SeverityPermanent, service.ChannelFailed, true},
+		{"complaint", email.EventComplained, "", service.ChannelSent, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServices(t)
+			ctx := context.Background()
+			mail := &fakeNotifier{channel: service.Email, messageId: "msg-1@mg.test"}
+			d := s.deliveryService(service.NewNotifierRegistry(mail))
+
+			c := s.createClient(t, time.Now().Add(-week))
+			event := s.scheduleFor(t, c.Id, service.Email)
+
+			if _, err := d.SendNow(ctx, creator, event.ID); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.HandleEmailEvent(ctx, emailEvent(tc.event, tc.severity, event.ID, "<msg-1@mg.test>", time.Now())); err != nil {
+				t.Fatal(err)
+			}
+			n, err := s.notifications.GetNotification(creator, event.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n.Deliveries[0].Status != tc.status {
+				t.Errorf("expected delivery status %s, got: %s", tc.status, n.Deliveries[0].Status)
+			}
+			if tc.status == service.ChannelFailed && n.Status != string(service.FailedStatus) {
+				t.Errorf("expected the notification to fail, got: %s", n.Status)
+			}
+
+			client, err := s.clients.GetClientById(ctx, creator, c.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if disabled := slices.Contains(client.DisabledChannels, int8(service.Email)); disabled != tc.disabled {
+				t.Fatalf("expected email disabled=%t, got: %v", tc.disabled, client.DisabledChannels)
+			}
+
+			// the next reminders skip the disabled channel.
+			next := s.scheduleFor(t, c.Id, service.Email)
+			if err := d.Deliver(ctx, next); err != nil {
+				t.Fatal(err)
+			}
+			if sent := len(mail.reminders); tc.disabled && sent != 1 || !tc.disabled && sent != 2 {
+				t.Errorf("expected email disabled=%t, got %d emails", tc.disabled, sent)
+			}
+		})
+	}
+}
+
+func TestHandleEmailEventBeforeDelivery(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	d := s.deliveryService(service.NewNotifierRegistry(&fakeNotifier{channel: service.Email, messageId: "msg-1@mg.test"}))
+
+	c := s.createClient(t, time.Now().Add(-week))
+	event := s.scheduleFor(t, c.Id, service.Email)
+
+	// the send claimed the notification but did not record its deliveries yet.
+	if err := s.notifications.ClaimSend(creator, event.ID); err != nil {
+		t.Fatal(err)
+	}
+	delivered := emailEvent(email.EventDelivered, "", event.ID, "msg-1@mg.test", time.Now())
+	if err := d.HandleEmailEvent(ctx, delivered); !errors.Is(err, service.ErrDeliveryPending) {
+		t.Fatalf("expected ErrDeliveryPending, got: %v", err)
+	}
+	// old events are not retried forever.
+	if err := d.HandleEmailEvent(ctx, emailEvent(email.EventDelivered, "", event.ID, "msg-1@mg.test", time.Now().Add(-time.Hour*2))); err != nil {
+		t.Fatalf("expected old events to be ignored, got: %v", err)
+	}
+
+	// mailgun sends the event again once the delivery is recorded.
+	if _, err := s.notifications.RecordDelivery(creator, event.ID, service.DeliveryRecord{
+		Status:  service.SentStatus,
+		Results: []service.ChannelResult{{Channel: service.Email, Status: service.ChannelSent, MessageId: "msg-1@mg.test"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.HandleEmailEvent(ctx, delivered); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.notifications.GetNotification(creator, event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(n.Deliveries) != 1 || n.Deliveries[0].Status != service.ChannelDelivered {
+		t.Fatalf("expected the delivery to be delivered, got: %+v", n.Deliveries)
+	}
+}
+
+func TestHandleMessageStatus(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	d := s.deliveryService(service.NewNotifierRegistry(
+		&fakeNotifier{channel: service.Phone, messageId: "SM1"},
+		&fakeNotifier{channel: service.SMS, messageId: "SM2"},
+	))
+
+	c := s.createClient(t, time.Now().Add(-week))
+	event := s.scheduleFor(t, c.Id, service.Phone, service.SMS)
+
+	if _, err := d.SendNow(ctx, creator, event.ID); err != nil {
+		t.Fatal(err)
+	}
+	metadata := map[string]string{"createdBy": creator, "notificationId": event.ID}
+
+	callbacks := []sms.StatusCallback{
+		{MessageSid: "SM1", MessageStatus: sms.StatusRead, To: "whatsapp:" + c.Phone, Metadata: metadata},
+		{MessageSid: "SM1", MessageStatus: sms.StatusDelivered, To: "whatsapp:" + c.Phone, Metadata: metadata},
+		{MessageSid: "SM2", MessageStatus: sms.StatusUndelivered, ErrorCode: "30003", To: c.Phone, Metadata: metadata},
+		// the sid is not a delivery of the channel.
+		{MessageSid: "SM2", MessageStatus: sms.StatusDelivered, To: "whatsapp:" + c.Phone, Metadata: metadata},
+		{MessageSid: "SM3", MessageStatus: sms.StatusDelivered, To: c.Phone},
+	}
+	for _, cb := range callbacks {
+		if err := d.HandleMessageStatus(ctx, cb); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := s.notifications.GetNotification(creator, event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := make(map[service.ContactOptions]service.Delivery)
+	for _, delivery := range n.Deliveries {
+		statuses[delivery.Channel] = delivery
+	}
+	if statuses[service.Phone].Status != service.ChannelOpened {
+		t.Errorf("expected the whatsapp message to be read, got: %+v", statuses[service.Phone])
+	}
+	if text := statuses[service.SMS]; text.Status != service.ChannelFailed || text.Error != "undelivered error code=30003" {
+		t.Errorf("expected the SMS to fail, got: %+v", text)
+	}
+	if n.Status != string(service.FailedStatus) {
+		t.Errorf("expected the notification to fail, got: %s", n.Status)
+	}
+}
+
+// callbackNotifier runs the callback once the message is sent, like a provider that answers before the delivery is recorded.
+type callbackNotifier struct {
+	*fakeNotifier
+	callback func(r service.Reminder)
+}
+
+func (c *callbackNotifier) Notify(ctx context.Context, r service.Reminder) (string, error) {
+	id, err := c.fakeNotifier.Notify(ctx, r)
+	c.callback(r)
+	return id, err
+}
+
+func TestHandleMessageStatusBeforeDelivery(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	notifier := &callbackNotifier{fakeNotifier: &fakeNotifier{channel: service.SMS, messageId: "SM1"}}
+	d := s.deliveryService(service.NewNotifierRegistry(notifier))
+
+	c := s.createClient(t, time.Now().Add(-week))
+	event := s.scheduleFor(t, c.Id, service.SMS)
+	at := time.Now().UTC().Add(-time.Minute).Truncate(time.Minute)
+
+	notifier.callback = func(r service.Reminder) {
+		metadata := map[string]string{"createdBy": r.CreatedBy, "notificationId": r.NotificationId}
+
+		if err := d.HandleMessageStatus(ctx, sms.StatusCallback{MessageSid: "SM1", MessageStatus: sms.StatusDelivered, To: c.Phone, At: at, Metadata: metadata}); err != nil {
+			t.Errorf("expected the early callback to be kept, got: %v", err)
+		}
+	}
+	if _, err := d.SendNow(ctx, creator, event.ID); err != nil {
+		t.Fatal(err)
+	}
+	item, err := s.store.GetNotification(creator, event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(item.Deliveries) != 1 || item.Deliveries[0].Status != service.ChannelDelivered || item.Deliveries[0].UpdatedAt != at.Format(time.RFC3339) || len(item.PendingEvents) != 0 {
+		t.Fatalf("expected the callback to be applied with its time, got: %+v", item)
+	}
+}
+
+func TestHandleInboundMessage(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	d := s.deliveryService(service.NewNotifierRegistry(&fakeNotifier{channel: service.Phone, messageId: "SM1"}))
+
+	c := s.createClient(t, time.Now().Add(-week))
+	event := s.scheduleFor(t, c.Id, service.Phone)
+
+	if _, err := d.SendNow(ctx, creator, event.ID); err != nil {
+		t.Fatal(err)
+	}
+	ls := time.Now().Format(time.RFC3339)
+	other, err := s.clients.CreateClient(ctx, "other@test.com", service.CreateClient{FirstName: "Ana", Phone: c.Phone, LastSeen: &ls})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// replies that are not keywords change nothing.
+	if err := d.HandleInboundMessage(ctx, sms.InboundMessage{From: "whatsapp:" + c.Phone, Body: "si puedo el martes?"}); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.notifications.GetNotification(creator, event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.ConfirmedAt != "" {
+		t.Fatalf("expected the notification to not be confirmed, got: %s", n.ConfirmedAt)
+	}
+
+	if err := d.HandleInboundMessage(ctx, sms.InboundMessage{From: "whatsapp:" + c.Phone, Body: " ¡Sí! "}); err != nil {
+		t.Fatal(err)
+	}
+	n, err = s.notifications.GetNotification(creator, event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.ConfirmedAt == "" {
+		t.Fatal("expected the notification to be confirmed")
+	}
+
+	// the reply only opts out the client of the creator that texted the number.
+	if err := d.HandleInboundMessage(ctx, sms.InboundMessage{From: c.Phone, Body: "baja"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		client service.ClientDto
+		optIn  bool
+	}{{c, false}, {other, true}} {
+		cl, optIn := tc.client, tc.optIn
+		got, err := s.clients.GetClientById(ctx, cl.CreatedBy, cl.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.OptIn == nil || *got.OptIn != optIn {
+			t.Errorf("expected opt in of the client of %s to be %t, got: %v", cl.CreatedBy, optIn, got.OptIn)
+		}
+	}
+
+	// unknown numbers are ignored.
+	if err := d.HandleInboundMessage(ctx, sms.InboundMessage{From: "+17869999999", Body: "STOP"}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/model"
+)
+
+// what happens to the notifications scheduled outside of the send window.
+const (
+	OutOfWindowReject = "reject"
+	OutOfWindowShift  = "shift" // moved to the next time inside of the window.
+)
+
+// windowTimeLayout is the layout of the start and end of the send windows.
+const windowTimeLayout = "15:04"
+
+var (
+	ErrInvalidSendWindow = errors.New("invalid send window")
+	ErrOutsideSendWindow = errors.New("date is outside of the creator send window")
+)
+
+// SendWindow are the hours reminders can be sent at in the time zone of the notification, the end is included.
+// Days are the allowed week days, 0 is sunday, every day is allowed when empty.
+// OutOfWindow rejects the notifications outside of the window or shifts them to the next allowed time, it defaults to reject.
+type SendWindow struct {
+	Start       string `json:"start" binding:"required,datetime=15:04"`
+	End         string `json:"end" binding:"required,datetime=15:04"`
+	Days        []int8 `json:"days,omitempty" binding:"omitempty,dive,min=0,max=6"`
+	OutOfWindow string `json:"outOfWindow" binding:"omitempty,oneof=reject shift"`
+}
+
+func newSendWindowFromItem(item *model.SendWindowItem) *SendWindow {
+	if item == nil {
+		return nil
+	}
+	return &SendWindow{
+		Start:       item.Start,
+		End:         item.End,
+		Days:        item.Days,
+		OutOfWindow: item.OutOfWindow,
+	}
+}
+
+// item validates the window and returns it as it is stored.
+func (w SendWindow) item() (*model.SendWindowItem, error) {
+	start, err := time.Parse(windowTimeLayout, w.Start)
+
+	if err != nil {
+		return nil, fmt.Errorf("start='%s': %w", w.Start, ErrInvalidSendWindow)
+	}
+	end, err := time.Parse(windowTimeLayout, w.End)
+
+	if err != nil {
+		return nil, fmt.Errorf("end='%s': %w", w.End, ErrInvalidSendWindow)
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("the start must be before the end: %w", ErrInvalidSendWindow)
+	}
+	for _, d := range w.Days {
+		if d < 0 || d > 6 {
+			return nil, fmt.Errorf("day='%d' must be between 0 and 6: %w", d, ErrInvalidSendWindow)
+		}
+	}
+	policy := w.OutOfWindow
+	if policy == "" {
+		policy = OutOfWindowReject
+	}
+	if policy != OutOfWindowReject && policy != OutOfWindowShift {
+		return nil, fmt.Errorf("outOfWindow='%s': %w", w.OutOfWindow, ErrInvalidSendWindow)
+	}
+	days := slices.Clone(w.Days)
+	slices.Sort(days)
+
+	return &model.SendWindowItem{
+		Start:       w.Start,
+		End:         w.End,
+		Days:        slices.Compact(days),
+		OutOfWindow: policy,
+	}, nil
+}
+
+// nextInWindow returns the date when it is inside of the window, otherwise the start of the next allowed day.
+// the window hours are read in the location, DST changes move the start when it falls in a skipped hour.
+func nextInWindow(w model.SendWindowItem, date time.Time, loc *time.Location) time.Time {
+	// item already validated the times.
+	start, _ := time.Parse(windowTimeLayout, w.Start)
+	end, _ := time.Parse(windowTimeLayout, w.End)
+	local := date.In(loc)
+
+	// a week later is always an allowed day.
+	for i := 0; i <= 7; i++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+i, 0, 0, 0, 0, loc)
+
+		if len(w.Days) > 0 && !slices.Contains(w.Days, int8(day.Weekday())) {
+			continue
+		}
+		dayStart := wallClock(day, start, loc)
+		dayEnd := wallClock(day, end, loc)
+
+		if i == 0 && local.After(dayEnd) {
+			continue
+		}
+		if local.Before(dayStart) {
+			return dayStart
+		}
+		return date
+	}
+	return date
+}
+
+// wallClock returns the hour and minute of clock on the day. times skipped when DST starts are moved forward by the skipped time.
+func wallClock(day, clock time.Time, loc *time.Location) time.Time {
+	t := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+
+	if t.Hour() == clock.Hour() && t.Minute() == clock.Minute() {
+		return t
+	}
+	_, before := t.Zone()
+	_, after := t.Add(time.Hour * 3).Zone()
+	return t.Add(time.Duration(after-before) * time.Second)
+}
+
+// applySendWindow returns the date the notification is sent at. dates outside of the creator window fail with ErrOutsideSendWindow
+// or are shifted to the next allowed time, depending on the policy. an empty policy uses the creator one.
+func (s *NotificationService) applySendWindow(createdBy string, date time.Time, tz, policy string) (time.Time, error) {
+	settings, err := getSettingsItem(s.settings, createdBy)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error getting send window error: %w", err)
+	}
+	if settings.SendWindow == nil {
+		return date, nil
+	}
+	next := nextInWindow(*settings.SendWindow, date, location(tz))
+
+	if next.Equal(date) {
+		return date, nil
+	}
+	if policy == "" {
+		policy = settings.SendWindow.OutOfWindow
+	}
+	if policy != OutOfWindowShift {
+		return time.Time{}, fmt.Errorf("date='%s' window='%s-%s' time zone='%s': %w", date.Format(time.RFC3339), settings.SendWindow.Start, settings.SendWindow.End, tz, ErrOutsideSendWindow)
+	}
+	notificationLogger.Info("date shifted to the send window", slog.String("createdBy", createdBy), slog.Time("date", date), slog.Time("shifted", next))
+	return next, nil
+}
@@ -0,0 +1,157 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/japb1998/control-tower/internal/service"
+)
+
+func TestSendWindowSettings(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	settings := service.NewSettingsService(s.settings)
+	weeks := 0
+
+	invalid := []service.SendWindow{
+		{Start: "20:00", End: "09:00"},
+		{Start: "9am", End: "20:00"},
+		{Start: "09:00", End: "20:00", Days: []int8{7}},
+		{Start: "09:00", End: "20:00", OutOfWindow: "ignore"},
+	}
+	for _, w := range invalid {
+		if _, err := settings.SaveSettings(ctx, creator, service.PutSettings{MaintenanceIntervalWeeks: &weeks, SendWindow: &w}); !errors.Is(err, service.ErrInvalidSendWindow) {
+			t.Errorf("window %+v: expected ErrInvalidSendWindow, got: %v", w, err)
+		}
+	}
+
+	saved, err := settings.SaveSettings(ctx, creator, service.PutSettings{MaintenanceIntervalWeeks: &weeks, SendWindow: &service.SendWindow{Start: "09:00", End: "20:00", Days: []int8{6, 1, 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := saved.SendWindow; w == nil || w.OutOfWindow != service.OutOfWindowReject || len(w.Days) != 2 || w.Days[0] != 1 {
+		t.Fatalf("unexpected send window: %+v", saved.SendWindow)
+	}
+
+	// the window is kept when it is not provided.
+	saved, err = settings.SaveSettings(ctx, creator, service.PutSettings{MaintenanceIntervalWeeks: &weeks})
+	if err != nil || saved.SendWindow == nil {
+		t.Fatalf("expected the send window to be kept, got: %+v, %v", saved, err)
+	}
+	saved, err = settings.SaveSettings(ctx, creator, service.PutSettings{MaintenanceIntervalWeeks: &weeks, RemoveSendWindow: true})
+	if err != nil || saved.SendWindow != nil {
+		t.Fatalf("expected the send window to be removed, got: %+v, %v", saved, err)
+	}
+}
+
+func TestSendWindow(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	settings := service.NewSettingsService(s.settings)
+	c := s.createClient(t, time.Now())
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	weeks := 0
+	window := service.SendWindow{Start: "09:00", End: "20:00", Days: []int8{1, 2, 3, 4, 5, 6}}
+
+	if _, err := settings.SaveSettings(ctx, creator, service.PutSettings{MaintenanceIntervalWeeks: &weeks, TimeZone: "America/New_York", SendWindow: &window}); err != nil {
+		t.Fatal(err)
+	}
+	create := func(date time.Time) (*service.Notification, error) {
+		return s.notifications.CreateNotification(creator, &service.NotificationInput{
+			Date:            date.Format(time.RFC3339),
+			ClientId:        c.Id,
+			DeliveryMethods: []int8{int8(service.SMS)},
+		})
+	}
+
+	inside := time.Date(2031, time.June, 14, 10, 0, 0, 0, ny)
+	n, err := create(inside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.RequestedDate != "" || !sameTime(n.Date, inside) {
+		t.Errorf("expected dates inside of the window to be kept, got: %+v", n)
+	}
+
+	for _, date := range []time.Time{
+		time.Date(2031, time.June, 14, 20, 30, 0, 0, ny), // after the end.
+		time.Date(2031, time.June, 15, 12, 0, 0, 0, ny),  // sunday.
+	} {
+		if _, err := create(date); !errors.Is(err, service.ErrOutsideSendWindow) {
+			t.Errorf("date %s: expected ErrOutsideSendWindow, got: %v", date, err)
+		}
+	}
+
+	window.OutOfWindow = service.OutOfWindowShift
+	if _, err := settings.SaveSettings(ctx, creator, service.PutSettings{MaintenanceIntervalWeeks: &weeks, SendWindow: &window}); err != nil {
+		t.Fatal(err)
+	}
+	requested := time.Date(2031, time.June, 14, 20, 30, 0, 0, ny)
+	shifted, err := create(requested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// saturday night moves to monday morning, sunday is not allowed.
+	monday := time.Date(2031, time.June, 16, 9, 0, 0, 0, ny)
+	if !sameTime(shifted.Date, monday) || shifted.RequestedDate != requested.Format(time.RFC3339) {
+		t.Errorf("expected the notification to be moved to %s, got: %+v", monday, shifted)
+	}
+	sch, err := s.scheduler.GetSchedule(shifted.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sch.Date.Equal(monday) {
+		t.Errorf("expected the schedule at %s, got: %s", monday, sch.Date)
+	}
+	stored, err := s.notifications.GetNotification(creator, shifted.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.RequestedDate != shifted.RequestedDate {
+		t.Errorf("expected the requested date to be stored, got: %+v", stored)
+	}
+
+	early := time.Date(2031, time.June, 17, 7, 0, 0, 0, ny)
+	updated, err := s.notifications.UpdateNotification(creator, shifted.ID, service.PatchNotification{Date: early.Format(time.RFC3339)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameTime(updated.Date, time.Date(2031, time.June, 17, 9, 0, 0, 0, ny)) || updated.RequestedDate != early.Format(time.RFC3339) {
+		t.Errorf("expected the update to be moved to the start of the window, got: %+v", updated)
+	}
+	updated, err = s.notifications.UpdateNotification(creator, shifted.ID, service.PatchNotification{Date: inside.Format(time.RFC3339)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err = s.notifications.GetNotification(creator, shifted.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.RequestedDate != "" || stored.RequestedDate != "" {
+		t.Errorf("expected the requested date to be removed, got: %+v, %+v", updated, stored)
+	}
+
+	// the start of the window is skipped when DST starts, the next valid time is used.
+	window = service.SendWindow{Start: "02:30", End: "20:00", OutOfWindow: service.OutOfWindowShift}
+	if _, err := settings.SaveSettings(ctx, creator, service.PutSettings{MaintenanceIntervalWeeks: &weeks, SendWindow: &window}); err != nil {
+		t.Fatal(err)
+	}
+	dst, err := create(time.Date(2031, time.March, 9, 1, 0, 0, 0, ny))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2031, time.March, 9, 7, 30, 0, 0, time.UTC); !sameTime(dst.Date, want) {
+		t.Errorf("expected %s, got: %s", want, dst.Date)
+	}
+}
+
+// sameTime reports if the RFC3339 date is the time, whatever offset it was written with.
+func sameTime(date string, want time.Time) bool {
+	d, err := time.Parse(time.RFC3339, date)
+	return err == nil && d.Equal(want)
+}
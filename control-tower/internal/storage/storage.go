@@ -0,0 +1,72 @@
+// storage package builds the repositories for the backend selected with the DATABASE env variable.
+package storage
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/japb1998/control-tower/internal/database"
+	"github.com/japb1998/control-tower/internal/database/memory"
+	"github.com/japb1998/control-tower/internal/database/sqldb"
+	"github.com/japb1998/control-tower/internal/service"
+)
+
+const (
+	Dynamo   = "dynamo"
+	Postgres = sqldb.DriverPostgres
+	SQLite   = sqldb.DriverSQLite
+	Memory   = "memory"
+)
+
+type Repositories struct {
+	Clients       service.ClientRepository
+	Notifications service.NotificationRepository
+	Connections   service.ConnectionRepo
+	Settings      service.SettingsRepository
+	Messages      service.MessageRepository
+	Templates     service.TemplateRepository
+}
+
+// FromEnv returns the repositories of the DATABASE backend, dynamo when it is not set. sql backends connect to DATABASE_URL.
+func FromEnv(sess *session.Session) (*Repositories, error) {
+	return New(os.Getenv("DATABASE"), os.Getenv("DATABASE_URL"), sess)
+}
+
+func New(backend, dsn string, sess *session.Session) (*Repositories, error) {
+	switch backend {
+	case "", Dynamo:
+		return &Repositories{
+			Clients:       database.NewClientRepo(sess),
+			Notifications: database.NewNotificationRepository(sess),
+			Connections:   database.NewConnectionRepo(sess),
+			Settings:      database.NewSettingsRepo(sess),
+			Messages:      database.NewMessageRepo(sess),
+			Templates:     database.NewTemplateRepo(sess),
+		}, nil
+	case Postgres, SQLite:
+		db, err := sqldb.Open(backend, dsn)
+
+		if err != nil {
+			return nil, err
+		}
+		return &Repositories{
+			Clients:       sqldb.NewClientRepo(db),
+			Notifications: sqldb.NewNotificationRepo(db),
+			Connections:   sqldb.NewConnectionRepo(db),
+			Settings:      sqldb.NewSettingsRepo(db),
+			Messages:      sqldb.NewMessageRepo(db),
+			Templates:     sqldb.NewTemplateRepo(db),
+		}, nil
+	case Memory:
+		return &Repositories{
+			Clients:       memory.NewClientRepo(),
+			Notifications: memory.NewNotificationRepo(),
+			Connections:   memory.NewConnectionRepo(),
+			Settings:      memory.NewSettingsRepo(),
+			Messages:      memory.NewMessageRepo(),
+			Templates:     memory.NewTemplateRepo(),
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported database='%s'", backend)
+}
@@ -12,8 +12,9 @@ var (
 )
 
 type EmailSvcOpts struct {
-	Domain string `json:"domain"`
-	ApiKey string `json:"apiKey"`
+	Domain            string `json:"domain"`
+	ApiKey            string `json:"apiKey"`
+	WebhookSigningKey string `json:"webhookSigningKey"` // used to verify the event webhooks.
 }
 
 type Email struct {
@@ -21,9 +22,12 @@ type Email struct {
 	Subject    string
 	Html       string
 	From       string
+	ReplyTo    string // address the client replies go to, the from address when empty.
 	Variables  *map[string]any
 	To         []string
 	Cc         []string
+	// UserVariables are attached to the message and sent back in the webhook events.
+	UserVariables map[string]string
 }
 
 type EmailService struct {
@@ -47,9 +51,10 @@ func NewEmail(templateId, html, subject, from string, variables *map[string]any,
 	}
 }
 
-func (s *EmailService) Send(ctx context.Context, email *Email) (err error) {
+// Send sends the email and returns the mailgun message id.
+func (s *EmailService) Send(ctx context.Context, email *Email) (id string, err error) {
 	if email == nil || (email.Html == "" && email.TemplateId == "") {
-		return ErrEmptyEmail
+		return "", ErrEmptyEmail
 	}
 	m := s.client.NewMessage(email.From, email.Subject, email.Html, email.To...)
 
@@ -60,10 +65,18 @@ func (s *EmailService) Send(ctx context.Context, email *Email) (err error) {
 			err = m.AddTemplateVariable(k, v)
 
 			if err != nil {
-				return err
+				return "", err
 			}
 		}
 	}
-	_, _, err = s.client.Send(ctx, m)
-	return err
+	if email.ReplyTo != "" {
+		m.SetReplyTo(email.ReplyTo)
+	}
+	for k, v := range email.UserVariables {
+		if err = m.AddVariable(k, v); err != nil {
+			return "", err
+		}
+	}
+	_, id, err = s.client.Send(ctx, m)
+	return NormalizeMessageId(id), err
 }
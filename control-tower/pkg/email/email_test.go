@@ -42,7 +42,7 @@ func TestEmail(t *testing.T) {
 
 	svc := email.NewEmailService(&ops)
 	e := email.NewEmail("lashroom", "", "test subject", "no-reply@webdevlife.me", &map[string]any{"op_out_url": "webdevlife.me", "customer_name": "Javier Perez"}, []string{"japb.dev@gmail.com"}, nil)
-	err = svc.Send(context.Background(), e)
+	_, err = svc.Send(context.Background(), e)
 
 	if err != nil {
 		t.Fatal(err)
@@ -0,0 +1,50 @@
+package email
+
+import (
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// InboundEmail is an email forwarded by a mailgun route.
+type InboundEmail struct {
+	Sender    string
+	Recipient string
+	Subject   string
+	Body      string // the reply without the quoted message when mailgun can strip it.
+	MessageId string
+}
+
+// ParseInboundEmail verifies the signature of the form posted by a mailgun route with the signing key and returns the email.
+func ParseInboundEmail(form url.Values, signingKey string, now time.Time) (*InboundEmail, error) {
+	signature := WebhookSignature{
+		Timestamp: form.Get("timestamp"),
+		Token:     form.Get("token"),
+		Signature: form.Get("signature"),
+	}
+	if err := signature.Verify(signingKey, now); err != nil {
+		return nil, err
+	}
+
+	sender := form.Get("sender")
+	// the sender is the envelope address, the from header can have the name too.
+	if addr, err := mail.ParseAddress(sender); err == nil {
+		sender = addr.Address
+	}
+	if sender == "" {
+		return nil, ErrInvalidWebhook
+	}
+	body := strings.TrimSpace(form.Get("stripped-text"))
+	if body == "" {
+		body = strings.TrimSpace(form.Get("body-plain"))
+	}
+
+	return &InboundEmail{
+		Sender:    strings.ToLower(sender),
+		Recipient: form.Get("recipient"),
+		Subject:   form.Get("subject"),
+		Body:      body,
+		MessageId: NormalizeMessageId(form.Get("Message-Id")),
+	}, nil
+}
@@ -0,0 +1,46 @@
+package email_test
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/japb1998/control-tower/pkg/email"
+)
+
+func TestParseInboundEmail(t *testing.T) {
+	now := time.Now()
+	ts := strconv.FormatInt(now.Unix(), 10)
+	form := url.Values{
+		"timestamp":     {ts},
+		"token":         {"token-1"},
+		"signature":     {sign(signingKey, ts, "token-1")},
+		"sender":        {"Ana@Test.com"},
+		"recipient":     {"replies@mg.test.com"},
+		"subject":       {"Re: Lash Room"},
+		"body-plain":    {"See you tuesday\n\n> On Monday Lash Room wrote:"},
+		"stripped-text": {"See you tuesday"},
+		"Message-Id":    {"<20231114.2@mail.test.com>"},
+	}
+
+	e, err := email.ParseInboundEmail(form, signingKey, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Sender != "ana@test.com" || e.Body != "See you tuesday" || e.Subject != "Re: Lash Room" || e.MessageId != "20231114.2@mail.test.com" {
+		t.Errorf("unexpected email %+v", e)
+	}
+
+	// the full body is used when mailgun can't strip the quote.
+	form.Del("stripped-text")
+	if e, err := email.ParseInboundEmail(form, signingKey, now); err != nil || e.Body != "See you tuesday\n\n> On Monday Lash Room wrote:" {
+		t.Errorf("expected the plain body, got: %+v, %v", e, err)
+	}
+
+	form.Set("signature", sign("other", ts, "token-1"))
+	if _, err := email.ParseInboundEmail(form, signingKey, now); !errors.Is(err, email.ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature, got: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package email
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// SecretGetter returns the secret string for the provided id.
+type SecretGetter interface {
+	GetSecret(secretArn string) (*string, error)
+}
+
+// LoadOpts returns the mailgun options from MAILGUN_DOMAIN, MAILGUN_API_KEY and MAILGUN_WEBHOOK_SIGNING_KEY, when they are not set the options are read from the secret.
+func LoadOpts(sm SecretGetter, secretArn string) (*EmailSvcOpts, error) {
+	if domain, key := os.Getenv("MAILGUN_DOMAIN"), os.Getenv("MAILGUN_API_KEY"); domain != "" && key != "" {
+		return &EmailSvcOpts{
+			Domain:            domain,
+			ApiKey:            key,
+			WebhookSigningKey: os.Getenv("MAILGUN_WEBHOOK_SIGNING_KEY"),
+		}, nil
+	}
+
+	b, err := sm.GetSecret(secretArn)
+
+	if err != nil {
+		return nil, fmt.Errorf("error getting secret arn='%s' error: %w", secretArn, err)
+	}
+	var ops EmailSvcOpts
+
+	if err := json.Unmarshal([]byte(*b), &ops); err != nil {
+		return nil, fmt.Errorf("error unmarshalling secret error: %w", err)
+	}
+	return &ops, nil
+}
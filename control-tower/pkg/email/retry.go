@@ -0,0 +1,26 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/mailgun/mailgun-go/v4"
+)
+
+// IsRetryable reports whether sending the email again can succeed. rate limits, mailgun server errors and network errors are retryable,
+// rejected requests are not.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, ErrEmptyEmail) {
+		return false
+	}
+	var unexpected *mailgun.UnexpectedResponseError
+
+	if errors.As(err, &unexpected) {
+		return unexpected.Actual == http.StatusTooManyRequests || unexpected.Actual >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
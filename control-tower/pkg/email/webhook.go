@@ -0,0 +1,128 @@
+package email
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mailgun webhook events
+const (
+	EventDelivered  = "delivered"
+	EventOpened     = "opened"
+	EventFailed     = "failed"
+	EventComplained = "complained"
+
+	SeverityPermanent = "permanent" // hard bounces, mailgun does not retry them.
+)
+
+// WebhookMaxAge is how old a webhook signature can be. older requests are rejected so they can't be replayed.
+const WebhookMaxAge = time.Minute * 5
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidWebhook   = errors.New("invalid webhook payload")
+)
+
+// Webhook is the body mailgun posts for every event.
+type Webhook struct {
+	Signature WebhookSignature `json:"signature"`
+	EventData EventData        `json:"event-data"`
+}
+
+type WebhookSignature struct {
+	Timestamp string `json:"timestamp"`
+	Token     string `json:"token"`
+	Signature string `json:"signature"`
+}
+
+// EventData is the mailgun event. UserVariables are the variables set on the sent message.
+type EventData struct {
+	Event          string         `json:"event"`
+	Severity       string         `json:"severity"`
+	Reason         string         `json:"reason"`
+	Recipient      string         `json:"recipient"`
+	Timestamp      float64        `json:"timestamp"`
+	UserVariables  map[string]any `json:"user-variables"`
+	DeliveryStatus struct {
+		Code        int    `json:"code"`
+		Message     string `json:"message"`
+		Description string `json:"description"`
+	} `json:"delivery-status"`
+	Message struct {
+		Headers struct {
+			MessageId string `json:"message-id"`
+		} `json:"headers"`
+	} `json:"message"`
+}
+
+// ParseWebhook verifies the signature of the webhook body with the signing key and returns the event.
+func ParseWebhook(body []byte, signingKey string, now time.Time) (*EventData, error) {
+	var w Webhook
+
+	if err := json.Unmarshal(body, &w); err != nil {
+		return nil, fmt.Errorf("error='%s': %w", err, ErrInvalidWebhook)
+	}
+	if err := w.Signature.Verify(signingKey, now); err != nil {
+		return nil, err
+	}
+	return &w.EventData, nil
+}
+
+// Verify checks the signature is the HMAC of the timestamp and token and that it is not older than WebhookMaxAge.
+func (s WebhookSignature) Verify(signingKey string, now time.Time) error {
+	if signingKey == "" || s.Signature == "" {
+		return ErrInvalidSignature
+	}
+	ts, err := strconv.ParseInt(s.Timestamp, 10, 64)
+
+	if err != nil {
+		return fmt.Errorf("invalid timestamp='%s': %w", s.Timestamp, ErrInvalidSignature)
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > WebhookMaxAge || age < -WebhookMaxAge {
+		return fmt.Errorf("signature is too old: %w", ErrInvalidSignature)
+	}
+
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(s.Timestamp + s.Token))
+	expected := mac.Sum(nil)
+	signature, err := hex.DecodeString(s.Signature)
+
+	if err != nil || !hmac.Equal(signature, expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// MessageId returns the message id without the angle brackets, the format Send returns it in.
+func (e *EventData) MessageId() string {
+	return NormalizeMessageId(e.Message.Headers.MessageId)
+}
+
+// Time returns the time of the event.
+func (e *EventData) Time() time.Time {
+	sec, frac := math.Modf(e.Timestamp)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+}
+
+// Variable returns the user variable of the message as a string.
+func (e *EventData) Variable(name string) string {
+	v, ok := e.UserVariables[name]
+
+	if !ok || v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// NormalizeMessageId removes the angle brackets mailgun adds to the ids returned by the api.
+func NormalizeMessageId(id string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(id), "<"), ">")
+}
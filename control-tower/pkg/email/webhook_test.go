@@ -0,0 +1,92 @@
+package email_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/japb1998/control-tower/pkg/email"
+)
+
+const signingKey = "key-test"
+
+func sign(key, timestamp, token string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(timestamp + token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookBody(timestamp, signature string) []byte {
+	return []byte(fmt.Sprintf(`{
+		"signature": {"timestamp": %q, "token": "token-1", "signature": %q},
+		"event-data": {
+			"event": "failed",
+			"severity": "permanent",
+			"reason": "bounce",
+			"recipient": "ana@test.com",
+			"timestamp": 1700000000.5,
+			"user-variables": {"notificationId": "n-1", "createdBy": "creator@test.com"},
+			"delivery-status": {"code": 550, "message": "mailbox does not exist"},
+			"message": {"headers": {"message-id": "20231114.1@mg.test.com"}}
+		}
+	}`, timestamp, signature))
+}
+
+func TestParseWebhook(t *testing.T) {
+	now := time.Now()
+	ts := strconv.FormatInt(now.Unix(), 10)
+
+	event, err := email.ParseWebhook(webhookBody(ts, sign(signingKey, ts, "token-1")), signingKey, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Event != email.EventFailed || event.Severity != email.SeverityPermanent || event.DeliveryStatus.Code != 550 {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if event.MessageId() != "20231114.1@mg.test.com" || event.Variable("notificationId") != "n-1" || event.Variable("missing") != "" {
+		t.Errorf("unexpected message data %+v", event)
+	}
+	if !event.Time().Equal(time.Unix(1700000000, 5e8)) {
+		t.Errorf("unexpected time %s", event.Time())
+	}
+}
+
+func TestParseWebhookSignature(t *testing.T) {
+	now := time.Now()
+	ts := strconv.FormatInt(now.Unix(), 10)
+	old := strconv.FormatInt(now.Add(-email.WebhookMaxAge*2).Unix(), 10)
+
+	cases := []struct {
+		name string
+		body []byte
+		key  string
+	}{
+		{name: "wrong key", body: webhookBody(ts, sign("other", ts, "token-1")), key: signingKey},
+		{name: "not hex", body: webhookBody(ts, "zz"), key: signingKey},
+		{name: "too old", body: webhookBody(old, sign(signingKey, old, "token-1")), key: signingKey},
+		{name: "no signing key", body: webhookBody(ts, sign("", ts, "token-1")), key: ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := email.ParseWebhook(tc.body, tc.key, now); !errors.Is(err, email.ErrInvalidSignature) {
+				t.Fatalf("expected ErrInvalidSignature, got: %v", err)
+			}
+		})
+	}
+
+	if _, err := email.ParseWebhook([]byte("{"), signingKey, now); !errors.Is(err, email.ErrInvalidWebhook) {
+		t.Fatalf("expected ErrInvalidWebhook, got: %v", err)
+	}
+}
+
+func TestNormalizeMessageId(t *testing.T) {
+	if id := email.NormalizeMessageId(" <20231114.1@mg.test.com>"); id != "20231114.1@mg.test.com" {
+		t.Errorf("unexpected id %s", id)
+	}
+}
@@ -0,0 +1,26 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/twilio/twilio-go/client"
+)
+
+// IsRetryable reports whether sending the message again can succeed. rate limits, twilio server errors and network errors are retryable,
+// invalid numbers, opted out recipients and invalid bodies are not.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, ErrEmptyBody) || errors.Is(err, ErrTooManySegments) {
+		return false
+	}
+	var restErr *client.TwilioRestError
+
+	if errors.As(err, &restErr) {
+		return restErr.Status == http.StatusTooManyRequests || restErr.Status >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
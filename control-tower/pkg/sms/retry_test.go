@@ -0,0 +1,35 @@
+package sms_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/japb1998/control-tower/pkg/sms"
+	"github.com/twilio/twilio-go/client"
+)
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"rate limited", fmt.Errorf("failed to send SMS message! error: %w", &client.TwilioRestError{Status: 429, Code: 20429}), true},
+		{"server error", &client.TwilioRestError{Status: 503}, true},
+		{"invalid number", fmt.Errorf("failed to send SMS message! error: %w", &client.TwilioRestError{Status: 400, Code: 21211}), false},
+		{"too long", fmt.Errorf("body: %w", sms.ErrTooManySegments), false},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"unknown", errors.New("boom"), false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := sms.IsRetryable(tc.err); got != tc.want {
+				t.Errorf("expected %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package sms
+
+const (
+	EncodingGSM7 = "GSM-7"
+	EncodingUCS2 = "UCS-2"
+
+	gsm7SingleLength = 160
+	gsm7PartLength   = 153 // each part of a long message loses 7 septets to the concatenation header.
+	ucs2SingleLength = 70
+	ucs2PartLength   = 67
+)
+
+// gsm7Basic is the GSM 03.38 basic character set.
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Extended are the characters sent with an escape, they take two septets.
+const gsm7Extended = "\f^{}\\[~]|€"
+
+var gsm7Length = func() map[rune]int {
+	m := make(map[rune]int)
+	for _, r := range gsm7Basic {
+		m[r] = 1
+	}
+	for _, r := range gsm7Extended {
+		m[r] = 2
+	}
+	return m
+}()
+
+// Segments returns the number of segments carriers bill for the body and the encoding it is sent with.
+// bodies with a character outside of GSM-7 are sent as UCS-2, which fits less than half the characters per segment.
+func Segments(body string) (int, string) {
+	if body == "" {
+		return 0, EncodingGSM7
+	}
+	septets := 0
+	for _, r := range body {
+		n, ok := gsm7Length[r]
+		if !ok {
+			return ucs2Segments(body), EncodingUCS2
+		}
+		septets += n
+	}
+	if septets <= gsm7SingleLength {
+		return 1, EncodingGSM7
+	}
+	return (septets + gsm7PartLength - 1) / gsm7PartLength, EncodingGSM7
+}
+
+// ucs2Segments counts UTF-16 code units, characters outside of the BMP such as emojis take two.
+func ucs2Segments(body string) int {
+	units := 0
+	for _, r := range body {
+		if r > 0xFFFF {
+			units += 2
+		} else {
+			units++
+		}
+	}
+	if units <= ucs2SingleLength {
+		return 1
+	}
+	return (units + ucs2PartLength - 1) / ucs2PartLength
+}
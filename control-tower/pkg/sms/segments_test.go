@@ -0,0 +1,46 @@
+package sms_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/japb1998/control-tower/pkg/sms"
+)
+
+func TestSegments(t *testing.T) {
+	cases := []struct {
+		name     string
+		body     string
+		segments int
+		encoding string
+	}{
+		{"empty", "", 0, sms.EncodingGSM7},
+		{"single gsm", strings.Repeat("a", 160), 1, sms.EncodingGSM7},
+		{"multipart gsm", strings.Repeat("a", 161), 2, sms.EncodingGSM7},
+		{"extended characters take two septets", strings.Repeat("€", 80), 1, sms.EncodingGSM7},
+		{"extended characters overflow", strings.Repeat("€", 81), 2, sms.EncodingGSM7},
+		{"single ucs2", strings.Repeat("ñ", 69) + "ó", 1, sms.EncodingUCS2},
+		{"multipart ucs2", strings.Repeat("ó", 71), 2, sms.EncodingUCS2},
+		{"emoji takes two units", strings.Repeat("a", 69) + "💅", 2, sms.EncodingUCS2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			segments, encoding := sms.Segments(tc.body)
+			if segments != tc.segments || encoding != tc.encoding {
+				t.Fatalf("expected %d %s segments, got: %d %s", tc.segments, tc.encoding, segments, encoding)
+			}
+		})
+	}
+}
+
+func TestSendTextValidation(t *testing.T) {
+	svc := &sms.MsgSvc{MessagingServiceId: "MGtest"}
+
+	if _, err := svc.SendText(&sms.TextMsg{To: "+17860000001", Body: " "}); !errors.Is(err, sms.ErrEmptyBody) {
+		t.Fatalf("expected ErrEmptyBody, got: %v", err)
+	}
+	if _, err := svc.SendText(&sms.TextMsg{To: "+17860000001", Body: strings.Repeat("ó", 67*sms.MaxSegments+1)}); !errors.Is(err, sms.ErrTooManySegments) {
+		t.Fatalf("expected ErrTooManySegments, got: %v", err)
+	}
+}
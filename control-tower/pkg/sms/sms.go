@@ -1,16 +1,28 @@
 package sms
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	twilio "github.com/twilio/twilio-go"
 	openapi "github.com/twilio/twilio-go/rest/api/v2010"
 )
 
+// MaxSegments is the longest text message we send. longer bodies are rejected instead of being billed as many messages.
+const MaxSegments = 3
+
+var (
+	ErrTooManySegments = errors.New("text message is too long")
+	ErrEmptyBody       = errors.New("text message body can't be empty")
+)
+
 type MsgSvc struct {
 	Client             *twilio.RestClient
 	MessagingServiceId string `validate:"required"` //twilio service id
+	StatusCallback     string // url twilio posts the status changes of the messages to, no callback when empty.
 }
 
 // Msg. TemplateVariables is the json containing the variables to be replaces in the template
@@ -18,9 +30,12 @@ type Msg struct {
 	TemplateId        string // whatsapp
 	TemplateVariables []byte // json
 	To                string `validate:"e164"`
+	// Metadata is added to the status callback url and sent back in the status callbacks.
+	Metadata map[string]string
 }
 
-func (svc *MsgSvc) SendMessage(msg *Msg) error {
+// SendMessage sends the whatsapp template and returns the twilio message sid.
+func (svc *MsgSvc) SendMessage(msg *Msg) (string, error) {
 	params := &openapi.CreateMessageParams{}
 	params.SetTo(fmt.Sprintf("whatsapp:%s", msg.To))
 	params.SetFrom(svc.MessagingServiceId)
@@ -29,17 +44,114 @@ func (svc *MsgSvc) SendMessage(msg *Msg) error {
 	if msg.TemplateVariables != nil {
 		params.SetContentVariables(string(msg.TemplateVariables))
 	}
+	if callback := svc.statusCallback(msg.Metadata); callback != "" {
+		params.SetStatusCallback(callback)
+	}
 
-	_, err := svc.Client.Api.CreateMessage(params)
+	resp, err := svc.Client.Api.CreateMessage(params)
 	if err != nil {
 		fmt.Println(err.Error())
-		return fmt.Errorf("failed to send Whatsapp message! error: %w", err)
+		return "", fmt.Errorf("failed to send Whatsapp message! error: %w", err)
 	}
-	return nil
+	return sid(resp), nil
 }
 
-// MustInitSvc returns a MsgSvc or panics if error.
-func MusInitMsgSvc(serviceId string) *MsgSvc {
+// TextMsg is a plain text SMS.
+type TextMsg struct {
+	Body string
+	To   string `validate:"e164"`
+	// Metadata is added to the status callback url and sent back in the status callbacks.
+	Metadata map[string]string
+}
+
+// SendText sends the body as a plain SMS and returns the twilio message sid. bodies longer than MaxSegments segments are rejected.
+func (svc *MsgSvc) SendText(msg *TextMsg) (string, error) {
+	if strings.TrimSpace(msg.Body) == "" {
+		return "", ErrEmptyBody
+	}
+	if segments, encoding := Segments(msg.Body); segments > MaxSegments {
+		return "", fmt.Errorf("body takes %d %s segments, max is %d: %w", segments, encoding, MaxSegments, ErrTooManySegments)
+	}
+	params := &openapi.CreateMessageParams{}
+	params.SetTo(msg.To)
+	params.SetBody(msg.Body)
+
+	if callback := svc.statusCallback(msg.Metadata); callback != "" {
+		params.SetStatusCallback(callback)
+	}
+
+	// messaging services pick the sender from their pool, otherwise the id is the sender number.
+	if strings.HasPrefix(svc.MessagingServiceId, "MG") {
+		params.SetMessagingServiceSid(svc.MessagingServiceId)
+	} else {
+		params.SetFrom(svc.MessagingServiceId)
+	}
+
+	resp, err := svc.Client.Api.CreateMessage(params)
+	if err != nil {
+		fmt.Println(err.Error())
+		return "", fmt.Errorf("failed to send SMS message! error: %w", err)
+	}
+	return sid(resp), nil
+}
+
+// WithStatusCallback sets the url twilio posts the status changes of the messages to.
+// urls that are not absolute are ignored, twilio rejects the messages with them.
+func (svc *MsgSvc) WithStatusCallback(callbackUrl string) *MsgSvc {
+	if u, err := url.Parse(callbackUrl); err == nil && u.IsAbs() {
+		svc.StatusCallback = callbackUrl
+	}
+	return svc
+}
+
+// statusCallback returns the callback url with the metadata in the query.
+func (svc *MsgSvc) statusCallback(metadata map[string]string) string {
+	if svc.StatusCallback == "" || len(metadata) == 0 {
+		return svc.StatusCallback
+	}
+	query := make(url.Values, len(metadata))
+	for k, v := range metadata {
+		query.Set(k, v)
+	}
+	sep := "?"
+	if strings.Contains(svc.StatusCallback, "?") {
+		sep = "&"
+	}
+	return svc.StatusCallback + sep + query.Encode()
+}
+
+// SendWhatsappText sends the body as a free form whatsapp message and returns the twilio message sid.
+// whatsapp only delivers them within 24 hours of the last message of the client, otherwise twilio fails the message.
+func (svc *MsgSvc) SendWhatsappText(msg *TextMsg) (string, error) {
+	if strings.TrimSpace(msg.Body) == "" {
+		return "", ErrEmptyBody
+	}
+	params := &openapi.CreateMessageParams{}
+	params.SetTo(whatsappPrefix + msg.To)
+	params.SetFrom(svc.MessagingServiceId)
+	params.SetBody(msg.Body)
+
+	if callback := svc.statusCallback(msg.Metadata); callback != "" {
+		params.SetStatusCallback(callback)
+	}
+
+	resp, err := svc.Client.Api.CreateMessage(params)
+	if err != nil {
+		fmt.Println(err.Error())
+		return "", fmt.Errorf("failed to send Whatsapp message! error: %w", err)
+	}
+	return sid(resp), nil
+}
+
+func sid(resp *openapi.ApiV2010Message) string {
+	if resp == nil || resp.Sid == nil {
+		return ""
+	}
+	return *resp.Sid
+}
+
+// NewMsgSvc returns a MsgSvc for the twilio messaging service, it fails when the service id is missing.
+func NewMsgSvc(serviceId string) (*MsgSvc, error) {
 	svc := &MsgSvc{
 		Client:             twilio.NewRestClient(),
 		MessagingServiceId: serviceId,
@@ -53,8 +165,18 @@ func MusInitMsgSvc(serviceId string) *MsgSvc {
 		for _, ve := range err.(validator.ValidationErrors) {
 			fmt.Printf("%s validation: %s failed. value='%s', param='%s'\n", ve.Namespace(), ve.Tag(), ve.Value(), ve.Param())
 		}
-		panic("failed to setup messaging service")
+		return nil, fmt.Errorf("failed to setup messaging service error: %w", err)
 	}
 
+	return svc, nil
+}
+
+// MustInitSvc returns a MsgSvc or panics if error.
+func MusInitMsgSvc(serviceId string) *MsgSvc {
+	svc, err := NewMsgSvc(serviceId)
+
+	if err != nil {
+		panic(err.Error())
+	}
 	return svc
 }
@@ -32,7 +32,7 @@ func TestMessage(t *testing.T) {
 		TemplateVariables: templateVariables,
 	}
 
-	err = msgSvc.SendMessage(&msg)
+	_, err = msgSvc.SendMessage(&msg)
 
 	if err != nil {
 		t.Fatal(err)
@@ -0,0 +1,111 @@
+package sms
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/twilio/twilio-go/client"
+)
+
+// twilio message statuses
+const (
+	StatusDelivered   = "delivered"
+	StatusRead        = "read" // whatsapp only.
+	StatusFailed      = "failed"
+	StatusUndelivered = "undelivered"
+)
+
+const whatsappPrefix = "whatsapp:"
+
+var (
+	ErrInvalidSignature = errors.New("invalid twilio signature")
+)
+
+// ValidateRequest checks the X-Twilio-Signature header of a webhook. url is the full url twilio called including the query,
+// form are the posted parameters.
+func ValidateRequest(authToken, url string, form url.Values, signature string) error {
+	if authToken == "" || signature == "" {
+		return ErrInvalidSignature
+	}
+	params := make(map[string]string, len(form))
+	for k := range form {
+		params[k] = form.Get(k)
+	}
+	validator := client.NewRequestValidator(authToken)
+
+	if !validator.Validate(url, params, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// rawDlrDoneDateLayout is the format of the RawDlrDoneDate parameter, the carrier time of the delivery receipt in UTC.
+const rawDlrDoneDateLayout = "0601021504"
+
+// StatusCallback is the status change of a sent message. Metadata are the values set on the sent message.
+type StatusCallback struct {
+	MessageSid    string
+	MessageStatus string
+	ErrorCode     string
+	ErrorMessage  string
+	To            string
+	Metadata      map[string]string
+	At            time.Time // time of the delivery receipt, zero when the carrier does not report it.
+}
+
+// ParseStatusCallback reads the callback from the query of the callback url and the posted form.
+func ParseStatusCallback(query, form url.Values) StatusCallback {
+	metadata := make(map[string]string, len(query))
+	for k := range query {
+		metadata[k] = query.Get(k)
+	}
+	s := StatusCallback{
+		MessageSid:    form.Get("MessageSid"),
+		MessageStatus: form.Get("MessageStatus"),
+		ErrorCode:     form.Get("ErrorCode"),
+		ErrorMessage:  form.Get("ErrorMessage"),
+		To:            form.Get("To"),
+		Metadata:      metadata,
+	}
+	if at, err := time.Parse(rawDlrDoneDateLayout, form.Get("RawDlrDoneDate")); err == nil {
+		s.At = at
+	}
+	return s
+}
+
+// IsWhatsapp reports whether the message was sent through whatsapp instead of SMS.
+func (s StatusCallback) IsWhatsapp() bool {
+	return strings.HasPrefix(s.To, whatsappPrefix)
+}
+
+// InboundMessage is a message a client sent to our number.
+type InboundMessage struct {
+	MessageSid string
+	From       string
+	To         string
+	Body       string
+	// RepliedMessageSid is the message the client replied to, only whatsapp replies have it.
+	RepliedMessageSid string
+}
+
+func ParseInboundMessage(form url.Values) InboundMessage {
+	return InboundMessage{
+		MessageSid:        form.Get("MessageSid"),
+		From:              form.Get("From"),
+		To:                form.Get("To"),
+		Body:              form.Get("Body"),
+		RepliedMessageSid: form.Get("OriginalRepliedMessageSid"),
+	}
+}
+
+// IsWhatsapp reports whether the message was received through whatsapp instead of SMS.
+func (m InboundMessage) IsWhatsapp() bool {
+	return strings.HasPrefix(m.From, whatsappPrefix)
+}
+
+// Phone returns the number of the sender in E.164 format.
+func (m InboundMessage) Phone() string {
+	return strings.TrimPrefix(m.From, whatsappPrefix)
+}
@@ -0,0 +1,82 @@
+package sms_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/japb1998/control-tower/pkg/sms"
+)
+
+// sign returns the signature twilio sends for the request.
+func sign(authToken, callbackUrl string, form url.Values) string {
+	keys := make([]string, 0, len(form))
+	for k := range form {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	data := callbackUrl
+	for _, k := range keys {
+		data += k + form.Get(k)
+	}
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(data))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidateRequest(t *testing.T) {
+	callbackUrl := "https://api.test/dev/webhooks/twilio/status?createdBy=creator%40test.com&notificationId=n1"
+	form := url.Values{
+		"MessageSid":    {"SM1"},
+		"MessageStatus": {"delivered"},
+		"To":            {"whatsapp:+17860000001"},
+	}
+	signature := sign("token", callbackUrl, form)
+
+	if err := sms.ValidateRequest("token", callbackUrl, form, signature); err != nil {
+		t.Fatalf("expected a valid signature, got: %s", err)
+	}
+	if err := sms.ValidateRequest("other", callbackUrl, form, signature); !errors.Is(err, sms.ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for another token, got: %v", err)
+	}
+	form.Set("MessageStatus", "read")
+	if err := sms.ValidateRequest("token", callbackUrl, form, signature); !errors.Is(err, sms.ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for a changed form, got: %v", err)
+	}
+	if err := sms.ValidateRequest("", callbackUrl, form, ""); !errors.Is(err, sms.ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature without token, got: %v", err)
+	}
+}
+
+func TestParseWebhooks(t *testing.T) {
+	status := sms.ParseStatusCallback(url.Values{"notificationId": {"n1"}}, url.Values{
+		"MessageSid":    {"SM1"},
+		"MessageStatus": {"undelivered"},
+		"ErrorCode":     {"63016"},
+		"To":            {"whatsapp:+17860000001"},
+	})
+	if !status.IsWhatsapp() || status.Metadata["notificationId"] != "n1" || status.ErrorCode != "63016" || !status.At.IsZero() {
+		t.Errorf("unexpected status callback: %+v", status)
+	}
+	delivered := sms.ParseStatusCallback(url.Values{}, url.Values{"MessageStatus": {"delivered"}, "RawDlrDoneDate": {"2403041502"}})
+	if !delivered.At.Equal(time.Date(2024, time.March, 4, 15, 2, 0, 0, time.UTC)) {
+		t.Errorf("expected the delivery receipt time, got: %s", delivered.At)
+	}
+
+	msg := sms.ParseInboundMessage(url.Values{
+		"MessageSid": {"SM2"},
+		"From":       {"whatsapp:+17860000001"},
+		"Body":       {"STOP"},
+	})
+	if !msg.IsWhatsapp() || msg.Phone() != "+17860000001" || msg.Body != "STOP" {
+		t.Errorf("unexpected inbound message: %+v", msg)
+	}
+	if sms.ParseInboundMessage(url.Values{"From": {"+17860000001"}}).IsWhatsapp() {
+		t.Error("expected an SMS message")
+	}
+}